@@ -0,0 +1,505 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package arrowpb converts between protocol buffers and Apache Arrow records.
+
+Every field of a message is a column named by its original name. Nested
+messages are structs, repeated fields lists and map fields maps. Fields
+with presence, like optional fields of proto2, nested messages and members
+of oneofs, are nullable. Enums are stored by name.
+
+The package is a module of its own, so that only users of Arrow depend on
+it.
+*/
+package arrowpb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxDepth limits the nesting of messages, which would be infinite for
+// recursive messages.
+const maxDepth = 32
+
+// Schema returns the Arrow schema of records holding messages of md.
+func Schema(md protoreflect.MessageDescriptor) (*arrow.Schema, error) {
+	fields, err := structFields(md, 0)
+	if err != nil {
+		return nil, err
+	}
+	return arrow.NewSchema(fields, nil), nil
+}
+
+func structFields(md protoreflect.MessageDescriptor, depth int) ([]arrow.Field, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("arrowpb: message %s nested too deeply", md.FullName())
+	}
+	fds := md.Fields()
+	fields := make([]arrow.Field, fds.Len())
+	for i := range fields {
+		fd := fds.Get(i)
+		t, err := fieldType(fd, depth)
+		if err != nil {
+			return nil, err
+		}
+		fields[i] = arrow.Field{
+			Name:     string(fd.Name()),
+			Type:     t,
+			Nullable: fd.HasPresence(),
+		}
+	}
+	return fields, nil
+}
+
+func fieldType(fd protoreflect.FieldDescriptor, depth int) (arrow.DataType, error) {
+	switch {
+	case fd.IsMap():
+		key, err := valueType(fd.MapKey(), depth)
+		if err != nil {
+			return nil, err
+		}
+		item, err := valueType(fd.MapValue(), depth)
+		if err != nil {
+			return nil, err
+		}
+		return arrow.MapOf(key, item), nil
+	case fd.IsList():
+		elem, err := valueType(fd, depth)
+		if err != nil {
+			return nil, err
+		}
+		return arrow.ListOfNonNullable(elem), nil
+	}
+	return valueType(fd, depth)
+}
+
+// valueType returns the type of a single value of fd.
+func valueType(fd protoreflect.FieldDescriptor, depth int) (arrow.DataType, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return arrow.FixedWidthTypes.Boolean, nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return arrow.PrimitiveTypes.Int32, nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return arrow.PrimitiveTypes.Int64, nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return arrow.PrimitiveTypes.Uint32, nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return arrow.PrimitiveTypes.Uint64, nil
+	case protoreflect.FloatKind:
+		return arrow.PrimitiveTypes.Float32, nil
+	case protoreflect.DoubleKind:
+		return arrow.PrimitiveTypes.Float64, nil
+	case protoreflect.StringKind, protoreflect.EnumKind:
+		return arrow.BinaryTypes.String, nil
+	case protoreflect.BytesKind:
+		return arrow.BinaryTypes.Binary, nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		fields, err := structFields(fd.Message(), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		return arrow.StructOf(fields...), nil
+	}
+	return nil, fmt.Errorf("arrowpb: field %s of kind %v not supported", fd.FullName(), fd.Kind())
+}
+
+// NewRecord converts msgs, which have to be messages of md, into a record.
+// The record has to be released once done.
+func NewRecord(mem memory.Allocator, md protoreflect.MessageDescriptor, msgs []proto.Message) (arrow.Record, error) {
+	schema, err := Schema(md)
+	if err != nil {
+		return nil, err
+	}
+	b := array.NewRecordBuilder(mem, schema)
+	defer b.Release()
+
+	fds := md.Fields()
+	for _, msg := range msgs {
+		m := proto.MessageReflect(msg)
+		if m.Descriptor().FullName() != md.FullName() {
+			return nil, fmt.Errorf("arrowpb: message %s is not %s", m.Descriptor().FullName(), md.FullName())
+		}
+		for i := 0; i < fds.Len(); i++ {
+			appendField(b.Field(i), fds.Get(i), m)
+		}
+	}
+	return b.NewRecord(), nil
+}
+
+// appendField appends field fd of m to b.
+func appendField(b array.Builder, fd protoreflect.FieldDescriptor, m protoreflect.Message) {
+	switch {
+	case fd.IsList():
+		lb := b.(*array.ListBuilder)
+		lb.Append(true)
+		list := m.Get(fd).List()
+		for i := 0; i < list.Len(); i++ {
+			appendValue(lb.ValueBuilder(), fd, list.Get(i))
+		}
+	case fd.IsMap():
+		mb := b.(*array.MapBuilder)
+		mb.Append(true)
+		mp := m.Get(fd).Map()
+		keys := make([]protoreflect.MapKey, 0, mp.Len())
+		mp.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+			keys = append(keys, k)
+			return true
+		})
+		sortKeys(keys)
+		for _, k := range keys {
+			appendValue(mb.KeyBuilder(), fd.MapKey(), k.Value())
+			appendValue(mb.ItemBuilder(), fd.MapValue(), mp.Get(k))
+		}
+	case fd.HasPresence() && !m.Has(fd):
+		b.AppendNull()
+	default:
+		appendValue(b, fd, m.Get(fd))
+	}
+}
+
+// sortKeys sorts map keys, so that records do not depend on the order of
+// map iteration.
+func sortKeys(keys []protoreflect.MapKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		switch x := keys[i].Interface().(type) {
+		case bool:
+			return !x && keys[j].Bool()
+		case int32, int64:
+			return keys[i].Int() < keys[j].Int()
+		case uint32, uint64:
+			return keys[i].Uint() < keys[j].Uint()
+		}
+		return keys[i].String() < keys[j].String()
+	})
+}
+
+// appendValue appends a single value of fd to b.
+func appendValue(b array.Builder, fd protoreflect.FieldDescriptor, v protoreflect.Value) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		b.(*array.BooleanBuilder).Append(v.Bool())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		b.(*array.Int32Builder).Append(int32(v.Int()))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		b.(*array.Int64Builder).Append(v.Int())
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		b.(*array.Uint32Builder).Append(uint32(v.Uint()))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		b.(*array.Uint64Builder).Append(v.Uint())
+	case protoreflect.FloatKind:
+		b.(*array.Float32Builder).Append(float32(v.Float()))
+	case protoreflect.DoubleKind:
+		b.(*array.Float64Builder).Append(v.Float())
+	case protoreflect.StringKind:
+		b.(*array.StringBuilder).Append(v.String())
+	case protoreflect.EnumKind:
+		name := fmt.Sprint(int32(v.Enum()))
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			name = string(ev.Name())
+		}
+		b.(*array.StringBuilder).Append(name)
+	case protoreflect.BytesKind:
+		b.(*array.BinaryBuilder).Append(v.Bytes())
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		sb := b.(*array.StructBuilder)
+		sb.Append(true)
+		m := v.Message()
+		fds := fd.Message().Fields()
+		for i := 0; i < fds.Len(); i++ {
+			appendField(sb.FieldBuilder(i), fds.Get(i), m)
+		}
+	}
+}
+
+// Unmarshaler is a configurable object for converting records into
+// messages. Columns are matched with fields by either their original or
+// their lowerCamelCase name.
+type Unmarshaler struct {
+	// Whether to ignore columns without a matching field, as opposed to
+	// failing to unmarshal.
+	AllowUnknownFields bool
+}
+
+// Unmarshal converts every row of rec into a message created by factory.
+func (u *Unmarshaler) Unmarshal(rec arrow.Record, factory func() proto.Message) ([]proto.Message, error) {
+	msgs := make([]proto.Message, rec.NumRows())
+	for i := range msgs {
+		msgs[i] = factory()
+	}
+	if len(msgs) == 0 {
+		return msgs, nil
+	}
+	fds := proto.MessageReflect(msgs[0]).Descriptor().Fields()
+	for j, col := range rec.Columns() {
+		name := rec.ColumnName(j)
+		fd := fieldByName(fds, name)
+		if fd == nil {
+			if u.AllowUnknownFields {
+				continue
+			}
+			return nil, fmt.Errorf("arrowpb: unknown field %q", name)
+		}
+		for i, msg := range msgs {
+			if err := u.setField(proto.MessageReflect(msg), fd, col, i); err != nil {
+				return nil, fmt.Errorf("arrowpb: row %d: column %s: %v", i, name, err)
+			}
+		}
+	}
+	return msgs, nil
+}
+
+// Unmarshal converts every row of rec into a message created by factory,
+// using default options.
+func Unmarshal(rec arrow.Record, factory func() proto.Message) ([]proto.Message, error) {
+	return new(Unmarshaler).Unmarshal(rec, factory)
+}
+
+func fieldByName(fds protoreflect.FieldDescriptors, name string) protoreflect.FieldDescriptor {
+	if fd := fds.ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return fds.ByJSONName(name)
+}
+
+// setField sets field fd of m from row i of arr.
+func (u *Unmarshaler) setField(m protoreflect.Message, fd protoreflect.FieldDescriptor, arr arrow.Array, i int) error {
+	if arr.IsNull(i) {
+		return nil
+	}
+	switch {
+	case fd.IsList():
+		la, ok := arr.(*array.List)
+		if !ok {
+			return fmt.Errorf("cannot convert %v to list", arr.DataType())
+		}
+		start, end := la.ValueOffsets(i)
+		values := la.ListValues()
+		list := m.Mutable(fd).List()
+		for k := int(start); k < int(end); k++ {
+			if values.IsNull(k) {
+				return errors.New("null element in list")
+			}
+			v, err := u.value(fd, values, k, list.NewElement)
+			if err != nil {
+				return err
+			}
+			list.Append(v)
+		}
+	case fd.IsMap():
+		ma, ok := arr.(*array.Map)
+		if !ok {
+			return fmt.Errorf("cannot convert %v to map", arr.DataType())
+		}
+		start, end := ma.ValueOffsets(i)
+		mp := m.Mutable(fd).Map()
+		for k := int(start); k < int(end); k++ {
+			key, err := u.value(fd.MapKey(), ma.Keys(), k, nil)
+			if err != nil {
+				return err
+			}
+			if ma.Items().IsNull(k) {
+				return errors.New("null value in map")
+			}
+			v, err := u.value(fd.MapValue(), ma.Items(), k, mp.NewValue)
+			if err != nil {
+				return err
+			}
+			mp.Set(key.MapKey(), v)
+		}
+	case fd.Message() != nil:
+		v, err := u.value(fd, arr, i, func() protoreflect.Value {
+			return m.Mutable(fd)
+		})
+		if err != nil {
+			return err
+		}
+		m.Set(fd, v)
+	default:
+		v, err := u.value(fd, arr, i, nil)
+		if err != nil {
+			return err
+		}
+		m.Set(fd, v)
+	}
+	return nil
+}
+
+// value converts row i of arr into a single value of fd. newMessage
+// creates the value of message fields.
+func (u *Unmarshaler) value(fd protoreflect.FieldDescriptor, arr arrow.Array, i int, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		if a, ok := arr.(*array.Boolean); ok {
+			return protoreflect.ValueOfBool(a.Value(i)), nil
+		}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		if v, ok := signed(arr, i); ok {
+			if int64(int32(v)) != v {
+				return protoreflect.Value{}, fmt.Errorf("value %d overflows int32", v)
+			}
+			return protoreflect.ValueOfInt32(int32(v)), nil
+		}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		if v, ok := signed(arr, i); ok {
+			return protoreflect.ValueOfInt64(v), nil
+		}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		if v, ok := unsigned(arr, i); ok {
+			if uint64(uint32(v)) != v {
+				return protoreflect.Value{}, fmt.Errorf("value %d overflows uint32", v)
+			}
+			return protoreflect.ValueOfUint32(uint32(v)), nil
+		}
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		if v, ok := unsigned(arr, i); ok {
+			return protoreflect.ValueOfUint64(v), nil
+		}
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		var f float64
+		switch a := arr.(type) {
+		case *array.Float32:
+			f = float64(a.Value(i))
+		case *array.Float64:
+			f = a.Value(i)
+		default:
+			return protoreflect.Value{}, fmt.Errorf("cannot convert %v to %v", arr.DataType(), fd.Kind())
+		}
+		if fd.Kind() == protoreflect.FloatKind {
+			return protoreflect.ValueOfFloat32(float32(f)), nil
+		}
+		return protoreflect.ValueOfFloat64(f), nil
+	case protoreflect.StringKind:
+		if s, ok := text(arr, i); ok {
+			return protoreflect.ValueOfString(s), nil
+		}
+	case protoreflect.BytesKind:
+		if s, ok := text(arr, i); ok {
+			return protoreflect.ValueOfBytes([]byte(s)), nil
+		}
+	case protoreflect.EnumKind:
+		if s, ok := text(arr, i); ok {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(s))
+			if ev == nil {
+				return protoreflect.Value{}, fmt.Errorf("unknown value %q for enum %s", s, fd.Enum().FullName())
+			}
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		if v, ok := signed(arr, i); ok && int64(int32(v)) == v {
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), nil
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		sa, ok := arr.(*array.Struct)
+		if !ok {
+			break
+		}
+		v := newMessage()
+		m := v.Message()
+		fds := fd.Message().Fields()
+		st := sa.DataType().(*arrow.StructType)
+		for j := 0; j < sa.NumField(); j++ {
+			name := st.Field(j).Name
+			child := fieldByName(fds, name)
+			if child == nil {
+				if u.AllowUnknownFields {
+					continue
+				}
+				return protoreflect.Value{}, fmt.Errorf("unknown field %q in %s", name, fd.Message().FullName())
+			}
+			if err := u.setField(m, child, sa.Field(j), i); err != nil {
+				return protoreflect.Value{}, err
+			}
+		}
+		return v, nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("cannot convert %v to %v", arr.DataType(), fd.Kind())
+}
+
+// signed returns row i of an integer array.
+func signed(arr arrow.Array, i int) (int64, bool) {
+	switch a := arr.(type) {
+	case *array.Int8:
+		return int64(a.Value(i)), true
+	case *array.Int16:
+		return int64(a.Value(i)), true
+	case *array.Int32:
+		return int64(a.Value(i)), true
+	case *array.Int64:
+		return a.Value(i), true
+	case *array.Uint8:
+		return int64(a.Value(i)), true
+	case *array.Uint16:
+		return int64(a.Value(i)), true
+	case *array.Uint32:
+		return int64(a.Value(i)), true
+	case *array.Uint64:
+		if v := a.Value(i); v <= 1<<63-1 {
+			return int64(v), true
+		}
+	}
+	return 0, false
+}
+
+// unsigned returns row i of an integer array, should it not be negative.
+func unsigned(arr arrow.Array, i int) (uint64, bool) {
+	if a, ok := arr.(*array.Uint64); ok {
+		return a.Value(i), true
+	}
+	v, ok := signed(arr, i)
+	if !ok || v < 0 {
+		return 0, false
+	}
+	return uint64(v), true
+}
+
+// text returns row i of a string or binary array.
+func text(arr arrow.Array, i int) (string, bool) {
+	switch a := arr.(type) {
+	case *array.String:
+		return a.Value(i), true
+	case *array.LargeString:
+		return a.Value(i), true
+	case *array.Binary:
+		return string(a.Value(i)), true
+	case *array.LargeBinary:
+		return string(a.Value(i)), true
+	}
+	return "", false
+}
@@ -0,0 +1,305 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package arrowpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/apache/arrow/go/v15/arrow"
+	"github.com/apache/arrow/go/v15/arrow/array"
+	"github.com/apache/arrow/go/v15/arrow/memory"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// everything is a message with fields of every supported kind.
+var everything = func() protoreflect.MessageDescriptor {
+	field := func(name string, number int32, t descriptorpb.FieldDescriptorProto_Type, label descriptorpb.FieldDescriptorProto_Label, typeName string) *descriptorpb.FieldDescriptorProto {
+		fd := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(jsonName(name)),
+			Number:   proto.Int32(number),
+			Type:     t.Enum(),
+			Label:    label.Enum(),
+		}
+		if typeName != "" {
+			fd.TypeName = proto.String(typeName)
+		}
+		return fd
+	}
+	opt := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	rep := descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	file := &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("arrowpb_test.proto"),
+		Package: proto.String("arrowpb.test"),
+		Syntax:  proto.String("proto2"),
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Color"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("RED"), Number: proto.Int32(0)},
+				{Name: proto.String("GREEN"), Number: proto.Int32(1)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Inner"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("label", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt, ""),
+				field("values", 2, descriptorpb.FieldDescriptorProto_TYPE_SINT32, rep, ""),
+			},
+		}, {
+			Name: proto.String("Everything"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("o_bool", 1, descriptorpb.FieldDescriptorProto_TYPE_BOOL, opt, ""),
+				field("o_int32", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, opt, ""),
+				field("o_int64", 3, descriptorpb.FieldDescriptorProto_TYPE_SFIXED64, opt, ""),
+				field("o_uint32", 4, descriptorpb.FieldDescriptorProto_TYPE_FIXED32, opt, ""),
+				field("o_uint64", 5, descriptorpb.FieldDescriptorProto_TYPE_UINT64, opt, ""),
+				field("o_float", 6, descriptorpb.FieldDescriptorProto_TYPE_FLOAT, opt, ""),
+				field("o_double", 7, descriptorpb.FieldDescriptorProto_TYPE_DOUBLE, opt, ""),
+				field("o_string", 8, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt, ""),
+				field("o_bytes", 9, descriptorpb.FieldDescriptorProto_TYPE_BYTES, opt, ""),
+				field("o_color", 10, descriptorpb.FieldDescriptorProto_TYPE_ENUM, opt, ".arrowpb.test.Color"),
+				field("o_inner", 11, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, opt, ".arrowpb.test.Inner"),
+				field("r_string", 12, descriptorpb.FieldDescriptorProto_TYPE_STRING, rep, ""),
+				field("r_inner", 13, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, rep, ".arrowpb.test.Inner"),
+				field("m_counts", 14, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, rep, ".arrowpb.test.Everything.MCountsEntry"),
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{
+				Name: proto.String("MCountsEntry"),
+				Field: []*descriptorpb.FieldDescriptorProto{
+					field("key", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, opt, ""),
+					field("value", 2, descriptorpb.FieldDescriptorProto_TYPE_INT64, opt, ""),
+				},
+				Options: &descriptorpb.MessageOptions{MapEntry: proto.Bool(true)},
+			}},
+		}},
+	}
+	fd, err := protodesc.NewFile(file, nil)
+	if err != nil {
+		panic(err)
+	}
+	return fd.Messages().ByName("Everything")
+}()
+
+func jsonName(name string) string {
+	parts := strings.Split(name, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = strings.Title(parts[i])
+	}
+	return strings.Join(parts, "")
+}
+
+func newEverything() proto.Message {
+	return proto.MessageV1(dynamicpb.NewMessage(everything))
+}
+
+// set returns a message of everything with the text format s.
+func set(t *testing.T, s string) proto.Message {
+	t.Helper()
+	msg := newEverything()
+	if err := proto.UnmarshalText(s, msg); err != nil {
+		t.Fatalf("UnmarshalText(%q): %v", s, err)
+	}
+	return msg
+}
+
+func TestSchema(t *testing.T) {
+	schema, err := Schema(everything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := arrow.StructOf(
+		arrow.Field{Name: "label", Type: arrow.BinaryTypes.String, Nullable: true},
+		arrow.Field{Name: "values", Type: arrow.ListOfNonNullable(arrow.PrimitiveTypes.Int32)},
+	)
+	want := arrow.NewSchema([]arrow.Field{
+		{Name: "o_bool", Type: arrow.FixedWidthTypes.Boolean, Nullable: true},
+		{Name: "o_int32", Type: arrow.PrimitiveTypes.Int32, Nullable: true},
+		{Name: "o_int64", Type: arrow.PrimitiveTypes.Int64, Nullable: true},
+		{Name: "o_uint32", Type: arrow.PrimitiveTypes.Uint32, Nullable: true},
+		{Name: "o_uint64", Type: arrow.PrimitiveTypes.Uint64, Nullable: true},
+		{Name: "o_float", Type: arrow.PrimitiveTypes.Float32, Nullable: true},
+		{Name: "o_double", Type: arrow.PrimitiveTypes.Float64, Nullable: true},
+		{Name: "o_string", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "o_bytes", Type: arrow.BinaryTypes.Binary, Nullable: true},
+		{Name: "o_color", Type: arrow.BinaryTypes.String, Nullable: true},
+		{Name: "o_inner", Type: inner, Nullable: true},
+		{Name: "r_string", Type: arrow.ListOfNonNullable(arrow.BinaryTypes.String)},
+		{Name: "r_inner", Type: arrow.ListOfNonNullable(inner)},
+		{Name: "m_counts", Type: arrow.MapOf(arrow.BinaryTypes.String, arrow.PrimitiveTypes.Int64)},
+	}, nil)
+	if !schema.Equal(want) {
+		t.Errorf("Schema() =\n%v\nwant\n%v", schema, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	msgs := []proto.Message{
+		set(t, `o_bool: true o_int32: -3 o_int64: -4 o_uint32: 5 o_uint64: 18446744073709551615
+			o_float: 1.5 o_double: 2.25 o_string: "héllo" o_bytes: "\x00\xff" o_color: GREEN
+			o_inner: <label: "in" values: -1 values: 2>
+			r_string: "a" r_string: "b"
+			r_inner: <label: "x"> r_inner: <values: 7>
+			m_counts: <key: "b" value: 2> m_counts: <key: "a" value: 1>`),
+		set(t, ``),
+		set(t, `o_string: "" o_inner: <> r_inner: <>`),
+	}
+	rec, err := NewRecord(memory.DefaultAllocator, everything, msgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Release()
+	if rec.NumRows() != int64(len(msgs)) {
+		t.Fatalf("NumRows() = %d, want %d", rec.NumRows(), len(msgs))
+	}
+
+	got, err := Unmarshal(rec, newEverything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range msgs {
+		if !proto.Equal(got[i], msgs[i]) {
+			t.Errorf("row %d = %v, want %v", i, got[i], msgs[i])
+		}
+	}
+}
+
+func TestNewRecordWrongMessage(t *testing.T) {
+	other := dynamicpb.NewMessage(everything.Fields().ByName("o_inner").Message())
+	_, err := NewRecord(memory.DefaultAllocator, everything, []proto.Message{proto.MessageV1(other)})
+	if err == nil {
+		t.Error("NewRecord() succeeded, want error")
+	}
+}
+
+// record builds a record with a single row from columns.
+func record(t *testing.T, fields []arrow.Field, build func(b *array.RecordBuilder)) arrow.Record {
+	t.Helper()
+	b := array.NewRecordBuilder(memory.DefaultAllocator, arrow.NewSchema(fields, nil))
+	defer b.Release()
+	build(b)
+	return b.NewRecord()
+}
+
+func TestUnmarshalConversions(t *testing.T) {
+	rec := record(t, []arrow.Field{
+		{Name: "oInt64", Type: arrow.PrimitiveTypes.Int8},
+		{Name: "o_uint32", Type: arrow.PrimitiveTypes.Int64},
+		{Name: "o_double", Type: arrow.PrimitiveTypes.Float32},
+		{Name: "o_bytes", Type: arrow.BinaryTypes.String},
+		{Name: "o_color", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "o_string", Type: arrow.BinaryTypes.String, Nullable: true},
+	}, func(b *array.RecordBuilder) {
+		b.Field(0).(*array.Int8Builder).Append(-8)
+		b.Field(1).(*array.Int64Builder).Append(32)
+		b.Field(2).(*array.Float32Builder).Append(0.5)
+		b.Field(3).(*array.StringBuilder).Append("raw")
+		b.Field(4).(*array.Int32Builder).Append(1)
+		b.Field(5).AppendNull()
+	})
+	defer rec.Release()
+
+	got, err := Unmarshal(rec, newEverything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := set(t, `o_int64: -8 o_uint32: 32 o_double: 0.5 o_bytes: "raw" o_color: GREEN`)
+	if !proto.Equal(got[0], want) {
+		t.Errorf("Unmarshal() = %v, want %v", got[0], want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		desc   string
+		fields []arrow.Field
+		build  func(b *array.RecordBuilder)
+		want   string
+	}{{
+		desc:   "unknown field",
+		fields: []arrow.Field{{Name: "nope", Type: arrow.PrimitiveTypes.Int32}},
+		build:  func(b *array.RecordBuilder) { b.Field(0).(*array.Int32Builder).Append(1) },
+		want:   `unknown field "nope"`,
+	}, {
+		desc:   "overflow",
+		fields: []arrow.Field{{Name: "o_int32", Type: arrow.PrimitiveTypes.Int64}},
+		build:  func(b *array.RecordBuilder) { b.Field(0).(*array.Int64Builder).Append(1 << 40) },
+		want:   "overflows int32",
+	}, {
+		desc:   "negative unsigned",
+		fields: []arrow.Field{{Name: "o_uint64", Type: arrow.PrimitiveTypes.Int64}},
+		build:  func(b *array.RecordBuilder) { b.Field(0).(*array.Int64Builder).Append(-1) },
+		want:   "cannot convert int64 to uint64",
+	}, {
+		desc:   "unknown enum",
+		fields: []arrow.Field{{Name: "o_color", Type: arrow.BinaryTypes.String}},
+		build:  func(b *array.RecordBuilder) { b.Field(0).(*array.StringBuilder).Append("BLUE") },
+		want:   `unknown value "BLUE"`,
+	}, {
+		desc:   "not a list",
+		fields: []arrow.Field{{Name: "r_string", Type: arrow.BinaryTypes.String}},
+		build:  func(b *array.RecordBuilder) { b.Field(0).(*array.StringBuilder).Append("a") },
+		want:   "cannot convert utf8 to list",
+	}}
+	for _, tt := range tests {
+		rec := record(t, tt.fields, tt.build)
+		_, err := Unmarshal(rec, newEverything)
+		rec.Release()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Unmarshal() error = %v, want %q", tt.desc, err, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalAllowUnknownFields(t *testing.T) {
+	rec := record(t, []arrow.Field{
+		{Name: "nope", Type: arrow.PrimitiveTypes.Int32},
+		{Name: "o_int32", Type: arrow.PrimitiveTypes.Int32},
+	}, func(b *array.RecordBuilder) {
+		b.Field(0).(*array.Int32Builder).Append(1)
+		b.Field(1).(*array.Int32Builder).Append(2)
+	})
+	defer rec.Release()
+
+	u := Unmarshaler{AllowUnknownFields: true}
+	got, err := u.Unmarshal(rec, newEverything)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := set(t, `o_int32: 2`); !proto.Equal(got[0], want) {
+		t.Errorf("Unmarshal() = %v, want %v", got[0], want)
+	}
+}
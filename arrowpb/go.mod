@@ -0,0 +1,19 @@
+module github.com/abergmeier/golang-protobuf/arrowpb
+
+go 1.20
+
+require (
+	github.com/apache/arrow/go/v15 v15.0.2
+	github.com/golang/protobuf v1.5.3
+	google.golang.org/protobuf v1.31.0
+)
+
+require (
+	github.com/goccy/go-json v0.10.2 // indirect
+	github.com/google/flatbuffers v23.5.26+incompatible // indirect
+	github.com/klauspost/cpuid/v2 v2.2.5 // indirect
+	github.com/zeebo/xxh3 v1.0.2 // indirect
+	golang.org/x/exp v0.0.0-20231006140011-7918f672742d // indirect
+	golang.org/x/sys v0.13.0 // indirect
+	golang.org/x/xerrors v0.0.0-20220907171357-04be3eba64a2 // indirect
+)
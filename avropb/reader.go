@@ -0,0 +1,683 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package avropb
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// DefaultMaxBlockSize is the MaxBlockSize of a Reader if 0.
+const DefaultMaxBlockSize = 64 << 20
+
+const (
+	// maxHeaderSize limits the metadata of a file, which holds its schema.
+	maxHeaderSize = 16 << 20
+	// maxEmptyItems limits the items of an array, or the records of a
+	// block, which are encoded as no bytes at all, like null.
+	maxEmptyItems = 1 << 16
+	// maxDepth limits the nesting of values, which would be infinite for
+	// some recursive schemas.
+	maxDepth = 64
+)
+
+// Reader reads protocol buffers from an object container file.
+type Reader struct {
+	// Whether to ignore fields of the file without a matching field of the
+	// message, as opposed to failing to read.
+	AllowUnknownFields bool
+
+	// MaxBlockSize limits the size of the blocks of the file, both as
+	// stored and decompressed. DefaultMaxBlockSize if 0.
+	MaxBlockSize int
+
+	r      *bufio.Reader
+	schema *schema
+	json   string
+	codec  string
+	sync   [16]byte
+	block  *bytes.Reader
+	count  int64
+}
+
+// record is a decoded record, with a value per field.
+type record struct {
+	schema *schema
+	values []interface{}
+}
+
+// NewReader returns a Reader reading the file from r, having read its
+// header.
+func NewReader(r io.Reader) (*Reader, error) {
+	rd := &Reader{r: bufio.NewReader(r)}
+	var m [4]byte
+	if _, err := io.ReadFull(rd.r, m[:]); err != nil || !bytes.Equal(m[:], magic) {
+		return nil, errors.New("avropb: not an object container file")
+	}
+	header := &headerReader{r: rd.r, n: maxHeaderSize}
+	meta, err := decodeValue(header, &schema{kind: kindMap, values: &schema{kind: kindBytes}}, 0)
+	if err != nil {
+		return nil, fmt.Errorf("avropb: header: %v", err)
+	}
+	if _, err := io.ReadFull(rd.r, rd.sync[:]); err != nil {
+		return nil, fmt.Errorf("avropb: header: %v", err)
+	}
+
+	metadata := meta.(map[string]interface{})
+	schemaJSON, _ := metadata["avro.schema"].([]byte)
+	if rd.schema, err = parseSchema(schemaJSON); err != nil {
+		return nil, err
+	}
+	rd.json = string(schemaJSON)
+	rd.codec = "null"
+	if codec, ok := metadata["avro.codec"].([]byte); ok && len(codec) > 0 {
+		rd.codec = string(codec)
+	}
+	if rd.codec != "null" && rd.codec != "deflate" {
+		return nil, fmt.Errorf("avropb: codec %s not supported", rd.codec)
+	}
+	return rd, nil
+}
+
+// Schema returns the schema of the file, as JSON.
+func (r *Reader) Schema() string {
+	return r.json
+}
+
+// Read populates pb from the next record of the file. It returns io.EOF
+// once there are no more records.
+// pb is reset before being populated.
+func (r *Reader) Read(pb proto.Message) error {
+	for r.count == 0 {
+		if err := r.nextBlock(); err != nil {
+			return err
+		}
+	}
+	v, err := decodeValue(r.block, r.schema, 0)
+	if err != nil {
+		return fmt.Errorf("avropb: %v", err)
+	}
+	r.count--
+	if r.count == 0 && r.block.Len() > 0 {
+		return errors.New("avropb: block has trailing data")
+	}
+	rec, ok := v.(*record)
+	if !ok {
+		return errors.New("avropb: file does not hold records")
+	}
+	pb.Reset()
+	if err := r.setRecord(reflect.ValueOf(pb).Elem(), rec); err != nil {
+		return fmt.Errorf("avropb: %v", err)
+	}
+	return nil
+}
+
+// ReadAll reads every remaining record into a message created by factory.
+func (r *Reader) ReadAll(factory func() proto.Message) ([]proto.Message, error) {
+	var pbs []proto.Message
+	for {
+		pb := factory()
+		err := r.Read(pb)
+		if err == io.EOF {
+			return pbs, nil
+		}
+		if err != nil {
+			return pbs, err
+		}
+		pbs = append(pbs, pb)
+	}
+}
+
+// nextBlock reads the next block of the file.
+func (r *Reader) nextBlock() error {
+	count, err := binary.ReadVarint(r.r)
+	if err == io.EOF {
+		return io.EOF
+	}
+	if err != nil {
+		return fmt.Errorf("avropb: block: %v", err)
+	}
+	size, err := binary.ReadVarint(r.r)
+	if err != nil || count < 0 || size < 0 {
+		return errors.New("avropb: invalid block")
+	}
+	max := r.MaxBlockSize
+	if max == 0 {
+		max = DefaultMaxBlockSize
+	}
+	if size > int64(max) {
+		return fmt.Errorf("avropb: block of %d bytes exceeds MaxBlockSize", size)
+	}
+	// Grow data as it is read, rather than trusting size.
+	var buf bytes.Buffer
+	if n, err := io.CopyN(&buf, r.r, size); err != nil {
+		if n < size && err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("avropb: block: %v", err)
+	}
+	var sync [16]byte
+	if _, err := io.ReadFull(r.r, sync[:]); err != nil || sync != r.sync {
+		return errors.New("avropb: block without sync marker")
+	}
+	data := buf.Bytes()
+	if r.codec == "deflate" {
+		if data, err = ioutil.ReadAll(io.LimitReader(flate.NewReader(&buf), int64(max)+1)); err != nil {
+			return fmt.Errorf("avropb: block: %v", err)
+		}
+		if len(data) > max {
+			return errors.New("avropb: decompressed block exceeds MaxBlockSize")
+		}
+	}
+	if empty(r.schema, 0) {
+		if count > maxEmptyItems {
+			return fmt.Errorf("avropb: invalid count %d of block", count)
+		}
+	} else if count > int64(len(data)) {
+		// Every record takes a byte at least.
+		return fmt.Errorf("avropb: invalid count %d of block", count)
+	}
+	r.block = bytes.NewReader(data)
+	r.count = count
+	return nil
+}
+
+// byteReader is the input of decodeValue. Len returns the number of bytes
+// left, which bounds the lengths and counts read.
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+	Len() int
+}
+
+// errHeaderSize is returned for headers larger than maxHeaderSize.
+var errHeaderSize = errors.New("header too large")
+
+// headerReader is the byteReader of a header, reading at most n more bytes
+// of r.
+type headerReader struct {
+	r *bufio.Reader
+	n int
+}
+
+func (h *headerReader) Read(p []byte) (int, error) {
+	if h.n == 0 {
+		return 0, errHeaderSize
+	}
+	if len(p) > h.n {
+		p = p[:h.n]
+	}
+	n, err := h.r.Read(p)
+	h.n -= n
+	return n, err
+}
+
+func (h *headerReader) ReadByte() (byte, error) {
+	if h.n == 0 {
+		return 0, errHeaderSize
+	}
+	b, err := h.r.ReadByte()
+	if err == nil {
+		h.n--
+	}
+	return b, err
+}
+
+func (h *headerReader) Len() int {
+	return h.n
+}
+
+// decodeValue decodes a value of schema s, nested in depth others.
+// Records are decoded as *record, enums as their symbol, arrays as
+// []interface{} and maps as map[string]interface{}. Timestamps are
+// time.Time.
+func decodeValue(r byteReader, s *schema, depth int) (interface{}, error) {
+	if depth > maxDepth {
+		return nil, errors.New("values nested too deeply")
+	}
+	switch s.kind {
+	case kindNull:
+		return nil, nil
+	case kindBoolean:
+		b, err := r.ReadByte()
+		return b != 0, err
+	case kindInt:
+		v, err := binary.ReadVarint(r)
+		if err == nil && int64(int32(v)) != v {
+			err = errors.New("int out of range")
+		}
+		return int32(v), err
+	case kindLong:
+		v, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		switch s.logical {
+		case "timestamp-millis":
+			return time.Unix(v/1e3, v%1e3*1e6).UTC(), nil
+		case "timestamp-micros":
+			return time.Unix(v/1e6, v%1e6*1e3).UTC(), nil
+		case "timestamp-nanos":
+			return time.Unix(0, v).UTC(), nil
+		}
+		return v, nil
+	case kindFloat:
+		var b [4]byte
+		_, err := io.ReadFull(r, b[:])
+		return math.Float32frombits(binary.LittleEndian.Uint32(b[:])), err
+	case kindDouble:
+		var b [8]byte
+		_, err := io.ReadFull(r, b[:])
+		return math.Float64frombits(binary.LittleEndian.Uint64(b[:])), err
+	case kindBytes, kindString:
+		n, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 || n > int64(r.Len()) {
+			return nil, fmt.Errorf("invalid length %d", n)
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if s.kind == kindString {
+			return string(b), nil
+		}
+		return b, nil
+	case kindFixed:
+		if s.size < 0 || s.size > r.Len() {
+			return nil, fmt.Errorf("invalid size %d of fixed %s", s.size, s.name)
+		}
+		b := make([]byte, s.size)
+		_, err := io.ReadFull(r, b)
+		return b, err
+	case kindEnum:
+		i, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(s.symbols)) {
+			return nil, fmt.Errorf("invalid symbol %d of enum %s", i, s.name)
+		}
+		return s.symbols[i], nil
+	case kindUnion:
+		i, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if i < 0 || i >= int64(len(s.branches)) {
+			return nil, fmt.Errorf("invalid branch %d of union", i)
+		}
+		return decodeValue(r, s.branches[i], depth+1)
+	case kindRecord:
+		rec := &record{schema: s, values: make([]interface{}, len(s.fields))}
+		for i, f := range s.fields {
+			v, err := decodeValue(r, f.schema, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			rec.values[i] = v
+		}
+		return rec, nil
+	case kindArray:
+		var items []interface{}
+		err := decodeBlocks(r, empty(s.items, depth+1), func() error {
+			v, err := decodeValue(r, s.items, depth+1)
+			items = append(items, v)
+			return err
+		})
+		return items, err
+	case kindMap:
+		values := make(map[string]interface{})
+		err := decodeBlocks(r, false, func() error {
+			k, err := decodeValue(r, &schema{kind: kindString}, depth+1)
+			if err != nil {
+				return err
+			}
+			v, err := decodeValue(r, s.values, depth+1)
+			values[k.(string)] = v
+			return err
+		})
+		return values, err
+	}
+	return nil, fmt.Errorf("invalid schema kind %d", s.kind)
+}
+
+// decodeBlocks calls fn for every item of the blocks of an array or map.
+// Items are encoded as no bytes at all if empty.
+func decodeBlocks(r byteReader, empty bool, fn func() error) error {
+	var items int64
+	for {
+		n, err := binary.ReadVarint(r)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return nil
+		}
+		if n < 0 {
+			// The count is followed by the size of the block.
+			n = -n
+			size, err := binary.ReadVarint(r)
+			if err != nil {
+				return err
+			}
+			if size < 0 || size > int64(r.Len()) {
+				return fmt.Errorf("invalid block size %d", size)
+			}
+		}
+		// Every item but empty ones takes a byte at least.
+		if n < 0 || empty && n > maxEmptyItems-items || !empty && n > int64(r.Len()) {
+			return fmt.Errorf("invalid block count %d", n)
+		}
+		items += n
+		for ; n > 0; n-- {
+			if err := fn(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// empty tells whether values of s, nested in depth others, are encoded as
+// no bytes at all.
+func empty(s *schema, depth int) bool {
+	switch {
+	case depth > maxDepth:
+		return false
+	case s.kind == kindNull:
+		return true
+	case s.kind == kindFixed:
+		return s.size == 0
+	case s.kind != kindRecord:
+		return false
+	}
+	for _, f := range s.fields {
+		if !empty(f.schema, depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// setRecord sets the fields of the message struct v from rec. Fields are
+// matched by their original or lowerCamelCase name.
+func (r *Reader) setRecord(v reflect.Value, rec *record) error {
+	type target struct {
+		index int
+		prop  *proto.Properties
+		oneof reflect.Type
+	}
+	targets := make(map[string]target)
+	add := func(t target) {
+		targets[t.prop.OrigName] = t
+		if t.prop.JSONName != "" {
+			targets[t.prop.JSONName] = t
+		}
+	}
+	sprops := proto.GetProperties(v.Type())
+	for i := 0; i < v.NumField(); i++ {
+		ft := v.Type().Field(i)
+		if strings.HasPrefix(ft.Name, "XXX_") {
+			continue
+		}
+		if ft.Tag.Get("protobuf_oneof") == "" {
+			add(target{index: i, prop: sprops.Prop[i]})
+		}
+	}
+	for _, oop := range sprops.OneofTypes {
+		add(target{index: oop.Field, prop: oop.Prop, oneof: oop.Type})
+	}
+
+	for i, f := range rec.schema.fields {
+		t, ok := targets[f.name]
+		if !ok {
+			if r.AllowUnknownFields {
+				continue
+			}
+			return fmt.Errorf("unknown field %q in %v", f.name, v.Type())
+		}
+		value := rec.values[i]
+		if value == nil {
+			continue
+		}
+		fv := v.Field(t.index)
+		if t.oneof != nil {
+			wrapper := reflect.New(t.oneof.Elem())
+			if err := r.setValue(wrapper.Elem().Field(0), t.prop, value); err != nil {
+				return fmt.Errorf("%s: %v", f.name, err)
+			}
+			fv.Set(wrapper)
+			continue
+		}
+		if err := r.setValue(fv, t.prop, value); err != nil {
+			return fmt.Errorf("%s: %v", f.name, err)
+		}
+	}
+	return nil
+}
+
+var (
+	timestampPtrType = reflect.TypeOf(&tspb.Timestamp{})
+	bytesType        = reflect.TypeOf([]byte(nil))
+)
+
+// setValue sets v, a field described by prop, from the decoded value.
+func (r *Reader) setValue(v reflect.Value, prop *proto.Properties, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	t := v.Type()
+	switch {
+	case t == timestampPtrType:
+		ts, ok := value.(time.Time)
+		if !ok {
+			return fmt.Errorf("cannot convert %T to timestamp", value)
+		}
+		v.Set(reflect.ValueOf(&tspb.Timestamp{Seconds: ts.Unix(), Nanos: int32(ts.Nanosecond())}))
+		return nil
+	case t.Kind() == reflect.Ptr:
+		elem := reflect.New(t.Elem())
+		if t.Elem().Kind() == reflect.Struct {
+			if rec, ok := value.(*record); ok {
+				if err := r.setRecord(elem.Elem(), rec); err != nil {
+					return err
+				}
+				v.Set(elem)
+				return nil
+			}
+			// Wrappers hold the value as their first field.
+			if err := r.setValue(elem.Elem().Field(0), prop, value); err != nil {
+				return err
+			}
+			v.Set(elem)
+			return nil
+		}
+		if err := r.setValue(elem.Elem(), prop, value); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case t == bytesType:
+		switch value := value.(type) {
+		case []byte:
+			v.SetBytes(value)
+			return nil
+		case string:
+			v.SetBytes([]byte(value))
+			return nil
+		}
+	case t.Kind() == reflect.Slice:
+		items, ok := value.([]interface{})
+		if !ok {
+			break
+		}
+		s := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			if item == nil {
+				return errors.New("null item")
+			}
+			if err := r.setValue(s.Index(i), prop, item); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	case t.Kind() == reflect.Map:
+		values, ok := value.(map[string]interface{})
+		if !ok {
+			break
+		}
+		m := reflect.MakeMapWithSize(t, len(values))
+		for k, item := range values {
+			key := reflect.New(t.Key()).Elem()
+			if err := parseKey(key, k); err != nil {
+				return err
+			}
+			if item == nil {
+				return errors.New("null value")
+			}
+			val := reflect.New(t.Elem()).Elem()
+			if err := r.setValue(val, prop.MapValProp, item); err != nil {
+				return err
+			}
+			m.SetMapIndex(key, val)
+		}
+		v.Set(m)
+		return nil
+	case t.Kind() == reflect.Int32 && prop != nil && prop.Enum != "":
+		if symbol, ok := value.(string); ok {
+			n, ok := proto.EnumValueMap(prop.Enum)[symbol]
+			if !ok {
+				return fmt.Errorf("unknown value %q of enum %s", symbol, prop.Enum)
+			}
+			v.SetInt(int64(n))
+			return nil
+		}
+	case t.Kind() == reflect.String:
+		switch value := value.(type) {
+		case string:
+			v.SetString(value)
+			return nil
+		case []byte:
+			v.SetString(string(value))
+			return nil
+		}
+	case t.Kind() == reflect.Bool:
+		if b, ok := value.(bool); ok {
+			v.SetBool(b)
+			return nil
+		}
+	}
+	return setNumber(v, value)
+}
+
+// setNumber sets the number field v from a decoded number, failing should
+// it not fit.
+func setNumber(v reflect.Value, value interface{}) error {
+	var i int64
+	var f float64
+	isInt := true
+	switch value := value.(type) {
+	case int32:
+		i = int64(value)
+	case int64:
+		i = value
+	case float32:
+		f, isInt = float64(value), false
+	case float64:
+		f, isInt = value, false
+	default:
+		return fmt.Errorf("cannot convert %T to %v", value, v.Type())
+	}
+	switch v.Kind() {
+	case reflect.Int32, reflect.Int64:
+		if !isInt || v.OverflowInt(i) {
+			return fmt.Errorf("cannot convert %v to %v", value, v.Type())
+		}
+		v.SetInt(i)
+	case reflect.Uint32, reflect.Uint64:
+		if !isInt || i < 0 || v.OverflowUint(uint64(i)) {
+			return fmt.Errorf("cannot convert %v to %v", value, v.Type())
+		}
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		if isInt {
+			f = float64(i)
+		}
+		v.SetFloat(f)
+	default:
+		return fmt.Errorf("cannot convert %T to %v", value, v.Type())
+	}
+	return nil
+}
+
+// parseKey parses the map key k into v.
+func parseKey(v reflect.Value, k string) error {
+	var err error
+	switch v.Kind() {
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(k)
+		v.SetBool(b)
+	case reflect.Int32, reflect.Int64:
+		var i int64
+		i, err = strconv.ParseInt(k, 10, v.Type().Bits())
+		v.SetInt(i)
+	case reflect.Uint32, reflect.Uint64:
+		var u uint64
+		u, err = strconv.ParseUint(k, 10, v.Type().Bits())
+		v.SetUint(u)
+	default:
+		v.SetString(k)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid key %q", k)
+	}
+	return nil
+}
@@ -0,0 +1,298 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package avropb
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// legacy_deflate.avro was written by goavro with the schema below and the
+// deflate codec. Its records are
+//
+//	{"oInt32": 7, "o_string": "seven", "o_double": 0.5, "extra": [1, 2], "o_bytes": "abcd"}
+//	{"oInt32": -1, "o_string": null, "o_double": 2, "extra": [], "o_bytes": "\x00\x01\x02\x03"}
+const legacySchema = `{"type":"record","name":"Simple","namespace":"legacy","fields":[
+ {"name":"oInt32","type":"int"},
+ {"name":"o_string","type":["null","string"],"default":null},
+ {"name":"o_double","type":"float"},
+ {"name":"extra","type":{"type":"array","items":"long"}},
+ {"name":"o_bytes","type":{"type":"fixed","name":"Four","size":4}}
+]}`
+
+func openLegacy(t *testing.T) *Reader {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/legacy_deflate.avro")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReadForeignFile(t *testing.T) {
+	r := openLegacy(t)
+	if r.Schema() != legacySchema {
+		t.Errorf("Schema() = %s, want %s", r.Schema(), legacySchema)
+	}
+	r.AllowUnknownFields = true
+	got, err := r.ReadAll(func() proto.Message { return new(pb.Simple) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*pb.Simple{
+		{OInt32: proto.Int32(7), OString: proto.String("seven"), ODouble: proto.Float64(0.5), OBytes: []byte("abcd")},
+		{OInt32: proto.Int32(-1), ODouble: proto.Float64(2), OBytes: []byte{0, 1, 2, 3}},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ReadAll() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("message %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestReadUnknownField(t *testing.T) {
+	r := openLegacy(t)
+	err := r.Read(new(pb.Simple))
+	if err == nil || !strings.Contains(err.Error(), `unknown field "extra"`) {
+		t.Errorf("Read() error = %v, want unknown field", err)
+	}
+}
+
+func TestReadMessages(t *testing.T) {
+	tests := []struct {
+		desc string
+		in   proto.Message
+		out  proto.Message
+		want string
+	}{
+		{"other message", &pb.Simple{OInt64: proto.Int64(1 << 40)}, &pb.Simple3{}, "unknown field"},
+		{"enum symbol", &pb.Widget{Color: pb.Widget_GREEN.Enum()}, &pb.Widget{}, ""},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, tt.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Write(tt.in); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		r, err := NewReader(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.Read(tt.out)
+		if tt.want == "" {
+			if err != nil || !proto.Equal(tt.in, tt.out) {
+				t.Errorf("%s: Read() = %v, %v, want %v", tt.desc, tt.out, err, tt.in)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Read() error = %v, want %q", tt.desc, err, tt.want)
+		}
+	}
+}
+
+func TestNewReaderErrors(t *testing.T) {
+	tests := []struct {
+		desc string
+		in   string
+		want string
+	}{
+		{"magic", "PAR1", "not an object container file"},
+		{"truncated", "Obj\x01\x04", "header"},
+		{"codec", "Obj\x01\x04\x16avro.schema\x0c\"long\"\x14avro.codec\x0csnappy\x00" + strings.Repeat("s", 16), "codec snappy not supported"},
+		{"schema", "Obj\x01\x02\x16avro.schema\x0c\"Nope\"\x00" + strings.Repeat("s", 16), `unknown type "Nope"`},
+	}
+	for _, tt := range tests {
+		_, err := NewReader(strings.NewReader(tt.in))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: NewReader() error = %v, want %q", tt.desc, err, tt.want)
+		}
+	}
+}
+
+func TestReadNotRecords(t *testing.T) {
+	in := "Obj\x01\x02\x16avro.schema\x0c\"long\"\x00" + strings.Repeat("s", 16) +
+		"\x02\x02\x06" + strings.Repeat("s", 16)
+	r, err := NewReader(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Read(new(pb.Simple)); err == nil || !strings.Contains(err.Error(), "does not hold records") {
+		t.Errorf("Read() error = %v", err)
+	}
+}
+
+func TestReadBadSync(t *testing.T) {
+	in := "Obj\x01\x02\x16avro.schema\x0c\"long\"\x00" + strings.Repeat("s", 16) +
+		"\x02\x02\x06" + strings.Repeat("x", 16)
+	r, err := NewReader(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Read(new(pb.Simple)); err == nil || !strings.Contains(err.Error(), "sync marker") {
+		t.Errorf("Read() error = %v", err)
+	}
+}
+
+// avroFile returns a file of schema and codec holding a block of count
+// records encoded as data.
+func avroFile(schema, codec string, count int64, data string) string {
+	varint := func(v int64) string {
+		b := make([]byte, binary.MaxVarintLen64)
+		return string(b[:binary.PutVarint(b, v)])
+	}
+	meta := varint(2) + varint(int64(len("avro.schema"))) + "avro.schema" + varint(int64(len(schema))) + schema +
+		varint(int64(len("avro.codec"))) + "avro.codec" + varint(int64(len(codec))) + codec + "\x00"
+	sync := strings.Repeat("s", 16)
+	return "Obj\x01" + meta + sync + varint(count) + varint(int64(len(data))) + data + sync
+}
+
+func TestReadCorrupt(t *testing.T) {
+	huge := string([]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x10}) // 1<<59 zigzagged
+	var bomb bytes.Buffer
+	fw, _ := flate.NewWriter(&bomb, flate.BestCompression)
+	fw.Write(make([]byte, 2<<20))
+	fw.Close()
+	tests := []struct {
+		desc string
+		in   string
+		want string
+	}{
+		{"block size", "Obj\x01\x02\x16avro.schema\x0c\"long\"\x00" + strings.Repeat("s", 16) + "\x02" + huge,
+			"exceeds MaxBlockSize"},
+		{"block count", avroFile(`"long"`, "null", 1<<40, "\x02"), "invalid count"},
+		{"string length", avroFile(`"string"`, "null", 1, huge), "invalid length"},
+		{"array of null", avroFile(`{"type":"array","items":"null"}`, "null", 1, huge), "invalid block count"},
+		{"array block size", avroFile(`{"type":"array","items":"long"}`, "null", 1, "\x01"+huge), "invalid block size"},
+		{"fixed size", avroFile(`{"type":"fixed","name":"F","size":1e9}`, "null", 1, "\x00"), "invalid size"},
+		{"deflate bomb", avroFile(`"bytes"`, "deflate", 1, bomb.String()), "decompressed block exceeds MaxBlockSize"},
+		{"recursion", avroFile(`{"type":"record","name":"R","fields":[{"name":"r","type":"R"}]}`, "null", 1, "\x00"),
+			"nested too deeply"},
+	}
+	for _, tt := range tests {
+		r, err := NewReader(strings.NewReader(tt.in))
+		if err != nil {
+			t.Fatalf("%s: NewReader() error = %v", tt.desc, err)
+		}
+		r.MaxBlockSize = 1 << 20
+		if err := r.Read(new(pb.Simple)); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: Read() error = %v, want %q", tt.desc, err, tt.want)
+		}
+	}
+}
+
+// TestReadMutated reads a file with every byte replaced by a few values,
+// which has to fail or succeed, but never panic.
+func TestReadMutated(t *testing.T) {
+	var buf bytes.Buffer
+	in := &pb.Repeats{RString: []string{"a", "bc"}, RInt64: []int64{1, -1 << 40}, RBytes: [][]byte{{0, 1}}}
+	w, err := NewWriter(&buf, in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Codec = Deflate
+	for i := 0; i < 3; i++ {
+		if err := w.Write(in); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	mutated := make([]byte, len(data))
+	for i := range data {
+		for _, b := range []byte{0x00, 0x01, 0x7f, 0x80, 0xff, data[i] ^ 0x40} {
+			copy(mutated, data)
+			mutated[i] = b
+			func() {
+				defer func() {
+					if p := recover(); p != nil {
+						t.Errorf("byte %d set to %#x: panic: %v", i, b, p)
+					}
+				}()
+				r, err := NewReader(bytes.NewReader(mutated))
+				if err == nil {
+					r.ReadAll(func() proto.Message { return new(pb.Repeats) })
+				}
+			}()
+		}
+	}
+}
+
+func TestParseSchema(t *testing.T) {
+	s, err := parseSchema([]byte(`{"type":"record","name":"a.R","fields":[
+		{"name":"self","type":["null","R"]},
+		{"name":"e","type":{"type":"enum","name":"E","symbols":["X","Y"]}},
+		{"name":"e2","type":"a.E"},
+		{"name":"ts","type":{"type":"long","logicalType":"timestamp-millis"}},
+		{"name":"m","type":{"type":"map","values":{"type":"array","items":"string"}}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.kind != kindRecord || s.name != "a.R" || len(s.fields) != 5 {
+		t.Fatalf("parseSchema() = %+v", s)
+	}
+	if s.fields[0].schema.branches[1] != s {
+		t.Error("recursive reference not resolved")
+	}
+	if s.fields[1].schema != s.fields[2].schema || s.fields[1].schema.name != "a.E" {
+		t.Error("enum reference not resolved")
+	}
+	if ts := s.fields[3].schema; ts.kind != kindLong || ts.logical != "timestamp-millis" {
+		t.Errorf("timestamp = %+v", ts)
+	}
+	if m := s.fields[4].schema; m.kind != kindMap || m.values.kind != kindArray || m.values.items.kind != kindString {
+		t.Errorf("map = %+v", m)
+	}
+}
@@ -0,0 +1,496 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package avropb writes protocol buffers to Avro object container files and
+reads Avro object container files back into protocol buffers.
+
+The Avro schema of a file is derived from the message type. Every field is
+a field of a record, named by its original name. Nested messages are
+records, repeated fields arrays and map fields maps, with keys converted
+to strings. Optional fields of proto2, message fields, wrappers and
+members of oneofs are unions with null. Enums are Avro enums, Timestamps
+longs of the logical type timestamp-micros.
+
+Reading accepts files of any schema, matching fields by name.
+*/
+package avropb
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// kind is the type of an Avro schema.
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBoolean
+	kindInt
+	kindLong
+	kindFloat
+	kindDouble
+	kindBytes
+	kindString
+	kindRecord
+	kindEnum
+	kindArray
+	kindMap
+	kindUnion
+	kindFixed
+)
+
+var primitives = map[string]kind{
+	"null":    kindNull,
+	"boolean": kindBoolean,
+	"int":     kindInt,
+	"long":    kindLong,
+	"float":   kindFloat,
+	"double":  kindDouble,
+	"bytes":   kindBytes,
+	"string":  kindString,
+}
+
+// schema is an Avro schema, either derived from a message type or parsed
+// from a file.
+type schema struct {
+	kind    kind
+	name    string
+	logical string
+
+	fields   []*field  // records
+	items    *schema   // arrays
+	values   *schema   // maps
+	branches []*schema // unions
+	symbols  []string  // enums
+	size     int       // fixed
+
+	// Derived schemas only.
+	numbers   map[int32]int // index of the symbol of enum numbers
+	wrapper   bool          // value is Field(0) of a struct
+	timestamp bool          // value is a Timestamp
+}
+
+// field is a field of a record.
+type field struct {
+	name   string
+	schema *schema
+
+	// Derived schemas only.
+	index int          // of the struct field
+	oneof reflect.Type // wrapper of members of a oneof
+}
+
+var timestampType = reflect.TypeOf(tspb.Timestamp{})
+
+// wrapperNames are the well-known wrapper types, stored as their value.
+var wrapperNames = map[string]bool{
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+}
+
+// Schema returns the Avro schema, as JSON, of files holding messages of
+// the type of pb.
+func Schema(pb proto.Message) (string, error) {
+	s, err := schemaOfMessage(pb)
+	if err != nil {
+		return "", err
+	}
+	b, err := json.Marshal(s.json(make(map[*schema]bool)))
+	return string(b), err
+}
+
+func schemaOfMessage(pb proto.Message) (*schema, error) {
+	t := reflect.TypeOf(pb)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("avropb: message must be a pointer to a struct")
+	}
+	return recordOf(t.Elem(), make(map[reflect.Type]*schema))
+}
+
+// recordOf returns the record of the messages of struct type t. Records
+// and enums already derived are in defined by Go type, so that every named
+// type is defined once and recursive messages refer to themselves.
+func recordOf(t reflect.Type, defined map[reflect.Type]*schema) (*schema, error) {
+	if s, ok := defined[t]; ok {
+		return s, nil
+	}
+	name := proto.MessageName(reflect.New(t).Interface().(proto.Message))
+	if name == "" {
+		return nil, fmt.Errorf("avropb: %v is not a registered message", t)
+	}
+	s := &schema{kind: kindRecord, name: name}
+	defined[t] = s
+
+	sprops := proto.GetProperties(t)
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		if strings.HasPrefix(ft.Name, "XXX_") {
+			continue
+		}
+		if ft.Tag.Get("protobuf_oneof") != "" {
+			var oneofs []*proto.OneofProperties
+			for _, oop := range sprops.OneofTypes {
+				if oop.Field == i {
+					oneofs = append(oneofs, oop)
+				}
+			}
+			sort.Slice(oneofs, func(i, j int) bool { return oneofs[i].Prop.Tag < oneofs[j].Prop.Tag })
+			for _, oop := range oneofs {
+				fs, err := fieldSchema(oop.Type.Elem().Field(0).Type, oop.Prop, true, defined)
+				if err != nil {
+					return nil, err
+				}
+				s.fields = append(s.fields, &field{name: oop.Prop.OrigName, schema: fs, index: i, oneof: oop.Type})
+			}
+			continue
+		}
+
+		prop := sprops.Prop[i]
+		optional := ft.Type.Kind() == reflect.Ptr ||
+			ft.Type.Kind() == reflect.Slice && ft.Type.Elem().Kind() == reflect.Uint8 &&
+				!strings.Contains(ft.Tag.Get("protobuf"), ",proto3") && !prop.Required
+		fs, err := fieldSchema(ft.Type, prop, optional, defined)
+		if err != nil {
+			return nil, err
+		}
+		s.fields = append(s.fields, &field{name: prop.OrigName, schema: fs, index: i})
+	}
+	return s, nil
+}
+
+// fieldSchema returns the schema of a field of Go type t. Should the field
+// be optional, the schema is a union with null.
+func fieldSchema(t reflect.Type, prop *proto.Properties, optional bool, defined map[reflect.Type]*schema) (*schema, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var s *schema
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			s = &schema{kind: kindBytes}
+			break
+		}
+		items, err := fieldSchema(t.Elem(), prop, false, defined)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{kind: kindArray, items: items}, nil
+	case reflect.Map:
+		switch t.Key().Kind() {
+		case reflect.Bool, reflect.Int32, reflect.Int64, reflect.Uint32, reflect.Uint64, reflect.String:
+		default:
+			return nil, fmt.Errorf("avropb: field %s has keys of type %v", prop.OrigName, t.Key())
+		}
+		values, err := fieldSchema(t.Elem(), prop.MapValProp, false, defined)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{kind: kindMap, values: values}, nil
+	case reflect.Struct:
+		name := proto.MessageName(reflect.New(t).Interface().(proto.Message))
+		switch {
+		case t == timestampType:
+			s = &schema{kind: kindLong, logical: "timestamp-micros", timestamp: true}
+		case wrapperNames[name]:
+			var err error
+			if s, err = fieldSchema(t.Field(0).Type, prop, false, defined); err != nil {
+				return nil, err
+			}
+			s.wrapper = true
+		default:
+			var err error
+			if s, err = recordOf(t, defined); err != nil {
+				return nil, err
+			}
+		}
+	case reflect.Bool:
+		s = &schema{kind: kindBoolean}
+	case reflect.Int32:
+		if prop != nil && prop.Enum != "" {
+			if s = defined[t]; s != nil {
+				break
+			}
+			var err error
+			if s, err = enumSchema(prop.Enum); err != nil {
+				return nil, err
+			}
+			defined[t] = s
+			break
+		}
+		s = &schema{kind: kindInt}
+	case reflect.Int64, reflect.Uint32, reflect.Uint64:
+		s = &schema{kind: kindLong}
+	case reflect.Float32:
+		s = &schema{kind: kindFloat}
+	case reflect.Float64:
+		s = &schema{kind: kindDouble}
+	case reflect.String:
+		s = &schema{kind: kindString}
+	default:
+		return nil, fmt.Errorf("avropb: field %s of type %v not supported", prop.OrigName, t)
+	}
+	if optional {
+		return &schema{kind: kindUnion, branches: []*schema{{kind: kindNull}, s}}, nil
+	}
+	return s, nil
+}
+
+// enumSchema returns the schema of the registered enum name.
+func enumSchema(name string) (*schema, error) {
+	values := proto.EnumValueMap(name)
+	if values == nil {
+		return nil, fmt.Errorf("avropb: enum %s not registered", name)
+	}
+	symbols := make([]string, 0, len(values))
+	for symbol := range values {
+		symbols = append(symbols, symbol)
+	}
+	sort.Slice(symbols, func(i, j int) bool {
+		if values[symbols[i]] != values[symbols[j]] {
+			return values[symbols[i]] < values[symbols[j]]
+		}
+		return symbols[i] < symbols[j]
+	})
+	numbers := make(map[int32]int, len(symbols))
+	for i := len(symbols) - 1; i >= 0; i-- {
+		// Aliases use the first symbol.
+		numbers[values[symbols[i]]] = i
+	}
+	return &schema{kind: kindEnum, name: name, symbols: symbols, numbers: numbers}, nil
+}
+
+// jsonField is a field of a record in the JSON of a schema.
+type jsonField struct {
+	Name    string           `json:"name"`
+	Type    interface{}      `json:"type"`
+	Default *json.RawMessage `json:"default,omitempty"`
+}
+
+var jsonNull = json.RawMessage("null")
+
+// json returns the value of the JSON of s. Named schemas already in
+// defined are referred to by name.
+func (s *schema) json(defined map[*schema]bool) interface{} {
+	var v map[string]interface{}
+	switch s.kind {
+	case kindRecord, kindEnum, kindFixed:
+		if defined[s] {
+			return s.name
+		}
+		defined[s] = true
+		v = map[string]interface{}{"name": s.name}
+		switch s.kind {
+		case kindRecord:
+			v["type"] = "record"
+			fields := make([]jsonField, len(s.fields))
+			for i, f := range s.fields {
+				fields[i] = jsonField{Name: f.name, Type: f.schema.json(defined)}
+				if f.schema.kind == kindUnion && f.schema.branches[0].kind == kindNull {
+					fields[i].Default = &jsonNull
+				}
+			}
+			v["fields"] = fields
+		case kindEnum:
+			v["type"] = "enum"
+			v["symbols"] = s.symbols
+		case kindFixed:
+			v["type"] = "fixed"
+			v["size"] = s.size
+		}
+	case kindArray:
+		v = map[string]interface{}{"type": "array", "items": s.items.json(defined)}
+	case kindMap:
+		v = map[string]interface{}{"type": "map", "values": s.values.json(defined)}
+	case kindUnion:
+		branches := make([]interface{}, len(s.branches))
+		for i, b := range s.branches {
+			branches[i] = b.json(defined)
+		}
+		return branches
+	default:
+		var name string
+		for n, k := range primitives {
+			if k == s.kind {
+				name = n
+			}
+		}
+		if s.logical == "" {
+			return name
+		}
+		v = map[string]interface{}{"type": name}
+	}
+	if s.logical != "" {
+		v["logicalType"] = s.logical
+	}
+	return v
+}
+
+// parseSchema parses the JSON of a schema.
+func parseSchema(data []byte) (*schema, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("avropb: schema: %v", err)
+	}
+	return parseValue(v, "", make(map[string]*schema))
+}
+
+// parseValue parses the JSON value v of a schema within namespace. named
+// holds the named schemas by full name.
+func parseValue(v interface{}, namespace string, named map[string]*schema) (*schema, error) {
+	switch v := v.(type) {
+	case string:
+		if k, ok := primitives[v]; ok {
+			return &schema{kind: k}, nil
+		}
+		if s, ok := named[fullName(v, namespace)]; ok {
+			return s, nil
+		}
+		if s, ok := named[v]; ok {
+			return s, nil
+		}
+		return nil, fmt.Errorf("avropb: unknown type %q", v)
+	case []interface{}:
+		s := &schema{kind: kindUnion}
+		for _, b := range v {
+			bs, err := parseValue(b, namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			s.branches = append(s.branches, bs)
+		}
+		return s, nil
+	case map[string]interface{}:
+		return parseObject(v, namespace, named)
+	}
+	return nil, fmt.Errorf("avropb: invalid schema %v", v)
+}
+
+func parseObject(v map[string]interface{}, namespace string, named map[string]*schema) (*schema, error) {
+	typ, _ := v["type"].(string)
+	logical, _ := v["logicalType"].(string)
+	if k, ok := primitives[typ]; ok {
+		return &schema{kind: k, logical: logical}, nil
+	}
+
+	switch typ {
+	case "array":
+		items, err := parseValue(v["items"], namespace, named)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{kind: kindArray, items: items}, nil
+	case "map":
+		values, err := parseValue(v["values"], namespace, named)
+		if err != nil {
+			return nil, err
+		}
+		return &schema{kind: kindMap, values: values}, nil
+	case "record", "error", "enum", "fixed":
+	default:
+		if t, ok := v["type"]; ok {
+			// A type wrapped in an object, like {"type": {"type": "array"}}.
+			if _, isObject := t.(map[string]interface{}); isObject {
+				return parseValue(t, namespace, named)
+			}
+		}
+		return nil, fmt.Errorf("avropb: unknown type %v", v["type"])
+	}
+
+	name, _ := v["name"].(string)
+	if ns, ok := v["namespace"].(string); ok && !strings.Contains(name, ".") {
+		namespace = ns
+	}
+	name = fullName(name, namespace)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		namespace = name[:i]
+	}
+	s := &schema{name: name, logical: logical}
+	named[name] = s
+
+	switch typ {
+	case "enum":
+		s.kind = kindEnum
+		symbols, _ := v["symbols"].([]interface{})
+		for _, sym := range symbols {
+			str, ok := sym.(string)
+			if !ok {
+				return nil, fmt.Errorf("avropb: enum %s has invalid symbols", name)
+			}
+			s.symbols = append(s.symbols, str)
+		}
+	case "fixed":
+		s.kind = kindFixed
+		size, _ := v["size"].(float64)
+		s.size = int(size)
+	default:
+		s.kind = kindRecord
+		fields, _ := v["fields"].([]interface{})
+		for _, f := range fields {
+			fm, ok := f.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("avropb: record %s has invalid fields", name)
+			}
+			fs, err := parseValue(fm["type"], namespace, named)
+			if err != nil {
+				return nil, err
+			}
+			fname, _ := fm["name"].(string)
+			s.fields = append(s.fields, &field{name: fname, schema: fs})
+		}
+	}
+	return s, nil
+}
+
+// fullName qualifies name with namespace, unless it is qualified already.
+func fullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
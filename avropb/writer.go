@@ -0,0 +1,337 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package avropb
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Codec compresses the blocks of a file.
+type Codec int
+
+const (
+	Null Codec = iota
+	Deflate
+)
+
+var codecNames = map[Codec]string{
+	Null:    "null",
+	Deflate: "deflate",
+}
+
+// magic starts every object container file.
+var magic = []byte{'O', 'b', 'j', 1}
+
+// Writer writes protocol buffers to an object container file.
+type Writer struct {
+	// BlockSize is the number of messages per block, 1000 if zero.
+	BlockSize int
+
+	// Codec of the blocks. Must be set before the first Write.
+	Codec Codec
+
+	w      io.Writer
+	typ    reflect.Type
+	schema *schema
+	sync   [16]byte
+	header bool
+	block  []byte
+	count  int
+	err    error
+}
+
+// NewWriter returns a Writer writing messages of the type of pb to w.
+func NewWriter(w io.Writer, pb proto.Message) (*Writer, error) {
+	s, err := schemaOfMessage(pb)
+	if err != nil {
+		return nil, err
+	}
+	wr := &Writer{w: w, typ: reflect.TypeOf(pb), schema: s}
+	if _, err := rand.Read(wr.sync[:]); err != nil {
+		return nil, err
+	}
+	return wr, nil
+}
+
+// Write buffers pb, writing a block once BlockSize messages are buffered.
+func (w *Writer) Write(pb proto.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	if reflect.TypeOf(pb) != w.typ {
+		return fmt.Errorf("avropb: message of type %T, want %v", pb, w.typ)
+	}
+	block, err := appendValue(w.block, w.schema, reflect.ValueOf(pb).Elem())
+	if err != nil {
+		return err
+	}
+	w.block = block
+	w.count++
+	size := w.BlockSize
+	if size <= 0 {
+		size = 1000
+	}
+	if w.count >= size {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush writes the buffered messages as a block.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.err = w.writeHeader(); w.err != nil {
+		return w.err
+	}
+	if w.count == 0 {
+		return nil
+	}
+	data := w.block
+	if w.Codec == Deflate {
+		var buf bytes.Buffer
+		fw, err := flate.NewWriter(&buf, flate.DefaultCompression)
+		if err != nil {
+			return err
+		}
+		fw.Write(data)
+		if err := fw.Close(); err != nil {
+			return err
+		}
+		data = buf.Bytes()
+	}
+	out := appendLong(nil, int64(w.count))
+	out = appendLong(out, int64(len(data)))
+	out = append(out, data...)
+	out = append(out, w.sync[:]...)
+	if _, w.err = w.w.Write(out); w.err != nil {
+		return w.err
+	}
+	w.block = w.block[:0]
+	w.count = 0
+	return nil
+}
+
+// Close flushes the buffered messages. It does not close the underlying
+// writer.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	w.err = errors.New("avropb: Writer closed")
+	return nil
+}
+
+// writeHeader writes the header of the file, unless written already.
+func (w *Writer) writeHeader() error {
+	if w.header {
+		return nil
+	}
+	codec, ok := codecNames[w.Codec]
+	if !ok {
+		return fmt.Errorf("avropb: unknown codec %d", w.Codec)
+	}
+	schemaJSON, err := json.Marshal(w.schema.json(make(map[*schema]bool)))
+	if err != nil {
+		return err
+	}
+	w.header = true
+
+	out := append([]byte(nil), magic...)
+	out = appendLong(out, 2)
+	out = appendBytes(out, []byte("avro.schema"))
+	out = appendBytes(out, schemaJSON)
+	out = appendBytes(out, []byte("avro.codec"))
+	out = appendBytes(out, []byte(codec))
+	out = appendLong(out, 0)
+	out = append(out, w.sync[:]...)
+	_, err = w.w.Write(out)
+	return err
+}
+
+// appendLong appends v zigzag and varint encoded.
+func appendLong(b []byte, v int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutVarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendBytes(b, v []byte) []byte {
+	b = appendLong(b, int64(len(v)))
+	return append(b, v...)
+}
+
+// appendValue appends the Go value v of a field of schema s.
+func appendValue(b []byte, s *schema, v reflect.Value) ([]byte, error) {
+	if v.Kind() == reflect.Ptr && s.kind != kindUnion {
+		v = v.Elem()
+	}
+	if s.wrapper {
+		v = v.Field(0)
+	}
+	switch s.kind {
+	case kindUnion:
+		if isNull(v) {
+			return appendLong(b, 0), nil
+		}
+		return appendValue(appendLong(b, 1), s.branches[1], v)
+	case kindBoolean:
+		if v.Bool() {
+			return append(b, 1), nil
+		}
+		return append(b, 0), nil
+	case kindInt:
+		return appendLong(b, v.Int()), nil
+	case kindLong:
+		switch {
+		case s.timestamp:
+			sec, nanos := v.Field(0).Int(), v.Field(1).Int()
+			if sec > math.MaxInt64/1000000-1 || sec < math.MinInt64/1000000+1 {
+				return nil, fmt.Errorf("avropb: timestamp %ds out of range", sec)
+			}
+			return appendLong(b, sec*1000000+nanos/1000), nil
+		case v.Kind() == reflect.Uint32 || v.Kind() == reflect.Uint64:
+			if v.Uint() > math.MaxInt64 {
+				return nil, fmt.Errorf("avropb: %d exceeds long", v.Uint())
+			}
+			return appendLong(b, int64(v.Uint())), nil
+		}
+		return appendLong(b, v.Int()), nil
+	case kindFloat:
+		return appendUint32(b, math.Float32bits(float32(v.Float()))), nil
+	case kindDouble:
+		bits := math.Float64bits(v.Float())
+		return append(appendUint32(b, uint32(bits)), byte(bits>>32), byte(bits>>40), byte(bits>>48), byte(bits>>56)), nil
+	case kindBytes:
+		return appendBytes(b, v.Bytes()), nil
+	case kindString:
+		b = appendLong(b, int64(v.Len()))
+		return append(b, v.String()...), nil
+	case kindEnum:
+		i, ok := s.numbers[int32(v.Int())]
+		if !ok {
+			return nil, fmt.Errorf("avropb: unknown value %d of enum %s", v.Int(), s.name)
+		}
+		return appendLong(b, int64(i)), nil
+	case kindArray:
+		if v.Len() > 0 {
+			b = appendLong(b, int64(v.Len()))
+			for i := 0; i < v.Len(); i++ {
+				var err error
+				if b, err = appendValue(b, s.items, v.Index(i)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return appendLong(b, 0), nil
+	case kindMap:
+		if v.Len() > 0 {
+			keys := v.MapKeys()
+			names := make([]string, len(keys))
+			for i, k := range keys {
+				names[i] = formatKey(k)
+			}
+			order := make([]int, len(keys))
+			for i := range order {
+				order[i] = i
+			}
+			sort.Slice(order, func(i, j int) bool { return names[order[i]] < names[order[j]] })
+			b = appendLong(b, int64(len(keys)))
+			for _, i := range order {
+				b = appendBytes(b, []byte(names[i]))
+				var err error
+				if b, err = appendValue(b, s.values, v.MapIndex(keys[i])); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return appendLong(b, 0), nil
+	case kindRecord:
+		for _, f := range s.fields {
+			fv := v.Field(f.index)
+			if f.oneof != nil {
+				// Members of a oneof not set are null.
+				if fv.IsNil() || fv.Elem().Type() != f.oneof {
+					b = appendLong(b, 0)
+					continue
+				}
+				fv = fv.Elem().Elem().Field(0)
+			}
+			var err error
+			if b, err = appendValue(b, f.schema, fv); err != nil {
+				return nil, fmt.Errorf("%s: %v", f.name, err)
+			}
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("avropb: cannot write %v", v.Type())
+}
+
+// isNull reports whether the value v of an optional field is unset.
+func isNull(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// formatKey formats a map key as string.
+func formatKey(k reflect.Value) string {
+	switch k.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(k.Bool())
+	case reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10)
+	case reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(k.Uint(), 10)
+	}
+	return k.String()
+}
@@ -0,0 +1,216 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package avropb
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+
+	durpb "github.com/golang/protobuf/ptypes/duration"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	wpb "github.com/golang/protobuf/ptypes/wrappers"
+)
+
+// roundTrip writes pbs to a file and reads them back.
+func roundTrip(t *testing.T, codec Codec, blockSize int, pbs ...proto.Message) []proto.Message {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, pbs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Codec = codec
+	w.BlockSize = blockSize
+	for _, p := range pbs {
+		if err := w.Write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.ReadAll(func() proto.Message {
+		return reflect.New(reflect.TypeOf(pbs[0]).Elem()).Interface().(proto.Message)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		desc string
+		pbs  []proto.Message
+	}{
+		{"simple", []proto.Message{
+			&pb.Simple{
+				OBool: proto.Bool(true), OInt32: proto.Int32(math.MinInt32), OInt64: proto.Int64(math.MaxInt64),
+				OUint32: proto.Uint32(math.MaxUint32), OUint64: proto.Uint64(math.MaxInt64), OSint32: proto.Int32(-5),
+				OFloat: proto.Float32(1.5), ODouble: proto.Float64(math.Inf(1)), OString: proto.String("héllo"),
+				OBytes: []byte{0, 0xff},
+			},
+			&pb.Simple{},
+			&pb.Simple{OBytes: []byte{}},
+		}},
+		{"repeats", []proto.Message{
+			&pb.Repeats{
+				RBool: []bool{true, false}, RInt32: []int32{-1}, RUint64: []uint64{3}, RFloat: []float32{0.25},
+				RDouble: []float64{-2}, RString: []string{"a", ""}, RBytes: [][]byte{{1}, {}},
+			},
+			&pb.Repeats{},
+		}},
+		{"widget", []proto.Message{
+			&pb.Widget{
+				Color:    pb.Widget_BLUE.Enum(),
+				RColor:   []pb.Widget_Color{pb.Widget_RED, pb.Widget_GREEN},
+				Simple:   &pb.Simple{OInt32: proto.Int32(3)},
+				RSimple:  []*pb.Simple{{OString: proto.String("x")}, {}},
+				RRepeats: []*pb.Repeats{{RInt64: []int64{9}}},
+			},
+		}},
+		{"oneof", []proto.Message{
+			&pb.MsgWithOneof{Union: &pb.MsgWithOneof_Title{Title: "t"}},
+			&pb.MsgWithOneof{Union: &pb.MsgWithOneof_Salary{Salary: 0}},
+			&pb.MsgWithOneof{},
+		}},
+		{"maps", []proto.Message{
+			&pb.Mappy{
+				Nummy: map[int64]int32{-1: 2}, Strry: map[string]string{"a": "b", "": "c"},
+				Objjy: map[int32]*pb.Simple3{7: {Dub: 0.5}}, Booly: map[bool]bool{true: false},
+				Enumy: map[string]pb.Numeral{"x": pb.Numeral_ARABIC}, U64Booly: map[uint64]bool{math.MaxUint32 + 1: true},
+			},
+		}},
+		{"known types", []proto.Message{
+			&pb.KnownTypes{
+				Ts:  &tspb.Timestamp{Seconds: -1, Nanos: 999999000},
+				Dur: &durpb.Duration{Seconds: 3},
+				Dbl: &wpb.DoubleValue{Value: 0.5}, I64: &wpb.Int64Value{}, Str: &wpb.StringValue{Value: "s"},
+				Bytes: &wpb.BytesValue{Value: []byte("b")},
+			},
+			&pb.KnownTypes{},
+		}},
+	}
+	for _, tt := range tests {
+		for _, codec := range []Codec{Null, Deflate} {
+			out := roundTrip(t, codec, 2, tt.pbs...)
+			if len(out) != len(tt.pbs) {
+				t.Errorf("%s: read %d messages, want %d", tt.desc, len(out), len(tt.pbs))
+				continue
+			}
+			for i := range out {
+				if !proto.Equal(out[i], tt.pbs[i]) {
+					t.Errorf("%s, codec %d: message %d = %v, want %v", tt.desc, codec, i, out[i], tt.pbs[i])
+				}
+			}
+		}
+	}
+}
+
+func TestSchema(t *testing.T) {
+	got, err := Schema(new(pb.Widget))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Type   string
+		Name   string
+		Fields []struct {
+			Name string
+			Type json.RawMessage
+		}
+	}
+	if err := json.Unmarshal([]byte(got), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Type != "record" || v.Name != "jsonpb.Widget" || len(v.Fields) != 6 {
+		t.Fatalf("Schema() = %s", got)
+	}
+	wantTypes := []string{
+		`["null",{"name":"jsonpb.Widget_Color","symbols":["RED","GREEN","BLUE"],"type":"enum"}]`,
+		`{"items":"jsonpb.Widget_Color","type":"array"}`,
+	}
+	for i, want := range wantTypes {
+		if string(v.Fields[i].Type) != want {
+			t.Errorf("field %s has type %s, want %s", v.Fields[i].Name, v.Fields[i].Type, want)
+		}
+	}
+	if !strings.Contains(got, `{"name":"color","type":["null",{"name":"jsonpb.Widget_Color","symbols":["RED","GREEN","BLUE"],"type":"enum"}],"default":null}`) {
+		t.Errorf("Schema() lacks default of color: %s", got)
+	}
+	if string(v.Fields[3].Type) != `{"items":"jsonpb.Simple","type":"array"}` {
+		t.Errorf("repeated message has type %s", v.Fields[3].Type)
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, new(pb.Simple))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(new(pb.Repeats)); err == nil {
+		t.Error("Write() of other message type succeeded, want error")
+	}
+	if err := w.Write(&pb.Simple{OUint64: proto.Uint64(math.MaxUint64)}); err == nil {
+		t.Error("Write() of uint64 exceeding long succeeded, want error")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(new(pb.Simple)); err == nil {
+		t.Error("Write() after Close() succeeded, want error")
+	}
+
+	// An empty file still has a header.
+	r, err := NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Read(new(pb.Simple)); err != io.EOF {
+		t.Errorf("Read() of empty file = %v, want io.EOF", err)
+	}
+}
@@ -0,0 +1,272 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// dynamicType is a message type loaded from a descriptor set. Only scalar,
+// enum and repeated fields are supported, like by protoc-gen-csvpb.
+type dynamicType struct {
+	name   string
+	proto3 bool
+	// fields are in declaration order, ordered by number.
+	fields   []*dynamicField
+	ordered  []*dynamicField
+	byNumber map[int32]*dynamicField
+	// byName holds the fields by both their original and JSON name.
+	byName map[string]*dynamicField
+}
+
+// dynamicField is a field of a dynamicType. Its values are held as the Go
+// type of generated code, int32 for enums.
+type dynamicField struct {
+	index    int
+	name     string
+	jsonName string
+	number   int32
+	kind     descriptor.FieldDescriptorProto_Type
+	repeated bool
+	required bool
+	packed   bool
+	// enumValues maps the names of the values of an enum field to their
+	// numbers, enumNames the numbers to the first name declared.
+	enumValues map[string]int32
+	enumNames  map[int32]string
+}
+
+// loadDescriptorSet reads a FileDescriptorSet, as written by
+// protoc --descriptor_set_out, from the file at path.
+func loadDescriptorSet(path string) (*descriptor.FileDescriptorSet, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := new(descriptor.FileDescriptorSet)
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return set, nil
+}
+
+// newDynamicType returns the message type of set with the full name.
+func newDynamicType(set *descriptor.FileDescriptorSet, name string) (*dynamicType, error) {
+	enums := make(map[string]*descriptor.EnumDescriptorProto)
+	var found *descriptor.DescriptorProto
+	var proto3 bool
+	for _, f := range set.File {
+		prefix := ""
+		if f.GetPackage() != "" {
+			prefix = f.GetPackage() + "."
+		}
+		for _, e := range f.EnumType {
+			enums[prefix+e.GetName()] = e
+		}
+		if d := findMessage(f.MessageType, prefix, name, enums); d != nil {
+			found, proto3 = d, f.GetSyntax() == "proto3"
+		}
+	}
+	if found == nil {
+		return nil, fmt.Errorf("unknown message type %q in descriptor set", name)
+	}
+
+	t := &dynamicType{
+		name:     name,
+		proto3:   proto3,
+		byNumber: make(map[int32]*dynamicField),
+		byName:   make(map[string]*dynamicField),
+	}
+	for i, fd := range found.Field {
+		f := &dynamicField{
+			index:    i,
+			name:     fd.GetName(),
+			jsonName: fd.GetJsonName(),
+			number:   fd.GetNumber(),
+			kind:     fd.GetType(),
+			repeated: fd.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED,
+			required: fd.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED,
+		}
+		if f.jsonName == "" {
+			f.jsonName = jsonCamelCase(f.name)
+		}
+		if fd.OneofIndex != nil {
+			return nil, fmt.Errorf("field %s: oneofs not supported", f.name)
+		}
+
+		switch f.kind {
+		case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+			return nil, fmt.Errorf("field %s: nested messages not supported", f.name)
+		case descriptor.FieldDescriptorProto_TYPE_ENUM:
+			e := enums[strings.TrimPrefix(fd.GetTypeName(), ".")]
+			if e == nil {
+				return nil, fmt.Errorf("field %s: unknown enum %s", f.name, fd.GetTypeName())
+			}
+			f.enumValues = make(map[string]int32)
+			f.enumNames = make(map[int32]string)
+			for _, v := range e.Value {
+				f.enumValues[v.GetName()] = v.GetNumber()
+				if _, ok := f.enumNames[v.GetNumber()]; !ok {
+					f.enumNames[v.GetNumber()] = v.GetName()
+				}
+			}
+		}
+
+		if f.repeated && f.wireType() != proto.WireBytes {
+			if proto3 {
+				f.packed = fd.GetOptions() == nil || fd.GetOptions().Packed == nil || fd.GetOptions().GetPacked()
+			} else {
+				f.packed = fd.GetOptions().GetPacked()
+			}
+		}
+		t.fields = append(t.fields, f)
+		t.byNumber[f.number] = f
+		t.byName[f.name] = f
+		t.byName[f.jsonName] = f
+	}
+	t.ordered = append([]*dynamicField(nil), t.fields...)
+	sort.Slice(t.ordered, func(i, j int) bool {
+		return t.ordered[i].number < t.ordered[j].number
+	})
+	return t, nil
+}
+
+// findMessage returns the message with the full name among ds and their
+// nested messages, whose names start with prefix. Enums declared along the
+// way are added to enums.
+func findMessage(ds []*descriptor.DescriptorProto, prefix, name string, enums map[string]*descriptor.EnumDescriptorProto) *descriptor.DescriptorProto {
+	var found *descriptor.DescriptorProto
+	for _, d := range ds {
+		full := prefix + d.GetName()
+		for _, e := range d.EnumType {
+			enums[full+"."+e.GetName()] = e
+		}
+		if full == name {
+			found = d
+		}
+		if n := findMessage(d.NestedType, full+".", name, enums); n != nil {
+			found = n
+		}
+	}
+	return found
+}
+
+// jsonCamelCase converts a field name to lowerCamelCase the way protoc
+// derives json_name.
+func jsonCamelCase(s string) string {
+	var b []byte
+	upper := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+// wireType returns the wire type of a single value of f.
+func (f *dynamicField) wireType() int {
+	switch f.kind {
+	case descriptor.FieldDescriptorProto_TYPE_FIXED64, descriptor.FieldDescriptorProto_TYPE_SFIXED64,
+		descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return proto.WireFixed64
+	case descriptor.FieldDescriptorProto_TYPE_FIXED32, descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return proto.WireFixed32
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return proto.WireBytes
+	}
+	return proto.WireVarint
+}
+
+// zero returns the default value of f.
+func (f *dynamicField) zero() interface{} {
+	switch f.kind {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return false
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32, descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return int32(0)
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return int64(0)
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return uint32(0)
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return uint64(0)
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return float32(0)
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return float64(0)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return []byte{}
+	}
+	return ""
+}
+
+// isZero tells whether v is the default value of its type.
+func isZero(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return !v
+	case int32:
+		return v == 0
+	case int64:
+		return v == 0
+	case uint32:
+		return v == 0
+	case uint64:
+		return v == 0
+	case float32:
+		return v == 0
+	case float64:
+		return v == 0
+	case string:
+		return v == ""
+	case []byte:
+		return len(v) == 0
+	}
+	return v == nil
+}
@@ -0,0 +1,498 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// dynamicFactory returns the factory for the message type with the full
+// name in the descriptor set at path. The type becomes the FastCodec of
+// dynamicMessage, as csvproto processes a single type.
+func dynamicFactory(path, name string) (func() proto.Message, error) {
+	set, err := loadDescriptorSet(path)
+	if err != nil {
+		return nil, err
+	}
+	t, err := newDynamicType(set, name)
+	if err != nil {
+		return nil, err
+	}
+	csvpb.RegisterFastCodec((*dynamicMessage)(nil), t)
+	return func() proto.Message {
+		return newDynamicMessage(t)
+	}, nil
+}
+
+// dynamicMessage is a message of a dynamicType. As reflection does not
+// apply to it, it implements the interfaces proto, jsonpb and csvpb
+// consult first.
+type dynamicMessage struct {
+	typ *dynamicType
+	// values holds the value of each field, nil if unset. Repeated fields
+	// hold []interface{}.
+	values []interface{}
+	// unknown holds the encoded fields not part of typ.
+	unknown []byte
+}
+
+func newDynamicMessage(t *dynamicType) *dynamicMessage {
+	return &dynamicMessage{
+		typ:    t,
+		values: make([]interface{}, len(t.fields)),
+	}
+}
+
+func (m *dynamicMessage) Reset() {
+	m.values = make([]interface{}, len(m.typ.fields))
+	m.unknown = nil
+}
+
+func (m *dynamicMessage) String() string {
+	return proto.CompactTextString(m)
+}
+
+func (*dynamicMessage) ProtoMessage() {}
+
+// list returns the values of the repeated field f.
+func (m *dynamicMessage) list(f *dynamicField) []interface{} {
+	l, _ := m.values[f.index].([]interface{})
+	return l
+}
+
+// set sets the value of f, appending it to repeated fields.
+func (m *dynamicMessage) set(f *dynamicField, v interface{}) {
+	if f.repeated {
+		m.values[f.index] = append(m.list(f), v)
+		return
+	}
+	m.values[f.index] = v
+}
+
+// has tells whether the singular field f is set. Defaults are not in
+// proto3.
+func (m *dynamicMessage) has(f *dynamicField) bool {
+	v := m.values[f.index]
+	return v != nil && !(m.typ.proto3 && isZero(v))
+}
+
+func (m *dynamicMessage) checkRequired() error {
+	for _, f := range m.typ.fields {
+		if f.required && m.values[f.index] == nil {
+			return fmt.Errorf("required field %q is not set", f.name)
+		}
+	}
+	return nil
+}
+
+// Marshal implements proto.Marshaler.
+func (m *dynamicMessage) Marshal() ([]byte, error) {
+	if err := m.checkRequired(); err != nil {
+		return nil, err
+	}
+	var b []byte
+	for _, f := range m.typ.ordered {
+		switch {
+		case f.packed:
+			l := m.list(f)
+			if len(l) == 0 {
+				continue
+			}
+			var p []byte
+			for _, v := range l {
+				p = f.appendValue(p, v)
+			}
+			b = appendKey(b, f.number, proto.WireBytes)
+			b = append(b, proto.EncodeVarint(uint64(len(p)))...)
+			b = append(b, p...)
+		case f.repeated:
+			for _, v := range m.list(f) {
+				b = appendKey(b, f.number, f.wireType())
+				b = f.appendValue(b, v)
+			}
+		case m.has(f):
+			b = appendKey(b, f.number, f.wireType())
+			b = f.appendValue(b, m.values[f.index])
+		}
+	}
+	return append(b, m.unknown...), nil
+}
+
+// Unmarshal implements proto.Unmarshaler. Repeated fields are accepted
+// both packed and unpacked.
+func (m *dynamicMessage) Unmarshal(b []byte) error {
+	for len(b) > 0 {
+		key, n := proto.DecodeVarint(b)
+		if n == 0 {
+			return io.ErrUnexpectedEOF
+		}
+		num, wire := key>>3, int(key&7)
+		if num == 0 || num > 1<<29-1 {
+			return fmt.Errorf("invalid field number %d", num)
+		}
+		field, rest := b, b[n:]
+
+		var err error
+		switch f := m.typ.byNumber[int32(num)]; {
+		case f == nil:
+			if rest, err = skipValue(rest, wire); err == nil {
+				m.unknown = append(m.unknown, field[:len(field)-len(rest)]...)
+			}
+		case wire == proto.WireBytes && f.wireType() != proto.WireBytes && f.repeated:
+			var p []byte
+			if p, rest, err = splitBytes(rest); err != nil {
+				break
+			}
+			for len(p) > 0 && err == nil {
+				var v interface{}
+				if v, p, err = f.decodeValue(p); err == nil {
+					m.set(f, v)
+				}
+			}
+		case wire == f.wireType():
+			var v interface{}
+			if v, rest, err = f.decodeValue(rest); err == nil {
+				m.set(f, v)
+			}
+		default:
+			err = fmt.Errorf("bad wire type %d for field %s", wire, f.name)
+		}
+		if err != nil {
+			return err
+		}
+		b = rest
+	}
+	return m.checkRequired()
+}
+
+func appendKey(b []byte, num int32, wire int) []byte {
+	return append(b, proto.EncodeVarint(uint64(num)<<3|uint64(wire))...)
+}
+
+// appendValue appends the encoding of the value v of f to b.
+func (f *dynamicField) appendValue(b []byte, v interface{}) []byte {
+	switch v := v.(type) {
+	case string:
+		b = append(b, proto.EncodeVarint(uint64(len(v)))...)
+		return append(b, v...)
+	case []byte:
+		b = append(b, proto.EncodeVarint(uint64(len(v)))...)
+		return append(b, v...)
+	}
+
+	x := f.bits(v)
+	switch f.wireType() {
+	case proto.WireFixed32:
+		var p [4]byte
+		binary.LittleEndian.PutUint32(p[:], uint32(x))
+		return append(b, p[:]...)
+	case proto.WireFixed64:
+		var p [8]byte
+		binary.LittleEndian.PutUint64(p[:], x)
+		return append(b, p[:]...)
+	}
+	return append(b, proto.EncodeVarint(x)...)
+}
+
+// bits returns the numeric value v of f as encoded.
+func (f *dynamicField) bits(v interface{}) uint64 {
+	switch v := v.(type) {
+	case bool:
+		if v {
+			return 1
+		}
+	case int32:
+		if f.kind == descriptor.FieldDescriptorProto_TYPE_SINT32 {
+			return uint64(uint32(v<<1) ^ uint32(v>>31))
+		}
+		return uint64(int64(v))
+	case int64:
+		if f.kind == descriptor.FieldDescriptorProto_TYPE_SINT64 {
+			return uint64(v<<1) ^ uint64(v>>63)
+		}
+		return uint64(v)
+	case uint32:
+		return uint64(v)
+	case uint64:
+		return v
+	case float32:
+		return uint64(math.Float32bits(v))
+	case float64:
+		return math.Float64bits(v)
+	}
+	return 0
+}
+
+// decodeValue decodes a single value of f from the start of b, returning
+// the rest of b.
+func (f *dynamicField) decodeValue(b []byte) (interface{}, []byte, error) {
+	var x uint64
+	switch f.wireType() {
+	case proto.WireVarint:
+		var n int
+		if x, n = proto.DecodeVarint(b); n == 0 {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		b = b[n:]
+	case proto.WireFixed32:
+		if len(b) < 4 {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		x, b = uint64(binary.LittleEndian.Uint32(b)), b[4:]
+	case proto.WireFixed64:
+		if len(b) < 8 {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		x, b = binary.LittleEndian.Uint64(b), b[8:]
+	default:
+		p, rest, err := splitBytes(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		if f.kind == descriptor.FieldDescriptorProto_TYPE_STRING {
+			return string(p), rest, nil
+		}
+		return append([]byte{}, p...), rest, nil
+	}
+
+	switch f.kind {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return x != 0, b, nil
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32,
+		descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return int32(x), b, nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT32:
+		return int32(uint32(x)>>1) ^ -int32(x&1), b, nil
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return int64(x), b, nil
+	case descriptor.FieldDescriptorProto_TYPE_SINT64:
+		return int64(x>>1) ^ -int64(x&1), b, nil
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return uint32(x), b, nil
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return math.Float32frombits(uint32(x)), b, nil
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return math.Float64frombits(x), b, nil
+	}
+	return x, b, nil
+}
+
+// splitBytes splits a length-delimited value from the start of b.
+func splitBytes(b []byte) ([]byte, []byte, error) {
+	l, n := proto.DecodeVarint(b)
+	if n == 0 || l > uint64(len(b)-n) {
+		return nil, nil, io.ErrUnexpectedEOF
+	}
+	return b[n : n+int(l)], b[n+int(l):], nil
+}
+
+// skipValue returns b without the value of wire type at its start.
+func skipValue(b []byte, wire int) ([]byte, error) {
+	switch wire {
+	case proto.WireVarint:
+		_, n := proto.DecodeVarint(b)
+		if n == 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return b[n:], nil
+	case proto.WireFixed32, proto.WireFixed64:
+		size := 4
+		if wire == proto.WireFixed64 {
+			size = 8
+		}
+		if len(b) < size {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return b[size:], nil
+	case proto.WireBytes:
+		_, rest, err := splitBytes(b)
+		return rest, err
+	}
+	return nil, fmt.Errorf("unsupported wire type %d", wire)
+}
+
+// Header implements csvpb.FastCodec, naming columns by the JSON names of
+// the fields like generated codecs. Options of the Marshaler but Dialect
+// are ignored.
+func (t *dynamicType) Header(m *csvpb.Marshaler) ([]string, error) {
+	header := make([]string, len(t.fields))
+	for i, f := range t.fields {
+		header[i] = f.jsonName
+	}
+	return header, nil
+}
+
+// MarshalRecord implements csvpb.FastCodec.
+func (t *dynamicType) MarshalRecord(m *csvpb.Marshaler, pb proto.Message) ([]string, error) {
+	dm, ok := pb.(*dynamicMessage)
+	if !ok {
+		return nil, csvpb.ErrNoFastPath
+	}
+	if err := dm.checkRequired(); err != nil {
+		return nil, err
+	}
+	record := make([]string, len(dm.typ.fields))
+	for i, f := range dm.typ.fields {
+		var err error
+		switch v := dm.values[i]; {
+		case f.repeated:
+			l := dm.list(f)
+			cells := make([]string, len(l))
+			for j, v := range l {
+				cells[j] = f.formatCell(m, v)
+			}
+			record[i], err = csvpb.JoinList(cells)
+		case v != nil:
+			record[i] = f.formatCell(m, v)
+		case dm.typ.proto3 || f.kind == descriptor.FieldDescriptorProto_TYPE_BYTES:
+			// Like generated code, which has no pointers for them
+			record[i] = f.formatCell(m, f.zero())
+		case m.Dialect != nil:
+			record[i] = m.Dialect.Null
+		default:
+			record[i] = "null"
+		}
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %v", f.jsonName, err)
+		}
+	}
+	return record, nil
+}
+
+// formatCell formats the value v of f as a cell.
+func (f *dynamicField) formatCell(m *csvpb.Marshaler, v interface{}) string {
+	switch v := v.(type) {
+	case bool:
+		if m.Dialect == nil {
+			return strconv.FormatBool(v)
+		}
+		if v {
+			return m.Dialect.True
+		}
+		return m.Dialect.False
+	case int32:
+		if name, ok := f.enumNames[v]; ok {
+			return name
+		}
+	case float32:
+		return csvpb.FormatFloat(float64(v), 32)
+	case float64:
+		return csvpb.FormatFloat(v, 64)
+	case []byte:
+		return base64.StdEncoding.EncodeToString(v)
+	case string:
+		if m.Dialect != nil && m.Dialect.Newline != "" {
+			n := m.Dialect.Newline
+			return strings.NewReplacer("\r\n", n, "\n", n, "\r", n).Replace(v)
+		}
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// UnmarshalRecord implements csvpb.FastCodec. Columns may be named by
+// either the original or the JSON name of a field. Options of the
+// Unmarshaler but Header are ignored.
+func (t *dynamicType) UnmarshalRecord(u *csvpb.Unmarshaler, record []string, pb proto.Message) error {
+	dm, ok := pb.(*dynamicMessage)
+	if !ok {
+		return csvpb.ErrNoFastPath
+	}
+	if len(u.Header) != len(record) {
+		return csv.ErrFieldCount
+	}
+	dm.Reset()
+	for i, name := range u.Header {
+		f := dm.typ.byName[name]
+		if f == nil {
+			return fmt.Errorf("unknown field %q in %s", name, dm.typ.name)
+		}
+		cells := []string{record[i]}
+		if f.repeated {
+			var err error
+			if cells, err = csvpb.SplitList(record[i]); err != nil {
+				return fmt.Errorf("column %q: %v", name, err)
+			}
+		} else if record[i] == "null" && f.kind != descriptor.FieldDescriptorProto_TYPE_BYTES {
+			continue
+		}
+		for _, cell := range cells {
+			v, err := f.parseCell(cell)
+			if err != nil {
+				return fmt.Errorf("column %q: %v", name, err)
+			}
+			dm.set(f, v)
+		}
+	}
+	return dm.checkRequired()
+}
+
+// parseCell parses a cell holding a value of f.
+func (f *dynamicField) parseCell(cell string) (interface{}, error) {
+	switch f.kind {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return csvpb.ParseBool(cell)
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		n, err := csvpb.ParseInt(cell, 32)
+		return int32(n), err
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return csvpb.ParseInt(cell, 64)
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		n, err := csvpb.ParseUint(cell, 32)
+		return uint32(n), err
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return csvpb.ParseUint(cell, 64)
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		x, err := csvpb.ParseFloat(cell, 32)
+		return float32(x), err
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return csvpb.ParseFloat(cell, 64)
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return csvpb.ParseBytes(cell)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return csvpb.ParseEnum(cell, f.enumValues)
+	}
+	return cell, nil
+}
@@ -0,0 +1,240 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// writeDescriptorSet writes the descriptor set of files to dir, returning
+// its path.
+func writeDescriptorSet(t *testing.T, dir string, files ...*descriptor.FileDescriptorProto) string {
+	b, err := proto.Marshal(&descriptor.FileDescriptorSet{File: files})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "set.pb")
+	if err := ioutil.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// registeredFile returns the descriptor of a file compiled into the binary.
+func registeredFile(t *testing.T, name string) *descriptor.FileDescriptorProto {
+	r, err := gzip.NewReader(bytes.NewReader(proto.FileDescriptor(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := new(descriptor.FileDescriptorProto)
+	if err := proto.Unmarshal(b, fd); err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+var dynamicTests = []struct {
+	desc  string
+	args  []string
+	stdin string
+}{
+	{"csv to json", []string{"convert", "-type", "jsonpb.Simple"}, simpleCSV},
+	{"csv to text", []string{"convert", "-type", "jsonpb.Simple", "-to", "text"}, simpleCSV},
+	{"csv to binary", []string{"convert", "-type", "jsonpb.Simple", "-to", "binary"}, simpleCSV},
+	{"lists", []string{"convert", "-type", "jsonpb.Repeats", "-to", "binary"},
+		"rBool,rInt32,rSint64,rUint64,rFloat,rString,rBytes\n\"true,false\",\"1,-2\",-3,\"4,5\",1.5,\"a,b\",AQI=\n"},
+	{"json to csv", []string{"convert", "-type", "jsonpb.Simple", "-from", "json", "-to", "csv"},
+		`{"oBool":true,"oInt32":-1,"oInt64":"-2","oUint32":3,"oUint64":"4","oSint32":-5,"oSint64":"-6","oFloat":1.5,"oDouble":"NaN","oString":"a\nb","oBytes":"AQI="}` + "\n"},
+	{"json to text", []string{"convert", "-type", "jsonpb.Repeats", "-from", "json", "-to", "text"},
+		`{"rInt64":["1","-2"],"r_string":["x\u0000y"],"rDouble":[0.5]}` + "\n"},
+	{"text to json", []string{"convert", "-type", "jsonpb.Simple", "-from", "text"},
+		"o_int32: 5 o_string: 'x\\\"y' o_bytes: \"\\001\\377\" o_float: -inf o_uint64: 0x10\n"},
+	{"text lists", []string{"convert", "-type", "jsonpb.Repeats", "-from", "text", "-to", "csv"},
+		"r_int32: [1, -2]; r_int32: 3 r_string: [\"a\", 'b'] # comment\n"},
+	{"bigquery csv", []string{"convert", "-type", "jsonpb.Simple", "-from", "text", "-to", "csv", "-dialect", "bigquery"},
+		"o_bool: true o_string: \"a\\nb\"\n"},
+	{"proto3", []string{"convert", "-type", "jsonpb.Simple3"}, "dub\n0\n1.5\n"},
+	{"filter", []string{"convert", "-type", "jsonpb.Simple", "-filter", `oString != "foo"`}, simpleCSV},
+	{"head", []string{"head", "-type", "jsonpb.Simple", "-n", "1"}, simpleCSV},
+	{"validate", []string{"validate", "-type", "jsonpb.Simple"}, simpleCSV},
+}
+
+func TestDynamicRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvproto_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	set := writeDescriptorSet(t, dir, registeredFile(t, "test_objects.proto"), registeredFile(t, "more_test_objects.proto"))
+
+	for _, tt := range dynamicTests {
+		var want, got, stderr bytes.Buffer
+		if code := run(tt.args, strings.NewReader(tt.stdin), &want, &stderr); code != 0 {
+			t.Fatalf("%s: %s", tt.desc, stderr.String())
+		}
+		args := append([]string{tt.args[0], "-descriptor_set", set}, tt.args[1:]...)
+		if code := run(args, strings.NewReader(tt.stdin), &got, &stderr); code != 0 {
+			t.Errorf("%s: got exit code %d: %s", tt.desc, code, stderr.String())
+			continue
+		}
+		if got.String() != want.String() {
+			t.Errorf("%s: got [%s] want [%s]", tt.desc, got.String(), want.String())
+		}
+	}
+}
+
+func TestDynamicBinaryRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvproto_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	set := writeDescriptorSet(t, dir, registeredFile(t, "test_objects.proto"))
+
+	input := "oBool,oInt32,oInt64,oUint32,oUint64,oSint32,oSint64,oFloat,oDouble,oString,oBytes\n" +
+		"true,-1,-2,3,4,-5,-6,1.5,-Infinity,\"a,b\",AQI=\n" +
+		"null,null,null,null,null,null,null,null,null,null,\n"
+	var binary, stderr bytes.Buffer
+	if code := run([]string{"convert", "-type", "jsonpb.Simple", "-to", "binary"}, strings.NewReader(input), &binary, &stderr); code != 0 {
+		t.Fatal(stderr.String())
+	}
+
+	var out bytes.Buffer
+	args := []string{"convert", "-descriptor_set", set, "-type", "jsonpb.Simple", "-from", "binary", "-to", "csv"}
+	if code := run(args, &binary, &out, &stderr); code != 0 {
+		t.Fatal(stderr.String())
+	}
+	want := "oBool,oInt32,oInt32Str,oInt64,oInt64Str,oUint32,oUint32Str,oUint64,oUint64Str,oSint32,oSint32Str,oSint64,oSint64Str,oFloat,oFloatStr,oDouble,oDoubleStr,oString,oBytes\n" +
+		"true,-1,null,-2,null,3,null,4,null,-5,null,-6,null,1.5,null,-Infinity,null,\"a,b\",AQI=\n" +
+		"null,null,null,null,null,null,null,null,null,null,null,null,null,null,null,null,null,null,\n"
+	if out.String() != want {
+		t.Errorf("got [%s] want [%s]", out.String(), want)
+	}
+}
+
+// orderFile declares a message with enums, required and packed fields.
+var orderFile = &descriptor.FileDescriptorProto{
+	Name:    proto.String("order.proto"),
+	Package: proto.String("shop"),
+	EnumType: []*descriptor.EnumDescriptorProto{{
+		Name: proto.String("Status"),
+		Value: []*descriptor.EnumValueDescriptorProto{
+			{Name: proto.String("OPEN"), Number: proto.Int32(0)},
+			{Name: proto.String("PAID"), Number: proto.Int32(1)},
+		},
+	}},
+	MessageType: []*descriptor.DescriptorProto{{
+		Name: proto.String("Order"),
+		Field: []*descriptor.FieldDescriptorProto{
+			{Name: proto.String("order_id"), Number: proto.Int32(1), Label: descriptor.FieldDescriptorProto_LABEL_REQUIRED.Enum(),
+				Type: descriptor.FieldDescriptorProto_TYPE_FIXED64.Enum()},
+			{Name: proto.String("status"), Number: proto.Int32(2), Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type: descriptor.FieldDescriptorProto_TYPE_ENUM.Enum(), TypeName: proto.String(".shop.Status")},
+			{Name: proto.String("quantities"), Number: proto.Int32(3), Label: descriptor.FieldDescriptorProto_LABEL_REPEATED.Enum(),
+				Type: descriptor.FieldDescriptorProto_TYPE_SFIXED32.Enum(), Options: &descriptor.FieldOptions{Packed: proto.Bool(true)}},
+		},
+		NestedType: []*descriptor.DescriptorProto{{
+			Name: proto.String("Line"),
+			Field: []*descriptor.FieldDescriptorProto{
+				{Name: proto.String("kind"), Number: proto.Int32(1), Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type: descriptor.FieldDescriptorProto_TYPE_ENUM.Enum(), TypeName: proto.String(".shop.Order.Line.Kind")},
+				{Name: proto.String("order"), Number: proto.Int32(2), Label: descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type: descriptor.FieldDescriptorProto_TYPE_MESSAGE.Enum(), TypeName: proto.String(".shop.Order")},
+			},
+			EnumType: []*descriptor.EnumDescriptorProto{{
+				Name:  proto.String("Kind"),
+				Value: []*descriptor.EnumValueDescriptorProto{{Name: proto.String("ITEM"), Number: proto.Int32(0)}},
+			}},
+		}},
+	}},
+}
+
+func TestDynamicType(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvproto_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	set := writeDescriptorSet(t, dir, orderFile)
+
+	tests := []struct {
+		desc  string
+		args  []string
+		stdin string
+		code  int
+		out   string
+	}{
+		{"csv to json", []string{"convert", "-type", "shop.Order"}, "orderId,status,quantities\n1,PAID,\"1,-2\"\n2,null,\n", 0,
+			"{\"orderId\":\"1\",\"status\":\"PAID\",\"quantities\":[1,-2]}\n{\"orderId\":\"2\"}\n"},
+		{"json to text", []string{"convert", "-type", "shop.Order", "-from", "json", "-to", "text"},
+			"{\"order_id\":\"3\",\"status\":1,\"quantities\":[]}\n", 0, "order_id:3 status:PAID \n"},
+		{"binary", []string{"convert", "-type", "shop.Order", "-from", "text", "-to", "binary"},
+			"order_id: 1 quantities: [1, -1]\n", 0,
+			"\x13\x09\x01\x00\x00\x00\x00\x00\x00\x00\x1a\x08\x01\x00\x00\x00\xff\xff\xff\xff"},
+		{"missing required", []string{"convert", "-type", "shop.Order"}, "status\nOPEN\n", 1, ""},
+		{"unknown column", []string{"convert", "-type", "shop.Order"}, "orderId,price\n1,2\n", 1, ""},
+		{"unknown enum value", []string{"validate", "-type", "shop.Order"}, "orderId,status\n1,LOST\n", 1,
+			"row 2: column \"status\": unknown enum value \"LOST\"\n1 rows, 1 invalid\n"},
+		{"nested message", []string{"head", "-type", "shop.Order.Line"}, "kind\nITEM\n", 1, ""},
+		{"unknown type", []string{"head", "-type", "shop.Invoice"}, "orderId\n1\n", 1, ""},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		args := append([]string{tt.args[0], "-descriptor_set", set}, tt.args[1:]...)
+		code := run(args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("%s: got exit code %d, expected %d: %s", tt.desc, code, tt.code, stderr.String())
+			continue
+		}
+		if stdout.String() != tt.out {
+			t.Errorf("%s: got [%q] want [%q]", tt.desc, stdout.String(), tt.out)
+		}
+	}
+
+	var stderr bytes.Buffer
+	if code := run([]string{"head", "-descriptor_set", filepath.Join(dir, "missing.pb"), "-type", "shop.Order"}, strings.NewReader(""), ioutil.Discard, &stderr); code != 1 {
+		t.Errorf("missing descriptor set: got exit code %d, expected 1", code)
+	}
+}
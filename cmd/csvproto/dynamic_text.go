@@ -0,0 +1,502 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+)
+
+// MarshalText implements encoding.TextMarshaler, writing the compact text
+// format like proto.CompactTextString does for generated messages.
+func (m *dynamicMessage) MarshalText() ([]byte, error) {
+	var b []byte
+	for _, f := range m.typ.fields {
+		var values []interface{}
+		switch {
+		case f.repeated:
+			values = m.list(f)
+		case m.has(f):
+			values = []interface{}{m.values[f.index]}
+		}
+		for _, v := range values {
+			b = append(b, f.name...)
+			b = append(b, ':')
+			b = f.appendText(b, v)
+			b = append(b, ' ')
+		}
+	}
+	return b, nil
+}
+
+// appendText appends the value v of f in the text format to b.
+func (f *dynamicField) appendText(b []byte, v interface{}) []byte {
+	var x float64
+	switch v := v.(type) {
+	case string:
+		return appendQuoted(b, v)
+	case []byte:
+		return appendQuoted(b, string(v))
+	case int32:
+		if name, ok := f.enumNames[v]; ok {
+			return append(b, name...)
+		}
+	case float32:
+		x = float64(v)
+	case float64:
+		x = v
+	}
+	switch {
+	case math.IsInf(x, 1):
+		return append(b, "inf"...)
+	case math.IsInf(x, -1):
+		return append(b, "-inf"...)
+	case math.IsNaN(x):
+		return append(b, "nan"...)
+	}
+	return append(b, fmt.Sprint(v)...)
+}
+
+// appendQuoted appends s quoted with C-style escapes to b.
+func appendQuoted(b []byte, s string) []byte {
+	b = append(b, '"')
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\n':
+			b = append(b, `\n`...)
+		case '\r':
+			b = append(b, `\r`...)
+		case '\t':
+			b = append(b, `\t`...)
+		case '"':
+			b = append(b, `\"`...)
+		case '\\':
+			b = append(b, `\\`...)
+		default:
+			if c >= 0x20 && c < 0x7f {
+				b = append(b, c)
+			} else {
+				b = append(b, fmt.Sprintf(`\%03o`, c)...)
+			}
+		}
+	}
+	return append(b, '"')
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler for the text format of
+// scalar fields. Repeated fields may be given as a list.
+func (m *dynamicMessage) UnmarshalText(text []byte) error {
+	m.Reset()
+	s := &textScanner{s: string(text)}
+	for {
+		name, err := s.next()
+		if err != nil {
+			return err
+		}
+		if name == "" {
+			return m.checkRequired()
+		}
+		f := m.typ.byName[name]
+		if f == nil || f.name != name {
+			return fmt.Errorf("unknown field name %q in %s", name, m.typ.name)
+		}
+		tok, err := s.next()
+		if tok == ":" {
+			tok, err = s.next()
+		}
+		if err != nil {
+			return err
+		}
+
+		if tok == "[" && f.repeated {
+			if err := m.unmarshalTextList(s, f); err != nil {
+				return err
+			}
+		} else {
+			v, err := f.parseText(tok)
+			if err != nil {
+				return fmt.Errorf("field %s: %v", name, err)
+			}
+			m.set(f, v)
+		}
+		if tok, _ := s.peek(); tok == ";" || tok == "," {
+			s.next()
+		}
+	}
+}
+
+// unmarshalTextList reads the values of f up to the end of a list.
+func (m *dynamicMessage) unmarshalTextList(s *textScanner, f *dynamicField) error {
+	for i := 0; ; i++ {
+		tok, err := s.next()
+		if err != nil {
+			return err
+		}
+		if tok == "]" && i == 0 {
+			return nil
+		}
+		v, err := f.parseText(tok)
+		if err != nil {
+			return fmt.Errorf("field %s: %v", f.name, err)
+		}
+		m.set(f, v)
+
+		switch tok, err := s.next(); {
+		case err != nil:
+			return err
+		case tok == "]":
+			return nil
+		case tok != ",":
+			return fmt.Errorf("field %s: expected ',' or ']', found %q", f.name, tok)
+		}
+	}
+}
+
+// parseText parses the token tok holding a value of f.
+func (f *dynamicField) parseText(tok string) (interface{}, error) {
+	switch f.kind {
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		if tok == "" || tok[0] != '"' && tok[0] != '\'' {
+			return nil, fmt.Errorf("expected string, found %q", tok)
+		}
+		s, err := unquoteC(tok)
+		if err != nil {
+			return nil, err
+		}
+		if f.kind == descriptor.FieldDescriptorProto_TYPE_BYTES {
+			return []byte(s), nil
+		}
+		return s, nil
+	}
+	return f.parseScalar(tok, 0)
+}
+
+// parseScalar parses s holding a number, bool or enum value of f, with
+// integers in base.
+func (f *dynamicField) parseScalar(s string, base int) (interface{}, error) {
+	switch f.kind {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return strconv.ParseBool(s)
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		if n, ok := f.enumValues[s]; ok {
+			return n, nil
+		}
+		fallthrough
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		n, err := strconv.ParseInt(s, base, 32)
+		return int32(n), err
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64,
+		descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return strconv.ParseInt(s, base, 64)
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		n, err := strconv.ParseUint(s, base, 32)
+		return uint32(n), err
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return strconv.ParseUint(s, base, 64)
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		x, err := strconv.ParseFloat(s, 32)
+		return float32(x), err
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return strconv.ParseFloat(s, 64)
+	}
+	return nil, fmt.Errorf("unexpected %q", s)
+}
+
+// unquoteC unquotes a string of the text format, quoted with either single
+// or double quotes and C-style escapes.
+func unquoteC(tok string) (string, error) {
+	s := tok[1 : len(tok)-1]
+	var b []byte
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b = append(b, s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return "", fmt.Errorf("invalid escape in %s", tok)
+		}
+		switch c := s[i]; c {
+		case 'a':
+			b = append(b, '\a')
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'v':
+			b = append(b, '\v')
+		case '\\', '\'', '"', '?':
+			b = append(b, c)
+		case '0', '1', '2', '3', '4', '5', '6', '7', 'x', 'X':
+			base, digits, max, start := 8, "01234567", 3, i
+			if c == 'x' || c == 'X' {
+				base, digits, max, start = 16, "0123456789abcdefABCDEF", 2, i+1
+			}
+			end := start
+			for end < len(s) && end-start < max && strings.IndexByte(digits, s[end]) >= 0 {
+				end++
+			}
+			n, err := strconv.ParseUint(s[start:end], base, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid escape in %s", tok)
+			}
+			b = append(b, byte(n))
+			i = end - 1
+		default:
+			return "", fmt.Errorf("invalid escape in %s", tok)
+		}
+	}
+	return string(b), nil
+}
+
+var errUnterminated = errors.New("unterminated string")
+
+// textScanner splits the text format into tokens.
+type textScanner struct {
+	s      string
+	peeked *string
+}
+
+// next returns the next token, or an empty one at the end of the input.
+func (s *textScanner) next() (string, error) {
+	if s.peeked != nil {
+		tok := *s.peeked
+		s.peeked = nil
+		return tok, nil
+	}
+	for {
+		s.s = strings.TrimLeft(s.s, " \t\r\n")
+		if !strings.HasPrefix(s.s, "#") {
+			break
+		}
+		if i := strings.IndexByte(s.s, '\n'); i >= 0 {
+			s.s = s.s[i:]
+		} else {
+			s.s = ""
+		}
+	}
+	if s.s == "" {
+		return "", nil
+	}
+
+	n := 1
+	switch c := s.s[0]; c {
+	case ':', ';', ',', '[', ']', '{', '}', '<', '>':
+	case '"', '\'':
+		for ; n < len(s.s) && s.s[n] != c; n++ {
+			if s.s[n] == '\\' {
+				n++
+			}
+		}
+		if n >= len(s.s) {
+			return "", errUnterminated
+		}
+		n++
+	default:
+		n = strings.IndexAny(s.s, " \t\r\n#:;,[]{}<>\"'")
+		if n < 0 {
+			n = len(s.s)
+		}
+	}
+	tok := s.s[:n]
+	s.s = s.s[n:]
+	return tok, nil
+}
+
+// peek returns the next token without consuming it.
+func (s *textScanner) peek() (string, error) {
+	tok, err := s.next()
+	if err == nil {
+		s.peeked = &tok
+	}
+	return tok, err
+}
+
+// MarshalJSONPB implements jsonpb.JSONPBMarshaler.
+func (m *dynamicMessage) MarshalJSONPB(jm *jsonpb.Marshaler) ([]byte, error) {
+	if err := m.checkRequired(); err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for _, f := range m.typ.fields {
+		v := m.values[f.index]
+		var value []byte
+		var err error
+		switch {
+		case f.repeated:
+			l := m.list(f)
+			if len(l) == 0 && !jm.EmitDefaults {
+				continue
+			}
+			values := make([]json.RawMessage, len(l))
+			for i, v := range l {
+				if values[i], err = f.marshalJSONValue(jm, v); err != nil {
+					break
+				}
+			}
+			if err == nil {
+				value, err = json.Marshal(values)
+			}
+		case m.has(f):
+			value, err = f.marshalJSONValue(jm, v)
+		case !jm.EmitDefaults:
+			continue
+		case v == nil && !m.typ.proto3:
+			value = []byte("null")
+		default:
+			value, err = f.marshalJSONValue(jm, f.zero())
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", f.name, err)
+		}
+
+		name := f.jsonName
+		if jm.OrigName {
+			name = f.name
+		}
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// marshalJSONValue returns the JSON of the value v of f.
+func (f *dynamicField) marshalJSONValue(jm *jsonpb.Marshaler, v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case int32:
+		if name, ok := f.enumNames[v]; ok && !jm.EnumsAsInts {
+			return json.Marshal(name)
+		}
+	case int64, uint64:
+		return json.Marshal(fmt.Sprint(v))
+	case float32:
+		if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+			return json.Marshal(csvpb.FormatFloat(float64(v), 32))
+		}
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return json.Marshal(csvpb.FormatFloat(v, 64))
+		}
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSONPB implements jsonpb.JSONPBUnmarshaler. Fields may be named
+// by either their original or JSON name.
+func (m *dynamicMessage) UnmarshalJSONPB(ju *jsonpb.Unmarshaler, b []byte) error {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(b, &object); err != nil {
+		return err
+	}
+	for name, raw := range object {
+		f := m.typ.byName[name]
+		if f == nil {
+			if ju.AllowUnknownFields {
+				continue
+			}
+			return fmt.Errorf("unknown field %q in %s", name, m.typ.name)
+		}
+		if string(raw) == "null" {
+			continue
+		}
+
+		values := []json.RawMessage{raw}
+		if f.repeated {
+			if err := json.Unmarshal(raw, &values); err != nil {
+				return fmt.Errorf("field %s: %v", name, err)
+			}
+		}
+		for _, raw := range values {
+			v, err := f.unmarshalJSONValue(raw)
+			if err != nil {
+				return fmt.Errorf("field %s: %v", name, err)
+			}
+			m.set(f, v)
+		}
+	}
+	return m.checkRequired()
+}
+
+// unmarshalJSONValue parses the JSON of a value of f. Numbers may be
+// quoted.
+func (f *dynamicField) unmarshalJSONValue(raw json.RawMessage) (interface{}, error) {
+	switch f.kind {
+	case descriptor.FieldDescriptorProto_TYPE_STRING, descriptor.FieldDescriptorProto_TYPE_BYTES:
+		var s string
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+		if f.kind == descriptor.FieldDescriptorProto_TYPE_STRING {
+			return s, nil
+		}
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			b, err = base64.URLEncoding.DecodeString(s)
+		}
+		return b, err
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		var b bool
+		err := json.Unmarshal(raw, &b)
+		return b, err
+	}
+	s := string(raw)
+	if len(s) >= 2 && s[0] == '"' {
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return nil, err
+		}
+	}
+	return f.parseScalar(s, 10)
+}
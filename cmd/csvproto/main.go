@@ -0,0 +1,394 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Command csvproto converts between CSV and other encodings of protocol
+buffers.
+
+Usage:
+
+	csvproto convert -type NAME [-descriptor_set FILE] [-from FORMAT] [-to FORMAT] [-dialect DIALECT] [-filter EXPR] [-summary] [FILE]
+	csvproto validate -type NAME [-descriptor_set FILE] [FILE]
+	csvproto head -type NAME [-descriptor_set FILE] [-n N] [FILE]
+
+FORMAT is one of csv, binary, json or text. binary is a stream of varint
+length-delimited messages, json and text hold one message per line. CSV
+input starts with a header record. CSV output can be adapted to a loader
+with DIALECT being bigquery or redshift. CSV input can be filtered by
+EXPR, a predicate over the cells of each record in a subset of CEL, like
+'amount >= 100 && status == "active"'.
+
+Message types are looked up by their full name in the descriptor set
+given by -descriptor_set, as written by protoc --descriptor_set_out. Such
+types are limited to scalar, enum and repeated fields and converted like
+by protoc-gen-csvpb. Without a descriptor set, types are looked up in the
+protobuf registry of the binary, which holds messages of packages
+imported by csvproto.
+*/
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+)
+
+const usage = `usage:
+	csvproto convert -type NAME [-descriptor_set FILE] [-from FORMAT] [-to FORMAT] [-dialect DIALECT] [-filter EXPR] [-summary] [FILE]
+	csvproto validate -type NAME [-descriptor_set FILE] [FILE]
+	csvproto head -type NAME [-descriptor_set FILE] [-n N] [FILE]
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	var err error
+	switch args[0] {
+	case "convert":
+		err = convert(args[1:], stdin, stdout, stderr)
+	case "validate":
+		err = validate(args[1:], stdin, stdout, stderr)
+	case "head":
+		err = head(args[1:], stdin, stdout, stderr)
+	default:
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	if err == flag.ErrHelp {
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "csvproto: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// command holds the flags shared by all subcommands.
+type command struct {
+	flags         *flag.FlagSet
+	typeName      string
+	descriptorSet string
+}
+
+func newCommand(name string, stderr io.Writer) *command {
+	c := &command{
+		flags: flag.NewFlagSet(name, flag.ContinueOnError),
+	}
+	c.flags.SetOutput(stderr)
+	c.flags.StringVar(&c.typeName, "type", "", "full name of the message type")
+	c.flags.StringVar(&c.descriptorSet, "descriptor_set", "", "FileDescriptorSet to look up the message type in")
+	return c
+}
+
+// parse parses args and returns the factory for the message type along
+// with the input to read.
+func (c *command) parse(args []string, stdin io.Reader) (func() proto.Message, io.ReadCloser, error) {
+	if err := c.flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	factory, err := messageFactory(c.typeName, c.descriptorSet)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch c.flags.NArg() {
+	case 0:
+		return factory, nopCloser{stdin}, nil
+	case 1:
+		f, err := os.Open(c.flags.Arg(0))
+		if err != nil {
+			return nil, nil, err
+		}
+		return factory, f, nil
+	}
+	return nil, nil, errors.New("at most one input file allowed")
+}
+
+type nopCloser struct {
+	io.Reader
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// messageFactory returns the factory for the message type with the full
+// name, looked up in the descriptor set at path unless empty.
+func messageFactory(name, path string) (func() proto.Message, error) {
+	if name == "" {
+		return nil, errors.New("-type is required")
+	}
+	if path != "" {
+		return dynamicFactory(path, name)
+	}
+	t := proto.MessageType(name)
+	if t == nil {
+		return nil, fmt.Errorf("unknown message type %q", name)
+	}
+	return func() proto.Message {
+		return reflect.New(t.Elem()).Interface().(proto.Message)
+	}, nil
+}
+
+func convert(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	c := newCommand("convert", stderr)
+	from := c.flags.String("from", "csv", "input format")
+	to := c.flags.String("to", "json", "output format")
+	dialect := c.flags.String("dialect", "", "dialect of CSV output")
+	filter := c.flags.String("filter", "", "predicate selecting records of CSV input")
+	summary := c.flags.Bool("summary", false, "report processed rows of CSV input")
+	factory, r, err := c.parse(args, stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	u := new(csvpb.Unmarshaler)
+	if *filter != "" {
+		if *from != "csv" {
+			return fmt.Errorf("filter not supported for %s", *from)
+		}
+		if u.RecordFilter, err = csvpb.CompileRecordFilter(*filter); err != nil {
+			return err
+		}
+	}
+
+	var m csvpb.Marshaler
+	if *dialect != "" {
+		if m.Dialect = dialects[*dialect]; m.Dialect == nil || *to != "csv" {
+			return fmt.Errorf("dialect %q not supported for %s", *dialect, *to)
+		}
+	}
+
+	w := bufio.NewWriter(stdout)
+	write, flush, err := messageWriter(*to, w, &m)
+	if err != nil {
+		return err
+	}
+
+	if *from == "csv" {
+		s, err := u.UnmarshalEach(r, factory, write)
+		if *summary {
+			fmt.Fprintf(stderr, "rows read: %d, decoded: %d, skipped: %d, filtered: %d, bytes: %d\n",
+				s.RowsRead, s.RowsDecoded, s.RowsSkipped, s.RowsFiltered, s.BytesConsumed)
+		}
+		if err != nil {
+			return err
+		}
+	} else if err := readMessages(*from, r, factory, write); err != nil {
+		return err
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+func validate(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	c := newCommand("validate", stderr)
+	factory, r, err := c.parse(args, stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	dec := csvpb.NewDecoder(r)
+	if !dec.More() {
+		return dec.Err()
+	}
+	header, err := dec.Decode()
+	if err != nil {
+		return err
+	}
+
+	u := &csvpb.Unmarshaler{Header: header}
+	rows, invalid := 0, 0
+	for dec.More() {
+		rows++
+		if err := u.UnmarshalNext(dec, factory()); err != nil {
+			invalid++
+			// Rows are counted including the header
+			fmt.Fprintf(stdout, "row %d: %v\n", rows+1, err)
+		}
+	}
+	if err := dec.Err(); err != nil {
+		// Malformed CSV ends validation
+		rows++
+		invalid++
+		fmt.Fprintf(stdout, "row %d: %v\n", rows+1, err)
+	}
+	fmt.Fprintf(stdout, "%d rows, %d invalid\n", rows, invalid)
+	if invalid > 0 {
+		return fmt.Errorf("%d invalid rows", invalid)
+	}
+	return nil
+}
+
+// errHeadDone stops reading once enough rows are printed.
+var errHeadDone = errors.New("head done")
+
+func head(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	c := newCommand("head", stderr)
+	n := c.flags.Int("n", 10, "number of rows to print")
+	factory, r, err := c.parse(args, stdin)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if *n <= 0 {
+		return nil
+	}
+	printed := 0
+	_, err = new(csvpb.Unmarshaler).UnmarshalEach(r, factory, func(pb proto.Message) error {
+		fmt.Fprintln(stdout, proto.CompactTextString(pb))
+		printed++
+		if printed == *n {
+			return errHeadDone
+		}
+		return nil
+	})
+	if err == errHeadDone {
+		return nil
+	}
+	return err
+}
+
+// dialects are the dialects of CSV output by name.
+var dialects = map[string]*csvpb.Dialect{
+	"bigquery": csvpb.BigQuery,
+	"redshift": csvpb.Redshift,
+}
+
+// messageWriter returns functions writing messages to w in format and
+// flushing any buffered state. m converts messages into CSV.
+func messageWriter(format string, w io.Writer, m *csvpb.Marshaler) (func(proto.Message) error, func() error, error) {
+	noFlush := func() error { return nil }
+	switch format {
+	case "csv":
+		enc := csvpb.NewEncoder(w)
+		return func(pb proto.Message) error {
+			return m.MarshalNext(enc, pb)
+		}, enc.Flush, nil
+	case "binary":
+		return func(pb proto.Message) error {
+			return csvpb.WriteDelimited(w, pb)
+		}, noFlush, nil
+	case "json":
+		var m jsonpb.Marshaler
+		return func(pb proto.Message) error {
+			if err := m.Marshal(w, pb); err != nil {
+				return err
+			}
+			_, err := io.WriteString(w, "\n")
+			return err
+		}, noFlush, nil
+	case "text":
+		return func(pb proto.Message) error {
+			_, err := io.WriteString(w, proto.CompactTextString(pb)+"\n")
+			return err
+		}, noFlush, nil
+	}
+	return nil, nil, fmt.Errorf("unknown format %q", format)
+}
+
+// readMessages reads messages in a format other than csv from r and passes
+// them to fn.
+func readMessages(format string, r io.Reader, factory func() proto.Message, fn func(proto.Message) error) error {
+	switch format {
+	case "binary":
+		br := bufio.NewReader(r)
+		for {
+			pb := factory()
+			err := csvpb.ReadDelimited(br, pb)
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := fn(pb); err != nil {
+				return err
+			}
+		}
+	case "json":
+		dec := json.NewDecoder(r)
+		for dec.More() {
+			pb := factory()
+			if err := jsonpb.UnmarshalNext(dec, pb); err != nil {
+				return err
+			}
+			if err := fn(pb); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "text":
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, 64*1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" {
+				continue
+			}
+			pb := factory()
+			if err := proto.UnmarshalText(line, pb); err != nil {
+				return err
+			}
+			if err := fn(pb); err != nil {
+				return err
+			}
+		}
+		return scanner.Err()
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
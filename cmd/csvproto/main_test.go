@@ -0,0 +1,112 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	_ "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+const simpleCSV = "oInt32,oString\n1,foo\n2,bar\n"
+
+var runTests = []struct {
+	desc  string
+	args  []string
+	stdin string
+	code  int
+	out   string
+}{
+	{"csv to json", []string{"convert", "-type", "jsonpb.Simple"}, simpleCSV, 0,
+		"{\"oInt32\":1,\"oString\":\"foo\"}\n{\"oInt32\":2,\"oString\":\"bar\"}\n"},
+	{"csv to text", []string{"convert", "-type", "jsonpb.Simple", "-to", "text"}, simpleCSV, 0,
+		"o_int32:1 o_string:\"foo\" \no_int32:2 o_string:\"bar\" \n"},
+	{"json to csv", []string{"convert", "-type", "jsonpb.Widget", "-from", "json", "-to", "csv"},
+		"{\"color\":\"RED\"}\n{\"rColor\":[\"GREEN\",\"BLUE\"]}\n", 0,
+		"color,rColor,simple,rSimple,repeats,rRepeats\nRED,,null,,null,\nnull,\"GREEN,BLUE\",null,,null,\n"},
+	{"text to csv", []string{"convert", "-type", "jsonpb.Widget", "-from", "text", "-to", "csv"},
+		"color: BLUE\n", 0,
+		"color,rColor,simple,rSimple,repeats,rRepeats\nBLUE,,null,,null,\n"},
+	{"json to bigquery csv", []string{"convert", "-type", "jsonpb.Simple", "-from", "json", "-to", "csv", "-dialect", "bigquery"},
+		"{\"oBool\":true,\"oString\":\"a\\nb\"}\n", 0,
+		"oBool,oInt32,oInt32Str,oInt64,oInt64Str,oUint32,oUint32Str,oUint64,oUint64Str,oSint32,oSint32Str,oSint64,oSint64Str,oFloat,oFloatStr,oDouble,oDoubleStr,oString,oBytes\n" +
+			"true,,,,,,,,,,,,,,,,,\"a\nb\",\n"},
+	{"csv filtered", []string{"convert", "-type", "jsonpb.Simple", "-filter", `oString != "foo"`}, simpleCSV, 0,
+		"{\"oInt32\":2,\"oString\":\"bar\"}\n"},
+	{"bad filter", []string{"convert", "-type", "jsonpb.Simple", "-filter", "oString =="}, simpleCSV, 1, ""},
+	{"filter of json", []string{"convert", "-type", "jsonpb.Simple", "-from", "json", "-filter", "true"}, "{}\n", 1, ""},
+	{"unknown dialect", []string{"convert", "-type", "jsonpb.Simple", "-to", "csv", "-dialect", "oracle"}, simpleCSV, 1, ""},
+	{"dialect of json", []string{"convert", "-type", "jsonpb.Simple", "-dialect", "redshift"}, simpleCSV, 1, ""},
+	{"head", []string{"head", "-type", "jsonpb.Simple", "-n", "1"}, simpleCSV, 0,
+		"o_int32:1 o_string:\"foo\" \n"},
+	{"validate", []string{"validate", "-type", "jsonpb.Simple"}, simpleCSV, 0,
+		"2 rows, 0 invalid\n"},
+	{"validate invalid", []string{"validate", "-type", "jsonpb.Simple"}, "oInt32\nfoo\n2\nbar\n", 1,
+		"row 2: strconv.ParseInt: parsing \"foo\": invalid syntax\nrow 4: strconv.ParseInt: parsing \"bar\": invalid syntax\n3 rows, 2 invalid\n"},
+	{"validate malformed", []string{"validate", "-type", "jsonpb.Simple"}, "oInt32\n1\n2,3\n4\n", 1,
+		"row 3: record on line 3: wrong number of fields\n2 rows, 1 invalid\n"},
+	{"unknown type", []string{"head", "-type", "foo.Bar"}, simpleCSV, 1, ""},
+	{"unknown command", []string{"foo"}, simpleCSV, 2, ""},
+}
+
+func TestRun(t *testing.T) {
+	for _, tt := range runTests {
+		var stdout, stderr bytes.Buffer
+		code := run(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if code != tt.code {
+			t.Errorf("%s: got exit code %d, expected %d: %s", tt.desc, code, tt.code, stderr.String())
+			continue
+		}
+		if stdout.String() != tt.out {
+			t.Errorf("%s: got [%s] want [%s]", tt.desc, stdout.String(), tt.out)
+		}
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	var binary, stderr bytes.Buffer
+	if code := run([]string{"convert", "-type", "jsonpb.Simple", "-to", "binary"}, strings.NewReader(simpleCSV), &binary, &stderr); code != 0 {
+		t.Fatal(stderr.String())
+	}
+
+	var out bytes.Buffer
+	if code := run([]string{"convert", "-type", "jsonpb.Simple", "-from", "binary", "-to", "text"}, &binary, &out, &stderr); code != 0 {
+		t.Fatal(stderr.String())
+	}
+
+	exp := "o_int32:1 o_string:\"foo\" \no_int32:2 o_string:\"bar\" \n"
+	if out.String() != exp {
+		t.Fatalf("Unexpected: got %q, expected %q", out.String(), exp)
+	}
+}
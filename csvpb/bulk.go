@@ -0,0 +1,356 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"sync/atomic"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// ErrorCategory classifies the errors counted by bulk operations.
+type ErrorCategory int
+
+const (
+	// CategoryParse is malformed CSV.
+	CategoryParse ErrorCategory = iota
+	// CategoryConversion is a cell that could not be converted into its
+	// field.
+	CategoryConversion
+	// CategoryUnknownField is a column without a matching field.
+	CategoryUnknownField
+	// CategoryRequiredField is a required field left unset.
+	CategoryRequiredField
+	// CategoryValidation is a message rejected by the Validator.
+	CategoryValidation
+)
+
+func (c ErrorCategory) String() string {
+	switch c {
+	case CategoryParse:
+		return "parse"
+	case CategoryConversion:
+		return "conversion"
+	case CategoryUnknownField:
+		return "unknown field"
+	case CategoryRequiredField:
+		return "required field"
+	case CategoryValidation:
+		return "validation"
+	}
+	return fmt.Sprintf("ErrorCategory(%d)", int(c))
+}
+
+// RowError describes a record that failed to unmarshal.
+type RowError struct {
+	// Row is the 1-based index of the record within the input, counting a
+	// header read from the input.
+	Row      int
+	Category ErrorCategory
+	Err      error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("csv: row %d: %v", e.Row, e.Err)
+}
+
+// Summary reports what a bulk operation processed.
+type Summary struct {
+	// RowsRead is the number of records read, excluding the header.
+	RowsRead int
+	// RowsDecoded is the number of records successfully unmarshaled.
+	RowsDecoded int
+	// RowsSkipped is the number of records dropped because of
+	// SkipInvalidRows.
+	RowsSkipped int
+	// RowsFiltered is the number of records dropped by RecordFilter or
+	// Filter. They count as decoded if dropped by Filter.
+	RowsFiltered int
+	// BytesConsumed is the number of bytes of the input consumed.
+	BytesConsumed int64
+	// Errors counts the errors encountered per category.
+	Errors map[ErrorCategory]int
+}
+
+// Progress reports how far a bulk operation got through its input.
+type Progress struct {
+	// RowsRead is the number of records read, excluding the header.
+	RowsRead int
+	// BytesConsumed is the number of bytes of the input consumed.
+	BytesConsumed int64
+	// Size is the size of the input, -1 if unknown.
+	Size int64
+}
+
+// Fraction returns the part of the input consumed, between 0 and 1, or -1
+// if Size is unknown.
+func (p Progress) Fraction() float64 {
+	if p.Size < 0 {
+		return -1
+	}
+	if p.Size == 0 || p.BytesConsumed >= p.Size {
+		return 1
+	}
+	return float64(p.BytesConsumed) / float64(p.Size)
+}
+
+// RecordMiddleware rewrites a record between parsing and conversion, like
+// for sanitizing it. row is the 1-based index of the record within the
+// input, like RowError.Row. It may modify and return record.
+type RecordMiddleware func(record []string, row int) ([]string, error)
+
+// TrimCells is a RecordMiddleware removing leading and trailing white space
+// from every cell.
+func TrimCells(record []string, row int) ([]string, error) {
+	for i, cell := range record {
+		record[i] = strings.TrimSpace(cell)
+	}
+	return record, nil
+}
+
+// applyMiddleware passes record through Middleware.
+func (u *Unmarshaler) applyMiddleware(record []string, row int) ([]string, error) {
+	for _, mw := range u.Middleware {
+		var err error
+		if record, err = mw(record, row); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// MemoryLimitError is returned by operations holding more messages in
+// memory than MaxMemory allows.
+type MemoryLimitError struct {
+	// Limit is the MaxMemory exceeded.
+	Limit int64
+}
+
+func (e *MemoryLimitError) Error() string {
+	return fmt.Sprintf("csvpb: messages exceed memory limit of %d bytes", e.Limit)
+}
+
+// memoryBudget accounts for the memory of messages held, possibly by
+// several goroutines.
+type memoryBudget struct {
+	limit int64
+	used  int64
+}
+
+// charge accounts for pb, failing should the limit be exceeded.
+func (b *memoryBudget) charge(pb proto.Message) error {
+	if b.limit == 0 {
+		return nil
+	}
+	if atomic.AddInt64(&b.used, int64(proto.Size(pb))) > b.limit {
+		return &MemoryLimitError{Limit: b.limit}
+	}
+	return nil
+}
+
+// UnmarshalAll unmarshals every record of a CSV into messages created by
+// factory. Should Header be nil, the first record is used as header.
+// The returned Summary is never nil, even if an error occurs.
+func (u *Unmarshaler) UnmarshalAll(r io.Reader, factory func() proto.Message) ([]proto.Message, *Summary, error) {
+	return u.unmarshalAll(r, factory, &memoryBudget{limit: u.MaxMemory})
+}
+
+func (u *Unmarshaler) unmarshalAll(r io.Reader, factory func() proto.Message, budget *memoryBudget) ([]proto.Message, *Summary, error) {
+	var pbs []proto.Message
+	s, err := u.UnmarshalEach(r, factory, func(pb proto.Message) error {
+		if err := budget.charge(pb); err != nil {
+			return err
+		}
+		pbs = append(pbs, pb)
+		return nil
+	})
+	return pbs, s, err
+}
+
+// Stream unmarshals every record of a CSV into messages created by
+// factory and sends them to ch, which is closed once Stream returns. It
+// blocks until every message is received, see StreamContext to stop early.
+// Should Header be nil, the first record is used as header.
+// The returned Summary is never nil, even if an error occurs.
+func (u *Unmarshaler) Stream(r io.Reader, factory func() proto.Message, ch chan<- proto.Message) (*Summary, error) {
+	defer close(ch)
+	return u.UnmarshalEach(r, factory, func(pb proto.Message) error {
+		ch <- pb
+		return nil
+	})
+}
+
+// UnmarshalEach unmarshals every record of a CSV into messages created by
+// factory and passes them to fn. It stops at the first error returned by
+// fn. Should Header be nil, the first record is used as header.
+// The returned Summary is never nil, even if an error occurs.
+func (u *Unmarshaler) UnmarshalEach(r io.Reader, factory func() proto.Message, fn func(proto.Message) error) (*Summary, error) {
+	dec := u.newDecoder(r)
+	defer dec.Release()
+	s := &Summary{
+		Errors: make(map[ErrorCategory]int),
+	}
+	err := u.eachDecoded(dec, s, factoryDecoder(factory), fn)
+	s.BytesConsumed = dec.InputOffset()
+	return s, err
+}
+
+// UnmarshalEachFrom is UnmarshalEach for the records of rr, like of another
+// parser or a source other than CSV text. BytesConsumed of the returned
+// Summary is 0.
+func (u *Unmarshaler) UnmarshalEachFrom(rr RecordReader, factory func() proto.Message, fn func(proto.Message) error) (*Summary, error) {
+	s := &Summary{
+		Errors: make(map[ErrorCategory]int),
+	}
+	err := u.eachDecoded(NewRecordDecoder(rr), s, factoryDecoder(factory), fn)
+	return s, err
+}
+
+// UnmarshalEachTransient is UnmarshalEach for pure streaming, saving the
+// allocations per record. Every record is unmarshaled into pb, which is
+// reset for the next record once fn returns. Unless NewRecordReader is
+// set, the strings held by pb are not copied out of the buffer the record
+// is parsed into, which is overwritten by a later record. So they are only
+// valid until fn returns, just like pb. fn has to copy whatever it keeps,
+// like with proto.Marshal, but not with proto.Clone, which shares strings.
+// The same holds for the Filter and Validator.
+// The returned Summary is never nil, even if an error occurs.
+func (u *Unmarshaler) UnmarshalEachTransient(r io.Reader, pb proto.Message, fn func(proto.Message) error) (*Summary, error) {
+	uc := *u
+	if uc.NewRecordReader == nil {
+		uc.NewRecordReader = newTransientReader
+	}
+	dec := uc.newDecoder(r)
+	defer dec.Release()
+	dec.ReuseRecord = true
+	s := &Summary{
+		Errors: make(map[ErrorCategory]int),
+	}
+	err := u.eachDecoded(dec, s, factoryDecoder(func() proto.Message { return pb }), fn)
+	s.BytesConsumed = dec.InputOffset()
+	return s, err
+}
+
+// recordDecoder unmarshals a record into a message, using the copy of the
+// Unmarshaler of a bulk operation.
+type recordDecoder func(uc *Unmarshaler, record []string) (proto.Message, ErrorCategory, error)
+
+// factoryDecoder unmarshals records into messages created by factory.
+func factoryDecoder(factory func() proto.Message) recordDecoder {
+	return func(uc *Unmarshaler, record []string) (proto.Message, ErrorCategory, error) {
+		pb := factory()
+		if uc.Columns != nil && uc.projection == nil {
+			uc.projection = uc.projectColumns(reflect.TypeOf(pb).Elem())
+		}
+		category, err := uc.unmarshalDecoded(record, pb)
+		return pb, category, err
+	}
+}
+
+func (u *Unmarshaler) eachDecoded(dec recordSource, s *Summary, decode recordDecoder, fn func(proto.Message) error) error {
+	uc := *u
+	row := 0
+	if uc.Header == nil {
+		if !dec.More() && dec.Err() == nil {
+			return nil
+		}
+		row++
+		header, err := dec.Decode()
+		if err != nil {
+			s.Errors[CategoryParse]++
+			return &RowError{Row: row, Category: CategoryParse, Err: err}
+		}
+		// The header outlives the record, should dec reuse it or its
+		// cells
+		uc.Header = make([]string, len(header))
+		for i, column := range header {
+			uc.Header[i] = string([]byte(column))
+		}
+	}
+	for i := 0; i < uc.SkipRows && dec.More(); i++ {
+		row++
+		// Skipped records are not converted, errors are reported by Err
+		dec.Decode()
+	}
+
+	for dec.More() {
+		row++
+		s.RowsRead++
+		// More guarantees a record, errors are reported by Err
+		record, _ := dec.Decode()
+		if uc.OnProgress != nil {
+			uc.OnProgress(Progress{RowsRead: s.RowsRead, BytesConsumed: dec.InputOffset(), Size: dec.inputSize()})
+		}
+		record, err := uc.applyMiddleware(record, row)
+		if err != nil {
+			s.Errors[CategoryConversion]++
+			if !uc.SkipInvalidRows {
+				return &RowError{Row: row, Category: CategoryConversion, Err: err}
+			}
+			s.RowsSkipped++
+			continue
+		}
+		if uc.RecordFilter != nil && !uc.RecordFilter(uc.Header, record) {
+			s.RowsFiltered++
+			continue
+		}
+		pb, category, err := decode(&uc, record)
+		if err != nil {
+			s.Errors[category]++
+			if category == CategoryParse || !uc.SkipInvalidRows {
+				return &RowError{Row: row, Category: category, Err: err}
+			}
+			s.RowsSkipped++
+			continue
+		}
+		s.RowsDecoded++
+		if uc.Filter != nil && !uc.Filter(pb) {
+			s.RowsFiltered++
+			continue
+		}
+		if err := fn(pb); err != nil {
+			return err
+		}
+	}
+	if err := dec.Err(); err != nil {
+		// The malformed record
+		row++
+		s.RowsRead++
+		s.Errors[CategoryParse]++
+		return &RowError{Row: row, Category: CategoryParse, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,374 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func newSimple() proto.Message {
+	return new(pb.Simple)
+}
+
+func TestUnmarshalAll(t *testing.T) {
+	input := "oInt32,oString\n1,foo\n2,bar\n"
+	pbs, s, err := new(Unmarshaler).UnmarshalAll(strings.NewReader(input), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("foo")},
+		&pb.Simple{OInt32: proto.Int32(2), OString: proto.String("bar")},
+	}
+	if len(pbs) != len(exp) {
+		t.Fatalf("Unexpected: got %v, expected %v", pbs, exp)
+	}
+	for i := range exp {
+		if !proto.Equal(pbs[i], exp[i]) {
+			t.Fatalf("Unexpected: got %v, expected %v", pbs[i], exp[i])
+		}
+	}
+
+	expSummary := &Summary{
+		RowsRead:      2,
+		RowsDecoded:   2,
+		BytesConsumed: int64(len(input)),
+		Errors:        map[ErrorCategory]int{},
+	}
+	if !reflect.DeepEqual(s, expSummary) {
+		t.Fatalf("Unexpected: got %+v, expected %+v", s, expSummary)
+	}
+}
+
+func TestUnmarshalAllSkipInvalidRows(t *testing.T) {
+	input := "oInt32,oString\n1,foo\nbad,bar\n3,baz\n"
+	u := &Unmarshaler{SkipInvalidRows: true}
+	pbs, s, err := u.UnmarshalAll(strings.NewReader(input), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pbs) != 2 {
+		t.Fatalf("Unexpected message count: %d", len(pbs))
+	}
+
+	expSummary := &Summary{
+		RowsRead:      3,
+		RowsDecoded:   2,
+		RowsSkipped:   1,
+		BytesConsumed: int64(len(input)),
+		Errors:        map[ErrorCategory]int{CategoryConversion: 1},
+	}
+	if !reflect.DeepEqual(s, expSummary) {
+		t.Fatalf("Unexpected: got %+v, expected %+v", s, expSummary)
+	}
+}
+
+func TestUnmarshalAllSkipRows(t *testing.T) {
+	for _, tt := range []struct {
+		u     *Unmarshaler
+		input string
+	}{
+		{&Unmarshaler{SkipRows: 1}, "oInt32,oString\ncount,text\n1,foo\nbad,bar\n"},
+		{&Unmarshaler{SkipRows: 2, Header: []string{"oInt32", "oString"}}, "count,text\n,\n1,foo\nbad,bar\n"},
+	} {
+		pbs, s, err := tt.u.UnmarshalAll(strings.NewReader(tt.input), newSimple)
+		if re, ok := err.(*RowError); !ok || re.Row != 4 {
+			t.Errorf("%q: got error %v, expected an error in row 4", tt.input, err)
+		}
+		exp := []proto.Message{&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("foo")}}
+		if !reflect.DeepEqual(pbs, exp) {
+			t.Errorf("%q: got %v, expected %v", tt.input, pbs, exp)
+		}
+		if s.RowsRead != 2 {
+			t.Errorf("%q: got summary %+v", tt.input, s)
+		}
+	}
+}
+
+func TestUnmarshalEachFrom(t *testing.T) {
+	var pbs []proto.Message
+	rr := &sliceReader{{"oInt32"}, {"1"}, {"bad"}}
+	u := &Unmarshaler{SkipInvalidRows: true}
+	s, err := u.UnmarshalEachFrom(rr, newSimple, func(pb proto.Message) error {
+		pbs = append(pbs, pb)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []proto.Message{&pb.Simple{OInt32: proto.Int32(1)}}
+	if !reflect.DeepEqual(pbs, exp) {
+		t.Errorf("got %v, expected %v", pbs, exp)
+	}
+	if s.RowsRead != 2 || s.RowsSkipped != 1 {
+		t.Errorf("got summary %+v", s)
+	}
+}
+
+var unmarshalAllErrorTests = []struct {
+	desc     string
+	input    string
+	row      int
+	category ErrorCategory
+}{
+	{"conversion", "oInt32\n1\nbad", 3, CategoryConversion},
+	{"unknown field", "unknown\nfoo", 2, CategoryUnknownField},
+	{"required field", "str\nnull", 2, CategoryRequiredField},
+	{"parse", "oInt32\n1,2", 2, CategoryParse},
+}
+
+func TestUnmarshalAllErrors(t *testing.T) {
+	for _, tt := range unmarshalAllErrorTests {
+		factory := newSimple
+		if tt.category == CategoryRequiredField {
+			factory = func() proto.Message { return new(pb.MsgWithRequired) }
+		}
+		_, s, err := new(Unmarshaler).UnmarshalAll(strings.NewReader(tt.input), factory)
+		re, ok := err.(*RowError)
+		if !ok {
+			t.Errorf("%s: expected RowError, got %v", tt.desc, err)
+			continue
+		}
+		if re.Row != tt.row || re.Category != tt.category {
+			t.Errorf("%s: got row %d %v, expected row %d %v", tt.desc, re.Row, re.Category, tt.row, tt.category)
+		}
+		if s.Errors[tt.category] != 1 {
+			t.Errorf("%s: unexpected error counts %v", tt.desc, s.Errors)
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	ch := make(chan proto.Message)
+	done := make(chan *Summary)
+	go func() {
+		u := &Unmarshaler{Header: []string{"oInt32"}}
+		s, err := u.Stream(strings.NewReader("1\n2\n3"), newSimple, ch)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- s
+	}()
+
+	var got []int32
+	for m := range ch {
+		got = append(got, m.(*pb.Simple).GetOInt32())
+	}
+	if !reflect.DeepEqual(got, []int32{1, 2, 3}) {
+		t.Fatalf("Unexpected: got %v", got)
+	}
+	if s := <-done; s.RowsDecoded != 3 {
+		t.Fatalf("Unexpected: got %+v", s)
+	}
+}
+
+func TestUnmarshalAllFilter(t *testing.T) {
+	input := "oInt32,oString\n1,foo\nbad,skip\n3,baz\n4,qux\n"
+	u := &Unmarshaler{
+		RecordFilter: func(header, record []string) bool {
+			return record[1] != "skip"
+		},
+		Filter: func(m proto.Message) bool {
+			return m.(*pb.Simple).GetOInt32()%2 == 1
+		},
+	}
+	pbs, s, err := u.UnmarshalAll(strings.NewReader(input), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []int32
+	for _, m := range pbs {
+		got = append(got, m.(*pb.Simple).GetOInt32())
+	}
+	if !reflect.DeepEqual(got, []int32{1, 3}) {
+		t.Fatalf("Unexpected: got %v", got)
+	}
+
+	expSummary := &Summary{
+		RowsRead:      4,
+		RowsDecoded:   3,
+		RowsFiltered:  2,
+		BytesConsumed: int64(len(input)),
+		Errors:        map[ErrorCategory]int{},
+	}
+	if !reflect.DeepEqual(s, expSummary) {
+		t.Fatalf("Unexpected: got %+v, expected %+v", s, expSummary)
+	}
+}
+
+func TestUnmarshalEachTransient(t *testing.T) {
+	input := "oInt32,oString\n1,foo\n2,\"b\"\"ar\"\n3,baz\n4,qux\n"
+	p := new(pb.Simple)
+	var got []proto.Message
+	var kept []*string
+	s, err := new(Unmarshaler).UnmarshalEachTransient(strings.NewReader(input), p, func(m proto.Message) error {
+		if m != p {
+			t.Errorf("Unexpected message %p", m)
+		}
+		b, err := proto.Marshal(m)
+		if err != nil {
+			return err
+		}
+		c := new(pb.Simple)
+		if err := proto.Unmarshal(b, c); err != nil {
+			return err
+		}
+		got = append(got, c)
+		kept = append(kept, p.OString)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("foo")},
+		&pb.Simple{OInt32: proto.Int32(2), OString: proto.String(`b"ar`)},
+		&pb.Simple{OInt32: proto.Int32(3), OString: proto.String("baz")},
+		&pb.Simple{OInt32: proto.Int32(4), OString: proto.String("qux")},
+	}
+	if len(got) != len(exp) {
+		t.Fatalf("Unexpected: got %v, expected %v", got, exp)
+	}
+	for i := range exp {
+		if !proto.Equal(got[i], exp[i]) {
+			t.Errorf("Unexpected: got %v, expected %v", got[i], exp[i])
+		}
+	}
+	if s.RowsDecoded != 4 || s.BytesConsumed != int64(len(input)) {
+		t.Fatalf("Unexpected: got %+v", s)
+	}
+	// The strings of the first record reference the buffer of the third
+	if *kept[0] != "baz" {
+		t.Errorf("Unexpected: kept string %q, expected it to be overwritten", *kept[0])
+	}
+}
+
+func TestUnmarshalAllMaxMemory(t *testing.T) {
+	// Each message is 2 bytes in wire format.
+	input := "oInt32\n1\n2\n3\n"
+	for _, tt := range []struct {
+		limit int64
+		n     int
+		fails bool
+	}{
+		{0, 3, false},
+		{6, 3, false},
+		{5, 2, true},
+	} {
+		u := &Unmarshaler{MaxMemory: tt.limit}
+		pbs, _, err := u.UnmarshalAll(strings.NewReader(input), newSimple)
+		if _, ok := err.(*MemoryLimitError); ok != tt.fails {
+			t.Errorf("%d: got error %v", tt.limit, err)
+		}
+		if len(pbs) != tt.n {
+			t.Errorf("%d: got %d messages, expected %d", tt.limit, len(pbs), tt.n)
+		}
+	}
+}
+
+func TestUnmarshalAllMiddleware(t *testing.T) {
+	input := "oInt32,oString\n 1 , foo\n2,BAD\n3,bar\n"
+	var rows []int
+	u := &Unmarshaler{
+		SkipInvalidRows: true,
+		Middleware: []RecordMiddleware{
+			TrimCells,
+			func(record []string, row int) ([]string, error) {
+				rows = append(rows, row)
+				if record[1] == "BAD" {
+					return nil, errors.New("bad record")
+				}
+				record[1] = strings.ToUpper(record[1])
+				return record, nil
+			},
+		},
+	}
+	pbs, s, err := u.UnmarshalAll(strings.NewReader(input), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("FOO")},
+		&pb.Simple{OInt32: proto.Int32(3), OString: proto.String("BAR")},
+	}
+	if len(pbs) != len(exp) || !proto.Equal(pbs[0], exp[0]) || !proto.Equal(pbs[1], exp[1]) {
+		t.Fatalf("Unexpected: got %v, expected %v", pbs, exp)
+	}
+	if !reflect.DeepEqual(rows, []int{2, 3, 4}) {
+		t.Errorf("Unexpected rows %v", rows)
+	}
+	if s.RowsSkipped != 1 || s.Errors[CategoryConversion] != 1 {
+		t.Errorf("Unexpected: got %+v", s)
+	}
+
+	u.SkipInvalidRows = false
+	_, _, err = u.UnmarshalAll(strings.NewReader(input), newSimple)
+	if re, ok := err.(*RowError); !ok || re.Row != 3 {
+		t.Errorf("Unexpected: got %v", err)
+	}
+}
+
+func TestUnmarshalAllProgress(t *testing.T) {
+	input := "oInt32\n1\n22\n333\n"
+	var got []Progress
+	u := &Unmarshaler{OnProgress: func(p Progress) { got = append(got, p) }}
+	if _, _, err := u.UnmarshalAll(strings.NewReader(input), newSimple); err != nil {
+		t.Fatal(err)
+	}
+	size := int64(len(input))
+	want := []Progress{{1, 9, size}, {2, 12, size}, {3, 16, size}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if f := got[2].Fraction(); f != 1 {
+		t.Errorf("got fraction %v, want 1", f)
+	}
+
+	got = nil
+	if _, _, err := u.UnmarshalAll(ioutil.NopCloser(strings.NewReader(input)), newSimple); err != nil {
+		t.Fatal(err)
+	}
+	if got[0].Size != -1 || got[0].Fraction() != -1 {
+		t.Errorf("unknown size: got %v", got[0])
+	}
+}
@@ -0,0 +1,90 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+// Coercer parses the cells of scalar fields, after Strictness prepared
+// them. Replacing it enforces house rules, like for the digits of numbers,
+// in one place. The fallbacks of Strictness and IntegerRange still apply
+// to cells it fails to parse.
+type Coercer interface {
+	ParseBool(cell string) (bool, error)
+	// ParseInt and ParseUint parse integers fitting into bitSize.
+	ParseInt(cell string, bitSize int) (int64, error)
+	ParseUint(cell string, bitSize int) (uint64, error)
+	// ParseFloat parses floats fitting into bitSize. Cells of NonFinite
+	// are recognized before.
+	ParseFloat(cell string, bitSize int) (float64, error)
+	ParseBytes(cell string) ([]byte, error)
+}
+
+// DefaultCoercer parses cells with ParseBool, ParseInt, ParseUint,
+// ParseFloat and ParseBytes. It is the Coercer of an Unmarshaler without
+// one.
+var DefaultCoercer Coercer = defaultCoercer{}
+
+type defaultCoercer struct{}
+
+func (defaultCoercer) ParseBool(cell string) (bool, error) { return ParseBool(cell) }
+
+func (defaultCoercer) ParseInt(cell string, bitSize int) (int64, error) {
+	return ParseInt(cell, bitSize)
+}
+
+func (defaultCoercer) ParseUint(cell string, bitSize int) (uint64, error) {
+	return ParseUint(cell, bitSize)
+}
+
+func (defaultCoercer) ParseFloat(cell string, bitSize int) (float64, error) {
+	return ParseFloat(cell, bitSize)
+}
+
+func (defaultCoercer) ParseBytes(cell string) ([]byte, error) { return ParseBytes(cell) }
+
+// coercer returns the Coercer of u.
+func (u *Unmarshaler) coercer() Coercer {
+	if u.Coercer == nil {
+		return DefaultCoercer
+	}
+	return u.Coercer
+}
+
+// parseBytes parses a bytes cell with the Coercer, falling back to
+// ParseBytesLenient for LenientBase64.
+func (u *Unmarshaler) parseBytes(cell string) ([]byte, error) {
+	b, err := u.coercer().ParseBytes(cell)
+	if err != nil && u.LenientBase64 {
+		if lb, lerr := ParseBytesLenient(cell); lerr == nil {
+			return lb, nil
+		}
+	}
+	return b, err
+}
@@ -0,0 +1,114 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// houseCoercer accepts apostrophes grouping digits and hex bytes.
+type houseCoercer struct {
+	Coercer
+}
+
+func (c houseCoercer) ParseInt(cell string, bitSize int) (int64, error) {
+	return c.Coercer.ParseInt(strings.Replace(cell, "'", "", -1), bitSize)
+}
+
+func (c houseCoercer) ParseFloat(cell string, bitSize int) (float64, error) {
+	return c.Coercer.ParseFloat(strings.Replace(cell, "'", "", -1), bitSize)
+}
+
+func (houseCoercer) ParseBytes(cell string) ([]byte, error) {
+	return hex.DecodeString(cell)
+}
+
+func TestCoercer(t *testing.T) {
+	u := &Unmarshaler{
+		Header:  []string{"oInt64", "oDouble", "oUint32", "oBytes"},
+		Coercer: houseCoercer{DefaultCoercer},
+	}
+	got := new(pb.Simple)
+	if err := u.UnmarshalRecord([]string{"1'000'000", "1'234.5", "7", "cafe"}, got); err != nil {
+		t.Fatal(err)
+	}
+	want := &pb.Simple{
+		OInt64:  proto.Int64(1000000),
+		ODouble: proto.Float64(1234.5),
+		OUint32: proto.Uint32(7),
+		OBytes:  []byte{0xca, 0xfe},
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if err := u.UnmarshalRecord([]string{"1'000", "0", "1'000", "00"}, got); err == nil {
+		t.Error("unsigned integers are not grouped, expected an error")
+	}
+}
+
+func TestLenientBase64(t *testing.T) {
+	tests := []struct {
+		lenient bool
+		cell    string
+		want    []byte
+		wantErr bool
+	}{
+		{false, "+/8=", []byte{0xfb, 0xff}, false},
+		{false, "+/8", nil, true},
+		{false, "-_8=", nil, true},
+		{true, "+/8=", []byte{0xfb, 0xff}, false},
+		{true, "+/8", []byte{0xfb, 0xff}, false},
+		{true, "-_8=", []byte{0xfb, 0xff}, false},
+		{true, "-_8", []byte{0xfb, 0xff}, false},
+		{true, "aGk", []byte("hi"), false},
+		{true, "+_8", nil, true},
+		{true, "a", nil, true},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{Header: []string{"oBytes"}, LenientBase64: tt.lenient}
+		got := new(pb.Simple)
+		err := u.UnmarshalRecord([]string{tt.cell}, got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalRecord(%q) with LenientBase64 %v error = %v, want error %v", tt.cell, tt.lenient, err, tt.wantErr)
+			continue
+		}
+		if err == nil && string(got.OBytes) != string(tt.want) {
+			t.Errorf("UnmarshalRecord(%q) with LenientBase64 %v = %x, want %x", tt.cell, tt.lenient, got.OBytes, tt.want)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestCompressedCells(t *testing.T) {
+	m := &Marshaler{}
+	m.SetFieldOptions("jsonpb.Simple.o_string", FieldOptions{Compressed: true})
+	m.SetFieldOptions("jsonpb.Simple.o_bytes", FieldOptions{Compressed: true})
+	u := &Unmarshaler{Header: []string{"oString", "oBytes"}}
+	u.SetFieldOptions("jsonpb.Simple.o_string", FieldOptions{Compressed: true})
+	u.SetFieldOptions("jsonpb.Simple.o_bytes", FieldOptions{Compressed: true})
+
+	text := strings.Repeat("a large, repetitive,\npayload ", 100)
+	in := &pb.Simple{OString: proto.String(text), OBytes: []byte{0, 1, 2, 0xff}}
+	record, err := m.MarshalRecord(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cells []string
+	columns, err := m.Header(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, column := range columns {
+		if column == "oString" || column == "oBytes" {
+			cells = append(cells, record[i])
+		}
+	}
+	if len(cells[0]) >= len(text) || strings.ContainsAny(cells[0], ",\n") {
+		t.Errorf("compressed cell %q not smaller than text or not plain base64", cells[0])
+	}
+
+	got := new(pb.Simple)
+	if err := u.UnmarshalRecord(cells, got); err != nil {
+		t.Fatal(err)
+	}
+	want := &pb.Simple{OString: in.OString, OBytes: in.OBytes}
+	if !proto.Equal(got, want) {
+		t.Errorf("round trip = %v, want %v", got, want)
+	}
+
+	if err := u.UnmarshalRecord([]string{"aGk=", "null"}, new(pb.Simple)); err == nil {
+		t.Error("cell not gzip compressed, expected an error")
+	}
+	u.MaxCellSize = len(text) - 1
+	if err := u.UnmarshalRecord(cells, new(pb.Simple)); err == nil {
+		t.Error("decompressed cell exceeds MaxCellSize, expected an error")
+	} else if _, ok := err.(*LimitError); !ok {
+		t.Errorf("got %T, want *LimitError", err)
+	}
+
+	columnReports, err := m.Report(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range columnReports {
+		if c.Column == "oString" && c.Format != "gzip compressed text, base64" {
+			t.Errorf("oString format = %q", c.Format)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Evaluator computes the value of a field from a message, like a compiled
+// CEL program evaluated with the message as variable.
+type Evaluator interface {
+	// Eval returns the value of the field for pb, as accepted by
+	// ColumnMapping.Combine.
+	Eval(pb proto.Message) (interface{}, error)
+}
+
+// EvaluatorFunc adapts a function to an Evaluator.
+type EvaluatorFunc func(pb proto.Message) (interface{}, error)
+
+// Eval calls f(pb).
+func (f EvaluatorFunc) Eval(pb proto.Message) (interface{}, error) {
+	return f(pb)
+}
+
+// CompileEvaluator compiles an expression computing a field into an
+// Evaluator, like
+//
+//	has(discount) ? price * quantity - discount : price * quantity
+//
+// expr is written in the subset of CEL of CompileRecordFilter, with
+// identifiers naming scalar or enum fields of the message by their name in
+// the .proto file or their JSON name, and has(field) telling whether a
+// field is set. Unset fields of proto2 messages are null and enums are
+// their number. The result converts into the computed field like a cell,
+// with null leaving it unset. Evaluating fails for operations on values
+// of different kinds, null or other fields.
+func CompileEvaluator(expr string) (Evaluator, error) {
+	n, err := compileExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("csvpb: expression %q: %v", expr, err)
+	}
+	return EvaluatorFunc(func(pb proto.Message) (interface{}, error) {
+		v, ok := n.eval(newMessageEnv(pb))
+		if !ok {
+			return nil, fmt.Errorf("cannot evaluate %q", expr)
+		}
+		switch v.kind {
+		case valBool:
+			return strconv.FormatBool(v.b), nil
+		case valNumber:
+			if v.f == math.Trunc(v.f) && math.Abs(v.f) < 1<<53 {
+				// Integers in a form integer fields accept
+				return strconv.FormatInt(int64(v.f), 10), nil
+			}
+			return strconv.FormatFloat(v.f, 'g', -1, 64), nil
+		case valString, valCell:
+			return v.s, nil
+		}
+		return nil, nil
+	}), nil
+}
+
+// messageEnv is the exprEnv of the fields of a message.
+type messageEnv struct {
+	v      reflect.Value
+	sprops *proto.StructProperties
+}
+
+func newMessageEnv(pb proto.Message) messageEnv {
+	v := reflect.ValueOf(pb).Elem()
+	return messageEnv{v, proto.GetProperties(v.Type())}
+}
+
+// field returns the field name, or an invalid Value.
+func (e messageEnv) field(name string) reflect.Value {
+	for i, prop := range e.sprops.Prop {
+		if strings.HasPrefix(e.v.Type().Field(i).Name, "XXX_") || prop.OrigName == "" {
+			continue
+		}
+		if prop.OrigName == name || prop.JSONName == name {
+			return e.v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+func (e messageEnv) lookup(name string) (filterValue, bool) {
+	f := e.field(name)
+	if !f.IsValid() {
+		return filterValue{kind: valNull}, true
+	}
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return filterValue{kind: valNull}, f.Type().Elem().Kind() != reflect.Struct
+		}
+		f = f.Elem()
+	}
+	switch f.Kind() {
+	case reflect.Bool:
+		return filterValue{kind: valBool, b: f.Bool()}, true
+	case reflect.Int32, reflect.Int64:
+		return filterValue{kind: valNumber, f: float64(f.Int())}, true
+	case reflect.Uint32, reflect.Uint64:
+		return filterValue{kind: valNumber, f: float64(f.Uint())}, true
+	case reflect.Float32, reflect.Float64:
+		return filterValue{kind: valNumber, f: f.Float()}, true
+	case reflect.String:
+		return filterValue{kind: valString, s: f.String()}, true
+	case reflect.Slice:
+		if f.Type().Elem().Kind() == reflect.Uint8 {
+			return filterValue{kind: valString, s: string(f.Bytes())}, true
+		}
+	}
+	return filterValue{}, false
+}
+
+func (e messageEnv) has(name string) bool {
+	f := e.field(name)
+	switch {
+	case !f.IsValid():
+		return false
+	case f.Kind() == reflect.Ptr || f.Kind() == reflect.Interface:
+		return !f.IsNil()
+	case f.Kind() == reflect.Slice || f.Kind() == reflect.Map:
+		return f.Len() > 0
+	}
+	// A scalar of proto3
+	return f.Interface() != reflect.Zero(f.Type()).Interface()
+}
+
+// computedField is a field set by ComputeField.
+type computedField struct {
+	field string
+	eval  Evaluator
+}
+
+// ComputeField sets the field with the full name field, like
+// "pkg.Msg.field", to the value e computes from every message unmarshaled,
+// once its columns are converted. Fields are computed in the order of the
+// calls, each seeing those computed before, after required fields are
+// checked and before the Validator is called. The field needs no column.
+// CompileEvaluator compiles e from an expression.
+func (u *Unmarshaler) ComputeField(field string, e Evaluator) {
+	u.computed = append(u.computed, computedField{field, e})
+}
+
+// computeFields sets the fields of ComputeField in pb.
+func (u *Unmarshaler) computeFields(pb proto.Message) error {
+	if len(u.computed) == 0 {
+		return nil
+	}
+	target := reflect.ValueOf(pb).Elem()
+	if err := scanFieldOptions(target.Type()); err != nil {
+		return err
+	}
+	sprops := proto.GetProperties(target.Type())
+	for _, c := range u.computed {
+		i := computedIndex(target.Type(), sprops, c.field)
+		if i < 0 {
+			if strings.HasPrefix(c.field, proto.MessageName(pb)+".") {
+				return fmt.Errorf("computed field %q not found", c.field)
+			}
+			// A field of another message type
+			continue
+		}
+		value, err := c.eval.Eval(pb)
+		if err != nil {
+			return fmt.Errorf("field %q: %v", sprops.Prop[i].OrigName, err)
+		}
+		if err := u.setValue(target.Field(i), sprops.Prop[i], value); err != nil {
+			return fmt.Errorf("field %q: %v", sprops.Prop[i].OrigName, err)
+		}
+	}
+	return nil
+}
+
+// computedIndex returns the index of the field with the full name field in
+// struct type t, or -1.
+func computedIndex(t reflect.Type, sprops *proto.StructProperties, field string) int {
+	for i, prop := range sprops.Prop {
+		if strings.HasPrefix(t.Field(i).Name, "XXX_") {
+			continue
+		}
+		if name, ok := propNames.Load(prop); ok && name.(string) == field {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,120 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestComputeField(t *testing.T) {
+	u := &Unmarshaler{Header: []string{"oFloat", "oInt32", "oInt64"}}
+	// price * quantity
+	u.ComputeField("jsonpb.Simple.o_double", EvaluatorFunc(func(m proto.Message) (interface{}, error) {
+		s := m.(*pb.Simple)
+		return float64(s.GetOFloat()) * float64(s.GetOInt32()), nil
+	}))
+	// has(o_int64) ? o_int64 : 0, as a string
+	u.ComputeField("jsonpb.Simple.o_string", EvaluatorFunc(func(m proto.Message) (interface{}, error) {
+		s := m.(*pb.Simple)
+		if s.OInt64 == nil {
+			return "0", nil
+		}
+		return fmt.Sprint(s.GetOInt64()), nil
+	}))
+	got := new(pb.Simple)
+	if err := u.UnmarshalRecord([]string{"2.5", "4", "null"}, got); err != nil {
+		t.Fatal(err)
+	}
+	want := &pb.Simple{OFloat: proto.Float32(2.5), OInt32: proto.Int32(4), ODouble: proto.Float64(10), OString: proto.String("0")}
+	if !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	u = &Unmarshaler{Header: []string{"oInt32"}}
+	u.ComputeField("jsonpb.Simple.o_double", EvaluatorFunc(func(proto.Message) (interface{}, error) {
+		return nil, errors.New("no discount")
+	}))
+	if _, _, err := u.UnmarshalAll(strings.NewReader("oInt32\n1\n"), newSimple); err == nil || err.(*RowError).Category != CategoryConversion {
+		t.Errorf("failing evaluator: got %v", err)
+	}
+
+	u = &Unmarshaler{Header: []string{"oInt32"}}
+	u.ComputeField("jsonpb.Simple.o_nothing", EvaluatorFunc(func(proto.Message) (interface{}, error) {
+		return 1.0, nil
+	}))
+	if err := u.UnmarshalRecord([]string{"1"}, new(pb.Simple)); err == nil {
+		t.Error("unknown field: expected an error")
+	}
+}
+
+func TestCompileEvaluator(t *testing.T) {
+	u := &Unmarshaler{Header: []string{"oFloat", "oInt32", "oInt64"}}
+	for _, c := range []struct{ field, expr string }{
+		{"jsonpb.Simple.o_double", "oFloat * o_int32"},
+		{"jsonpb.Simple.o_string", `has(o_int64) ? "" + o_int64 : "none"`},
+		{"jsonpb.Simple.o_uint32", "has(o_int64) ? o_int64 : o_int32 / 2"},
+		{"jsonpb.Simple.o_bool", "o_double > 3"},
+	} {
+		e, err := CompileEvaluator(c.expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u.ComputeField(c.field, e)
+	}
+	got := new(pb.Simple)
+	if err := u.UnmarshalRecord([]string{"2.5", "4", "null"}, got); err != nil {
+		t.Fatal(err)
+	}
+	want := &pb.Simple{OFloat: proto.Float32(2.5), OInt32: proto.Int32(4), ODouble: proto.Float64(10),
+		OString: proto.String("none"), OUint32: proto.Uint32(2), OBool: proto.Bool(true)}
+	if !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	e, err := CompileEvaluator("o_int64 * 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Eval(&pb.Simple{}); err == nil {
+		t.Error("null operand: expected an error")
+	}
+	if _, err := CompileEvaluator("o_int64 *"); err == nil {
+		t.Error("incomplete expression: expected an error")
+	}
+}
@@ -0,0 +1,157 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The functions in this file convert single cells the way Unmarshaler and
+// Marshaler do. They are used by code generated by protoc-gen-csvpb.
+
+// unquote drops the quotes of numbers and bools encoded as strings.
+func unquote(cell string) string {
+	if len(cell) >= 2 && strings.HasPrefix(cell, `"`) && strings.HasSuffix(cell, `"`) {
+		return cell[1 : len(cell)-1]
+	}
+	return cell
+}
+
+// ParseBool parses a bool cell.
+func ParseBool(cell string) (bool, error) {
+	return strconv.ParseBool(strings.ToLower(unquote(cell)))
+}
+
+// ParseInt parses a signed integer cell fitting into bitSize.
+func ParseInt(cell string, bitSize int) (int64, error) {
+	return strconv.ParseInt(unquote(cell), 10, bitSize)
+}
+
+// ParseUint parses an unsigned integer cell fitting into bitSize.
+func ParseUint(cell string, bitSize int) (uint64, error) {
+	return strconv.ParseUint(unquote(cell), 10, bitSize)
+}
+
+// ParseFloat parses a floating point cell fitting into bitSize.
+// Non-finite numbers are NaN, Inf or Infinity in any case, with an optional
+// sign, and can be encoded as strings.
+func ParseFloat(cell string, bitSize int) (float64, error) {
+	return strconv.ParseFloat(unquote(cell), bitSize)
+}
+
+// ParseBytes parses a base64 encoded cell.
+func ParseBytes(cell string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(cell)
+}
+
+// ParseBytesLenient parses a base64 encoded cell with or without padding,
+// in either the standard or the URL-safe alphabet.
+func ParseBytesLenient(cell string) ([]byte, error) {
+	raw := strings.TrimRight(cell, "=")
+	if strings.ContainsAny(raw, "-_") {
+		return base64.RawURLEncoding.DecodeString(raw)
+	}
+	return base64.RawStdEncoding.DecodeString(raw)
+}
+
+// ParseEnum parses an enum cell holding either the name or the number of
+// a value. values maps the names to numbers.
+func ParseEnum(cell string, values map[string]int32) (int32, error) {
+	cell = strings.TrimSpace(cell)
+	if n, ok := values[cell]; ok {
+		return n, nil
+	}
+	if _, err := strconv.ParseUint(cell, 10, 32); err != nil {
+		return 0, fmt.Errorf("unknown enum value %q", cell)
+	}
+	n, err := strconv.ParseInt(cell, 10, 32)
+	return int32(n), err
+}
+
+// FormatFloat formats f, which fits into bitSize, as a cell.
+func FormatFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	}
+	return strconv.FormatFloat(f, 'g', -1, bitSize)
+}
+
+// SplitList splits a cell holding a list, itself encoded as a single CSV
+// record, into its elements.
+func SplitList(cell string) ([]string, error) {
+	return splitList(cell, ',')
+}
+
+// splitList is SplitList with elements separated by comma.
+func splitList(cell string, comma rune) ([]string, error) {
+	if cell == "" {
+		return []string{}, nil
+	}
+	r := csv.NewReader(strings.NewReader(cell))
+	r.Comma = comma
+	return r.Read()
+}
+
+// JoinList encodes cells as a single CSV record without line ending,
+// suitable for a cell holding a list.
+func JoinList(cells []string) (string, error) {
+	return joinList(cells, ',')
+}
+
+// joinList is JoinList with elements separated by comma.
+func joinList(cells []string, comma rune) (string, error) {
+	if len(cells) == 0 {
+		return "", nil
+	}
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = comma
+	if err := w.Write(cells); err != nil {
+		return "", err
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
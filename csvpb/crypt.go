@@ -0,0 +1,67 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import "fmt"
+
+// Columns holding encrypted values, like envelope encrypted personal data,
+// are handled by callbacks, keeping the keys out of the codec. Decrypt sees
+// every cell of a record before it is converted, Encrypt every cell
+// written for a message. Both return cells they do not handle unchanged.
+
+// decryptCells passes the cells of fields, by their column, through
+// Decrypt.
+func (u *Unmarshaler) decryptCells(fields map[string]string) error {
+	if u.Decrypt == nil {
+		return nil
+	}
+	for column, cell := range fields {
+		plaintext, err := u.Decrypt(column, cell)
+		if err != nil {
+			return fmt.Errorf("column %q: %v", column, err)
+		}
+		fields[column] = plaintext
+	}
+	return nil
+}
+
+// encryptCell passes cell of column through Encrypt.
+func (m *Marshaler) encryptCell(column, cell string) (string, error) {
+	if m.Encrypt == nil {
+		return cell, nil
+	}
+	ciphertext, err := m.Encrypt(column, cell)
+	if err != nil {
+		return "", fmt.Errorf("column %q: %v", column, err)
+	}
+	return ciphertext, nil
+}
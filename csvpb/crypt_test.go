@@ -0,0 +1,94 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// rot13Column stands in for the encryption of the column oString.
+func rot13Column(column, cell string) (string, error) {
+	if column != "oString" {
+		return cell, nil
+	}
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return 'a' + (r-'a'+13)%26
+		case r >= 'A' && r <= 'Z':
+			return 'A' + (r-'A'+13)%26
+		}
+		return r
+	}, cell), nil
+}
+
+func TestEncryptedColumns(t *testing.T) {
+	in := &pb.Simple{OString: proto.String("Secret"), OInt32: proto.Int32(7), OBytes: []byte{}}
+	var buf strings.Builder
+	m := &Marshaler{Encrypt: rot13Column}
+	if err := m.Marshal(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); strings.Contains(out, "Secret") || !strings.Contains(out, "Frperg") {
+		t.Errorf("Marshal = %q, want oString encrypted", out)
+	}
+
+	u := &Unmarshaler{Decrypt: rot13Column}
+	pbs, _, err := u.UnmarshalAll(strings.NewReader(buf.String()), func() proto.Message { return new(pb.Simple) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pbs) != 1 || !proto.Equal(pbs[0], in) {
+		t.Errorf("round trip = %v, want %v", pbs, in)
+	}
+
+	fail := func(column, cell string) (string, error) {
+		if column == "oInt32" {
+			return "", errors.New("no key")
+		}
+		return cell, nil
+	}
+	u = &Unmarshaler{Header: []string{"oString", "oInt32"}, Decrypt: fail}
+	if err := u.UnmarshalRecord([]string{"x", "7"}, new(pb.Simple)); err == nil || !strings.Contains(err.Error(), "no key") {
+		t.Errorf("UnmarshalRecord error = %v, want the error of Decrypt", err)
+	}
+	m = &Marshaler{Encrypt: fail}
+	if _, err := m.MarshalRecord(in); err == nil || !strings.Contains(err.Error(), "no key") {
+		t.Errorf("MarshalRecord error = %v, want the error of Encrypt", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package csvhttp streams protocol buffers from and to text/csv bodies of
+HTTP requests and responses.
+*/
+package csvhttp
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// ContentType is the Content-Type of responses written by Download.
+const ContentType = "text/csv; charset=utf-8; header=present"
+
+// ErrUnsupportedMediaType is returned by Upload for requests whose body is
+// not text/csv.
+var ErrUnsupportedMediaType = errors.New("csvhttp: request body is not text/csv")
+
+// Upload unmarshals the text/csv body of r into messages created by factory
+// and sends them to ch, which is closed once Upload returns. Should the
+// Header of u be nil, the first record is used as header. A nil u uses
+// default options. The Content-Length is the InputSize reported to
+// OnProgress, unless set. Upload returns the error of the context of r
+// once it is done, like when the client disconnects, rather than blocking
+// on ch.
+// The returned Summary is nil only if the body is not text/csv.
+func Upload(r *http.Request, u *csvpb.Unmarshaler, factory func() proto.Message, ch chan<- proto.Message) (*csvpb.Summary, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "text/csv" {
+		close(ch)
+		return nil, ErrUnsupportedMediaType
+	}
+	if u == nil {
+		u = new(csvpb.Unmarshaler)
+	}
+	if u.OnProgress != nil && u.InputSize == 0 && r.ContentLength > 0 {
+		uc := *u
+		uc.InputSize = r.ContentLength
+		u = &uc
+	}
+	return u.StreamContext(r.Context(), r.Body, factory, ch)
+}
+
+// Download writes the messages returned by next to w as text/csv, until next
+// returns io.EOF. The header is written ahead of the first message, so an
+// empty download has an empty body. Every record is flushed to the client
+// as soon as it is written, should w support flushing. A nil m uses default
+// options.
+// Once a record has been written, the status can no longer be changed, so
+// a later error merely ends the body.
+func Download(w http.ResponseWriter, m *csvpb.Marshaler, next func() (proto.Message, error)) error {
+	if m == nil {
+		m = new(csvpb.Marshaler)
+	}
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	flusher, _ := w.(http.Flusher)
+	enc := csvpb.NewEncoder(w)
+	for {
+		pb, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := m.MarshalNext(enc, pb); err != nil {
+			return err
+		}
+		if err := enc.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+}
+
+// Messages returns a function returning the messages of pbs in order, and
+// io.EOF after the last one. It adapts a slice for Download.
+func Messages(pbs []proto.Message) func() (proto.Message, error) {
+	return func() (proto.Message, error) {
+		if len(pbs) == 0 {
+			return nil, io.EOF
+		}
+		pb := pbs[0]
+		pbs = pbs[1:]
+		return pb, nil
+	}
+}
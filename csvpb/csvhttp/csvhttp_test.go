@@ -0,0 +1,185 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvhttp
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func newSimple() proto.Message {
+	return new(pb.Simple)
+}
+
+func TestUpload(t *testing.T) {
+	tests := []struct {
+		desc        string
+		contentType string
+		body        string
+		want        []proto.Message
+		err         error
+	}{
+		{"csv", "text/csv", "oInt32\n1\n2\n", []proto.Message{
+			&pb.Simple{OInt32: proto.Int32(1)},
+			&pb.Simple{OInt32: proto.Int32(2)},
+		}, nil},
+		{"csv with parameters", "text/csv; charset=utf-8; header=present", "oString\nx\n", []proto.Message{
+			&pb.Simple{OString: proto.String("x")},
+		}, nil},
+		{"json", "application/json", "{}", nil, ErrUnsupportedMediaType},
+		{"missing content type", "", "oInt32\n1\n", nil, ErrUnsupportedMediaType},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		ch := make(chan proto.Message)
+		errc := make(chan error, 1)
+		go func() {
+			_, err := Upload(req, nil, newSimple, ch)
+			errc <- err
+		}()
+		var got []proto.Message
+		for msg := range ch {
+			got = append(got, msg)
+		}
+		if err := <-errc; err != tt.err {
+			t.Errorf("%s: got error %v, want %v", tt.desc, err, tt.err)
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.desc, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if !proto.Equal(got[i], tt.want[i]) {
+				t.Errorf("%s: record %d: got %v, want %v", tt.desc, i, got[i], tt.want[i])
+			}
+		}
+	}
+}
+
+func TestUploadProgress(t *testing.T) {
+	body := "oInt32\n1\n"
+	req := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/csv")
+	req.ContentLength = int64(len(body))
+	var got []csvpb.Progress
+	u := &csvpb.Unmarshaler{OnProgress: func(p csvpb.Progress) { got = append(got, p) }}
+	ch := make(chan proto.Message, 1)
+	if _, err := Upload(req, u, newSimple, ch); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Fraction() != 1 {
+		t.Errorf("got %v", got)
+	}
+	if u.InputSize != 0 {
+		t.Errorf("Upload modified the Unmarshaler")
+	}
+}
+
+func TestUploadCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest("POST", "/", strings.NewReader("oInt32\n1\n2\n")).WithContext(ctx)
+	req.Header.Set("Content-Type", "text/csv")
+	ch := make(chan proto.Message)
+	errc := make(chan error, 1)
+	go func() {
+		_, err := Upload(req, nil, newSimple, ch)
+		errc <- err
+	}()
+	<-ch
+	cancel()
+	if err := <-errc; err != context.Canceled {
+		t.Errorf("got error %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	w := httptest.NewRecorder()
+	msgs := []proto.Message{
+		&pb.Repeats{RString: []string{"a"}},
+		&pb.Repeats{RInt32: []int32{1, 2}},
+	}
+	if err := Download(w, nil, Messages(msgs)); err != nil {
+		t.Fatal(err)
+	}
+	want := "rBool,rInt32,rInt64,rUint32,rUint64,rSint32,rSint64,rFloat,rDouble,rString,rBytes\n" +
+		",,,,,,,,,a,\n" +
+		",\"1,2\",,,,,,,,,\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("got body\n%s\nwant\n%s", got, want)
+	}
+	if got := w.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("got Content-Type %q", got)
+	}
+	if !w.Flushed {
+		t.Error("response not flushed")
+	}
+}
+
+func TestDownloadEmpty(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := Download(w, nil, Messages(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("got body %q", w.Body.String())
+	}
+}
+
+func TestDownloadError(t *testing.T) {
+	w := httptest.NewRecorder()
+	broken := errors.New("broken")
+	sent := false
+	next := func() (proto.Message, error) {
+		if sent {
+			return nil, broken
+		}
+		sent = true
+		return &pb.Simple{OInt32: proto.Int32(1)}, nil
+	}
+	if err := Download(w, nil, next); err != broken {
+		t.Errorf("got error %v, want %v", err, broken)
+	}
+	if got := strings.Count(w.Body.String(), "\n"); got != 2 {
+		t.Errorf("expected header and a single record, got %q", w.Body.String())
+	}
+}
@@ -30,21 +30,23 @@
 // OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
 
 /*
-Package csvpb provides unmarshaling between protocol buffers and RFC 4180.
+Package csvpb provides marshaling and unmarshaling between protocol buffers and
+RFC 4180.
 */
 package csvpb
 
 import (
+	"encoding/csv"
 	"errors"
 	"fmt"
 	"io"
-	"math"
 	"reflect"
-	"strconv"
+	"regexp"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
-	"encoding/base64"
 	"github.com/golang/protobuf/proto"
 
 	stpb "github.com/golang/protobuf/ptypes/struct"
@@ -74,12 +76,6 @@ type CSVPBUnmarshaler interface {
 
 type int32Slice []int32
 
-var nonFinite = map[string]float64{
-	`"NaN"`:       math.NaN(),
-	`"Infinity"`:  math.Inf(1),
-	`"-Infinity"`: math.Inf(-1),
-}
-
 // For sorting extensions ids to ensure stable output.
 func (s int32Slice) Len() int           { return len(s) }
 func (s int32Slice) Less(i, j int) bool { return s[i] < s[j] }
@@ -106,6 +102,16 @@ func (e *InvalidUnmarshalError) Error() string {
 	return "csv: Unmarshal(nil " + e.Type.String() + ")"
 }
 
+// unknownFieldError reports a column without a matching field.
+type unknownFieldError struct {
+	field      string
+	targetType reflect.Type
+}
+
+func (e *unknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q in %v", e.field, e.targetType)
+}
+
 // Unmarshaler is a configurable object for converting from a CSV
 // representation to a protocol buffer object.
 type Unmarshaler struct {
@@ -113,29 +119,260 @@ type Unmarshaler struct {
 	// failing to unmarshal.
 	AllowUnknownFields bool
 
+	// SkipRows is the number of records bulk operations skip after the
+	// header, like a row of units. Should Header be set, they are the first
+	// records of the input.
+	SkipRows int
+
+	// Whether bulk operations skip records that fail to unmarshal, as
+	// opposed to stopping at the first failure. Malformed CSV always stops
+	// a bulk operation.
+	SkipInvalidRows bool
+
+	// CellHook, if set, is called with the properties of a field and its
+	// cell before the cell is converted. It returns the cell to convert
+	// instead, or false to leave the field unset, like for light cleaning of
+	// the data.
+	CellHook func(prop *proto.Properties, cell string) (string, bool)
+
+	// Decrypt, if set, is called with the column and cell of every cell of
+	// a record before it is converted, even before CellHook and the
+	// columns of Split. It returns the plaintext of encrypted columns and
+	// other cells unchanged.
+	Decrypt func(column, ciphertext string) (string, error)
+
+	// ValueDetectors interpret cells of google.protobuf.Value fields, the
+	// first one to apply deciding. Cells none apply to are strings.
+	// DefaultValueDetectors if nil, PreciseValueDetectors to keep long
+	// integers exact.
+	ValueDetectors []ValueDetector
+
+	// HeaderStyle decides which names of fields are accepted as columns.
+	// Columns name fields by either name regardless.
+	HeaderStyle HeaderStyle
+
+	// Strictness decides how liberally cells are converted into numbers,
+	// bools and enums.
+	Strictness Strictness
+
+	// IntegerPrefixes accepts integers with the prefixes of hexadecimal,
+	// octal and binary literals, like 0x1F, 0o17 and 0b11.
+	IntegerPrefixes bool
+
+	// DigitSeparators accepts underscores grouping the digits of integers
+	// and floats, like 1_000_000.
+	DigitSeparators bool
+
+	// LenientBase64 accepts bytes cells in base64 with or without padding,
+	// in either the standard or the URL-safe alphabet, as producers differ.
+	LenientBase64 bool
+
+	// IntegerRange decides what becomes of integers out of the range of
+	// their field.
+	IntegerRange RangePolicy
+
+	// Percent decides whether float fields accept percentages.
+	Percent PercentPolicy
+
+	// NonFinite holds additional cells accepted for non-finite floats.
+	NonFinite *NonFinite
+
+	// Coercer parses the cells of scalar fields. DefaultCoercer if nil.
+	Coercer Coercer
+
+	// ExtendedDurations accepts days (d) and weeks (w) in Duration cells,
+	// like 1w3d12h, taking days as 24 hours.
+	ExtendedDurations bool
+
+	// ClampTimestamps clamps times before 0001-01-01 or after 9999-12-31,
+	// the range of Timestamps, instead of failing with a
+	// *TimestampRangeError.
+	ClampTimestamps bool
+
+	// TimestampLayouts parse the cells of Timestamp fields without a
+	// Layout, the first one to succeed deciding, instead of RFC 3339.
+	TimestampLayouts []string
+
+	// MaxMemory bounds the memory of the messages UnmarshalAll and
+	// UnmarshalFileParallel hold, estimated by their wire size. Exceeding
+	// it fails with a *MemoryLimitError. Unlimited if 0.
+	MaxMemory int64
+
+	// MaxInputSize bounds the bytes bulk operations and Unmarshal read,
+	// failing with a *LimitError beyond. Unlimited if 0.
+	MaxInputSize int64
+
+	// MaxColumns bounds the cells of a record, MaxCellSize the bytes of a
+	// cell and MaxListLength the elements of a list cell. Exceeding them
+	// fails the record with a *LimitError. Unlimited if 0.
+	MaxColumns    int
+	MaxCellSize   int
+	MaxListLength int
+
+	// ValidUTF8 fails records with cells that are not valid UTF-8.
+	ValidUTF8 bool
+
+	// FileBase is the directory the paths in the cells of bytes fields
+	// with the File option are relative to. They cannot leave it.
+	// MaxFileSize bounds the bytes of such a file, failing with a
+	// *LimitError beyond. Unlimited if 0.
+	FileBase    string
+	MaxFileSize int64
+
+	// Columns, if not nil, projects records onto the fields named, by
+	// either their orig or camel name like the paths of a FieldMask. Cells
+	// of other columns are dropped before any conversion.
+	Columns []string
+
+	// Middleware rewrites every record of bulk operations before it is
+	// filtered and converted, in order. An error fails the record like a
+	// conversion error.
+	Middleware []RecordMiddleware
+
+	// RecordFilter, if set, is called by bulk operations with the header
+	// and every record before it is converted. Records it returns false for
+	// are dropped without being converted.
+	RecordFilter func(header, record []string) bool
+
+	// Filter, if set, is called by bulk operations with every message
+	// unmarshaled. Messages it returns false for are dropped.
+	Filter func(proto.Message) bool
+
+	// Validator, if set, checks every message unmarshaled before it is
+	// returned. A rejected message fails its record like a conversion
+	// error, with CategoryValidation.
+	Validator Validator
+
+	// OnProgress, if set, is called by bulk operations with every record
+	// read, except for UnmarshalFileParallel.
+	OnProgress func(Progress)
+
+	// InputSize is the size of the input reported by OnProgress. If 0, it
+	// is detected for regular files and readers with a Size method, like
+	// *bytes.Reader.
+	InputSize int64
+
+	// NewRecordReader, if set, creates the RecordReader parsing the input,
+	// instead of a csv.Reader. UnmarshalFileParallel still splits files
+	// like CSV.
+	NewRecordReader func(r io.Reader) RecordReader
+
+	// Warn, if set, is called whenever a cell is coerced into the type of
+	// its field, by Permissive, IntegerRange or ClampTimestamps, with reason
+	// describing what was done.
+	Warn func(prop *proto.Properties, cell, reason string)
+
 	Header []string
+
+	// fieldOptionsByName holds the options of SetFieldOptions.
+	fieldOptionsByName map[string]*FieldOptions
+
+	// mappingsByName holds the columns mapped onto fields, by the full name
+	// of the field.
+	mappingsByName map[string]*columnMapping
+
+	// splits holds the expressions of SplitColumn by column.
+	splits map[string]*regexp.Regexp
+
+	// computed holds the fields of ComputeField, in order.
+	computed []computedField
+
+	// projection caches which columns of Header are kept by Columns, for
+	// bulk operations.
+	projection []bool
 }
 
 // UnmarshalNext unmarshals the next protocol buffer from a CSV.
 // This function is lenient and will decode any options permutations of the
 // related Marshaler.
+// pb is reset before being populated, so the same message may be passed
+// repeatedly to avoid allocating one per record.
+// It returns the error of Decoder.Err, should the next record be malformed.
 // Will panic, should Header be nil or Decoder have nothing to actually decode
 func (u *Unmarshaler) UnmarshalNext(dec *Decoder, pb proto.Message) error {
 	if u.Header == nil {
 		panic("Unmarshal needs header")
 	}
 	if !dec.More() {
+		if err := dec.Err(); err != nil {
+			return err
+		}
 		panic("Decoder has nothing to decode")
 	}
+	_, err := u.unmarshalNext(dec, pb)
+	return err
+}
+
+// unmarshalNext is UnmarshalNext, additionally reporting the category of
+// any error encountered.
+func (u *Unmarshaler) unmarshalNext(dec *Decoder, pb proto.Message) (ErrorCategory, error) {
 	var inputValue []string
 	var err error
 	if inputValue, err = dec.Decode(); err != nil {
-		return err
+		return CategoryParse, err
+	}
+	return u.unmarshalDecoded(inputValue, pb)
+}
+
+// UnmarshalRecord populates pb from a single record matching Header, like
+// one obtained from a source other than a Decoder.
+// pb is reset before being populated.
+// Will panic, should Header be nil.
+func (u *Unmarshaler) UnmarshalRecord(record []string, pb proto.Message) error {
+	if u.Header == nil {
+		panic("Unmarshal needs header")
+	}
+	_, err := u.unmarshalDecoded(record, pb)
+	return err
+}
+
+// unmarshalDecoded is UnmarshalRecord, additionally reporting the category
+// of any error encountered.
+func (u *Unmarshaler) unmarshalDecoded(inputValue []string, pb proto.Message) (ErrorCategory, error) {
+	category, err := u.convertDecoded(inputValue, pb)
+	if err == nil {
+		if err = u.computeFields(pb); err != nil {
+			category = CategoryConversion
+		}
+	}
+	if err == nil && u.Validator != nil {
+		if err = u.Validator.Validate(pb); err != nil {
+			category = CategoryValidation
+		}
+	}
+	return category, err
+}
+
+// convertDecoded converts inputValue into pb, without validating it.
+func (u *Unmarshaler) convertDecoded(inputValue []string, pb proto.Message) (ErrorCategory, error) {
+	if err := u.checkRecord(inputValue); err != nil {
+		return CategoryConversion, err
+	}
+	if codec := fastCodec(pb); codec != nil {
+		switch err := codec.UnmarshalRecord(u, inputValue, pb); err {
+		case nil:
+			return 0, nil
+		case ErrNoFastPath:
+		case csv.ErrFieldCount:
+			return CategoryParse, err
+		default:
+			return CategoryConversion, err
+		}
 	}
+	pb.Reset()
 	if err := u.unmarshalRecord(reflect.ValueOf(pb).Elem(), inputValue, nil); err != nil {
-		return err
+		if _, ok := err.(*unknownFieldError); ok {
+			return CategoryUnknownField, err
+		}
+		if err == csv.ErrFieldCount {
+			return CategoryParse, err
+		}
+		return CategoryConversion, err
 	}
-	return checkRequiredFields(pb)
+	if err := checkRequiredFields(pb); err != nil {
+		return CategoryRequiredField, err
+	}
+	return 0, nil
 }
 
 // Unmarshal unmarshals a CSV object stream into a protocol
@@ -143,7 +380,8 @@ func (u *Unmarshaler) UnmarshalNext(dec *Decoder, pb proto.Message) error {
 // permutations of the related Marshaler.
 // Will panic, should Header be nil.
 func (u *Unmarshaler) Unmarshal(r io.Reader, pb proto.Message) error {
-	dec := NewDecoder(r)
+	dec := u.newDecoder(r)
+	defer dec.Release()
 	return u.UnmarshalNext(dec, pb)
 }
 
@@ -176,14 +414,28 @@ func (u *Unmarshaler) unmarshalRecord(target reflect.Value, inputRecord []string
 
 	// Handle struct.
 	if targetType.Kind() == reflect.Struct {
-		csvFields := make(map[string]string)
-		if err := u.csvUnmarshal(target, u.Header, inputRecord, &csvFields); err != nil {
+		if err := scanFieldOptions(targetType); err != nil {
+			return err
+		}
+		keep := u.projection
+		if keep == nil && u.Columns != nil {
+			keep = u.projectColumns(targetType)
+		}
+		csvFields := fieldsPool.Get().(map[string]string)
+		defer putFields(csvFields)
+		if err := u.csvUnmarshal(target, u.Header, inputRecord, keep, &csvFields); err != nil {
+			return err
+		}
+		if err := u.decryptCells(csvFields); err != nil {
+			return err
+		}
+		if err := u.splitColumns(csvFields); err != nil {
 			return err
 		}
 
 		consumeField := func(prop *proto.Properties) (string, bool) {
 			// Be liberal in what names we accept; both orig_name and camelName are okay.
-			fieldNames := acceptedJSONFieldNames(prop)
+			fieldNames := u.columnNames(prop)
 
 			vOrig, okOrig := csvFields[fieldNames.orig]
 			vCamel, okCamel := csvFields[fieldNames.camel]
@@ -210,10 +462,20 @@ func (u *Unmarshaler) unmarshalRecord(target reflect.Value, inputRecord []string
 				continue
 			}
 
+			if mapping := u.mapping(sprops.Prop[i]); mapping != nil {
+				if err := u.unmarshalMapped(target.Field(i), sprops.Prop[i], mapping, csvFields); err != nil {
+					return err
+				}
+				continue
+			}
+
 			valueForField, ok := consumeField(sprops.Prop[i])
 			if !ok {
 				continue
 			}
+			if valueForField, ok = u.hookCell(sprops.Prop[i], valueForField); !ok {
+				continue
+			}
 
 			if err := u.unmarshalValue(target.Field(i), valueForField, sprops.Prop[i], noneHint); err != nil {
 				return err
@@ -225,7 +487,11 @@ func (u *Unmarshaler) unmarshalRecord(target reflect.Value, inputRecord []string
 		if len(csvFields) > 0 {
 			for _, oop := range sprops.OneofTypes {
 				raw, ok := consumeField(oop.Prop)
-				if !ok {
+				if ok {
+					raw, ok = u.hookCell(oop.Prop, raw)
+				}
+				if !ok || isNull(u.fieldOptions(oop.Prop), raw) {
+					// Other members of the oneof are written as null
 					continue
 				}
 				nv := reflect.New(oop.Type.Elem())
@@ -245,7 +511,7 @@ func (u *Unmarshaler) unmarshalRecord(target reflect.Value, inputRecord []string
 				f = fname
 				break
 			}
-			return fmt.Errorf("unknown field %q in %v", f, targetType)
+			return &unknownFieldError{field: f, targetType: targetType}
 		}
 		return nil
 	}
@@ -253,7 +519,77 @@ func (u *Unmarshaler) unmarshalRecord(target reflect.Value, inputRecord []string
 	panic("FALLBACK NOT IMPLEMENTED")
 }
 
-func (u *Unmarshaler) csvUnmarshal(target reflect.Value, fieldNames []string, fields []string, v interface{}) error {
+// fieldsPool holds the maps of cells by column used by unmarshalRecord.
+var fieldsPool = sync.Pool{
+	New: func() interface{} {
+		return make(map[string]string)
+	},
+}
+
+func putFields(fields map[string]string) {
+	for k := range fields {
+		delete(fields, k)
+	}
+	fieldsPool.Put(fields)
+}
+
+// hookCell passes cell through CellHook, if any.
+func (u *Unmarshaler) hookCell(prop *proto.Properties, cell string) (string, bool) {
+	if u.CellHook == nil {
+		return cell, true
+	}
+	return u.CellHook(prop, cell)
+}
+
+// projectColumns returns which columns of Header name a field of t in
+// Columns.
+func (u *Unmarshaler) projectColumns(t reflect.Type) []bool {
+	wanted := make(map[string]bool, len(u.Columns))
+	for _, c := range u.Columns {
+		wanted[c] = true
+	}
+	// Whichever name a field is projected by, its column may use the other.
+	// Errors are reported by unmarshalRecord
+	scanFieldOptions(t)
+	var prefixed []*columnMapping
+	want := func(prop *proto.Properties) {
+		names := columnNames(u.fieldOptions(prop), prop)
+		if wanted[names.orig] || wanted[names.camel] {
+			wanted[names.orig] = true
+			wanted[names.camel] = true
+			if mapping := u.mapping(prop); mapping != nil {
+				for _, c := range mapping.columns {
+					wanted[c] = true
+				}
+				if mapping.dynamic() {
+					prefixed = append(prefixed, mapping)
+				}
+			}
+		}
+	}
+	sprops := proto.GetProperties(t)
+	for _, prop := range sprops.Prop {
+		want(prop)
+	}
+	for _, oop := range sprops.OneofTypes {
+		want(oop.Prop)
+	}
+	for column, re := range u.splits {
+		if splitsInto(re, wanted) {
+			wanted[column] = true
+		}
+	}
+
+	keep := make([]bool, len(u.Header))
+	for i, column := range u.Header {
+		keep[i] = wanted[column] || coveredBy(prefixed, column)
+	}
+	return keep
+}
+
+// csvUnmarshal stores the fields of a record in v by their column. Only
+// columns set in keep are stored, unless keep is nil.
+func (u *Unmarshaler) csvUnmarshal(target reflect.Value, fieldNames []string, fields []string, keep []bool, v interface{}) error {
 	rv := reflect.ValueOf(v)
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return &InvalidUnmarshalError{reflect.TypeOf(v)}
@@ -262,8 +598,15 @@ func (u *Unmarshaler) csvUnmarshal(target reflect.Value, fieldNames []string, fi
 	// Dereference
 	rv = rv.Elem()
 
+	if len(fields) != len(fieldNames) {
+		return csv.ErrFieldCount
+	}
+
 	if rv.Kind() == reflect.Map {
 		for i, fieldName := range fieldNames {
+			if keep != nil && !keep[i] {
+				continue
+			}
 			fieldValue := fields[i]
 			rv.SetMapIndex(reflect.ValueOf(fieldName), reflect.ValueOf(fieldValue))
 		}
@@ -283,7 +626,7 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue string, pr
 		// If input value is "null" and target is a pointer type, then the field should be treated as not set
 		// UNLESS the target is structpb.Value, in which case it should be set to structpb.NullValue.
 		_, isCSVPBUnmarshaler := target.Interface().(CSVPBUnmarshaler)
-		if string(inputValue) == "null" && targetType != reflect.TypeOf(&stpb.Value{}) && !isCSVPBUnmarshaler {
+		if isNull(u.fieldOptions(prop), inputValue) && targetType != reflect.TypeOf(&stpb.Value{}) && !isCSVPBUnmarshaler {
 			return nil
 		}
 		target.Set(reflect.New(targetType.Elem()))
@@ -314,18 +657,17 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue string, pr
 			return u.unmarshalValue(target.Field(0), inputValue, prop, noneHint)
 		case "Any":
 			return errors.New("Cannot determine type of Any")
+		case "Empty":
+			// Allocated for any cell but null
+			return nil
 		case "Duration":
 			// TODO: Possibly unquote necessary
 			unq := string(inputValue)
 
-			d, err := time.ParseDuration(unq)
+			s, ns, err := u.durationParts(unq)
 			if err != nil {
 				return fmt.Errorf("bad Duration: %v", err)
 			}
-
-			ns := d.Nanoseconds()
-			s := ns / 1e9
-			ns %= 1e9
 			target.Field(0).SetInt(s)
 			target.Field(1).SetInt(ns)
 			return nil
@@ -333,71 +675,27 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue string, pr
 			// TODO: Possibly unquote necessary
 			unq := string(inputValue)
 
-			t, err := time.Parse(time.RFC3339Nano, unq)
+			t, err := u.parseTimestamp(prop, unq)
 			if err != nil {
 				return fmt.Errorf("bad Timestamp: %v", err)
 			}
-
-			target.Field(0).SetInt(t.Unix())
-			target.Field(1).SetInt(int64(t.Nanosecond()))
-			return nil
-		case "ListValue":
-			var s []string
-			if inputValue == "" {
-				s = []string{}
-			} else {
-				dec := NewDecoder(strings.NewReader(inputValue))
-				var err error
-				s, err = dec.Decode()
-				if err != nil {
-					return fmt.Errorf("bad ListValue: %v", err)
-				}
+			s, ns, err := u.timestampParts(prop, unq, t)
+			if err != nil {
+				return err
 			}
 
-			target.Field(0).Set(reflect.ValueOf(make([]*stpb.Value, len(s))))
-			for i, sv := range s {
-				if err := u.unmarshalValue(target.Field(0).Index(i), sv, prop, noneHint); err != nil {
-					return err
-				}
-			}
+			target.Field(0).SetInt(s)
+			target.Field(1).SetInt(ns)
 			return nil
+		case "ListValue":
+			return u.unmarshalListValue(target.Addr().Interface().(*stpb.ListValue), inputValue, prop)
 		case "Value":
-			ivStr := string(inputValue)
-			if ivStr == "" {
-				target.Field(0).Set(reflect.ValueOf(&stpb.Value_NullValue{}))
-				return nil
-			}
-
-			if strings.Contains(ivStr, ".") {
-				if v, err := strconv.ParseFloat(ivStr, 0); err == nil {
-					target.Field(0).Set(reflect.ValueOf(&stpb.Value_NumberValue{
-						NumberValue: v,
-					}))
-					return nil
-				}
-			}
-
-			if ivStr != "1" && ivStr != "t" && ivStr != "T" && ivStr != "0" && ivStr != "f" && ivStr != "F" {
-				if v, err := strconv.ParseBool(ivStr); err == nil {
-					target.Field(0).Set(reflect.ValueOf(&stpb.Value_BoolValue{
-						BoolValue: v,
-					}))
-					return nil
-				}
-			}
-
-			if v, err := strconv.ParseFloat(ivStr, 0); err == nil {
-				target.Field(0).Set(reflect.ValueOf(&stpb.Value_NumberValue{
-					NumberValue: v,
-				}))
-				return nil
+			kind := target.Field(0)
+			if v := u.detectValue(inputValue); v.Kind != nil {
+				kind.Set(reflect.ValueOf(v.Kind))
+			} else {
+				kind.Set(reflect.Zero(kind.Type()))
 			}
-
-			// There is no good way to detect signedness so default to plain
-			// string for anything else
-			target.Field(0).Set(reflect.ValueOf(&stpb.Value_StringValue{
-				StringValue: ivStr,
-			}))
 			return nil
 		}
 	}
@@ -411,7 +709,15 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue string, pr
 	if targetType.Kind() == reflect.Slice {
 		// Handle encoded bytes
 		if targetType.Elem().Kind() == reflect.Uint8 {
-			decoded, err := base64.StdEncoding.DecodeString(inputValue)
+			var decoded []byte
+			var err error
+			if o := u.fieldOptions(prop); o != nil && o.File {
+				decoded, err = u.loadFile(inputValue)
+			} else if compressed(o) {
+				decoded, err = u.decompressCell(inputValue)
+			} else {
+				decoded, err = u.parseBytes(inputValue)
+			}
 			if err != nil {
 				return err
 			}
@@ -419,129 +725,103 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue string, pr
 			return nil
 		}
 
-		dec := NewDecoder(strings.NewReader(inputValue))
-		slc, err := dec.Decode()
-		if err != nil {
-			return err
-		}
+		return u.unmarshalList(target, RawMessage(inputValue), prop)
+	}
 
-		if slc != nil {
-			l := len(slc)
-			target.Set(reflect.MakeSlice(targetType, l, l))
-			for i := 0; i < l; i++ {
-				if err := u.unmarshalValue(target.Index(i), slc[i], prop, noneHint); err != nil {
-					return err
-				}
-			}
+	// Does not handle embedded maps
+	if targetType.Kind() == reflect.Map {
+		if isNull(u.fieldOptions(prop), inputValue) {
+			return nil
 		}
+		return errors.New("Maps not supported yet")
+	}
+
+	if prop != nil && prop.Enum == nullValueEnum && isNull(u.fieldOptions(prop), inputValue) {
+		target.SetInt(0)
 		return nil
 	}
 
-	// Does not handle embedded maps
+	switch targetType.Kind() {
+	case reflect.Bool, reflect.Float32, reflect.Float64, reflect.Int32,
+		reflect.Int64, reflect.Uint32, reflect.Uint64:
+		var err error
+		if inputValue, err = u.scalarCell(inputValue); err != nil {
+			return err
+		}
+	}
 
 	// Handle enums, which have an underlying type of int32,
-	// and may appear as strings.
-	// The case of an enum appearing as a number is handled
-	// at the bottom of this function.
+	// and may appear as strings or numbers.
 	if prop != nil && prop.Enum != "" {
-		vmap := proto.EnumValueMap(prop.Enum)
-		inputValue = strings.TrimSpace(inputValue)
-		s := inputValue
-		n, ok := vmap[s]
-		if !ok {
-			// Check whether input is a number and thus we handle it later
-			_, err := strconv.ParseUint(s, 10, 32)
-			if err != nil {
-				return fmt.Errorf("unknown value %q for enum %s", s, prop.Enum)
-			}
-			ok = false
+		n, err := ParseEnum(inputValue, proto.EnumValueMap(prop.Enum))
+		if err != nil {
+			return fmt.Errorf("unknown value %q for enum %s", strings.TrimSpace(inputValue), prop.Enum)
 		}
-		if ok { // Only process string
-			if targetType.Kind() != reflect.Int32 {
-				return fmt.Errorf("invalid target %q for enum %s", targetType.Kind(), prop.Enum)
-			}
-			target.SetInt(int64(n))
-			return nil
+		if targetType.Kind() != reflect.Int32 {
+			return fmt.Errorf("invalid target %q for enum %s", targetType.Kind(), prop.Enum)
 		}
+		target.SetInt(int64(n))
+		return nil
 	}
 
-	isBool := targetType.Kind() == reflect.Bool
-	if isBool {
-		if strings.HasPrefix(string(inputValue), `"`) {
-			inputValue = inputValue[1 : len(inputValue)-1]
-		}
-
-		lowerValue := strings.ToLower(inputValue)
-		boolValue, err := strconv.ParseBool(lowerValue)
+	switch targetType.Kind() {
+	case reflect.Bool:
+		boolValue, err := u.parseBool(inputValue, prop)
 		if err != nil {
 			return err
 		}
 		target.SetBool(boolValue)
 		return nil
-	}
-
-	// Non-finite numbers can be encoded as strings.
-	isFloat := targetType.Kind() == reflect.Float32 || targetType.Kind() == reflect.Float64
-	if isFloat {
-		if num, ok := nonFinite[string(inputValue)]; ok {
-			target.SetFloat(num)
-			return nil
-		}
-	}
-
-	// integers & floats can be encoded as strings. In this case we drop
-	// the quotes and proceed as normal.
-	isNum := targetType.Kind() == reflect.Int64 || targetType.Kind() == reflect.Uint64 ||
-		targetType.Kind() == reflect.Int32 || targetType.Kind() == reflect.Uint32 ||
-		targetType.Kind() == reflect.Float32 || targetType.Kind() == reflect.Float64
-	if isNum && strings.HasPrefix(string(inputValue), `"`) {
-		inputValue = inputValue[1 : len(inputValue)-1]
-	}
-
-	switch targetType.Kind() {
 	case reflect.Float32:
-		floatValue, err := strconv.ParseFloat(inputValue, 32)
+		floatValue, err := u.parseFloat(inputValue, 32)
 		if err != nil {
 			return err
 		}
 		target.SetFloat(floatValue)
 		return nil
 	case reflect.Float64:
-		floatValue, err := strconv.ParseFloat(inputValue, 64)
+		floatValue, err := u.parseFloat(inputValue, 64)
 		if err != nil {
 			return err
 		}
 		target.SetFloat(floatValue)
 		return nil
 	case reflect.Int32:
-		intValue, err := strconv.ParseInt(inputValue, 10, 32)
+		intValue, err := u.parseInt(inputValue, 32, prop)
 		if err != nil {
 			return err
 		}
 		target.SetInt(intValue)
 		return nil
 	case reflect.Int64:
-		intValue, err := strconv.ParseInt(inputValue, 10, 64)
+		intValue, err := u.parseInt(inputValue, 64, prop)
 		if err != nil {
 			return err
 		}
 		target.SetInt(intValue)
 		return nil
 	case reflect.Uint32:
-		uintValue, err := strconv.ParseUint(inputValue, 10, 32)
+		uintValue, err := u.parseUint(inputValue, 32, prop)
 		if err != nil {
 			return err
 		}
 		target.SetUint(uintValue)
 		return nil
 	case reflect.Uint64:
-		uintValue, err := strconv.ParseUint(inputValue, 10, 64)
+		uintValue, err := u.parseUint(inputValue, 64, prop)
 		if err != nil {
 			return err
 		}
 		target.SetUint(uintValue)
 		return nil
 	case reflect.String:
+		if compressed(u.fieldOptions(prop)) {
+			b, err := u.decompressCell(inputValue)
+			if err != nil {
+				return err
+			}
+			inputValue = string(b)
+		}
 		target.SetString(inputValue)
 		return nil
 	}
@@ -549,6 +829,31 @@ func (u *Unmarshaler) unmarshalValue(target reflect.Value, inputValue string, pr
 	return errors.New("Not handled yet")
 }
 
+// unmarshalList converts the elements of a cell holding a list into the
+// slice target.
+func (u *Unmarshaler) unmarshalList(target reflect.Value, raw RawMessage, prop *proto.Properties) error {
+	elems, err := splitList(string(raw), listDelimiter(u.fieldOptions(prop)))
+	if err != nil {
+		return fmt.Errorf("bad list: %v", err)
+	}
+	if u.MaxListLength > 0 && len(elems) > u.MaxListLength {
+		return &LimitError{What: "list length", Limit: int64(u.MaxListLength)}
+	}
+	target.Set(reflect.MakeSlice(target.Type(), len(elems), len(elems)))
+	for i, elem := range elems {
+		v := target.Index(i)
+		if err := u.unmarshalValue(v, elem, prop, noneHint); err != nil {
+			return err
+		}
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			// Repeated messages have no nil elements, null wrappers hold
+			// the zero value
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+	}
+	return nil
+}
+
 // jsonProperties returns parsed proto.Properties for the field and corrects JSONName attribute.
 func jsonProperties(f reflect.StructField, origName bool) *proto.Properties {
 	var prop proto.Properties
@@ -571,6 +876,35 @@ func acceptedJSONFieldNames(prop *proto.Properties) fieldNames {
 	return opts
 }
 
+// HeaderStyle decides how the columns of fields are named.
+type HeaderStyle int
+
+const (
+	// HeaderAuto accepts both the orig and the camel name of fields on
+	// input. Output uses the camel name, unless Marshaler.OrigName is set.
+	HeaderAuto HeaderStyle = iota
+	// HeaderOrigName uses the original (.proto) name of fields.
+	HeaderOrigName
+	// HeaderCamelCase uses the camel name of fields, like JSON.
+	HeaderCamelCase
+)
+
+// restrict returns the names of n accepted with style.
+func (n fieldNames) restrict(style HeaderStyle) fieldNames {
+	switch style {
+	case HeaderOrigName:
+		n.camel = n.orig
+	case HeaderCamelCase:
+		n.orig = n.camel
+	}
+	return n
+}
+
+// columnNames returns the column names u accepts for the field with prop.
+func (u *Unmarshaler) columnNames(prop *proto.Properties) fieldNames {
+	return columnNames(u.fieldOptions(prop), prop).restrict(u.HeaderStyle)
+}
+
 // Writer wrapper inspired by https://blog.golang.org/errors-are-values
 type errWriter struct {
 	writer io.Writer
@@ -681,7 +1015,9 @@ func checkRequiredFields(pb proto.Message) error {
 				continue
 			}
 			// Check each map value.
+			// Sorted, so the same field is reported every time.
 			keys := field.MapKeys()
+			sort.Sort(mapKeys(keys))
 			for _, k := range keys {
 				v := field.MapIndex(k)
 				if err := checkRequiredFieldsInValue(v); err != nil {
@@ -36,6 +36,7 @@ import (
 	"io"
 	"math"
 	"reflect"
+	"sort"
 	"strings"
 	"testing"
 
@@ -219,6 +220,8 @@ var unmarshalingTests = []struct {
 	{"PreEpochTimestamp", Unmarshaler{}, "ts\n1969-12-31T23:59:58.999999995Z", &pb.KnownTypes{Ts: &tspb.Timestamp{Seconds: -2, Nanos: 999999995}}},
 	{"ZeroTimeTimestamp", Unmarshaler{}, "ts\n0001-01-01T00:00:00Z", &pb.KnownTypes{Ts: &tspb.Timestamp{Seconds: -62135596800, Nanos: 0}}},
 	{"null Timestamp", Unmarshaler{}, "ts\nnull", &pb.KnownTypes{Ts: nil}},
+	{"Timestamp layouts", Unmarshaler{TimestampLayouts: []string{"2006-01-02", "2006-01-02T15:04:05Z07:00"}}, "ts\n2014-05-13", &pb.KnownTypes{Ts: &tspb.Timestamp{Seconds: 1399939200}}},
+	{"Timestamp fallback layout", Unmarshaler{TimestampLayouts: []string{"2006-01-02", "2006-01-02T15:04:05Z07:00"}}, "ts\n2014-05-13T16:53:20Z", &pb.KnownTypes{Ts: &tspb.Timestamp{Seconds: 14e8}}},
 	{"null Struct", Unmarshaler{}, "st\nnull", &pb.KnownTypes{St: nil}},
 
 	{"null ListValue", Unmarshaler{}, "lv\nnull", &pb.KnownTypes{Lv: nil}},
@@ -310,3 +313,193 @@ func TestUnmarshalingBadInput(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmarshalNextReuse(t *testing.T) {
+	u := Unmarshaler{Header: []string{"oInt32", "oString"}}
+	dec := NewDecoder(strings.NewReader("1,foo\n2,null"))
+
+	p := new(pb.Simple)
+	if err := u.UnmarshalNext(dec, p); err != nil {
+		t.Fatal(err)
+	}
+	exp := &pb.Simple{OInt32: proto.Int32(1), OString: proto.String("foo")}
+	if !proto.Equal(p, exp) {
+		t.Fatalf("Unexpected: got %v, expected %v", p, exp)
+	}
+
+	if err := u.UnmarshalNext(dec, p); err != nil {
+		t.Fatal(err)
+	}
+	exp = &pb.Simple{OInt32: proto.Int32(2)}
+	if !proto.Equal(p, exp) {
+		t.Fatalf("Unexpected: got %v, expected %v", p, exp)
+	}
+}
+
+func TestUnmarshalRecord(t *testing.T) {
+	u := Unmarshaler{Header: []string{"oInt32", "oString"}}
+	p := &pb.Simple{OBool: proto.Bool(true)}
+	if err := u.UnmarshalRecord([]string{"3", "a,b"}, p); err != nil {
+		t.Fatal(err)
+	}
+	exp := &pb.Simple{OInt32: proto.Int32(3), OString: proto.String("a,b")}
+	if !proto.Equal(p, exp) {
+		t.Errorf("Unexpected: got %v, expected %v", p, exp)
+	}
+
+	if err := u.UnmarshalRecord([]string{"3"}, p); err != csv.ErrFieldCount {
+		t.Errorf("UnmarshalRecord() error = %v, want %v", err, csv.ErrFieldCount)
+	}
+}
+
+func TestUnmarshalCellHook(t *testing.T) {
+	var seen []string
+	u := Unmarshaler{
+		Header: []string{"oInt32", "oDouble", "oString", "title"},
+		CellHook: func(prop *proto.Properties, cell string) (string, bool) {
+			seen = append(seen, prop.OrigName)
+			switch prop.OrigName {
+			case "o_double":
+				return strings.TrimPrefix(cell, "$"), true
+			case "o_string":
+				return cell, cell != "n/a"
+			}
+			return cell, true
+		},
+	}
+	p := new(pb.Simple)
+	if err := u.UnmarshalRecord([]string{"1", "$2.5", "n/a", "x"}, p); err == nil {
+		t.Error("UnmarshalRecord() succeeded with an unknown column")
+	}
+	u.Header = u.Header[:3]
+	if err := u.UnmarshalRecord([]string{"1", "$2.5", "n/a"}, p); err != nil {
+		t.Fatal(err)
+	}
+	exp := &pb.Simple{OInt32: proto.Int32(1), ODouble: proto.Float64(2.5)}
+	if !proto.Equal(p, exp) {
+		t.Errorf("Unexpected: got %v, expected %v", p, exp)
+	}
+
+	seen = nil
+	u.Header = []string{"title", "salary"}
+	w := new(pb.MsgWithOneof)
+	if err := u.UnmarshalRecord([]string{"null", "7"}, w); err != nil {
+		t.Fatal(err)
+	}
+	// Oneof members are visited in no particular order.
+	sort.Strings(seen)
+	if w.GetSalary() != 7 || !reflect.DeepEqual(seen, []string{"salary", "title"}) {
+		t.Errorf("Unexpected: got %v, hook saw %v", w, seen)
+	}
+}
+
+func TestHeaderStyle(t *testing.T) {
+	tests := []struct {
+		style  HeaderStyle
+		column string
+		ok     bool
+	}{
+		{HeaderAuto, "o_int32", true},
+		{HeaderAuto, "oInt32", true},
+		{HeaderOrigName, "o_int32", true},
+		{HeaderOrigName, "oInt32", false},
+		{HeaderCamelCase, "o_int32", false},
+		{HeaderCamelCase, "oInt32", true},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{Header: []string{tt.column}, HeaderStyle: tt.style}
+		p := new(pb.Simple)
+		err := u.UnmarshalRecord([]string{"1"}, p)
+		if tt.ok && (err != nil || p.GetOInt32() != 1) {
+			t.Errorf("%d %s: got %v, %v", tt.style, tt.column, p, err)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("%d %s: got %v, expected an error", tt.style, tt.column, p)
+		}
+	}
+}
+
+func TestUnmarshalColumns(t *testing.T) {
+	tests := []struct {
+		desc    string
+		columns []string
+		exp     *pb.Simple
+	}{
+		{"camel names", []string{"oInt32", "oString"}, &pb.Simple{OInt32: proto.Int32(1), OString: proto.String("x")}},
+		{"orig names", []string{"o_int32"}, &pb.Simple{OInt32: proto.Int32(1)}},
+		{"none", []string{}, &pb.Simple{}},
+	}
+	// The unknown and bad columns would fail unless dropped.
+	input := "oInt32,oBool,unknown,o_string\n1,bad,?,x\n"
+	for _, tt := range tests {
+		u := &Unmarshaler{Columns: tt.columns}
+		pbs, _, err := u.UnmarshalAll(strings.NewReader(input), func() proto.Message { return new(pb.Simple) })
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if len(pbs) != 1 || !proto.Equal(pbs[0], tt.exp) {
+			t.Errorf("%s: got %v, expected %v", tt.desc, pbs, tt.exp)
+		}
+
+		u.Header = []string{"oInt32", "oBool", "unknown", "o_string"}
+		p := new(pb.Simple)
+		if err := u.UnmarshalRecord([]string{"1", "bad", "?", "x"}, p); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+		} else if !proto.Equal(p, tt.exp) {
+			t.Errorf("%s: got %v, expected %v", tt.desc, p, tt.exp)
+		}
+	}
+
+	u := &Unmarshaler{Header: []string{"title", "salary"}, Columns: []string{"salary"}}
+	w := new(pb.MsgWithOneof)
+	if err := u.UnmarshalRecord([]string{"x", "7"}, w); err != nil {
+		t.Fatal(err)
+	}
+	if w.GetSalary() != 7 {
+		t.Errorf("Unexpected: got %v", w)
+	}
+}
+
+// wrapperLists is a message with repeated wrappers.
+type wrapperLists struct {
+	Ints                 []*wpb.Int32Value  `protobuf:"bytes,1,rep,name=ints,proto3"`
+	Strs                 []*wpb.StringValue `protobuf:"bytes,2,rep,name=strs,proto3"`
+	XXX_NoUnkeyedLiteral struct{}           `json:"-"`
+	XXX_unrecognized     []byte             `json:"-"`
+	XXX_sizecache        int32              `json:"-"`
+}
+
+func (m *wrapperLists) Reset()         { *m = wrapperLists{} }
+func (m *wrapperLists) String() string { return proto.CompactTextString(m) }
+func (*wrapperLists) ProtoMessage()    {}
+
+func TestRepeatedWrappers(t *testing.T) {
+	u := &Unmarshaler{Header: []string{"ints", "strs"}}
+	got := new(wrapperLists)
+	if err := u.UnmarshalString(`"1,null,3","a,,null"`, got); err != nil {
+		t.Fatal(err)
+	}
+	want := &wrapperLists{
+		Ints: []*wpb.Int32Value{{Value: 1}, {}, {Value: 3}},
+		Strs: []*wpb.StringValue{{Value: "a"}, {}, {}},
+	}
+	if !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if _, err := proto.Marshal(got); err != nil {
+		t.Errorf("Marshal() = %v", err)
+	}
+
+	var buf strings.Builder
+	if err := new(Marshaler).Marshal(&buf, want); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "ints,strs\n\"1,0,3\",\"a,,\"\n"; buf.String() != exp {
+		t.Errorf("got %q, want %q", buf.String(), exp)
+	}
+
+	if err := u.UnmarshalString(`"1,x",`, got); err == nil {
+		t.Error("UnmarshalString() succeeded, want error")
+	}
+}
@@ -0,0 +1,150 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package csvpbtest helps asserting that messages survive CSV.
+
+RoundTrip marshals messages and unmarshals them again, reporting every field
+that changed on the way:
+
+	func TestOrdersCSV(t *testing.T) {
+		csvpbtest.RoundTrip(t, orders, &csvpbtest.Options{
+			Marshaler: csvpb.Marshaler{OrigName: true},
+		})
+	}
+
+Golden compares the marshaled messages against a file, which running the
+tests with -csvpbtest.update rewrites.
+*/
+package csvpbtest
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+var update = flag.Bool("csvpbtest.update", false, "rewrite the golden files of csvpbtest.Golden")
+
+// Options configures how messages are marshaled and unmarshaled.
+// The header is always marshaled, so Unmarshaler.Header is usually left
+// nil.
+type Options struct {
+	Marshaler   csvpb.Marshaler
+	Unmarshaler csvpb.Unmarshaler
+}
+
+// Marshal marshals msgs as the records of a CSV, failing t on error.
+func Marshal(t testing.TB, msgs []proto.Message, opts *Options) []byte {
+	t.Helper()
+	if opts == nil {
+		opts = new(Options)
+	}
+	var buf bytes.Buffer
+	enc := csvpb.NewEncoder(&buf)
+	for i, pb := range msgs {
+		if err := opts.Marshaler.MarshalNext(enc, pb); err != nil {
+			t.Fatalf("marshaling message %d: %v", i+1, err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// RoundTrip marshals msgs, unmarshals the result and reports every field
+// differing from msgs as an error of t. msgs must all be of the same type.
+func RoundTrip(t testing.TB, msgs []proto.Message, opts *Options) {
+	t.Helper()
+	if opts == nil {
+		opts = new(Options)
+	}
+	if len(msgs) == 0 {
+		return
+	}
+	data := Marshal(t, msgs, opts)
+	diffs, err := opts.Unmarshaler.Diff(bytes.NewReader(data), factory(msgs[0]), msgs)
+	if err != nil {
+		t.Fatalf("unmarshaling %q: %v", data, err)
+	}
+	for _, d := range diffs {
+		t.Errorf("round trip: %v", d)
+	}
+}
+
+// Golden marshals msgs and compares the result against the file at path.
+// With -csvpbtest.update, the file is rewritten instead.
+func Golden(t testing.TB, path string, msgs []proto.Message, opts *Options) {
+	t.Helper()
+	got := Marshal(t, msgs, opts)
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(got, want) {
+		return
+	}
+	gotLines := strings.SplitAfter(string(got), "\n")
+	wantLines := strings.SplitAfter(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			t.Errorf("%s:%d: got %q, want %q", path, i+1, g, w)
+		}
+	}
+}
+
+// factory creates messages of the type of pb.
+func factory(pb proto.Message) func() proto.Message {
+	t := reflect.TypeOf(pb).Elem()
+	return func() proto.Message {
+		return reflect.New(t).Interface().(proto.Message)
+	}
+}
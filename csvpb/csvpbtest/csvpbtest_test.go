@@ -0,0 +1,117 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpbtest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// recorder collects the failures of the helpers under test.
+type recorder struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (r *recorder) Helper() {}
+
+func (r *recorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *recorder) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	r.fatal = true
+	// Like testing.T, do not return to the helper
+	panic(r)
+}
+
+func record(fn func(r *recorder)) (r *recorder) {
+	r = new(recorder)
+	defer func() {
+		if v := recover(); v != nil && v != r {
+			panic(v)
+		}
+	}()
+	fn(r)
+	return r
+}
+
+var simples = []proto.Message{
+	&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("one"), OBytes: []byte{1}},
+	&pb.Simple{OInt32: proto.Int32(2), OString: proto.String("two, \"quoted\""), OBytes: []byte("two")},
+}
+
+func TestRoundTrip(t *testing.T) {
+	RoundTrip(t, simples, nil)
+	RoundTrip(t, simples, &Options{Marshaler: csvpb.Marshaler{OrigName: true}})
+}
+
+func TestRoundTripLossy(t *testing.T) {
+	// A lossy float format changes the value
+	msgs := []proto.Message{&pb.Simple{ODouble: proto.Float64(1.25), OBytes: []byte{}}}
+	opts := &Options{Marshaler: csvpb.Marshaler{FloatFormat: csvpb.Decimals(1)}}
+	r := record(func(r *recorder) { RoundTrip(r, msgs, opts) })
+	if len(r.errors) != 1 || r.fatal {
+		t.Errorf("got errors %q", r.errors)
+	}
+}
+
+func TestGolden(t *testing.T) {
+	Golden(t, "testdata/simple.csv", simples, nil)
+	if *update {
+		return
+	}
+
+	dir, err := ioutil.TempDir("", "csvpbtest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "simple.csv")
+	if err := ioutil.WriteFile(path, []byte("oInt32,oString\n1,one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := record(func(r *recorder) { Golden(r, path, simples, nil) })
+	if len(r.errors) == 0 || r.fatal {
+		t.Errorf("got errors %q", r.errors)
+	}
+}
@@ -35,24 +35,89 @@ import (
 	"bufio"
 	"encoding/csv"
 	"io"
+	"sync"
 )
 
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	reader io.Reader
+	n      int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
+
+// RecordReader reads records one by one, returning io.EOF after the last.
+// It is implemented by csv.Reader, but may as well be a faster parser or a
+// source of records other than CSV text.
+type RecordReader interface {
+	Read() (record []string, err error)
+}
+
 // Decoder decodes single line
 type Decoder struct {
-	buffer        *bufio.Reader
-	reader        *csv.Reader
-	v             []string
-	err           error
-	reportedError bool
+	// ReuseRecord makes Decode return a slice sharing the backing array of
+	// the slice returned by the previous call, to save an allocation per
+	// record. The cells themselves are never overwritten.
+	ReuseRecord bool
+
+	record  []string
+	counter *countingReader
+	// offset is the input consumed by the records decoded so far.
+	offset int64
+	// size is the size of the input for progress reports, -1 if unknown.
+	size   int64
+	buffer *bufio.Reader
+	reader RecordReader
+	// plain tells whether reader is a default csv.Reader of buffer, whose
+	// input is CSV as written by WriteTo.
+	plain bool
+	v     []string
+	err   error
+}
+
+// bufferPool holds the buffers of released Decoders.
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		return bufio.NewReader(nil)
+	},
 }
 
 // NewDecoder creates a new Decoder. Internal state is implementation detail.
 func NewDecoder(r io.Reader) *Decoder {
+	return newDecoder(r, nil)
+}
 
-	br := bufio.NewReader(r)
+// NewRecordDecoder creates a Decoder of the records of rr. Its
+// InputOffset is always 0 and WriteTo writes the records as CSV.
+func NewRecordDecoder(rr RecordReader) *Decoder {
 	d := &Decoder{
-		buffer: br,
-		reader: csv.NewReader(br),
+		size:   -1,
+		reader: rr,
+	}
+	d.prefetch()
+	return d
+}
+
+// newDecoder creates a Decoder of the records read from r by the
+// RecordReader returned by newReader, a csv.Reader if nil.
+func newDecoder(r io.Reader, newReader func(io.Reader) RecordReader) *Decoder {
+	cr := &countingReader{reader: r}
+	br := bufferPool.Get().(*bufio.Reader)
+	br.Reset(cr)
+	d := &Decoder{
+		counter: cr,
+		size:    -1,
+		buffer:  br,
+	}
+	if newReader != nil {
+		d.reader = newReader(br)
+	} else {
+		d.reader = csv.NewReader(br)
+		d.plain = true
 	}
 
 	d.prefetch()
@@ -60,6 +125,12 @@ func NewDecoder(r io.Reader) *Decoder {
 }
 
 func (d *Decoder) prefetch() {
+	if d.buffer == nil {
+		d.v, d.err = d.reader.Read()
+		return
+	}
+	// Anything still buffered is not consumed yet
+	d.offset = d.counter.n - int64(d.buffer.Buffered())
 	next, _ := d.buffer.Peek(1)
 	d.v, d.err = d.reader.Read()
 	if len(next) == 0 {
@@ -69,27 +140,26 @@ func (d *Decoder) prefetch() {
 	}
 }
 
-// More returns whether there is another value to return
+// More returns whether another record is available. It returns false at
+// the end of the input as well as at malformed CSV, reported by Err.
 func (d *Decoder) More() bool {
-	if d.err == nil {
-		// We have a new value available
-		return true
-	}
+	return d.err == nil
+}
 
+// Err returns the error that stopped More, nil at the end of the input.
+// Decoding never advances beyond an error.
+func (d *Decoder) Err() error {
 	if d.err == io.EOF {
-		return false
+		return nil
 	}
-
-	// Has the error been reported yet?
-	return !d.reportedError
+	return d.err
 }
 
 // Decode extracts a slice of strings from next line. Returns nil when
-// nothing else to extract
+// nothing else to extract, along with the error of Err, if any.
 func (d *Decoder) Decode() ([]string, error) {
 	// Value and error are already prefetched
 	if d.err != nil {
-		d.reportedError = true
 		if d.err == io.EOF {
 			return d.v, nil
 		}
@@ -99,6 +169,104 @@ func (d *Decoder) Decode() ([]string, error) {
 	}
 
 	currentV, currentErr := d.v, d.err
+	if d.ReuseRecord {
+		// The prefetch overwrites the record of the reader
+		d.record = append(d.record[:0], currentV...)
+		currentV = d.record
+		if cr, ok := d.reader.(*csv.Reader); ok {
+			cr.ReuseRecord = true
+		}
+	}
 	d.prefetch()
 	return currentV, currentErr
 }
+
+// InputOffset returns the number of bytes of the input consumed by the
+// records decoded so far, unlike the bytes read ahead from the underlying
+// io.Reader.
+func (d *Decoder) InputOffset() int64 {
+	return d.offset
+}
+
+func (d *Decoder) inputSize() int64 {
+	return d.size
+}
+
+// WriteTo implements io.WriterTo. It writes the records not yet decoded to
+// w, consuming the input. The next record is re-encoded, as it is already
+// decoded ahead, whereas the rest of the input is copied verbatim, using
+// the fast paths of w and the underlying io.Reader. Input parsed by a
+// RecordReader other than the default csv.Reader is re-encoded as well.
+func (d *Decoder) WriteTo(w io.Writer) (int64, error) {
+	if d.err != nil {
+		return 0, d.Err()
+	}
+
+	cw := &countingWriter{writer: w}
+	enc := csv.NewWriter(cw)
+	if err := enc.Write(d.v); err != nil {
+		return cw.n, err
+	}
+	if !d.plain {
+		return d.writeRecordsTo(cw, enc)
+	}
+	enc.Flush()
+	if err := enc.Error(); err != nil {
+		return cw.n, err
+	}
+	n, err := d.buffer.WriteTo(w)
+	d.offset = d.counter.n
+	d.v = nil
+	d.err = io.EOF
+	if err != nil {
+		d.err = err
+	}
+	return cw.n + n, err
+}
+
+// writeRecordsTo re-encodes the records not yet decoded by enc, which
+// writes to cw.
+func (d *Decoder) writeRecordsTo(cw *countingWriter, enc *csv.Writer) (int64, error) {
+	for {
+		d.v, d.err = d.reader.Read()
+		if d.err != nil {
+			break
+		}
+		if err := enc.Write(d.v); err != nil {
+			return cw.n, err
+		}
+	}
+	d.v = nil
+	enc.Flush()
+	if err := enc.Error(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, d.Err()
+}
+
+// countingWriter counts the bytes written to the underlying writer.
+type countingWriter struct {
+	writer io.Writer
+	n      int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// Release returns the buffer of d to a pool shared by all Decoders, so
+// decoding many small inputs allocates less. More reports false
+// afterwards. Calling Release is optional.
+func (d *Decoder) Release() {
+	if d.buffer == nil {
+		return
+	}
+	d.buffer.Reset(nil)
+	bufferPool.Put(d.buffer)
+	d.buffer = nil
+	d.reader = nil
+	d.v = nil
+	d.err = io.EOF
+}
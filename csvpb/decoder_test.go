@@ -32,9 +32,15 @@
 package csvpb
 
 import (
+	"encoding/csv"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
 )
 
 func TestEmptyDecode(t *testing.T) {
@@ -102,3 +108,176 @@ func TestDoubleDecode(t *testing.T) {
 		t.Fatal("Fourth More() lies")
 	}
 }
+
+func TestDecodeReuseRecord(t *testing.T) {
+	d := NewDecoder(strings.NewReader("foo,0\nbar,1\nmy,2\n"))
+	d.ReuseRecord = true
+	var records [][]string
+	var cells []string
+	for d.More() {
+		v, err := d.Decode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, v)
+		cells = append(cells, v...)
+	}
+	if !reflect.DeepEqual(cells, []string{"foo", "0", "bar", "1", "my", "2"}) {
+		t.Fatalf("Cells wrong %v", cells)
+	}
+	if &records[0][0] != &records[2][0] {
+		t.Error("Record not reused")
+	}
+}
+
+func TestDecoderRelease(t *testing.T) {
+	d := NewDecoder(strings.NewReader("foo\nbar\n"))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	d.Release()
+	d.Release()
+	if d.More() {
+		t.Fatal("More() after Release")
+	}
+
+	// Buffers handed out again start afresh
+	for i := 0; i < 3; i++ {
+		d := NewDecoder(strings.NewReader("baz\n"))
+		v, err := d.Decode()
+		if err != nil || !reflect.DeepEqual(v, []string{"baz"}) {
+			t.Fatalf("Value wrong %v, %v", v, err)
+		}
+		if d.More() {
+			t.Fatal("More() lies")
+		}
+		d.Release()
+	}
+}
+
+func TestDecoderErr(t *testing.T) {
+	d := NewDecoder(strings.NewReader("foo,0\nbar\"baz\nmy,2"))
+	v, err := d.Decode()
+	if err != nil || !reflect.DeepEqual(v, []string{"foo", "0"}) {
+		t.Fatalf("Value wrong %v, %v", v, err)
+	}
+	for i := 0; i < 2; i++ {
+		if d.More() {
+			t.Fatal("More() at malformed record")
+		}
+		if _, ok := d.Err().(*csv.ParseError); !ok {
+			t.Fatalf("Unexpected error %v", d.Err())
+		}
+		if _, err := d.Decode(); err != d.Err() {
+			t.Fatalf("Decode() returned %v", err)
+		}
+	}
+
+	d = NewDecoder(strings.NewReader("foo\n"))
+	d.Decode()
+	if d.More() || d.Err() != nil {
+		t.Fatalf("Unexpected at end: %v", d.Err())
+	}
+}
+
+func TestDecoderWriteTo(t *testing.T) {
+	input := "a,b\n\"x\"\"y\",1\n\"z\n\",2\n"
+	d := NewDecoder(strings.NewReader(input))
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	n, err := d.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := "\"x\"\"y\",1\n\"z\n\",2\n"
+	if buf.String() != exp || n != int64(len(exp)) {
+		t.Fatalf("Unexpected: got %q (%d bytes), expected %q", buf.String(), n, exp)
+	}
+	if d.More() {
+		t.Fatal("More() after WriteTo")
+	}
+	if n, err := d.WriteTo(&buf); n != 0 || err != nil {
+		t.Fatalf("Unexpected: got %d, %v", n, err)
+	}
+}
+
+func TestDecoderInputOffset(t *testing.T) {
+	input := "a,b\n1,2\n\"3\n\",4\n"
+	d := NewDecoder(strings.NewReader(input))
+	for _, want := range []int64{4, 8, int64(len(input))} {
+		if _, err := d.Decode(); err != nil {
+			t.Fatal(err)
+		}
+		if got := d.InputOffset(); got != want {
+			t.Errorf("got offset %d, want %d", got, want)
+		}
+	}
+}
+
+// sliceReader is a RecordReader of records held in memory.
+type sliceReader [][]string
+
+func (r *sliceReader) Read() ([]string, error) {
+	if len(*r) == 0 {
+		return nil, io.EOF
+	}
+	record := (*r)[0]
+	*r = (*r)[1:]
+	return record, nil
+}
+
+func TestRecordDecoder(t *testing.T) {
+	d := NewRecordDecoder(&sliceReader{{"a", "b"}, {"x\"y", "1"}, {"z\n", "2"}})
+	v, err := d.Decode()
+	if err != nil || !reflect.DeepEqual(v, []string{"a", "b"}) {
+		t.Fatalf("Value wrong %v, %v", v, err)
+	}
+	var buf strings.Builder
+	n, err := d.WriteTo(&buf)
+	exp := "\"x\"\"y\",1\n\"z\n\",2\n"
+	if err != nil || buf.String() != exp || n != int64(len(exp)) {
+		t.Fatalf("Unexpected: got %q (%d bytes), %v, expected %q", buf.String(), n, err, exp)
+	}
+	if d.More() || d.Err() != nil || d.InputOffset() != 0 {
+		t.Fatalf("Unexpected at end: %v", d.Err())
+	}
+}
+
+func TestDecoderWriteToRecordReader(t *testing.T) {
+	d := newDecoder(strings.NewReader("a;b\n1;\"x,y\"\n2;z\n"), func(r io.Reader) RecordReader {
+		cr := csv.NewReader(r)
+		cr.Comma = ';'
+		return cr
+	})
+	defer d.Release()
+	if _, err := d.Decode(); err != nil {
+		t.Fatal(err)
+	}
+	var buf strings.Builder
+	exp := "1,\"x,y\"\n2,z\n"
+	if n, err := d.WriteTo(&buf); err != nil || buf.String() != exp || n != int64(len(exp)) {
+		t.Fatalf("Unexpected: got %q (%d bytes), %v, expected %q", buf.String(), n, err, exp)
+	}
+}
+
+func TestNewRecordReader(t *testing.T) {
+	u := &Unmarshaler{NewRecordReader: func(r io.Reader) RecordReader {
+		cr := csv.NewReader(r)
+		cr.Comma = ';'
+		return cr
+	}}
+	input := "oInt32;oString\n1;a,b\n"
+	pbs, s, err := u.UnmarshalAll(strings.NewReader(input), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := []proto.Message{&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("a,b")}}
+	if !reflect.DeepEqual(pbs, exp) {
+		t.Errorf("got %v, expected %v", pbs, exp)
+	}
+	if s.BytesConsumed != int64(len(input)) {
+		t.Errorf("got summary %+v", s)
+	}
+}
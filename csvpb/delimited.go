@@ -0,0 +1,123 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// WriteDelimited writes pb to w in wire format, prefixed by its length as
+// varint. This is the framing of delimited proto files, as written by
+// writeDelimitedTo of the Java and C++ libraries.
+func WriteDelimited(w io.Writer, pb proto.Message) error {
+	b, err := proto.Marshal(pb)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(proto.EncodeVarint(uint64(len(b)))); err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// maxDelimitedSize is the size of the largest message, 2 GiB.
+const maxDelimitedSize = math.MaxInt32
+
+// ReadDelimited reads the next length-delimited message from r into pb.
+// It returns io.EOF, should r end before the message starts, and
+// io.ErrUnexpectedEOF, should r end within the message. Lengths beyond the
+// size of a message fail, and memory is only allocated for the bytes
+// actually read, so corrupt lengths cannot exhaust it.
+func ReadDelimited(r *bufio.Reader, pb proto.Message) error {
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	if size > maxDelimitedSize {
+		return fmt.Errorf("csvpb: delimited message of %d bytes exceeds limit of %d", size, maxDelimitedSize)
+	}
+	var buf bytes.Buffer
+	if n, err := io.CopyN(&buf, r, int64(size)); n < int64(size) {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	return proto.Unmarshal(buf.Bytes(), pb)
+}
+
+// ToDelimited converts the CSV read from r into length-delimited messages,
+// written to w. Messages are created by factory. Should Header be nil, the
+// first record is used as header.
+// The returned Summary is never nil, even if an error occurs.
+func (u *Unmarshaler) ToDelimited(r io.Reader, w io.Writer, factory func() proto.Message) (*Summary, error) {
+	bw := bufio.NewWriter(w)
+	s, err := u.UnmarshalEach(r, factory, func(pb proto.Message) error {
+		return WriteDelimited(bw, pb)
+	})
+	if err != nil {
+		return s, err
+	}
+	return s, bw.Flush()
+}
+
+// FromDelimited converts the length-delimited messages read from r into
+// CSV, written to w along with the header. Messages are created by factory.
+// It returns the number of messages converted.
+func (m *Marshaler) FromDelimited(r io.Reader, w io.Writer, factory func() proto.Message) (int, error) {
+	br := bufio.NewReader(r)
+	enc := m.newEncoder(w)
+	n := 0
+	for {
+		pb := factory()
+		err := ReadDelimited(br, pb)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := m.MarshalNext(enc, pb); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, enc.Flush()
+}
@@ -0,0 +1,131 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestToDelimited(t *testing.T) {
+	var buf bytes.Buffer
+	s, err := new(Unmarshaler).ToDelimited(strings.NewReader("oInt32,oString\n1,foo\n2,bar\n"), &buf, newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.RowsDecoded != 2 {
+		t.Errorf("got %d rows decoded, want 2", s.RowsDecoded)
+	}
+
+	want := []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("foo")},
+		&pb.Simple{OInt32: proto.Int32(2), OString: proto.String("bar")},
+	}
+	br := bufio.NewReader(&buf)
+	for i, w := range want {
+		got := new(pb.Simple)
+		if err := ReadDelimited(br, got); err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if !proto.Equal(got, w) {
+			t.Errorf("message %d: got %v, want %v", i, got, w)
+		}
+	}
+	if err := ReadDelimited(br, new(pb.Simple)); err != io.EOF {
+		t.Errorf("got %v, want EOF", err)
+	}
+}
+
+func TestFromDelimited(t *testing.T) {
+	var in bytes.Buffer
+	for _, msg := range []proto.Message{
+		&pb.Repeats{RString: []string{"a"}},
+		&pb.Repeats{RInt32: []int32{1, 2}},
+	} {
+		if err := WriteDelimited(&in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	n, err := new(Marshaler).FromDelimited(&in, &out, func() proto.Message { return new(pb.Repeats) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("got %d messages, want 2", n)
+	}
+	want := "rBool,rInt32,rInt64,rUint32,rUint64,rSint32,rSint64,rFloat,rDouble,rString,rBytes\n" +
+		",,,,,,,,,a,\n" +
+		",\"1,2\",,,,,,,,,\n"
+	if got := out.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestReadDelimitedTruncated(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteDelimited(&buf, &pb.Simple{OString: proto.String("truncated")}); err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{1, buf.Len() - 1} {
+		br := bufio.NewReader(bytes.NewReader(buf.Bytes()[:n]))
+		if err := ReadDelimited(br, new(pb.Simple)); err != io.ErrUnexpectedEOF {
+			t.Errorf("%d bytes: got %v, want %v", n, err, io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+func TestReadDelimitedCorruptLength(t *testing.T) {
+	tests := []struct {
+		input []byte
+		want  error
+	}{
+		// 2^63, beyond any message
+		{[]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, nil},
+		// 1 GiB, followed by a few bytes only
+		{[]byte{0x80, 0x80, 0x80, 0x80, 0x04, 1, 2, 3}, io.ErrUnexpectedEOF},
+	}
+	for _, tt := range tests {
+		err := ReadDelimited(bufio.NewReader(bytes.NewReader(tt.input)), new(pb.Simple))
+		if err == nil || tt.want != nil && err != tt.want {
+			t.Errorf("%x: got %v, want %v", tt.input, err, tt.want)
+		}
+	}
+}
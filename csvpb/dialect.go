@@ -0,0 +1,145 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect adapts the cells written by a Marshaler to what a particular
+// loader expects. Unmarshaler does not read dialects.
+type Dialect struct {
+	// Null is the cell of a field without a value.
+	Null string
+
+	// True and False are the cells of booleans.
+	True, False string
+
+	// TimestampLayout formats Timestamp fields in UTC, as time.Time.Format
+	// does. RFC 3339 with 0, 3, 6 or 9 fractional digits is used if empty.
+	TimestampLayout string
+
+	// Newline replaces line breaks within strings, unless empty. Line
+	// breaks are quoted otherwise.
+	Newline string
+}
+
+var (
+	// BigQuery is the dialect loaded by BigQuery with default options.
+	// Strings with line breaks need allowQuotedNewlines to be set.
+	BigQuery = &Dialect{
+		Null:            "",
+		True:            "true",
+		False:           "false",
+		TimestampLayout: "2006-01-02 15:04:05.999999 UTC",
+	}
+
+	// Redshift is the dialect loaded by the COPY command of Amazon Redshift
+	// with the options CSV and NULL AS '\N'. Timestamps are for columns
+	// without time zone.
+	Redshift = &Dialect{
+		Null:            `\N`,
+		True:            "1",
+		False:           "0",
+		TimestampLayout: "2006-01-02 15:04:05.999999",
+	}
+)
+
+// null returns the cell of a field without a value.
+func (m *Marshaler) null() string {
+	if m.Dialect != nil {
+		return m.Dialect.Null
+	}
+	return nullToken
+}
+
+func (m *Marshaler) formatBool(b bool) string {
+	if m.Dialect == nil {
+		return strconv.FormatBool(b)
+	}
+	if b {
+		return m.Dialect.True
+	}
+	return m.Dialect.False
+}
+
+// NewlinePolicy decides what becomes of line breaks within strings, as
+// some line oriented tools split records on them even if quoted.
+type NewlinePolicy int
+
+const (
+	// NewlineKeep keeps line breaks, quoting the cell, unless
+	// Dialect.Newline replaces them.
+	NewlineKeep NewlinePolicy = iota
+	// NewlineReplace replaces line breaks with Marshaler.NewlineEscape.
+	NewlineReplace
+	// NewlineFail fails strings with line breaks.
+	NewlineFail
+)
+
+// errNewline is returned for strings with line breaks by NewlineFail.
+var errNewline = errors.New("line break in string")
+
+func (m *Marshaler) formatString(s string) (string, error) {
+	if !strings.ContainsAny(s, "\r\n") {
+		return s, nil
+	}
+	var n string
+	switch {
+	case m.Newlines == NewlineFail:
+		return "", errNewline
+	case m.Newlines == NewlineReplace:
+		n = m.NewlineEscape
+		if n == "" {
+			n = `\n`
+		}
+	case m.Dialect != nil && m.Dialect.Newline != "":
+		n = m.Dialect.Newline
+	default:
+		return s, nil
+	}
+	return strings.NewReplacer("\r\n", n, "\n", n, "\r", n).Replace(s), nil
+}
+
+func (m *Marshaler) formatTimestamp(s, ns int64) (string, error) {
+	if m.Dialect == nil || m.Dialect.TimestampLayout == "" {
+		return formatTimestamp(s, ns)
+	}
+	if ns < 0 || ns >= secondInNanos {
+		return "", fmt.Errorf("invalid timestamp nanos %d", ns)
+	}
+	return time.Unix(s, ns).UTC().Format(m.Dialect.TimestampLayout), nil
+}
@@ -0,0 +1,108 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	wpb "github.com/golang/protobuf/ptypes/wrappers"
+)
+
+func TestDialects(t *testing.T) {
+	tests := []struct {
+		desc    string
+		dialect *Dialect
+		pb      proto.Message
+		want    []string
+	}{
+		{"bigquery", BigQuery,
+			&pb.KnownTypes{Bool: &wpb.BoolValue{Value: true}, Ts: &tspb.Timestamp{Seconds: 1556712000, Nanos: 123456789}},
+			[]string{"", "", "", "2019-05-01 12:00:00.123456 UTC", "", "", "", "", "", "", "", "", "true", "", ""}},
+		{"redshift", Redshift,
+			&pb.KnownTypes{Bool: &wpb.BoolValue{Value: false}, Ts: &tspb.Timestamp{Seconds: 1556712000}},
+			[]string{`\N`, `\N`, `\N`, "2019-05-01 12:00:00", `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, `\N`, "0", `\N`, `\N`}},
+		{"newline", &Dialect{Null: "NULL", True: "Y", False: "N", Newline: `\n`},
+			&pb.Simple{OBool: proto.Bool(true), OString: proto.String("a\r\nb\nc\rd")},
+			[]string{"Y", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", `a\nb\nc\nd`, ""}},
+	}
+	for _, tt := range tests {
+		m := Marshaler{Dialect: tt.dialect}
+		got, err := m.MarshalRecord(tt.pb)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: MarshalRecord() = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestNewlinePolicy(t *testing.T) {
+	tests := []struct {
+		m    Marshaler
+		in   string
+		want string
+		err  error
+	}{
+		{Marshaler{}, "a\r\nb", "a\r\nb", nil},
+		{Marshaler{Dialect: &Dialect{Newline: " "}}, "a\nb", "a b", nil},
+		{Marshaler{Newlines: NewlineReplace}, "a\r\nb\rc", `a\nb\nc`, nil},
+		{Marshaler{Newlines: NewlineReplace, NewlineEscape: "<br>", Dialect: &Dialect{Newline: " "}}, "a\nb", "a<br>b", nil},
+		{Marshaler{Newlines: NewlineFail}, "ab", "ab", nil},
+		{Marshaler{Newlines: NewlineFail}, "a\nb", "", errNewline},
+	}
+	for _, tt := range tests {
+		got, err := tt.m.formatString(tt.in)
+		if got != tt.want || err != tt.err {
+			t.Errorf("%+v: formatString(%q) = %q, %v, want %q, %v", tt.m, tt.in, got, err, tt.want, tt.err)
+		}
+	}
+
+	m := Marshaler{Newlines: NewlineFail}
+	if _, err := m.MarshalRecord(&pb.Simple{OString: proto.String("a\nb")}); err == nil {
+		t.Error("MarshalRecord() succeeded, want error")
+	}
+}
+
+func TestDialectInvalidTimestamp(t *testing.T) {
+	m := Marshaler{Dialect: BigQuery}
+	if _, err := m.MarshalRecord(&pb.KnownTypes{Ts: &tspb.Timestamp{Nanos: -1}}); err == nil {
+		t.Error("MarshalRecord() succeeded, want error")
+	}
+}
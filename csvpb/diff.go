@@ -0,0 +1,185 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Difference describes a field that differs between a decoded record and
+// its expected message.
+type Difference struct {
+	// Row is the 1-based index of the message within the compared
+	// messages.
+	Row int
+	// Column names the differing field. It is empty if the whole row is
+	// missing on either side.
+	Column string
+	// Got and Want are the differing values, formatted for display.
+	Got, Want string
+}
+
+func (d Difference) String() string {
+	if d.Column == "" {
+		return fmt.Sprintf("row %d: got %s, want %s", d.Row, d.Got, d.Want)
+	}
+	return fmt.Sprintf("row %d, column %q: got %s, want %s", d.Row, d.Column, d.Got, d.Want)
+}
+
+// Diff unmarshals every record of a CSV into messages created by factory
+// and compares them field by field against want.
+// Should Header be nil, the first record is used as header.
+func (u *Unmarshaler) Diff(r io.Reader, factory func() proto.Message, want []proto.Message) ([]Difference, error) {
+	got, _, err := u.UnmarshalAll(r, factory)
+	if err != nil {
+		return nil, err
+	}
+	return diffMessages(got, want), nil
+}
+
+// DiffCSV unmarshals two CSVs into messages created by factory and
+// compares them field by field. Both CSVs are read with the same Header,
+// so cells are compared by value rather than by representation.
+func (u *Unmarshaler) DiffCSV(got, want io.Reader, factory func() proto.Message) ([]Difference, error) {
+	gotPBs, _, err := u.UnmarshalAll(got, factory)
+	if err != nil {
+		return nil, err
+	}
+	wantPBs, _, err := u.UnmarshalAll(want, factory)
+	if err != nil {
+		return nil, err
+	}
+	return diffMessages(gotPBs, wantPBs), nil
+}
+
+func diffMessages(got, want []proto.Message) []Difference {
+	var diffs []Difference
+	for i := 0; i < len(got) || i < len(want); i++ {
+		row := i + 1
+		switch {
+		case i >= len(want):
+			diffs = append(diffs, Difference{Row: row, Got: proto.CompactTextString(got[i]), Want: "no row"})
+		case i >= len(got):
+			diffs = append(diffs, Difference{Row: row, Got: "no row", Want: proto.CompactTextString(want[i])})
+		default:
+			diffs = append(diffs, diffMessage(row, got[i], want[i])...)
+		}
+	}
+	return diffs
+}
+
+func diffMessage(row int, got, want proto.Message) []Difference {
+	gv := reflect.ValueOf(got).Elem()
+	wv := reflect.ValueOf(want).Elem()
+	if gv.Type() != wv.Type() {
+		return []Difference{{Row: row, Got: gv.Type().String(), Want: wv.Type().String()}}
+	}
+
+	var diffs []Difference
+	appendDiff := func(prop *proto.Properties, g, w reflect.Value) {
+		if valuesEqual(g, w) {
+			return
+		}
+		diffs = append(diffs, Difference{
+			Row:    row,
+			Column: columnNames(tagOptions(prop), prop).camel,
+			Got:    formatDiffValue(g),
+			Want:   formatDiffValue(w),
+		})
+	}
+
+	sprops := proto.GetProperties(gv.Type())
+	for i := 0; i < gv.NumField(); i++ {
+		ft := gv.Type().Field(i)
+		if strings.HasPrefix(ft.Name, "XXX_") || ft.Tag.Get("protobuf_oneof") != "" {
+			continue
+		}
+		appendDiff(sprops.Prop[i], gv.Field(i), wv.Field(i))
+	}
+
+	// Compare oneof fields in a stable order.
+	oneofNames := make([]string, 0, len(sprops.OneofTypes))
+	for name := range sprops.OneofTypes {
+		oneofNames = append(oneofNames, name)
+	}
+	sort.Strings(oneofNames)
+	for _, name := range oneofNames {
+		oop := sprops.OneofTypes[name]
+		appendDiff(oop.Prop, oneofValue(gv.Field(oop.Field), oop.Type), oneofValue(wv.Field(oop.Field), oop.Type))
+	}
+	return diffs
+}
+
+// oneofValue returns the value held by a oneof field, should it hold the
+// given wrapper type. Otherwise the zero Value is returned.
+func oneofValue(field reflect.Value, wrapperType reflect.Type) reflect.Value {
+	if field.IsNil() || field.Elem().Type() != wrapperType {
+		return reflect.Value{}
+	}
+	return field.Elem().Elem().Field(0)
+}
+
+func valuesEqual(g, w reflect.Value) bool {
+	if !g.IsValid() || !w.IsValid() {
+		return g.IsValid() == w.IsValid()
+	}
+	if gm, ok := g.Interface().(proto.Message); ok {
+		return proto.Equal(gm, w.Interface().(proto.Message))
+	}
+	return reflect.DeepEqual(g.Interface(), w.Interface())
+}
+
+func formatDiffValue(v reflect.Value) string {
+	if !v.IsValid() {
+		return "unset"
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return "null"
+		}
+		if m, ok := v.Interface().(proto.Message); ok {
+			return proto.CompactTextString(m)
+		}
+		v = v.Elem()
+	}
+	if b, ok := v.Interface().([]byte); ok {
+		return base64.StdEncoding.EncodeToString(b)
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,99 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestDiff(t *testing.T) {
+	input := "oInt32,oString,oBool\n1,foo,true\n2,bar,null\n3,baz,false"
+	want := []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("foo"), OBool: proto.Bool(true)},
+		&pb.Simple{OInt32: proto.Int32(2), OString: proto.String("BAR"), OBool: proto.Bool(false)},
+	}
+
+	diffs, err := new(Unmarshaler).Diff(strings.NewReader(input), newSimple, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Difference{
+		{Row: 2, Column: "oBool", Got: "null", Want: "false"},
+		{Row: 2, Column: "oString", Got: "bar", Want: "BAR"},
+		{Row: 3, Got: `o_bool:false o_int32:3 o_string:"baz" `, Want: "no row"},
+	}
+	if !reflect.DeepEqual(diffs, exp) {
+		t.Fatalf("Unexpected: got %v, expected %v", diffs, exp)
+	}
+}
+
+func TestDiffOneof(t *testing.T) {
+	want := []proto.Message{&pb.MsgWithOneof{Union: &pb.MsgWithOneof_Salary{Salary: 31000}}}
+	factory := func() proto.Message { return new(pb.MsgWithOneof) }
+
+	diffs, err := new(Unmarshaler).Diff(strings.NewReader("Country\nAustralia"), factory, want)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Difference{
+		{Row: 1, Column: "Country", Got: "Australia", Want: "unset"},
+		{Row: 1, Column: "salary", Got: "unset", Want: "31000"},
+	}
+	if !reflect.DeepEqual(diffs, exp) {
+		t.Fatalf("Unexpected: got %v, expected %v", diffs, exp)
+	}
+}
+
+func TestDiffCSV(t *testing.T) {
+	got := "oInt32,oDouble\n1,1.0\n2,2.5"
+	want := "oDouble,oInt32\n1,1\n2,2"
+
+	diffs, err := new(Unmarshaler).DiffCSV(strings.NewReader(got), strings.NewReader(want), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	exp := []Difference{
+		{Row: 2, Column: "oDouble", Got: "2.5", Want: "2"},
+	}
+	if !reflect.DeepEqual(diffs, exp) {
+		t.Fatalf("Unexpected: got %v, expected %v", diffs, exp)
+	}
+}
@@ -0,0 +1,182 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxDurationSeconds bounds Durations to about 10,000 years either way, as
+// the protobuf spec does.
+const maxDurationSeconds = 315576000000
+
+// checkDuration tells whether s seconds and ns nanos make a valid Duration.
+func checkDuration(s, ns int64) error {
+	if s < -maxDurationSeconds || s > maxDurationSeconds {
+		return fmt.Errorf("%ds out of range of ±%ds", s, int64(maxDurationSeconds))
+	}
+	if ns <= -secondInNanos || ns >= secondInNanos {
+		return fmt.Errorf("ns out of range (%v, %v)", -secondInNanos, secondInNanos)
+	}
+	if (s > 0 && ns < 0) || (s < 0 && ns > 0) {
+		return errors.New("signs of seconds and nanos do not match")
+	}
+	return nil
+}
+
+// durationParts parses a Duration cell into seconds and nanos of the same
+// sign. Cells in seconds, like 1.5s, may exceed the range of time.Duration
+// up to that of Durations.
+func (u *Unmarshaler) durationParts(cell string) (int64, int64, error) {
+	d, err := u.parseDuration(cell)
+	if err == nil {
+		return int64(d / time.Second), int64(d % time.Second), nil
+	}
+	s, ns, ok := parseSeconds(cell)
+	if !ok {
+		return 0, 0, err
+	}
+	if err := checkDuration(s, ns); err != nil {
+		return 0, 0, err
+	}
+	return s, ns, nil
+}
+
+// parseSeconds parses cells like -1.5s, beyond the range of time.Duration,
+// into seconds and nanos of the same sign.
+func parseSeconds(cell string) (int64, int64, bool) {
+	if !strings.HasSuffix(cell, "s") {
+		return 0, 0, false
+	}
+	number := cell[:len(cell)-1]
+	neg := strings.HasPrefix(number, "-")
+	if neg || strings.HasPrefix(number, "+") {
+		number = number[1:]
+	}
+	whole, frac := number, ""
+	if i := strings.IndexByte(number, '.'); i >= 0 {
+		whole, frac = number[:i], number[i+1:]
+	}
+	if whole == "" || whole[0] < '0' || whole[0] > '9' || len(frac) > 9 {
+		return 0, 0, false
+	}
+	s, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	var ns int64
+	if frac != "" {
+		if ns, err = strconv.ParseInt(frac+strings.Repeat("0", 9-len(frac)), 10, 64); err != nil || frac[0] < '0' || frac[0] > '9' {
+			return 0, 0, false
+		}
+	}
+	if neg {
+		s, ns = -s, -ns
+	}
+	return s, ns, true
+}
+
+// parseDuration parses a Duration cell like time.ParseDuration, with
+// ExtendedDurations also accepting days (d) and weeks (w) of 24 and 168
+// hours, like 1w3d12h.
+func (u *Unmarshaler) parseDuration(cell string) (time.Duration, error) {
+	if !u.ExtendedDurations || !strings.ContainsAny(cell, "dw") {
+		return time.ParseDuration(cell)
+	}
+
+	s := cell
+	neg := false
+	if s != "" && (s[0] == '-' || s[0] == '+') {
+		neg = s[0] == '-'
+		s = s[1:]
+	}
+	// Days and weeks are summed up separately, the remaining units are
+	// left to time.ParseDuration.
+	var long time.Duration
+	var rest strings.Builder
+	for s != "" {
+		i := 0
+		for i < len(s) && (s[i] == '.' || '0' <= s[i] && s[i] <= '9') {
+			i++
+		}
+		j := i
+		for j < len(s) && s[j] != '.' && (s[j] < '0' || s[j] > '9') {
+			j++
+		}
+		if i == 0 || i == j {
+			return 0, fmt.Errorf("time: invalid duration %q", cell)
+		}
+		number, unit := s[:i], s[i:j]
+		s = s[j:]
+
+		var hours float64
+		switch unit {
+		case "d":
+			hours = 24
+		case "w":
+			hours = 7 * 24
+		default:
+			rest.WriteString(number)
+			rest.WriteString(unit)
+			continue
+		}
+		d, err := time.ParseDuration(number + "h")
+		if err != nil {
+			return 0, fmt.Errorf("time: invalid duration %q", cell)
+		}
+		if float64(d)*hours > math.MaxInt64-float64(long) {
+			return 0, fmt.Errorf("time: invalid duration %q", cell)
+		}
+		long += time.Duration(float64(d) * hours)
+	}
+
+	var d time.Duration
+	if rest.Len() > 0 {
+		var err error
+		if d, err = time.ParseDuration(rest.String()); err != nil {
+			return 0, fmt.Errorf("time: invalid duration %q", cell)
+		}
+		if d > math.MaxInt64-long {
+			return 0, fmt.Errorf("time: invalid duration %q", cell)
+		}
+	}
+	d += long
+	if neg {
+		d = -d
+	}
+	return d, nil
+}
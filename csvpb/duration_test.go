@@ -0,0 +1,117 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		cell     string
+		extended bool
+		want     time.Duration
+		fails    bool
+	}{
+		{"1h30m", false, 90 * time.Minute, false},
+		{"2d", false, 0, true},
+		{"2d", true, 48 * time.Hour, false},
+		{"1w3d12h", true, (7+3)*24*time.Hour + 12*time.Hour, false},
+		{"1.5d", true, 36 * time.Hour, false},
+		{"-1d30m", true, -(24*time.Hour + 30*time.Minute), false},
+		{"1h2d", true, 49 * time.Hour, false},
+		{"1.5s", true, 1500 * time.Millisecond, false},
+		{"d", true, 0, true},
+		{"1x2d", true, 0, true},
+		{"1d-2h", true, 0, true},
+		{"20000w", true, 0, true},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{ExtendedDurations: tt.extended}
+		got, err := u.parseDuration(tt.cell)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("%q: got %v, expected an error", tt.cell, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%q: got %v, %v, want %v", tt.cell, got, err, tt.want)
+		}
+	}
+}
+
+func TestDurationParts(t *testing.T) {
+	tests := []struct {
+		cell  string
+		s, ns int64
+		fails bool
+	}{
+		{"-1.5s", -1, -5e8, false},
+		{"-500ms", 0, -5e8, false},
+		{"315576000000s", 315576000000, 0, false},
+		{"-315576000000.999999999s", -315576000000, -999999999, false},
+		{"315576000001s", 0, 0, true},
+		{"1.-5s", 0, 0, true},
+		{"--1s", 0, 0, true},
+	}
+	for _, tt := range tests {
+		s, ns, err := new(Unmarshaler).durationParts(tt.cell)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("%q: got %d, %d, expected an error", tt.cell, s, ns)
+			}
+			continue
+		}
+		if err != nil || s != tt.s || ns != tt.ns {
+			t.Errorf("%q: got %d, %d, %v, want %d, %d", tt.cell, s, ns, err, tt.s, tt.ns)
+		}
+	}
+}
+
+func TestCheckDuration(t *testing.T) {
+	for _, tt := range []struct {
+		s, ns int64
+		valid bool
+	}{
+		{maxDurationSeconds, 999999999, true},
+		{-maxDurationSeconds, -999999999, true},
+		{maxDurationSeconds + 1, 0, false},
+		{1, -1, false},
+		{0, 1e9, false},
+	} {
+		if err := checkDuration(tt.s, tt.ns); (err == nil) != tt.valid {
+			t.Errorf("%d, %d: got %v", tt.s, tt.ns, err)
+		}
+	}
+}
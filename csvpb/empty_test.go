@@ -0,0 +1,112 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	emptypb "github.com/golang/protobuf/ptypes/empty"
+	stpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+// emptyEvent is a message with fields carrying no data.
+type emptyEvent struct {
+	Ping                 *emptypb.Empty `protobuf:"bytes,1,opt,name=ping,proto3"`
+	Nothing              stpb.NullValue `protobuf:"varint,2,opt,name=nothing,proto3,enum=google.protobuf.NullValue"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *emptyEvent) Reset()         { *m = emptyEvent{} }
+func (m *emptyEvent) String() string { return proto.CompactTextString(m) }
+func (*emptyEvent) ProtoMessage()    {}
+
+func TestMarshalEmpty(t *testing.T) {
+	tests := []struct {
+		m    *Marshaler
+		in   *emptyEvent
+		want string
+	}{
+		{&Marshaler{}, &emptyEvent{Ping: &emptypb.Empty{}}, "ping,nothing\n{},null\n"},
+		{&Marshaler{}, &emptyEvent{}, "ping,nothing\nnull,null\n"},
+		{&Marshaler{EnumsAsInts: true, Dialect: Redshift}, &emptyEvent{}, "ping,nothing\n\\N,\\N\n"},
+	}
+	for _, tt := range tests {
+		var buf strings.Builder
+		if err := tt.m.Marshal(&buf, tt.in); err != nil {
+			t.Errorf("%v: %v", tt.in, err)
+			continue
+		}
+		if buf.String() != tt.want {
+			t.Errorf("%v: got %q, want %q", tt.in, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestUnmarshalEmpty(t *testing.T) {
+	tests := []struct {
+		in   string
+		ping bool
+		err  bool
+	}{
+		{"{},null", true, false},
+		{"x,NULL_VALUE", true, false},
+		{"null,0", false, false},
+		{"null,bad", false, true},
+	}
+	u := &Unmarshaler{Header: []string{"ping", "nothing"}}
+	for _, tt := range tests {
+		got := new(emptyEvent)
+		err := u.UnmarshalString(tt.in, got)
+		if (err != nil) != tt.err {
+			t.Errorf("%q: got error %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if (got.Ping != nil) != tt.ping {
+			t.Errorf("%q: got %v, want ping %v", tt.in, got, tt.ping)
+		}
+	}
+}
+
+func TestReportEmpty(t *testing.T) {
+	columns, err := new(Marshaler).Report(new(emptyEvent))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(columns) != 2 || columns[0].Format != "any cell but null if set, written as {}" || columns[1].Format != "always null" {
+		t.Errorf("got %+v", columns)
+	}
+}
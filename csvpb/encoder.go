@@ -0,0 +1,216 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// RecordWriter writes records one by one, like csv.Writer. Should it have
+// a Flush method returning an error, Encoder.Flush calls it. It may as well
+// be a sink other than CSV text, like a spreadsheet or a message queue.
+type RecordWriter interface {
+	Write(record []string) error
+}
+
+// Encoder encodes records as lines of CSV
+type Encoder struct {
+	// Marshaler marshals the messages of EncodeChannel. A zero Marshaler
+	// if nil.
+	Marshaler *Marshaler
+
+	// FlushInterval is how often EncodeChannel flushes the records written
+	// so far. DefaultFlushInterval if 0.
+	FlushInterval time.Duration
+
+	// w is nil for the Encoders of NewRecordEncoder.
+	w       io.Writer
+	writer  RecordWriter
+	records int
+	// existing is the header of data appended to, until checked against
+	// the first message marshaled.
+	existing []string
+}
+
+// DefaultFlushInterval is the FlushInterval of an Encoder without one.
+const DefaultFlushInterval = time.Second
+
+// NewEncoder creates a new Encoder. Internal state is implementation detail.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{
+		w:      w,
+		writer: csv.NewWriter(w),
+	}
+}
+
+// NewRecordEncoder creates an Encoder writing records to rw.
+func NewRecordEncoder(rw RecordWriter) *Encoder {
+	return &Encoder{
+		writer: rw,
+	}
+}
+
+// NewAppendEncoder creates an Encoder appending to the CSV read from
+// existing, like the contents of w so far. The header of existing is not
+// written again, but checked against the header of the first message
+// MarshalNext writes. Should existing be empty, the Encoder is like one
+// from NewEncoder. existing has to end with a line break.
+//
+// For a file, both can be the same *os.File opened with
+// os.O_RDWR|os.O_APPEND, which is read from the start.
+func NewAppendEncoder(existing io.Reader, w io.Writer) (*Encoder, error) {
+	e := NewEncoder(w)
+	dec := NewDecoder(existing)
+	defer dec.Release()
+	if !dec.More() {
+		return e, dec.Err()
+	}
+	header, err := dec.Decode()
+	if err != nil {
+		return nil, err
+	}
+	e.existing = append([]string(nil), header...)
+	e.records++
+	return e, nil
+}
+
+// HeaderMismatchError is returned when appending messages whose header
+// differs from the header of the data appended to.
+type HeaderMismatchError struct {
+	Existing []string
+	Header   []string
+}
+
+func (e *HeaderMismatchError) Error() string {
+	return fmt.Sprintf("csvpb: header %q does not match existing header %q", e.Header, e.Existing)
+}
+
+// Encode writes a single record. Records are buffered, so Flush has to be
+// called once done.
+func (e *Encoder) Encode(record []string) error {
+	if err := e.writer.Write(record); err != nil {
+		return err
+	}
+	e.records++
+	return nil
+}
+
+// Flush writes any buffered records to the underlying io.Writer or
+// RecordWriter.
+func (e *Encoder) Flush() error {
+	switch w := e.writer.(type) {
+	case *csv.Writer:
+		w.Flush()
+		return w.Error()
+	case interface{ Flush() error }:
+		return w.Flush()
+	}
+	return nil
+}
+
+// ReadFrom implements io.ReaderFrom. It writes any buffered records and
+// copies the CSV read from r verbatim, so copying between files and
+// network connections can use the fast paths of the underlying io.Writer.
+// Any data copied counts as records, so MarshalNext writes no header
+// afterwards. Encoders of NewRecordEncoder write the records of the CSV
+// instead.
+func (e *Encoder) ReadFrom(r io.Reader) (int64, error) {
+	if err := e.Flush(); err != nil {
+		return 0, err
+	}
+	if e.w == nil {
+		return e.encodeFrom(r)
+	}
+	n, err := io.Copy(e.w, r)
+	if n > 0 {
+		e.records++
+	}
+	return n, err
+}
+
+// encodeFrom encodes the records of the CSV read from r.
+func (e *Encoder) encodeFrom(r io.Reader) (int64, error) {
+	dec := NewDecoder(r)
+	defer dec.Release()
+	for dec.More() {
+		// More guarantees a record, errors are reported by Err
+		record, _ := dec.Decode()
+		if err := e.Encode(record); err != nil {
+			return dec.InputOffset(), err
+		}
+	}
+	return dec.InputOffset(), dec.Err()
+}
+
+// EncodeChannel marshals the messages received from ch as records, like
+// MarshalNext, until ch is closed or ctx is done. Records are flushed every
+// FlushInterval and once EncodeChannel returns, so consumers see them while
+// messages are still arriving. Once ctx is done, the records written so far
+// are flushed and ctx.Err() is returned.
+func (e *Encoder) EncodeChannel(ctx context.Context, ch <-chan proto.Message) error {
+	m := e.Marshaler
+	if m == nil {
+		m = new(Marshaler)
+	}
+	interval := e.FlushInterval
+	if interval <= 0 {
+		interval = DefaultFlushInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case pb, ok := <-ch:
+			if !ok {
+				return e.Flush()
+			}
+			if err := m.MarshalNext(e, pb); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := e.Flush(); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			if err := e.Flush(); err != nil {
+				return err
+			}
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,228 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"context"
+	"encoding/csv"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestEncoderReadFrom(t *testing.T) {
+	var buf strings.Builder
+	enc := NewEncoder(&buf)
+	if err := enc.Encode([]string{"dub"}); err != nil {
+		t.Fatal(err)
+	}
+	n, err := enc.ReadFrom(strings.NewReader("1.5\n2\n"))
+	if err != nil || n != 6 {
+		t.Fatalf("Unexpected: got %d, %v", n, err)
+	}
+	// No header is written after the copied data
+	if err := new(Marshaler).MarshalNext(enc, &pb.Simple3{Dub: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "dub\n1.5\n2\n3\n"; buf.String() != exp {
+		t.Errorf("Unexpected: got %q, expected %q", buf.String(), exp)
+	}
+
+}
+
+func TestAppendEncoder(t *testing.T) {
+	path := writeTempCSV(t, []byte("dub\n1\n"))
+	defer os.Remove(path)
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	enc, err := NewAppendEncoder(f, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := new(Marshaler).MarshalNext(enc, &pb.Simple{}); err == nil {
+		t.Error("expected a HeaderMismatchError")
+	} else if _, ok := err.(*HeaderMismatchError); !ok {
+		t.Errorf("got %v, expected a HeaderMismatchError", err)
+	}
+	for _, dub := range []float64{2, 3} {
+		if err := new(Marshaler).MarshalNext(enc, &pb.Simple3{Dub: dub}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exp := "dub\n1\n2\n3\n"; string(data) != exp {
+		t.Errorf("Unexpected: got %q, expected %q", data, exp)
+	}
+
+	var buf strings.Builder
+	enc, err = NewAppendEncoder(strings.NewReader(""), &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := new(Marshaler).MarshalNext(enc, &pb.Simple3{Dub: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "dub\n1\n"; buf.String() != exp {
+		t.Errorf("Unexpected: got %q, expected %q", buf.String(), exp)
+	}
+}
+
+// syncBuffer is a strings.Builder safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestEncodeChannel(t *testing.T) {
+	var buf strings.Builder
+	ch := make(chan proto.Message, 2)
+	ch <- &pb.Simple3{Dub: 1}
+	ch <- &pb.Simple3{Dub: 2}
+	close(ch)
+	if err := NewEncoder(&buf).EncodeChannel(context.Background(), ch); err != nil {
+		t.Fatal(err)
+	}
+	if want := "dub\n1\n2\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestEncodeChannelFlush(t *testing.T) {
+	var buf syncBuffer
+	enc := NewEncoder(&buf)
+	enc.FlushInterval = time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	ch := make(chan proto.Message)
+	done := make(chan error)
+	go func() { done <- enc.EncodeChannel(ctx, ch) }()
+
+	ch <- &pb.Simple3{Dub: 1}
+	// The record is flushed while the channel is still open
+	for deadline := time.Now().Add(5 * time.Second); buf.String() != "dub\n1\n"; {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %q before cancel", buf.String())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+// sliceWriter is a RecordWriter keeping the records in memory.
+type sliceWriter struct {
+	records [][]string
+	flushed int
+}
+
+func (w *sliceWriter) Write(record []string) error {
+	w.records = append(w.records, append([]string(nil), record...))
+	return nil
+}
+
+func (w *sliceWriter) Flush() error {
+	w.flushed = len(w.records)
+	return nil
+}
+
+func TestRecordEncoder(t *testing.T) {
+	w := new(sliceWriter)
+	enc := NewRecordEncoder(w)
+	m := new(Marshaler)
+	if err := m.MarshalNext(enc, &pb.Simple3{Dub: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := enc.ReadFrom(strings.NewReader("2\n\"3\n\"\n")); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	exp := [][]string{{"dub"}, {"1.5"}, {"2"}, {"3\n"}}
+	if !reflect.DeepEqual(w.records, exp) || w.flushed != len(exp) {
+		t.Errorf("got %q (%d flushed), expected %q", w.records, w.flushed, exp)
+	}
+}
+
+func TestNewRecordWriter(t *testing.T) {
+	m := &Marshaler{NewRecordWriter: func(w io.Writer) RecordWriter {
+		cw := csv.NewWriter(w)
+		cw.Comma = ';'
+		return cw
+	}}
+	var buf strings.Builder
+	if err := m.MarshalMulti(&buf, "type", []proto.Message{&pb.Simple3{Dub: 1.5}}); err != nil {
+		t.Fatal(err)
+	}
+	if exp := "type;dub\njsonpb.Simple3;1.5\n"; buf.String() != exp {
+		t.Errorf("got %q, expected %q", buf.String(), exp)
+	}
+}
@@ -0,0 +1,150 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// ErrNoFastPath is returned by a FastCodec to leave a message to the
+// reflection based conversion, like for options it does not support.
+var ErrNoFastPath = errors.New("csvpb: no fast path")
+
+// FastCodec converts messages of a single type without reflection, like the
+// code generated by protoc-gen-csvpb. Every method receives the Marshaler
+// or Unmarshaler it is called for and returns ErrNoFastPath, should it not
+// honour their options. Codecs check required fields themselves.
+type FastCodec interface {
+	// Header returns the columns of the records written by MarshalRecord.
+	Header(m *Marshaler) ([]string, error)
+	// MarshalRecord converts pb into a record matching Header.
+	MarshalRecord(m *Marshaler, pb proto.Message) ([]string, error)
+	// UnmarshalRecord resets pb and populates it from a record matching
+	// u.Header.
+	UnmarshalRecord(u *Unmarshaler, record []string, pb proto.Message) error
+}
+
+var fastCodecs struct {
+	sync.RWMutex
+	m map[reflect.Type]FastCodec
+}
+
+// RegisterFastCodec makes Marshaler and Unmarshaler consult codec for
+// messages of the type of pb before resorting to reflection. A nil codec
+// removes the registration. Codecs are usually registered by init
+// functions.
+func RegisterFastCodec(pb proto.Message, codec FastCodec) {
+	fastCodecs.Lock()
+	defer fastCodecs.Unlock()
+	t := reflect.TypeOf(pb)
+	if codec == nil {
+		delete(fastCodecs.m, t)
+		return
+	}
+	if fastCodecs.m == nil {
+		fastCodecs.m = make(map[reflect.Type]FastCodec)
+	}
+	fastCodecs.m[t] = codec
+}
+
+// fastCodec returns the codec registered for the type of pb, if any. Types
+// with FieldOptions have none, as codecs do not know of them.
+func fastCodec(pb proto.Message) FastCodec {
+	if hasFieldOptions(pb) {
+		return nil
+	}
+	fastCodecs.RLock()
+	defer fastCodecs.RUnlock()
+	return fastCodecs.m[reflect.TypeOf(pb)]
+}
+
+// csvMarshaler and csvUnmarshaler are implemented by messages generated by
+// protoc-gen-csvpb.
+type csvMarshaler interface {
+	MarshalCSV() ([]string, error)
+}
+
+type csvUnmarshaler interface {
+	UnmarshalCSV(header, record []string) error
+}
+
+// GeneratedCodec returns the FastCodec of a message generated by
+// protoc-gen-csvpb, header being its generated header. It applies to
+// Marshaler and Unmarshaler with default conversions only.
+func GeneratedCodec(header []string) FastCodec {
+	return generatedCodec(header)
+}
+
+type generatedCodec []string
+
+func (c generatedCodec) Header(m *Marshaler) ([]string, error) {
+	if !m.defaultConversion() {
+		return nil, ErrNoFastPath
+	}
+	return append([]string(nil), c...), nil
+}
+
+func (c generatedCodec) MarshalRecord(m *Marshaler, pb proto.Message) ([]string, error) {
+	cm, ok := pb.(csvMarshaler)
+	if !ok || !m.defaultConversion() {
+		return nil, ErrNoFastPath
+	}
+	return cm.MarshalCSV()
+}
+
+func (c generatedCodec) UnmarshalRecord(u *Unmarshaler, record []string, pb proto.Message) error {
+	cu, ok := pb.(csvUnmarshaler)
+	if !ok || !u.defaultConversion() {
+		return ErrNoFastPath
+	}
+	return cu.UnmarshalCSV(u.Header, record)
+}
+
+// defaultConversion tells whether m writes cells like generated code.
+func (m *Marshaler) defaultConversion() bool {
+	return m.columnStyle() == HeaderCamelCase && !m.EnumsAsInts && m.Dialect == nil && m.Newlines == NewlineKeep && m.NonFinite == nil &&
+		m.FloatFormat == nil && len(m.FloatFormats) == 0 && len(m.ColumnFormats) == 0 &&
+		!m.Deterministic && m.Encrypt == nil && len(m.fieldOptionsByName) == 0 && len(m.mappingsByName) == 0
+}
+
+// defaultConversion tells whether u reads cells like generated code.
+func (u *Unmarshaler) defaultConversion() bool {
+	return !u.AllowUnknownFields && u.HeaderStyle == HeaderAuto && u.CellHook == nil && u.Strictness == Lenient &&
+		u.IntegerRange == RangeFail && !u.IntegerPrefixes && !u.DigitSeparators && !u.LenientBase64 && u.Decrypt == nil && u.MaxListLength == 0 &&
+		u.Percent == PercentFail && u.NonFinite == nil && u.Coercer == nil && u.Columns == nil &&
+		!u.ExtendedDurations && !u.ClampTimestamps && len(u.TimestampLayouts) == 0 && len(u.fieldOptionsByName) == 0 &&
+		len(u.mappingsByName) == 0 && len(u.splits) == 0
+}
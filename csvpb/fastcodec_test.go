@@ -0,0 +1,191 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// simple3Codec converts Simple3, counting its calls.
+type simple3Codec struct {
+	calls int
+}
+
+func (c *simple3Codec) Header(m *Marshaler) ([]string, error) {
+	if m.OrigName {
+		return nil, ErrNoFastPath
+	}
+	c.calls++
+	return []string{"dub"}, nil
+}
+
+func (c *simple3Codec) MarshalRecord(m *Marshaler, p proto.Message) ([]string, error) {
+	if m.OrigName {
+		return nil, ErrNoFastPath
+	}
+	c.calls++
+	return []string{strconv.FormatFloat(p.(*pb.Simple3).Dub, 'f', 1, 64)}, nil
+}
+
+func (c *simple3Codec) UnmarshalRecord(u *Unmarshaler, record []string, p proto.Message) error {
+	if u.AllowUnknownFields {
+		return ErrNoFastPath
+	}
+	c.calls++
+	dub, err := strconv.ParseFloat(record[0], 64)
+	*p.(*pb.Simple3) = pb.Simple3{Dub: dub}
+	return err
+}
+
+func TestFastCodec(t *testing.T) {
+	codec := new(simple3Codec)
+	RegisterFastCodec((*pb.Simple3)(nil), codec)
+	defer RegisterFastCodec((*pb.Simple3)(nil), nil)
+
+	str, err := new(Marshaler).MarshalToString(&pb.Simple3{Dub: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if str != "dub\n2.0\n" || codec.calls != 2 {
+		t.Errorf("got %q with %d calls", str, codec.calls)
+	}
+	// Falls back to reflection
+	if str, err = (&Marshaler{OrigName: true}).MarshalToString(&pb.Simple3{Dub: 2}); str != "dub\n2\n" || err != nil {
+		t.Errorf("got %q, %v", str, err)
+	}
+
+	p := new(pb.Simple3)
+	u := &Unmarshaler{Header: []string{"dub"}}
+	if err := u.UnmarshalRecord([]string{"1.5"}, p); err != nil || p.Dub != 1.5 || codec.calls != 3 {
+		t.Errorf("got %v, %v with %d calls", p, err, codec.calls)
+	}
+	_, s, err := u.UnmarshalAll(strings.NewReader("x\n"), func() proto.Message { return new(pb.Simple3) })
+	if _, ok := err.(*RowError); !ok || s.Errors[CategoryConversion] != 1 {
+		t.Errorf("got %v, %v", err, s.Errors)
+	}
+	u.AllowUnknownFields = true
+	if err := u.UnmarshalRecord([]string{"2.5"}, p); err != nil || p.Dub != 2.5 || codec.calls != 4 {
+		t.Errorf("got %v, %v with %d calls", p, err, codec.calls)
+	}
+}
+
+// generatedSimple3 has the methods generated by protoc-gen-csvpb.
+type generatedSimple3 struct {
+	pb.Simple3
+}
+
+func (m *generatedSimple3) MarshalCSV() ([]string, error) {
+	return []string{FormatFloat(m.Dub, 64)}, nil
+}
+
+func (m *generatedSimple3) UnmarshalCSV(header, record []string) error {
+	dub, err := ParseFloat(record[0], 64)
+	m.Dub = dub
+	return err
+}
+
+func TestGeneratedCodec(t *testing.T) {
+	codec := GeneratedCodec([]string{"dub"})
+	RegisterFastCodec((*generatedSimple3)(nil), codec)
+	defer RegisterFastCodec((*generatedSimple3)(nil), nil)
+
+	str, err := new(Marshaler).MarshalToString(&generatedSimple3{pb.Simple3{Dub: 3}})
+	if str != "dub\n3\n" || err != nil {
+		t.Errorf("got %q, %v", str, err)
+	}
+	p := new(generatedSimple3)
+	if err := (&Unmarshaler{Header: []string{"dub"}}).UnmarshalRecord([]string{"4"}, p); err != nil || p.Dub != 4 {
+		t.Errorf("got %v, %v", p, err)
+	}
+
+	if _, err := codec.MarshalRecord(&Marshaler{EnumsAsInts: true}, p); err != ErrNoFastPath {
+		t.Errorf("got %v, expected ErrNoFastPath", err)
+	}
+	if err := codec.UnmarshalRecord(&Unmarshaler{Strictness: Strict}, nil, p); err != ErrNoFastPath {
+		t.Errorf("got %v, expected ErrNoFastPath", err)
+	}
+	header, err := codec.Header(new(Marshaler))
+	if !reflect.DeepEqual(header, []string{"dub"}) || err != nil {
+		t.Errorf("got %v, %v", header, err)
+	}
+}
+
+// generatedRepeats has the methods generated by protoc-gen-csvpb for the
+// column rBool of Repeats.
+type generatedRepeats pb.Repeats
+
+func (m *generatedRepeats) Reset()         { *m = generatedRepeats{} }
+func (m *generatedRepeats) String() string { return proto.CompactTextString(m) }
+func (*generatedRepeats) ProtoMessage()    {}
+
+func (m *generatedRepeats) MarshalCSV() ([]string, error) {
+	return nil, ErrNoFastPath
+}
+
+func (m *generatedRepeats) UnmarshalCSV(header, record []string) error {
+	elems, err := SplitList(record[0])
+	if err != nil {
+		return err
+	}
+	m.RBool = nil
+	for _, elem := range elems {
+		b, err := ParseBool(elem)
+		if err != nil {
+			return err
+		}
+		m.RBool = append(m.RBool, b)
+	}
+	return nil
+}
+
+func TestGeneratedCodecListLimit(t *testing.T) {
+	RegisterFastCodec((*generatedRepeats)(nil), GeneratedCodec([]string{"rBool"}))
+	defer RegisterFastCodec((*generatedRepeats)(nil), nil)
+
+	u := &Unmarshaler{Header: []string{"rBool"}}
+	p := new(generatedRepeats)
+	if err := u.UnmarshalRecord([]string{"true,false,true"}, p); err != nil || len(p.RBool) != 3 {
+		t.Errorf("got %v, %v", p, err)
+	}
+	u.MaxListLength = 2
+	err := u.UnmarshalRecord([]string{"true,false,true"}, new(generatedRepeats))
+	if _, ok := err.(*LimitError); !ok {
+		t.Errorf("got %v, expected a *LimitError", err)
+	}
+}
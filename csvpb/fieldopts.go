@@ -0,0 +1,346 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// FieldOptions configure the column of a single field.
+//
+// Fields of Go structs declare them with a csv tag, holding the column
+// name followed by options:
+//
+//	Day *timestamp.Timestamp `csv:"day,layout=2006-01-02"`
+//	Tags []string            `csv:",delimiter=;,null=NA"`
+//
+// As options are separated by commas, they cannot contain commas
+// themselves.
+type FieldOptions struct {
+	// Column names the column, instead of the orig or camel name of the
+	// field.
+	Column string
+	// Layout formats and parses Timestamp fields, as time.Time.Format does.
+	Layout string
+	// Layouts are tried in order after Layout when parsing Timestamp
+	// fields. A tag declares them by repeating layout.
+	Layouts []string
+	// Delimiter separates the elements of list cells, instead of a comma.
+	Delimiter rune
+	// NestedDelimiter separates the elements of lists nested in ListValue
+	// cells, which are JSON arrays otherwise. A tag declares it as nested.
+	NestedDelimiter rune
+	// Null is the cell of the field without a value, instead of null.
+	Null *string
+	// File reads bytes fields from the file at the path in the cell,
+	// relative to FileBase of the Unmarshaler. Marshalers write the
+	// contents as usual. A tag declares it as file=true.
+	File bool
+	// Compressed holds string and bytes fields gzip compressed and base64
+	// encoded in their cells. A tag declares it as gzip=true.
+	Compressed bool
+}
+
+// ParseFieldOptions parses the csv tag of a field.
+func ParseFieldOptions(tag string) (*FieldOptions, error) {
+	parts := strings.Split(tag, ",")
+	o := &FieldOptions{Column: parts[0]}
+	for _, part := range parts[1:] {
+		i := strings.Index(part, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("csvpb: option %q without value", part)
+		}
+		value := part[i+1:]
+		switch part[:i] {
+		case "layout":
+			if o.Layout == "" {
+				o.Layout = value
+			} else {
+				o.Layouts = append(o.Layouts, value)
+			}
+		case "delimiter", "nested":
+			r, n := utf8.DecodeRuneInString(value)
+			if n == 0 || n != len(value) || r == '"' || r == '\r' || r == '\n' || r == utf8.RuneError {
+				return nil, fmt.Errorf("csvpb: invalid delimiter %q", value)
+			}
+			if part[:i] == "delimiter" {
+				o.Delimiter = r
+			} else {
+				o.NestedDelimiter = r
+			}
+		case "null":
+			o.Null = proto.String(value)
+		case "file", "gzip":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("csvpb: invalid %s %q", part[:i], value)
+			}
+			if part[:i] == "file" {
+				o.File = b
+			} else {
+				o.Compressed = b
+			}
+		default:
+			return nil, fmt.Errorf("csvpb: unknown option %q", part[:i])
+		}
+	}
+	return o, nil
+}
+
+// scanResult is the outcome of scanning the csv tags of a struct type.
+type scanResult struct {
+	// tagged tells whether any field has options.
+	tagged bool
+	err    error
+}
+
+// fieldOptionsScanned holds the scanResult of every struct type scanned so
+// far.
+var fieldOptionsScanned sync.Map
+
+// propOptions holds the options of the fields of the scanned types by
+// their properties.
+var propOptions sync.Map
+
+// propNames holds the full names of the fields of the scanned message
+// types by their properties.
+var propNames sync.Map
+
+// scanFieldOptions records the options of the fields of struct type t,
+// should they have any.
+func scanFieldOptions(t reflect.Type) error {
+	return scanStruct(t).err
+}
+
+func scanStruct(t reflect.Type) scanResult {
+	if res, ok := fieldOptionsScanned.Load(t); ok {
+		return res.(scanResult)
+	}
+	var res scanResult
+	err := func() error {
+		sprops := proto.GetProperties(t)
+		if pb, ok := reflect.New(t).Interface().(proto.Message); ok {
+			if name := proto.MessageName(pb); name != "" {
+				for _, prop := range sprops.Prop {
+					propNames.Store(prop, name+"."+prop.OrigName)
+				}
+				for _, oop := range sprops.OneofTypes {
+					propNames.Store(oop.Prop, name+"."+oop.Prop.OrigName)
+				}
+			}
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if strings.HasPrefix(f.Name, "XXX_") || f.Tag.Get("protobuf_oneof") != "" {
+				continue
+			}
+			tagged, err := storeFieldOptions(f, sprops.Prop[i])
+			if err != nil {
+				return err
+			}
+			res.tagged = res.tagged || tagged
+		}
+		for _, oop := range sprops.OneofTypes {
+			tagged, err := storeFieldOptions(oop.Type.Elem().Field(0), oop.Prop)
+			if err != nil {
+				return err
+			}
+			res.tagged = res.tagged || tagged
+		}
+		return nil
+	}()
+	if err != nil {
+		res.err = fmt.Errorf("%v: %v", t, err)
+	}
+	fieldOptionsScanned.Store(t, res)
+	return res
+}
+
+// storeFieldOptions records the options of field f with prop, reporting
+// whether it has any.
+func storeFieldOptions(f reflect.StructField, prop *proto.Properties) (bool, error) {
+	tag, ok := f.Tag.Lookup("csv")
+	if !ok {
+		return false, nil
+	}
+	o, err := ParseFieldOptions(tag)
+	if err != nil {
+		return false, fmt.Errorf("field %s: %v", f.Name, err)
+	}
+	propOptions.Store(prop, o)
+	return true, nil
+}
+
+// hasFieldOptions tells whether any field of the messages of the type of
+// pb has options.
+func hasFieldOptions(pb proto.Message) bool {
+	t := reflect.TypeOf(pb)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return false
+	}
+	res := scanStruct(t.Elem())
+	return res.tagged || res.err != nil
+}
+
+// tagOptions returns the options of the csv tag of the field with prop,
+// which may be nil, or nil if it has none. The type of the field has to be
+// scanned.
+func tagOptions(prop *proto.Properties) *FieldOptions {
+	if prop == nil {
+		return nil
+	}
+	if o, ok := propOptions.Load(prop); ok {
+		return o.(*FieldOptions)
+	}
+	return nil
+}
+
+// lookupFieldOptions returns the options of the field with prop, which may
+// be nil, from byName or else its csv tag.
+func lookupFieldOptions(byName map[string]*FieldOptions, prop *proto.Properties) *FieldOptions {
+	if prop == nil {
+		return nil
+	}
+	if len(byName) > 0 {
+		if name, ok := propNames.Load(prop); ok {
+			if o, ok := byName[name.(string)]; ok {
+				return o
+			}
+		}
+	}
+	return tagOptions(prop)
+}
+
+// SetFieldOptions configures the field with the full name field, like
+// "pkg.Msg.field", overriding the options of its csv tag. It is meant for
+// messages whose code cannot be changed.
+func (u *Unmarshaler) SetFieldOptions(field string, o FieldOptions) {
+	if u.fieldOptionsByName == nil {
+		u.fieldOptionsByName = make(map[string]*FieldOptions)
+	}
+	u.fieldOptionsByName[field] = &o
+}
+
+// SetFieldOptions configures the field with the full name field, like
+// "pkg.Msg.field", overriding the options of its csv tag. It is meant for
+// messages whose code cannot be changed.
+func (m *Marshaler) SetFieldOptions(field string, o FieldOptions) {
+	if m.fieldOptionsByName == nil {
+		m.fieldOptionsByName = make(map[string]*FieldOptions)
+	}
+	m.fieldOptionsByName[field] = &o
+}
+
+func (u *Unmarshaler) fieldOptions(prop *proto.Properties) *FieldOptions {
+	return lookupFieldOptions(u.fieldOptionsByName, prop)
+}
+
+func (m *Marshaler) fieldOptions(prop *proto.Properties) *FieldOptions {
+	return lookupFieldOptions(m.fieldOptionsByName, prop)
+}
+
+// columnNames returns the column names accepted for the field with prop
+// and options o, which may be nil.
+func columnNames(o *FieldOptions, prop *proto.Properties) fieldNames {
+	if o != nil && o.Column != "" {
+		return fieldNames{orig: o.Column, camel: o.Column}
+	}
+	return acceptedJSONFieldNames(prop)
+}
+
+// isNull tells whether cell stands for a field with options o, which may
+// be nil, without a value.
+func isNull(o *FieldOptions, cell string) bool {
+	if o != nil && o.Null != nil {
+		return cell == *o.Null
+	}
+	return cell == nullToken
+}
+
+// listDelimiter returns the delimiter of the list cells of a field with
+// options o, which may be nil.
+func listDelimiter(o *FieldOptions) rune {
+	if o != nil && o.Delimiter != 0 {
+		return o.Delimiter
+	}
+	return ','
+}
+
+// nullCell returns the cell m writes for the field with prop, which may be
+// nil, without a value.
+func (m *Marshaler) nullCell(prop *proto.Properties) string {
+	if o := m.fieldOptions(prop); o != nil && o.Null != nil {
+		return *o.Null
+	}
+	return m.null()
+}
+
+// timestampLayout returns the layout of Timestamp fields with options o,
+// which may be nil, empty for the default.
+func timestampLayout(o *FieldOptions) string {
+	if o != nil {
+		return o.Layout
+	}
+	return ""
+}
+
+// parseTimestamp parses cell of the Timestamp field with prop by the
+// layouts of its options, else TimestampLayouts, else RFC 3339. It fails
+// with the error of the first layout, should none apply.
+func (u *Unmarshaler) parseTimestamp(prop *proto.Properties, cell string) (time.Time, error) {
+	layouts := u.TimestampLayouts
+	if o := u.fieldOptions(prop); o != nil && o.Layout != "" {
+		layouts = append([]string{o.Layout}, o.Layouts...)
+	}
+	if len(layouts) == 0 {
+		return time.Parse(time.RFC3339Nano, cell)
+	}
+	var first error
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, cell)
+		if err == nil {
+			return t, nil
+		}
+		if first == nil {
+			first = err
+		}
+	}
+	return time.Time{}, first
+}
@@ -0,0 +1,188 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	wpb "github.com/golang/protobuf/ptypes/wrappers"
+)
+
+// taggedEvent is a message whose fields have csv tags, like those added to
+// generated code by tag injection.
+type taggedEvent struct {
+	Day                  *tspb.Timestamp  `protobuf:"bytes,1,opt,name=day,proto3" csv:"date,layout=2006-01-02"`
+	Tags                 []string         `protobuf:"bytes,2,rep,name=tags,proto3" csv:",delimiter=;"`
+	Score                *wpb.DoubleValue `protobuf:"bytes,3,opt,name=score,proto3" csv:",null=NA"`
+	Note                 *wpb.StringValue `protobuf:"bytes,4,opt,name=note,proto3"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *taggedEvent) Reset()         { *m = taggedEvent{} }
+func (m *taggedEvent) String() string { return proto.CompactTextString(m) }
+func (*taggedEvent) ProtoMessage()    {}
+
+func TestFieldOptionsTags(t *testing.T) {
+	in := &taggedEvent{
+		Day:  &tspb.Timestamp{Seconds: 1714521600},
+		Tags: []string{"a", "b,c"},
+	}
+	var buf strings.Builder
+	if err := new(Marshaler).Marshal(&buf, in); err != nil {
+		t.Fatal(err)
+	}
+	want := "date,tags,score,note\n2024-05-01,\"a;b,c\",NA,null\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	factory := func() proto.Message { return new(taggedEvent) }
+	out, _, err := new(Unmarshaler).UnmarshalAll(strings.NewReader(buf.String()), factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || !proto.Equal(out[0], in) {
+		t.Errorf("got %v, want %v", out, in)
+	}
+
+	// The default null token is an ordinary cell for score
+	out, _, err = new(Unmarshaler).UnmarshalAll(strings.NewReader("date,tags,score,note\n2024-05-01,,null,\n"), factory)
+	if err == nil {
+		t.Errorf("null for score: got %v, expected an error", out)
+	}
+}
+
+func TestSetFieldOptions(t *testing.T) {
+	m := new(Marshaler)
+	m.SetFieldOptions("jsonpb.Simple.o_double", FieldOptions{Column: "double", Null: proto.String("NA")})
+	header, err := m.Header(new(pb.Simple))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(header, "double") || contains(header, "oDouble") {
+		t.Errorf("got header %v, expected double instead of oDouble", header)
+	}
+	record, err := m.MarshalRecord(new(pb.Simple))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, column := range header {
+		if column == "double" && record[i] != "NA" {
+			t.Errorf("got %q for double, want NA", record[i])
+		}
+	}
+
+	u := &Unmarshaler{Header: []string{"oDouble", "oFloat"}}
+	u.SetFieldOptions("jsonpb.Simple.o_double", FieldOptions{Null: proto.String("NA")})
+	got := new(pb.Simple)
+	if err := u.UnmarshalRecord([]string{"NA", "1.5"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&pb.Simple{OFloat: proto.Float32(1.5)}); !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	// Only the configured field takes the token
+	if err := u.UnmarshalRecord([]string{"1", "NA"}, got); err == nil {
+		t.Errorf("NA for oFloat: got %v, expected an error", got)
+	}
+}
+
+func TestFieldTimestampLayouts(t *testing.T) {
+	u := &Unmarshaler{Header: []string{"ts"}, TimestampLayouts: []string{"2006-01-02"}}
+	u.SetFieldOptions("jsonpb.KnownTypes.ts", FieldOptions{Layout: "2006-01-02", Layouts: []string{"01/02/2006 3:04 PM"}})
+	for cell, seconds := range map[string]int64{"2024-05-01": 1714521600, "05/01/2024 3:04 PM": 1714575840} {
+		got := new(pb.KnownTypes)
+		if err := u.UnmarshalRecord([]string{cell}, got); err != nil || got.GetTs().GetSeconds() != seconds {
+			t.Errorf("%q: got %v, %v, want %d seconds", cell, got, err, seconds)
+		}
+	}
+	// The layouts of the field replace TimestampLayouts
+	u.TimestampLayouts = []string{time.RFC3339}
+	if err := u.UnmarshalRecord([]string{"2024-05-01T15:04:05Z"}, new(pb.KnownTypes)); err == nil {
+		t.Error("RFC 3339: expected an error")
+	}
+}
+
+func contains(ss []string, s string) bool {
+	for _, e := range ss {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+func TestParseFieldOptions(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want *FieldOptions
+	}{
+		{"", &FieldOptions{}},
+		{"day", &FieldOptions{Column: "day"}},
+		{",layout=2006-01-02 15:04", &FieldOptions{Layout: "2006-01-02 15:04"}},
+		{",layout=2006-01-02,layout=01/02/2006", &FieldOptions{Layout: "2006-01-02", Layouts: []string{"01/02/2006"}}},
+		{"tags,delimiter=;,null=", &FieldOptions{Column: "tags", Delimiter: ';', Null: proto.String("")}},
+		{",delimiter=\t", &FieldOptions{Delimiter: '\t'}},
+		{",delimiter=", nil},
+		{",delimiter=ab", nil},
+		{",delimiter=\"", nil},
+		{"lv,nested=|", &FieldOptions{Column: "lv", NestedDelimiter: '|'}},
+		{",nested=\n", nil},
+		{"scan,file=true", &FieldOptions{Column: "scan", File: true}},
+		{",file=yes", nil},
+		{"body,gzip=true", &FieldOptions{Column: "body", Compressed: true}},
+		{",gzip=1", &FieldOptions{Compressed: true}},
+		{",null", nil},
+		{",unknown=1", nil},
+	}
+	for _, tt := range tests {
+		got, err := ParseFieldOptions(tt.tag)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q: got %+v, expected an error", tt.tag, got)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %+v, %v, want %+v", tt.tag, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Cells of bytes fields with the File option are paths of files, relative
+// to FileBase of the Unmarshaler, like for manifests referencing
+// attachments. The fields are set to the contents of the files. Paths
+// leaving FileBase, including by symbolic links, are rejected.
+
+// loadFile reads the file at the path in cell for a bytes field with the
+// File option. An empty cell references no file.
+func (u *Unmarshaler) loadFile(cell string) ([]byte, error) {
+	if cell == "" {
+		return []byte{}, nil
+	}
+	if u.FileBase == "" {
+		return nil, fmt.Errorf("csvpb: file reference %q without FileBase", cell)
+	}
+	rel := filepath.Clean(filepath.FromSlash(cell))
+	if filepath.IsAbs(rel) || filepath.VolumeName(rel) != "" {
+		return nil, fmt.Errorf("csvpb: file reference %q is not relative", cell)
+	}
+	if !isLocal(rel) {
+		return nil, fmt.Errorf("csvpb: file reference %q leaves FileBase", cell)
+	}
+	base, err := filepath.EvalSymlinks(u.FileBase)
+	if err != nil {
+		return nil, err
+	}
+	path, err := filepath.EvalSymlinks(filepath.Join(base, rel))
+	if err != nil {
+		return nil, err
+	}
+	if rel, err := filepath.Rel(base, path); err != nil || !isLocal(rel) {
+		return nil, fmt.Errorf("csvpb: file reference %q leaves FileBase", cell)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("csvpb: file reference %q is not a regular file", cell)
+	}
+	if u.MaxFileSize <= 0 {
+		return ioutil.ReadAll(f)
+	}
+	if fi.Size() > u.MaxFileSize {
+		return nil, &LimitError{What: "file size", Limit: u.MaxFileSize}
+	}
+	// The file may grow while being read
+	b, err := ioutil.ReadAll(io.LimitReader(f, u.MaxFileSize+1))
+	if err == nil && int64(len(b)) > u.MaxFileSize {
+		return nil, &LimitError{What: "file size", Limit: u.MaxFileSize}
+	}
+	return b, err
+}
+
+// isLocal tells whether the clean relative path rel stays within its base.
+func isLocal(rel string) bool {
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
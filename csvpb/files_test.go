@@ -0,0 +1,99 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestFileReferences(t *testing.T) {
+	dir, err := ioutil.TempDir("", "csvpb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	base := filepath.Join(dir, "base")
+	for path, content := range map[string]string{
+		"base/a.bin":     "attachment",
+		"base/sub/b.bin": "nested",
+		"base/big.bin":   "0123456789abcdefghij",
+		"secret.bin":     "secret",
+	} {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(dir, "secret.bin"), filepath.Join(base, "link.bin")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		base    string
+		cell    string
+		want    string
+		wantErr bool
+	}{
+		{base, "a.bin", "attachment", false},
+		{base, "sub/b.bin", "nested", false},
+		{base, "sub/../a.bin", "attachment", false},
+		{base, "", "", false},
+		{base, "../secret.bin", "", true},
+		{base, filepath.Join(dir, "secret.bin"), "", true},
+		{base, "link.bin", "", true},
+		{base, "missing.bin", "", true},
+		{base, "sub", "", true},
+		{base, "big.bin", "", true},
+		{"", "a.bin", "", true},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{Header: []string{"oBytes"}, FileBase: tt.base, MaxFileSize: 16}
+		u.SetFieldOptions("jsonpb.Simple.o_bytes", FieldOptions{File: true})
+		got := new(pb.Simple)
+		err := u.UnmarshalRecord([]string{tt.cell}, got)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("UnmarshalRecord(%q) below %q error = %v, want error %v", tt.cell, tt.base, err, tt.wantErr)
+			continue
+		}
+		if err == nil && string(got.OBytes) != tt.want {
+			t.Errorf("UnmarshalRecord(%q) below %q = %q, want %q", tt.cell, tt.base, got.OBytes, tt.want)
+		}
+	}
+}
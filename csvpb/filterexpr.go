@@ -0,0 +1,677 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// CompileRecordFilter compiles a predicate over the cells of a record into
+// a RecordFilter. expr is written in a subset of CEL:
+//
+//	status == "active" && (amount >= 100 || has(discount)) && !(kind in ["test", "demo"])
+//
+// Identifiers name columns, with backquotes around names that are not
+// identifiers, like `unit price`. Literals are double or single quoted
+// strings, numbers, true, false and null. Operators are ==, !=, <, <=, >,
+// >=, in, !, && and ||, the arithmetic +, -, *, / and %, with + also
+// concatenating strings, and the conditional c ? a : b. has(column) tells
+// whether a column holds a cell other than null or empty. Cells compare
+// and compute as numbers against numbers, as bools against bools, equal
+// null if they are null or the column is missing, and compare as strings
+// otherwise. Operations on cells not converting fail, making the record
+// not match.
+func CompileRecordFilter(expr string) (func(header, record []string) bool, error) {
+	n, err := compileExpr(expr)
+	if err != nil {
+		return nil, fmt.Errorf("csvpb: filter %q: %v", expr, err)
+	}
+	return func(header, record []string) bool {
+		b, ok := asBool(n.eval(recordEnv{header, record}))
+		return ok && b
+	}, nil
+}
+
+// compileExpr parses expr into the root of its tree.
+func compileExpr(expr string) (filterNode, error) {
+	p := &filterParser{lex: filterLexer{src: expr}}
+	p.next()
+	n, err := p.parseExpr()
+	if err == nil && p.tok.kind != tokEOF {
+		err = p.errorf("unexpected %s", p.tok)
+	}
+	return n, err
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+)
+
+type token struct {
+	kind tokKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return strconv.Quote(t.text)
+}
+
+type filterLexer struct {
+	src string
+	pos int
+}
+
+func (l *filterLexer) next() (token, error) {
+	for l.pos < len(l.src) && (l.src[l.pos] == ' ' || l.src[l.pos] == '\t' || l.src[l.pos] == '\n' || l.src[l.pos] == '\r') {
+		l.pos++
+	}
+	start := l.pos
+	if l.pos == len(l.src) {
+		return token{kind: tokEOF, pos: start}, nil
+	}
+	c := l.src[l.pos]
+	switch {
+	case c == '"' || c == '\'':
+		for l.pos++; l.pos < len(l.src); l.pos++ {
+			switch l.src[l.pos] {
+			case '\\':
+				l.pos++
+			case c:
+				l.pos++
+				s, err := strconv.Unquote(`"` + strings.Replace(l.src[start+1:l.pos-1], `"`, `\"`, -1) + `"`)
+				if err != nil {
+					return token{}, fmt.Errorf("bad string at %d", start)
+				}
+				return token{tokString, s, start}, nil
+			}
+		}
+		return token{}, fmt.Errorf("unterminated string at %d", start)
+	case c == '`':
+		end := strings.IndexByte(l.src[l.pos+1:], '`')
+		if end < 0 {
+			return token{}, fmt.Errorf("unterminated identifier at %d", start)
+		}
+		l.pos += end + 2
+		return token{tokIdent, l.src[start+1 : l.pos-1], start}, nil
+	case c >= '0' && c <= '9' || c == '.':
+		l.pos++
+		for l.pos < len(l.src) && strings.IndexByte("0123456789.eE", l.src[l.pos]) >= 0 {
+			if (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') && l.pos+1 < len(l.src) && (l.src[l.pos+1] == '-' || l.src[l.pos+1] == '+') {
+				l.pos++
+			}
+			l.pos++
+		}
+		return token{tokNumber, l.src[start:l.pos], start}, nil
+	case c == '_' || c < utf8.RuneSelf && unicode.IsLetter(rune(c)):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || l.src[l.pos] < utf8.RuneSelf && (unicode.IsLetter(rune(l.src[l.pos])) || unicode.IsDigit(rune(l.src[l.pos])))) {
+			l.pos++
+		}
+		return token{tokIdent, l.src[start:l.pos], start}, nil
+	}
+	for _, op := range []string{"&&", "||", "==", "!=", "<=", ">=", "<", ">", "!", "(", ")", "[", "]", ",", "+", "-", "*", "/", "%", "?", ":"} {
+		if strings.HasPrefix(l.src[l.pos:], op) {
+			l.pos += len(op)
+			return token{tokOp, op, start}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unexpected %q at %d", c, start)
+}
+
+type filterParser struct {
+	lex filterLexer
+	tok token
+	err error
+}
+
+func (p *filterParser) next() {
+	if p.err != nil {
+		return
+	}
+	p.tok, p.err = p.lex.next()
+	if p.err != nil {
+		p.tok = token{kind: tokEOF}
+	}
+}
+
+func (p *filterParser) errorf(format string, args ...interface{}) error {
+	if p.err != nil {
+		return p.err
+	}
+	return fmt.Errorf(format+" at %d", append(args, p.tok.pos)...)
+}
+
+func (p *filterParser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+func (p *filterParser) expect(op string) error {
+	if !p.isOp(op) {
+		return p.errorf("expected %q, got %s", op, p.tok)
+	}
+	p.next()
+	return nil
+}
+
+func (p *filterParser) parseExpr() (filterNode, error) {
+	c, err := p.parseOr()
+	if err != nil || !p.isOp("?") {
+		return c, err
+	}
+	p.next()
+	n := &condNode{c: c}
+	if n.a, err = p.parseOr(); err != nil {
+		return nil, err
+	}
+	if err := p.expect(":"); err != nil {
+		return nil, err
+	}
+	n.b, err = p.parseExpr()
+	return n, err
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	l, err := p.parseAnd()
+	for err == nil && p.isOp("||") {
+		p.next()
+		var r filterNode
+		if r, err = p.parseAnd(); err == nil {
+			l = &logicalNode{or: true, l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	l, err := p.parseRelation()
+	for err == nil && p.isOp("&&") {
+		p.next()
+		var r filterNode
+		if r, err = p.parseRelation(); err == nil {
+			l = &logicalNode{l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *filterParser) parseRelation() (filterNode, error) {
+	l, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokIdent && p.tok.text == "in" {
+		p.next()
+		if err := p.expect("["); err != nil {
+			return nil, err
+		}
+		in := &inNode{l: l}
+		for !p.isOp("]") {
+			if len(in.list) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			e, err := p.parseAdditive()
+			if err != nil {
+				return nil, err
+			}
+			in.list = append(in.list, e)
+		}
+		p.next()
+		return in, nil
+	}
+	switch op := p.tok.text; {
+	case p.tok.kind == tokOp && (op == "==" || op == "!=" || op == "<" || op == "<=" || op == ">" || op == ">="):
+		p.next()
+		r, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &compareNode{op: op, l: l, r: r}, nil
+	}
+	return l, nil
+}
+
+func (p *filterParser) parseAdditive() (filterNode, error) {
+	l, err := p.parseMultiplicative()
+	for err == nil && (p.isOp("+") || p.isOp("-")) {
+		op := p.tok.text
+		p.next()
+		var r filterNode
+		if r, err = p.parseMultiplicative(); err == nil {
+			l = &arithNode{op: op, l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *filterParser) parseMultiplicative() (filterNode, error) {
+	l, err := p.parseUnary()
+	for err == nil && (p.isOp("*") || p.isOp("/") || p.isOp("%")) {
+		op := p.tok.text
+		p.next()
+		var r filterNode
+		if r, err = p.parseUnary(); err == nil {
+			l = &arithNode{op: op, l: l, r: r}
+		}
+	}
+	return l, err
+}
+
+func (p *filterParser) parseUnary() (filterNode, error) {
+	switch {
+	case p.isOp("!"):
+		p.next()
+		n, err := p.parseUnary()
+		return &notNode{n}, err
+	case p.isOp("-"):
+		p.next()
+		n, err := p.parseUnary()
+		return &negNode{n}, err
+	}
+	return p.parseOperand()
+}
+
+func (p *filterParser) parseOperand() (filterNode, error) {
+	t := p.tok
+	switch t.kind {
+	case tokString:
+		p.next()
+		return literalNode{kind: valString, s: t.text}, p.err
+	case tokNumber:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, p.errorf("bad number %s", t)
+		}
+		p.next()
+		return literalNode{kind: valNumber, f: f}, p.err
+	case tokIdent:
+		p.next()
+		switch t.text {
+		case "true", "false":
+			return literalNode{kind: valBool, b: t.text == "true"}, p.err
+		case "null":
+			return literalNode{kind: valNull}, p.err
+		case "has":
+			if !p.isOp("(") {
+				break
+			}
+			p.next()
+			if p.tok.kind != tokIdent {
+				return nil, p.errorf("expected identifier, got %s", p.tok)
+			}
+			name := p.tok.text
+			p.next()
+			return hasNode(name), p.expect(")")
+		}
+		return identNode(t.text), p.err
+	case tokOp:
+		if t.text == "(" {
+			p.next()
+			n, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		}
+	}
+	return nil, p.errorf("unexpected %s", t)
+}
+
+type valKind int
+
+const (
+	valNull valKind = iota
+	valBool
+	valNumber
+	valString
+	// valCell is a cell, converted depending on what it is compared to or
+	// computed with.
+	valCell
+)
+
+type filterValue struct {
+	kind valKind
+	b    bool
+	f    float64
+	s    string
+}
+
+// exprEnv holds the values identifiers of an expression name.
+type exprEnv interface {
+	// lookup returns the value of name, null if there is none. It fails
+	// for values not supported by expressions.
+	lookup(name string) (filterValue, bool)
+	// has tells whether name holds a value other than null or empty.
+	has(name string) bool
+}
+
+// recordEnv is the exprEnv of the cells of a record.
+type recordEnv struct {
+	header, record []string
+}
+
+func (e recordEnv) lookup(name string) (filterValue, bool) {
+	for i, column := range e.header {
+		if column == name && i < len(e.record) {
+			if e.record[i] == nullToken {
+				break
+			}
+			return filterValue{kind: valCell, s: e.record[i]}, true
+		}
+	}
+	return filterValue{kind: valNull}, true
+}
+
+func (e recordEnv) has(name string) bool {
+	v, _ := e.lookup(name)
+	return v.kind == valCell && v.s != ""
+}
+
+// filterNode is a node of a compiled expression. eval fails, should a
+// value not convert.
+type filterNode interface {
+	eval(env exprEnv) (filterValue, bool)
+}
+
+type literalNode filterValue
+
+func (n literalNode) eval(env exprEnv) (filterValue, bool) {
+	return filterValue(n), true
+}
+
+type identNode string
+
+func (n identNode) eval(env exprEnv) (filterValue, bool) {
+	return env.lookup(string(n))
+}
+
+type hasNode string
+
+func (n hasNode) eval(env exprEnv) (filterValue, bool) {
+	return filterValue{kind: valBool, b: env.has(string(n))}, true
+}
+
+type notNode struct {
+	n filterNode
+}
+
+func (n *notNode) eval(env exprEnv) (filterValue, bool) {
+	v, ok := asBool(n.n.eval(env))
+	return filterValue{kind: valBool, b: !v}, ok
+}
+
+type negNode struct {
+	n filterNode
+}
+
+func (n *negNode) eval(env exprEnv) (filterValue, bool) {
+	v, ok := n.n.eval(env)
+	if ok && v.kind == valCell {
+		v, ok = convertCell(v, valNumber)
+	}
+	return filterValue{kind: valNumber, f: -v.f}, ok && v.kind == valNumber
+}
+
+type arithNode struct {
+	op   string
+	l, r filterNode
+}
+
+func (n *arithNode) eval(env exprEnv) (filterValue, bool) {
+	l, lok := n.l.eval(env)
+	r, rok := n.r.eval(env)
+	if !lok || !rok {
+		return filterValue{}, false
+	}
+	l, r, ok := convertValues(l, r)
+	switch {
+	case !ok:
+		return filterValue{}, false
+	case l.kind == valString && n.op == "+":
+		return filterValue{kind: valString, s: l.s + r.s}, true
+	case l.kind != valNumber:
+		return filterValue{}, false
+	}
+	v := filterValue{kind: valNumber}
+	switch n.op {
+	case "+":
+		v.f = l.f + r.f
+	case "-":
+		v.f = l.f - r.f
+	case "*":
+		v.f = l.f * r.f
+	case "/", "%":
+		// Like CEL, division by zero is an error
+		if r.f == 0 {
+			return filterValue{}, false
+		}
+		if n.op == "/" {
+			v.f = l.f / r.f
+		} else {
+			v.f = math.Mod(l.f, r.f)
+		}
+	}
+	return v, true
+}
+
+type condNode struct {
+	c, a, b filterNode
+}
+
+func (n *condNode) eval(env exprEnv) (filterValue, bool) {
+	c, ok := asBool(n.c.eval(env))
+	switch {
+	case !ok:
+		return filterValue{}, false
+	case c:
+		return n.a.eval(env)
+	}
+	return n.b.eval(env)
+}
+
+type logicalNode struct {
+	or   bool
+	l, r filterNode
+}
+
+func (n *logicalNode) eval(env exprEnv) (filterValue, bool) {
+	l, ok := asBool(n.l.eval(env))
+	if ok && l == n.or {
+		return filterValue{kind: valBool, b: l}, true
+	}
+	r, rok := asBool(n.r.eval(env))
+	if !ok {
+		// Like CEL, an error is absorbed by the other operand deciding
+		if rok && r == n.or {
+			return filterValue{kind: valBool, b: r}, true
+		}
+		return filterValue{}, false
+	}
+	return filterValue{kind: valBool, b: r}, rok
+}
+
+type compareNode struct {
+	op   string
+	l, r filterNode
+}
+
+func (n *compareNode) eval(env exprEnv) (filterValue, bool) {
+	l, lok := n.l.eval(env)
+	r, rok := n.r.eval(env)
+	if !lok || !rok {
+		return filterValue{}, false
+	}
+	c, ok := compareValues(l, r)
+	if !ok {
+		// Mismatched kinds are only ever unequal
+		if n.op == "==" || n.op == "!=" {
+			return filterValue{kind: valBool, b: n.op == "!="}, l.kind == valNull || r.kind == valNull
+		}
+		return filterValue{}, false
+	}
+	var b bool
+	switch n.op {
+	case "==":
+		b = c == 0
+	case "!=":
+		b = c != 0
+	case "<":
+		b = c < 0
+	case "<=":
+		b = c <= 0
+	case ">":
+		b = c > 0
+	case ">=":
+		b = c >= 0
+	}
+	return filterValue{kind: valBool, b: b}, true
+}
+
+type inNode struct {
+	l    filterNode
+	list []filterNode
+}
+
+func (n *inNode) eval(env exprEnv) (filterValue, bool) {
+	l, ok := n.l.eval(env)
+	if !ok {
+		return filterValue{}, false
+	}
+	for _, e := range n.list {
+		r, ok := e.eval(env)
+		if !ok {
+			return filterValue{}, false
+		}
+		if c, ok := compareValues(l, r); ok && c == 0 {
+			return filterValue{kind: valBool, b: true}, true
+		}
+	}
+	return filterValue{kind: valBool}, true
+}
+
+func asBool(v filterValue, ok bool) (bool, bool) {
+	if !ok {
+		return false, false
+	}
+	switch v.kind {
+	case valBool:
+		return v.b, true
+	case valCell:
+		b, err := strconv.ParseBool(v.s)
+		return b, err == nil
+	}
+	return false, false
+}
+
+// convertCell converts the cell v into the kind of other.
+func convertCell(v filterValue, other valKind) (filterValue, bool) {
+	switch other {
+	case valBool:
+		b, err := strconv.ParseBool(v.s)
+		return filterValue{kind: valBool, b: b}, err == nil
+	case valNumber:
+		f, err := strconv.ParseFloat(v.s, 64)
+		return filterValue{kind: valNumber, f: f}, err == nil
+	}
+	return filterValue{kind: valString, s: v.s}, true
+}
+
+// convertValues converts the cells among l and r into the kind of the
+// other, or into numbers if both convert and strings otherwise. It fails,
+// should l and r be of different kinds afterwards.
+func convertValues(l, r filterValue) (filterValue, filterValue, bool) {
+	if l.kind == valCell && r.kind == valCell {
+		lf, lerr := strconv.ParseFloat(l.s, 64)
+		rf, rerr := strconv.ParseFloat(r.s, 64)
+		if lerr == nil && rerr == nil {
+			l, r = filterValue{kind: valNumber, f: lf}, filterValue{kind: valNumber, f: rf}
+		} else {
+			l.kind, r.kind = valString, valString
+		}
+	} else if l.kind == valCell && r.kind != valNull {
+		var ok bool
+		if l, ok = convertCell(l, r.kind); !ok {
+			return l, r, false
+		}
+	} else if r.kind == valCell && l.kind != valNull {
+		var ok bool
+		if r, ok = convertCell(r, l.kind); !ok {
+			return l, r, false
+		}
+	}
+	return l, r, l.kind == r.kind
+}
+
+// compareValues returns the order of l and r, failing if they are of
+// different kinds after converting cells.
+func compareValues(l, r filterValue) (int, bool) {
+	l, r, ok := convertValues(l, r)
+	if !ok {
+		return 0, false
+	}
+	switch l.kind {
+	case valBool:
+		if l.b == r.b {
+			return 0, true
+		}
+		if r.b {
+			return -1, true
+		}
+		return 1, true
+	case valNumber:
+		switch {
+		case l.f < r.f:
+			return -1, true
+		case l.f > r.f:
+			return 1, true
+		}
+		return 0, true
+	case valString:
+		return strings.Compare(l.s, r.s), true
+	}
+	return 0, true
+}
@@ -0,0 +1,114 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"strings"
+	"testing"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestCompileRecordFilter(t *testing.T) {
+	header := []string{"status", "amount", "discount", "kind", "unit price", "flag"}
+	record := []string{"active", "150", "null", "demo", "9.5", "true"}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`status == "active"`, true},
+		{`status != 'active'`, false},
+		{`amount >= 100`, true},
+		{`amount > 1e3`, false},
+		{`amount < 20`, false},
+		{`amount > "20"`, false},
+		{`amount == amount`, true},
+		{`has(discount)`, false},
+		{`has(amount) && !has(discount)`, true},
+		{`discount == null`, true},
+		{`missing == null`, true},
+		{`status == null`, false},
+		{`kind in ["test", "demo"]`, true},
+		{`!(kind in ["test"])`, true},
+		{"`unit price` < 10", true},
+		{`flag`, true},
+		{`flag == false`, false},
+		{`status > 5`, false},
+		{`status > 5 || amount > 5`, true},
+		{`amount > 5 && (status == "x" || kind == "demo")`, true},
+		{`status`, false},
+		{`amount - 50 == 100`, true},
+		{`amount-1 == 149`, true},
+		{`amount * 2 > 299 && amount / 3 == 50`, true},
+		{`amount % 7 == 3`, true},
+		{`amount / 0 > 1 || amount / 0 <= 1`, false},
+		{`-amount < 0 && - -amount > 0`, true},
+		{`1 + 2 * 3 == 7`, true},
+		{`status + "!" == 'active!'`, true},
+		{`(has(discount) ? discount : 0.5) * 2 == 1`, true},
+		{`discount + 1 > 0`, false},
+		{`status - 1 < 0`, false},
+		{`!flag == false`, true},
+		{`kind in ["test", "de" + "mo"]`, true},
+	}
+	for _, tt := range tests {
+		f, err := CompileRecordFilter(tt.expr)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got := f(header, record); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+
+	for _, expr := range []string{``, `a ==`, `(a`, `a == "b`, `a in [1`, `a b`, `has(1)`, `a # b`, `a ? b`, `a +`, `a ? b : `} {
+		if _, err := CompileRecordFilter(expr); err == nil {
+			t.Errorf("%s: expected an error", expr)
+		}
+	}
+}
+
+func TestRecordFilterExpression(t *testing.T) {
+	f, err := CompileRecordFilter(`oInt32 * 2 > 2 && oString != "skip"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &Unmarshaler{RecordFilter: f}
+	pbs, s, err := u.UnmarshalAll(strings.NewReader("oInt32,oString\n1,a\n2,skip\n3,b\n"), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pbs) != 1 || pbs[0].(*pb.Simple).GetOInt32() != 3 || s.RowsFiltered != 2 {
+		t.Errorf("got %v, %+v", pbs, s)
+	}
+}
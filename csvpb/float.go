@@ -0,0 +1,86 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// FloatFormat controls how finite floats are written, as
+// strconv.FormatFloat does.
+type FloatFormat struct {
+	// Notation is 'f' for fixed, 'e' for scientific and 'g' for whichever
+	// is shorter. 'g' if 0.
+	Notation byte
+
+	// Precision is the number of digits after the decimal point for 'f'
+	// and 'e', the number of significant digits for 'g'. -1 selects the
+	// fewest digits reading back into the same float.
+	Precision int
+}
+
+var (
+	// Shortest writes the fewest digits reading back into the same float,
+	// using scientific notation for large exponents. Floats are written
+	// like this by default.
+	Shortest = &FloatFormat{Notation: 'g', Precision: -1}
+
+	// Fixed writes the fewest digits reading back into the same float,
+	// never using scientific notation.
+	Fixed = &FloatFormat{Notation: 'f', Precision: -1}
+)
+
+// Decimals writes n digits after the decimal point, never using scientific
+// notation.
+func Decimals(n int) *FloatFormat {
+	return &FloatFormat{Notation: 'f', Precision: n}
+}
+
+func (ff *FloatFormat) format(f float64, bitSize int) string {
+	notation := ff.Notation
+	if notation == 0 {
+		notation = 'g'
+	}
+	return strconv.FormatFloat(f, notation, ff.Precision, bitSize)
+}
+
+// floatFormat returns the format of floats in the column of prop.
+func (m *Marshaler) floatFormat(prop *proto.Properties) *FloatFormat {
+	if prop != nil {
+		if ff, ok := m.FloatFormats[m.columnName(prop)]; ok {
+			return ff
+		}
+	}
+	return m.FloatFormat
+}
@@ -0,0 +1,89 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	stpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+func TestFloatFormat(t *testing.T) {
+	avogadro := &pb.Simple{ODouble: proto.Float64(6.02214179e23), OFloat: proto.Float32(0.125)}
+	tests := []struct {
+		desc      string
+		marshaler Marshaler
+		pb        proto.Message
+		columns   []string
+		cells     []string
+	}{
+		{"default", Marshaler{}, avogadro, []string{"oDouble", "oFloat"}, []string{"6.02214179e+23", "0.125"}},
+		{"shortest", Marshaler{FloatFormat: Shortest}, avogadro, []string{"oDouble", "oFloat"}, []string{"6.02214179e+23", "0.125"}},
+		{"fixed", Marshaler{FloatFormat: Fixed}, avogadro, []string{"oDouble", "oFloat"}, []string{"602214179000000000000000", "0.125"}},
+		{"decimals", Marshaler{FloatFormat: Decimals(2)}, avogadro, []string{"oDouble", "oFloat"}, []string{"602214178999999989284864.00", "0.12"}},
+		{"scientific", Marshaler{FloatFormat: &FloatFormat{Notation: 'e', Precision: 1}}, avogadro, []string{"oDouble", "oFloat"}, []string{"6.0e+23", "1.2e-01"}},
+		{"zero notation", Marshaler{FloatFormat: &FloatFormat{Precision: 3}}, avogadro, []string{"oDouble", "oFloat"}, []string{"6.02e+23", "0.125"}},
+		{"per column", Marshaler{FloatFormats: map[string]*FloatFormat{"oDouble": Fixed}}, avogadro, []string{"oDouble", "oFloat"}, []string{"602214179000000000000000", "0.125"}},
+		{"per column overrides", Marshaler{FloatFormat: Decimals(1), FloatFormats: map[string]*FloatFormat{"oFloat": Shortest}}, avogadro, []string{"oDouble", "oFloat"}, []string{"602214178999999989284864.0", "0.125"}},
+		{"per orig name column", Marshaler{OrigName: true, FloatFormats: map[string]*FloatFormat{"o_double": Decimals(0)}}, avogadro, []string{"o_double", "o_float"}, []string{"602214178999999989284864", "0.125"}},
+		{"non-finite", Marshaler{FloatFormat: Decimals(2)}, &pb.Simple{ODouble: proto.Float64(math.Inf(1)), OFloat: proto.Float32(float32(math.NaN()))}, []string{"oDouble", "oFloat"}, []string{"Infinity", "NaN"}},
+		{"repeated", Marshaler{FloatFormat: Decimals(1)}, &pb.Repeats{RDouble: []float64{1, 2.25}}, []string{"rDouble"}, []string{"1.0,2.2"}},
+		{"Value", Marshaler{FloatFormats: map[string]*FloatFormat{"val": Fixed}}, &pb.KnownTypes{Val: &stpb.Value{Kind: &stpb.Value_NumberValue{NumberValue: 1e21}}}, []string{"val"}, []string{"1000000000000000000000"}},
+	}
+	for _, tt := range tests {
+		header, err := tt.marshaler.Header(tt.pb)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		record, err := tt.marshaler.MarshalRecord(tt.pb)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		var cells []string
+		for _, column := range tt.columns {
+			for i, name := range header {
+				if name == column {
+					cells = append(cells, record[i])
+				}
+			}
+		}
+		if !reflect.DeepEqual(cells, tt.cells) {
+			t.Errorf("%s: got %q, expected %q", tt.desc, cells, tt.cells)
+		}
+	}
+}
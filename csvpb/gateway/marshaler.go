@@ -0,0 +1,240 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package gateway provides a grpc-gateway runtime.Marshaler for text/csv.
+
+Unary responses are written as a header and a single record. Responses of
+server streaming methods are written as one record per message, preceded by
+a single header, so that an endpoint offers a CSV download without further
+code:
+
+	m := &gateway.Marshaler{}
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(m.ContentType(), m),
+		runtime.WithForwardResponseOption(m.WriteStreamHeader),
+	)
+*/
+package gateway
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+)
+
+// ContentType is the MIME type of CSV.
+const ContentType = "text/csv"
+
+// streamHeaderPending marks a streamed response still lacking its header.
+// It is removed before the first record is written, so it never reaches
+// the client.
+const streamHeaderPending = "X-Csvpb-Header-Pending"
+
+// Marshaler is a runtime.Marshaler converting messages from and to CSV.
+type Marshaler struct {
+	Marshaler csvpb.Marshaler
+
+	// Unmarshaler is used for unmarshaling. Its Header is ignored, as the
+	// header is always read from the data.
+	Unmarshaler csvpb.Unmarshaler
+}
+
+var (
+	_ runtime.Marshaler = (*Marshaler)(nil)
+	_ runtime.Delimited = (*Marshaler)(nil)
+)
+
+// ContentType returns the Content-Type of CSV.
+func (m *Marshaler) ContentType() string {
+	return ContentType
+}
+
+// Delimiter returns no delimiter, as records already end in a newline.
+func (m *Marshaler) Delimiter() []byte {
+	return []byte{}
+}
+
+// Marshal converts v into CSV. A proto.Message is written as a header and a
+// single record. A chunk of a server stream is written as a single record,
+// its header being written by WriteStreamHeader. Errors of a stream cannot
+// be represented in CSV, so they end the response instead.
+func (m *Marshaler) Marshal(v interface{}) ([]byte, error) {
+	if chunk, ok := v.(map[string]proto.Message); ok {
+		return m.marshalChunk(chunk)
+	}
+	pb, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("gateway: cannot marshal %T, not a proto.Message", v)
+	}
+	var buf bytes.Buffer
+	if err := m.Marshaler.Marshal(&buf, pb); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (m *Marshaler) marshalChunk(chunk map[string]proto.Message) ([]byte, error) {
+	pb, ok := chunk["result"]
+	if !ok {
+		if serr, ok := chunk["error"].(interface{ GetMessage() string }); ok {
+			return nil, fmt.Errorf("gateway: stream failed: %s", serr.GetMessage())
+		}
+		return nil, errors.New("gateway: stream chunk without result")
+	}
+	record, err := m.Marshaler.MarshalRecord(pb)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	enc := csvpb.NewEncoder(&buf)
+	if err := enc.Encode(record); err != nil {
+		return nil, err
+	}
+	if err := enc.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteStreamHeader is a forward response option, writing the header ahead
+// of the first message of a server stream marshaled by m. Responses
+// marshaled by other marshalers are left untouched.
+func (m *Marshaler) WriteStreamHeader(ctx context.Context, w http.ResponseWriter, pb proto.Message) error {
+	h := w.Header()
+	if h.Get("Content-Type") != m.ContentType() {
+		return nil
+	}
+	if pb == nil {
+		// Only streams announce themselves without a message.
+		h.Set(streamHeaderPending, "1")
+		return nil
+	}
+	if h.Get(streamHeaderPending) == "" {
+		return nil
+	}
+	h.Del(streamHeaderPending)
+	if m.Marshaler.OmitHeader {
+		return nil
+	}
+	header, err := m.Marshaler.Header(pb)
+	if err != nil {
+		return err
+	}
+	enc := csvpb.NewEncoder(w)
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// Unmarshal parses data, consisting of a header and a single record, into
+// v, which has to be a proto.Message.
+func (m *Marshaler) Unmarshal(data []byte, v interface{}) error {
+	return m.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// NewDecoder returns a Decoder reading a header followed by records from r.
+func (m *Marshaler) NewDecoder(r io.Reader) runtime.Decoder {
+	return &decoder{
+		unmarshaler: m.Unmarshaler,
+		dec:         csvpb.NewDecoder(r),
+	}
+}
+
+// NewEncoder returns an Encoder writing a header followed by records to w.
+func (m *Marshaler) NewEncoder(w io.Writer) runtime.Encoder {
+	return &encoder{
+		marshaler: &m.Marshaler,
+		enc:       csvpb.NewEncoder(w),
+	}
+}
+
+type decoder struct {
+	unmarshaler csvpb.Unmarshaler
+	dec         *csvpb.Decoder
+}
+
+// Decode reads the next record into v, which has to be a proto.Message.
+// The header is read ahead of the first record.
+func (d *decoder) Decode(v interface{}) error {
+	pb, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("gateway: cannot unmarshal into %T, not a proto.Message", v)
+	}
+	if d.unmarshaler.Header == nil {
+		if !d.dec.More() {
+			return d.eof()
+		}
+		header, err := d.dec.Decode()
+		if err != nil {
+			return err
+		}
+		d.unmarshaler.Header = header
+	}
+	if !d.dec.More() {
+		return d.eof()
+	}
+	return d.unmarshaler.UnmarshalNext(d.dec, pb)
+}
+
+// eof returns the error ending the input, io.EOF unless malformed.
+func (d *decoder) eof() error {
+	if err := d.dec.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+type encoder struct {
+	marshaler *csvpb.Marshaler
+	enc       *csvpb.Encoder
+}
+
+// Encode writes v, which has to be a proto.Message, as the next record.
+// The header is written ahead of the first record.
+func (e *encoder) Encode(v interface{}) error {
+	pb, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("gateway: cannot marshal %T, not a proto.Message", v)
+	}
+	if err := e.marshaler.MarshalNext(e.enc, pb); err != nil {
+		return err
+	}
+	return e.enc.Flush()
+}
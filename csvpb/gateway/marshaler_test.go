@@ -0,0 +1,193 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/grpc-ecosystem/grpc-gateway/runtime"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func forwardStream(m *Marshaler, msgs []proto.Message, last error) *httptest.ResponseRecorder {
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(m.ContentType(), m),
+		runtime.WithForwardResponseOption(m.WriteStreamHeader),
+	)
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	req := httptest.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	recv := func() (proto.Message, error) {
+		if len(msgs) == 0 {
+			return nil, last
+		}
+		msg := msgs[0]
+		msgs = msgs[1:]
+		return msg, nil
+	}
+	runtime.ForwardResponseStream(ctx, mux, m, w, req, recv, mux.GetForwardResponseOptions()...)
+	return w
+}
+
+func TestForwardResponseStream(t *testing.T) {
+	msgs := []proto.Message{
+		&pb.Repeats{RString: []string{"a"}},
+		&pb.Repeats{RString: []string{"b", "c"}},
+	}
+	w := forwardStream(&Marshaler{Marshaler: csvpb.Marshaler{OrigName: true}}, msgs, io.EOF)
+	want := "r_bool,r_int32,r_int64,r_uint32,r_uint64,r_sint32,r_sint64,r_float,r_double,r_string,r_bytes\n" +
+		",,,,,,,,,a,\n" +
+		",,,,,,,,,\"b,c\",\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("got body\n%s\nwant\n%s", got, want)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/csv" {
+		t.Errorf("got Content-Type %q", got)
+	}
+	if got := w.Result().Header.Get(streamHeaderPending); got != "" {
+		t.Errorf("marker header sent to client")
+	}
+}
+
+func TestForwardResponseStreamError(t *testing.T) {
+	w := forwardStream(&Marshaler{}, []proto.Message{&pb.Simple{OInt32: proto.Int32(1)}}, errors.New("broken"))
+	lines := strings.Split(w.Body.String(), "\n")
+	if len(lines) != 3 || lines[2] != "" {
+		t.Errorf("expected header and a single record, got %q", w.Body.String())
+	}
+}
+
+func TestForwardResponseMessage(t *testing.T) {
+	m := &Marshaler{}
+	mux := runtime.NewServeMux(
+		runtime.WithMarshalerOption(m.ContentType(), m),
+		runtime.WithForwardResponseOption(m.WriteStreamHeader),
+	)
+	ctx := runtime.NewServerMetadataContext(context.Background(), runtime.ServerMetadata{})
+	w := httptest.NewRecorder()
+	resp := &pb.Repeats{RInt32: []int32{1, 2}}
+	runtime.ForwardResponseMessage(ctx, mux, m, w, httptest.NewRequest("GET", "/", nil), resp, mux.GetForwardResponseOptions()...)
+	want := "rBool,rInt32,rInt64,rUint32,rUint64,rSint32,rSint64,rFloat,rDouble,rString,rBytes\n" +
+		",\"1,2\",,,,,,,,,\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("got body\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteStreamHeaderOtherMarshaler(t *testing.T) {
+	w := httptest.NewRecorder()
+	w.Header().Set("Content-Type", "application/json")
+	m := &Marshaler{}
+	for _, msg := range []proto.Message{nil, &pb.Simple{}} {
+		if err := m.WriteStreamHeader(context.Background(), w, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if w.Body.Len() != 0 || w.Header().Get(streamHeaderPending) != "" {
+		t.Errorf("touched response of other marshaler")
+	}
+}
+
+func TestDecoder(t *testing.T) {
+	dec := new(Marshaler).NewDecoder(strings.NewReader("oInt32,oString\n1,a\n2,b\n"))
+	var got []proto.Message
+	for {
+		msg := new(pb.Simple)
+		err := dec.Decode(msg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, msg)
+	}
+	want := []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("a")},
+		&pb.Simple{OInt32: proto.Int32(2), OString: proto.String("b")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("record %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEncoderRoundTrip(t *testing.T) {
+	m := new(Marshaler)
+	var buf strings.Builder
+	enc := m.NewEncoder(&buf)
+	in := []*pb.Simple{
+		{OInt32: proto.Int32(1), OBytes: []byte{}},
+		{OInt32: proto.Int32(2), OBytes: []byte{1}},
+	}
+	for _, msg := range in {
+		if err := enc.Encode(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := strings.Count(buf.String(), "\n"); got != 3 {
+		t.Fatalf("expected header and 2 records, got %q", buf.String())
+	}
+
+	dec := m.NewDecoder(strings.NewReader(buf.String()))
+	for i, want := range in {
+		got := new(pb.Simple)
+		if err := dec.Decode(got); err != nil {
+			t.Fatal(err)
+		}
+		if !proto.Equal(got, want) {
+			t.Errorf("record %d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestMarshalNotAMessage(t *testing.T) {
+	if _, err := new(Marshaler).Marshal(42); err == nil {
+		t.Error("expected error")
+	}
+	var v int
+	if err := new(Marshaler).Unmarshal([]byte("a\n1\n"), &v); err == nil {
+		t.Error("expected error")
+	}
+}
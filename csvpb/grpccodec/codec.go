@@ -0,0 +1,123 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package grpccodec provides a gRPC codec exchanging messages as CSV.
+
+Every message is encoded as a header and a single record, the same way
+csvpb.Marshaler.Marshal does. The codec satisfies the Codec interface of
+google.golang.org/grpc/encoding, so that registering it
+
+	encoding.RegisterCodec(grpccodec.New())
+
+lets clients call methods with the content-subtype "csv", transmitted as
+"application/grpc+csv".
+*/
+package grpccodec
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Name is the content-subtype of the codec.
+const Name = "csv"
+
+// Codec marshals and unmarshals messages as CSV.
+type Codec struct {
+	Marshaler csvpb.Marshaler
+
+	// Unmarshaler is used for unmarshaling. Its Header is ignored, as the
+	// header is always read from the data.
+	Unmarshaler csvpb.Unmarshaler
+}
+
+// New returns a Codec with default options.
+func New() *Codec {
+	return &Codec{}
+}
+
+// Marshal returns the CSV encoding of v, which has to be a proto.Message.
+func (c *Codec) Marshal(v interface{}) ([]byte, error) {
+	pb, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("grpccodec: cannot marshal %T, not a proto.Message", v)
+	}
+	var buf bytes.Buffer
+	if err := c.Marshaler.Marshal(&buf, pb); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal parses data, consisting of a header and a single record, into
+// v, which has to be a proto.Message.
+func (c *Codec) Unmarshal(data []byte, v interface{}) error {
+	pb, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("grpccodec: cannot unmarshal into %T, not a proto.Message", v)
+	}
+	dec := csvpb.NewDecoder(bytes.NewReader(data))
+	defer dec.Release()
+	if !dec.More() {
+		if err := dec.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("grpccodec: missing header")
+	}
+	header, err := dec.Decode()
+	if err != nil {
+		return err
+	}
+	if !dec.More() {
+		if err := dec.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("grpccodec: missing record")
+	}
+	u := c.Unmarshaler
+	u.Header = header
+	if err := u.UnmarshalNext(dec, pb); err != nil {
+		return err
+	}
+	if dec.More() || dec.Err() != nil {
+		return fmt.Errorf("grpccodec: more than one record")
+	}
+	return nil
+}
+
+// Name returns the content-subtype of the codec.
+func (c *Codec) Name() string {
+	return Name
+}
@@ -0,0 +1,106 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package grpccodec
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// codec mirrors the Codec interface of google.golang.org/grpc/encoding.
+type codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+	Name() string
+}
+
+var _ codec = New()
+
+func TestRoundTrip(t *testing.T) {
+	in := &pb.Simple{OInt32: proto.Int32(7), OString: proto.String("a,b"), OBytes: []byte("x")}
+	data, err := New().Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := new(pb.Simple)
+	if err := New().Unmarshal(data, out); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(in, out) {
+		t.Errorf("got %v, want %v", out, in)
+	}
+}
+
+func TestUnmarshal(t *testing.T) {
+	tests := []struct {
+		desc string
+		data string
+		want proto.Message
+		err  string
+	}{
+		{"partial header", "oInt32\n3\n", &pb.Simple{OInt32: proto.Int32(3)}, ""},
+		{"empty", "", nil, "grpccodec: missing header"},
+		{"header only", "oInt32\n", nil, "grpccodec: missing record"},
+		{"two records", "oInt32\n1\n2\n", nil, "grpccodec: more than one record"},
+		{"unknown field", "foo\n1\n", nil, `unknown field "foo" in jsonpb.Simple`},
+	}
+	for _, tt := range tests {
+		got := new(pb.Simple)
+		err := New().Unmarshal([]byte(tt.data), got)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: got error %v, want %q", tt.desc, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestNotAMessage(t *testing.T) {
+	if _, err := New().Marshal(42); err == nil {
+		t.Error("Marshal: expected error")
+	}
+	var v int
+	if err := New().Unmarshal([]byte("a\n1\n"), &v); err == nil {
+		t.Error("Unmarshal: expected error")
+	}
+}
@@ -0,0 +1,234 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package grpcupload imports CSV files over client-streaming gRPC methods,
+sending one message per record.
+
+Records are sent in batches, each over a stream of its own, so a failed
+stream is retried with the messages of its batch only. Generated client
+streams send and receive concrete message types, so they are adapted to
+Stream by a few lines:
+
+	type importStream struct{ pb.Service_ImportClient }
+
+	func (s importStream) Send(m proto.Message) error {
+		return s.Service_ImportClient.Send(m.(*pb.Row))
+	}
+
+	func (s importStream) CloseAndRecv() (proto.Message, error) {
+		return s.Service_ImportClient.CloseAndRecv()
+	}
+
+	open := func(ctx context.Context) (grpcupload.Stream, error) {
+		s, err := client.Import(ctx)
+		return importStream{s}, err
+	}
+	res, err := new(grpcupload.Uploader).UploadFile(ctx, "rows.csv", newRow, open)
+*/
+package grpcupload
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Stream is the client side of a client-streaming call.
+type Stream interface {
+	// Send sends a message. Should the server have ended the call, it
+	// returns io.EOF and CloseAndRecv the status of the call.
+	Send(proto.Message) error
+	// CloseAndRecv closes the sending side and returns the response.
+	CloseAndRecv() (proto.Message, error)
+}
+
+// DefaultBatchSize is the BatchSize of an Uploader without one.
+const DefaultBatchSize = 1000
+
+// Uploader sends the records of CSV files over client-streaming calls.
+type Uploader struct {
+	// Unmarshaler reads the records. A zero Unmarshaler if nil.
+	Unmarshaler *csvpb.Unmarshaler
+
+	// BatchSize is the number of messages sent over a stream.
+	// DefaultBatchSize if 0.
+	BatchSize int
+
+	// Retries is how often a batch is sent again over a new stream, should
+	// its stream fail.
+	Retries int
+
+	// Backoff is the wait before the first retry of a batch, doubled for
+	// every further retry.
+	Backoff time.Duration
+
+	// Retryable, if set, tells whether a batch failing with err is retried.
+	// Every error is retried if nil.
+	Retryable func(err error) bool
+}
+
+// Result reports an upload.
+type Result struct {
+	// Summary reports the records read.
+	Summary *csvpb.Summary
+	// Sent is the number of messages of the batches acknowledged.
+	Sent int
+	// Responses holds the response of every batch acknowledged, in order.
+	Responses []proto.Message
+}
+
+// BatchError is returned for a batch failing for good. Its messages are
+// from the records in the rows FirstRow to LastRow, 1-based like
+// csvpb.RowError.Row.
+type BatchError struct {
+	FirstRow, LastRow int
+	// Attempts is the number of streams the batch was sent over.
+	Attempts int
+	Err      error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("rows %d to %d: %v", e.FirstRow, e.LastRow, e.Err)
+}
+
+// batch holds messages not yet acknowledged.
+type batch struct {
+	pbs               []proto.Message
+	firstRow, lastRow int
+}
+
+// UploadFile is Upload for the file at path.
+func (up *Uploader) UploadFile(ctx context.Context, path string, factory func() proto.Message, open func(context.Context) (Stream, error)) (*Result, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return &Result{Summary: &csvpb.Summary{}}, err
+	}
+	defer f.Close()
+	return up.Upload(ctx, f, factory, open)
+}
+
+// Upload unmarshals the CSV read from r into messages created by factory
+// and sends them over streams returned by open, a batch per stream. It
+// stops at the first batch failing for good, with a *BatchError, or at the
+// first record failing to unmarshal, with a *csvpb.RowError. The returned
+// Result is never nil, even if an error occurs.
+func (up *Uploader) Upload(ctx context.Context, r io.Reader, factory func() proto.Message, open func(context.Context) (Stream, error)) (*Result, error) {
+	var u csvpb.Unmarshaler
+	if up.Unmarshaler != nil {
+		u = *up.Unmarshaler
+	}
+	size := up.BatchSize
+	if size <= 0 {
+		size = DefaultBatchSize
+	}
+
+	// The last middleware sees the row of the message decoded next
+	var row int
+	u.Middleware = append(u.Middleware[:len(u.Middleware):len(u.Middleware)], func(record []string, r int) ([]string, error) {
+		row = r
+		return record, nil
+	})
+
+	res := &Result{}
+	var b batch
+	s, err := u.UnmarshalEach(r, factory, func(pb proto.Message) error {
+		if len(b.pbs) == 0 {
+			b.firstRow = row
+		}
+		b.pbs = append(b.pbs, pb)
+		b.lastRow = row
+		if len(b.pbs) < size {
+			return nil
+		}
+		err := up.send(ctx, &b, open, res)
+		b = batch{}
+		return err
+	})
+	res.Summary = s
+	if err == nil && len(b.pbs) > 0 {
+		err = up.send(ctx, &b, open, res)
+	}
+	return res, err
+}
+
+// send sends b over a stream returned by open, retrying it as configured.
+func (up *Uploader) send(ctx context.Context, b *batch, open func(context.Context) (Stream, error), res *Result) error {
+	backoff := up.Backoff
+	for attempt := 1; ; attempt++ {
+		resp, err := sendBatch(ctx, b.pbs, open)
+		if err == nil {
+			res.Sent += len(b.pbs)
+			res.Responses = append(res.Responses, resp)
+			return nil
+		}
+		if attempt > up.Retries || (up.Retryable != nil && !up.Retryable(err)) || ctx.Err() != nil {
+			return &BatchError{FirstRow: b.firstRow, LastRow: b.lastRow, Attempts: attempt, Err: err}
+		}
+		if backoff > 0 {
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return &BatchError{FirstRow: b.firstRow, LastRow: b.lastRow, Attempts: attempt, Err: ctx.Err()}
+			}
+			backoff *= 2
+		}
+	}
+}
+
+// sendBatch sends pbs over a single stream, returning its response.
+func sendBatch(ctx context.Context, pbs []proto.Message, open func(context.Context) (Stream, error)) (proto.Message, error) {
+	stream, err := open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i, pb := range pbs {
+		err := stream.Send(pb)
+		if err == io.EOF {
+			// The status of the call tells why the server ended it
+			if _, err := stream.CloseAndRecv(); err != nil {
+				return nil, err
+			}
+			return nil, fmt.Errorf("stream ended after %d of %d messages", i, len(pbs))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return stream.CloseAndRecv()
+}
@@ -0,0 +1,136 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package grpcupload
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+var errUnavailable = errors.New("unavailable")
+
+// server records the messages of the streams opened, failing the streams
+// listed in fail.
+type server struct {
+	streams  int
+	fail     map[int]bool
+	received [][]int32
+}
+
+type stream struct {
+	s    *server
+	fail bool
+	pbs  []int32
+}
+
+func (s *stream) Send(m proto.Message) error {
+	if s.fail && len(s.pbs) > 0 {
+		return io.EOF
+	}
+	s.pbs = append(s.pbs, m.(*pb.Simple).GetOInt32())
+	return nil
+}
+
+func (s *stream) CloseAndRecv() (proto.Message, error) {
+	if s.fail {
+		return nil, errUnavailable
+	}
+	s.s.received = append(s.s.received, s.pbs)
+	return &pb.Simple{OInt32: proto.Int32(int32(len(s.pbs)))}, nil
+}
+
+func (s *server) open(ctx context.Context) (Stream, error) {
+	s.streams++
+	return &stream{s: s, fail: s.fail[s.streams]}, nil
+}
+
+func newSimple() proto.Message {
+	return new(pb.Simple)
+}
+
+const input = "oInt32\n1\n2\n3\n4\n5\n"
+
+func TestUpload(t *testing.T) {
+	srv := &server{fail: map[int]bool{2: true}}
+	up := &Uploader{BatchSize: 2, Retries: 1}
+	res, err := up.Upload(context.Background(), strings.NewReader(input), newSimple, srv.open)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exp := [][]int32{{1, 2}, {3, 4}, {5}}
+	if !reflect.DeepEqual(srv.received, exp) {
+		t.Errorf("got %v, want %v", srv.received, exp)
+	}
+	if srv.streams != 4 || res.Sent != 5 || len(res.Responses) != 3 || res.Summary.RowsDecoded != 5 {
+		t.Errorf("got %d streams, result %+v", srv.streams, res)
+	}
+}
+
+func TestUploadBatchError(t *testing.T) {
+	srv := &server{fail: map[int]bool{2: true, 3: true}}
+	up := &Uploader{BatchSize: 2, Retries: 1}
+	res, err := up.Upload(context.Background(), strings.NewReader(input), newSimple, srv.open)
+	want := &BatchError{FirstRow: 4, LastRow: 5, Attempts: 2, Err: errUnavailable}
+	if !reflect.DeepEqual(err, want) {
+		t.Errorf("got error %v, want %v", err, want)
+	}
+	if res.Sent != 2 {
+		t.Errorf("got result %+v", res)
+	}
+
+	srv = &server{fail: map[int]bool{1: true}}
+	up.Retryable = func(err error) bool { return err != errUnavailable }
+	if _, err := up.Upload(context.Background(), strings.NewReader(input), newSimple, srv.open); err == nil || srv.streams != 1 {
+		t.Errorf("got error %v after %d streams, want no retry", err, srv.streams)
+	}
+}
+
+func TestUploadRowError(t *testing.T) {
+	srv := &server{}
+	up := &Uploader{Unmarshaler: &csvpb.Unmarshaler{Middleware: []csvpb.RecordMiddleware{csvpb.TrimCells}}}
+	res, err := up.Upload(context.Background(), strings.NewReader("oInt32\n 1\nbad\n"), newSimple, srv.open)
+	if re, ok := err.(*csvpb.RowError); !ok || re.Row != 3 {
+		t.Errorf("got error %v, want an error in row 3", err)
+	}
+	if res.Sent != 0 || srv.streams != 0 {
+		t.Errorf("got %d streams, result %+v", srv.streams, res)
+	}
+}
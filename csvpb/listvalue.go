@@ -0,0 +1,147 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+
+	stpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+// Elements of ListValue cells may be lists themselves, one level deep.
+// Should the field have a NestedDelimiter, nested lists are its elements
+// joined by it, like a|b,c|d for [[a, b], [c, d]]. Elements without it are
+// not lists then, so a nested list of a single element cannot be told from
+// the element. Otherwise nested lists are JSON arrays, like ["a","b"],c.
+
+// unmarshalListValue sets target, a ListValue, from a cell holding a list.
+func (u *Unmarshaler) unmarshalListValue(target *stpb.ListValue, cell string, prop *proto.Properties) error {
+	o := u.fieldOptions(prop)
+	elems, err := splitList(cell, listDelimiter(o))
+	if err != nil {
+		return fmt.Errorf("bad list: %v", err)
+	}
+	if u.MaxListLength > 0 && len(elems) > u.MaxListLength {
+		return &LimitError{What: "list length", Limit: int64(u.MaxListLength)}
+	}
+	target.Values = make([]*stpb.Value, len(elems))
+	for i, elem := range elems {
+		nested, err := u.nestedList(o, elem)
+		if err != nil {
+			return err
+		}
+		if nested != nil {
+			target.Values[i] = &stpb.Value{Kind: &stpb.Value_ListValue{ListValue: nested}}
+		} else {
+			target.Values[i] = u.detectValue(elem)
+		}
+	}
+	return nil
+}
+
+// nestedList returns the list held by elem, the element of a ListValue
+// cell, or nil if elem is not a list.
+func (u *Unmarshaler) nestedList(o *FieldOptions, elem string) (*stpb.ListValue, error) {
+	if o != nil && o.NestedDelimiter != 0 {
+		if !strings.ContainsRune(elem, o.NestedDelimiter) {
+			return nil, nil
+		}
+		cells, err := splitList(elem, o.NestedDelimiter)
+		if err != nil {
+			return nil, fmt.Errorf("bad nested list: %v", err)
+		}
+		if u.MaxListLength > 0 && len(cells) > u.MaxListLength {
+			return nil, &LimitError{What: "list length", Limit: int64(u.MaxListLength)}
+		}
+		lv := &stpb.ListValue{Values: make([]*stpb.Value, len(cells))}
+		for i, cell := range cells {
+			lv.Values[i] = u.detectValue(cell)
+		}
+		return lv, nil
+	}
+
+	trimmed := strings.TrimSpace(elem)
+	if !strings.HasPrefix(trimmed, "[") || !strings.HasSuffix(trimmed, "]") {
+		return nil, nil
+	}
+	lv := new(stpb.ListValue)
+	if err := jsonpb.UnmarshalString(trimmed, lv); err != nil {
+		// Text that merely looks like an array
+		return nil, nil
+	}
+	if u.MaxListLength > 0 && len(lv.Values) > u.MaxListLength {
+		return nil, &LimitError{What: "list length", Limit: int64(u.MaxListLength)}
+	}
+	return lv, nil
+}
+
+// marshalListValue converts a ListValue into a cell holding a list.
+func (m *Marshaler) marshalListValue(lv *stpb.ListValue, prop *proto.Properties) (string, error) {
+	o := m.fieldOptions(prop)
+	cells := make([]string, len(lv.Values))
+	for i, v := range lv.Values {
+		var err error
+		if nested := v.GetListValue(); nested != nil {
+			cells[i], err = m.marshalNestedList(o, nested, prop)
+		} else {
+			cells[i], err = m.marshalStructValue(v, prop)
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return joinList(cells, listDelimiter(o))
+}
+
+// marshalNestedList converts a ListValue nested in another into an
+// element of its cell.
+func (m *Marshaler) marshalNestedList(o *FieldOptions, lv *stpb.ListValue, prop *proto.Properties) (string, error) {
+	if o == nil || o.NestedDelimiter == 0 {
+		return new(jsonpb.Marshaler).MarshalToString(lv)
+	}
+	cells := make([]string, len(lv.Values))
+	for i, v := range lv.Values {
+		if v.GetListValue() != nil {
+			return "", errors.New("lists nested more than one level deep")
+		}
+		var err error
+		if cells[i], err = m.marshalStructValue(v, prop); err != nil {
+			return "", err
+		}
+	}
+	return joinList(cells, o.NestedDelimiter)
+}
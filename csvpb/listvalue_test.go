@@ -0,0 +1,106 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	stpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+func str(s string) *stpb.Value {
+	return &stpb.Value{Kind: &stpb.Value_StringValue{StringValue: s}}
+}
+
+func list(vs ...*stpb.Value) *stpb.Value {
+	return &stpb.Value{Kind: &stpb.Value_ListValue{ListValue: &stpb.ListValue{Values: vs}}}
+}
+
+func TestNestedListValue(t *testing.T) {
+	nested := &FieldOptions{NestedDelimiter: '|'}
+	tests := []struct {
+		desc string
+		o    *FieldOptions
+		cell string
+		want *stpb.ListValue
+	}{
+		{"json", nil, `"[""a"",""b""]",c`, &stpb.ListValue{Values: []*stpb.Value{list(str("a"), str("b")), str("c")}}},
+		{"not json", nil, `[a],c`, &stpb.ListValue{Values: []*stpb.Value{str("[a]"), str("c")}}},
+		{"delimiter", nested, `a|b,c|d`, &stpb.ListValue{Values: []*stpb.Value{list(str("a"), str("b")), list(str("c"), str("d"))}}},
+		{"delimiter scalar", nested, `a|b,c`, &stpb.ListValue{Values: []*stpb.Value{list(str("a"), str("b")), str("c")}}},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{Header: []string{"lv"}}
+		m := new(Marshaler)
+		if tt.o != nil {
+			u.SetFieldOptions("jsonpb.KnownTypes.lv", *tt.o)
+			m.SetFieldOptions("jsonpb.KnownTypes.lv", *tt.o)
+		}
+		got := new(pb.KnownTypes)
+		if err := u.UnmarshalRecord([]string{tt.cell}, got); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if !proto.Equal(got.Lv, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.desc, got.Lv, tt.want)
+			continue
+		}
+
+		var buf strings.Builder
+		if err := m.Marshal(&buf, &pb.KnownTypes{Lv: tt.want}); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		u.Header = nil
+		back, _, err := u.UnmarshalAll(strings.NewReader(buf.String()), func() proto.Message { return new(pb.KnownTypes) })
+		if err != nil || len(back) != 1 {
+			t.Errorf("%s: got %v, %v", tt.desc, back, err)
+			continue
+		}
+		if lv := back[0].(*pb.KnownTypes).Lv; !proto.Equal(lv, tt.want) {
+			t.Errorf("%s: round trip got %v, want %v", tt.desc, lv, tt.want)
+		}
+	}
+}
+
+func TestNestedListValueTooDeep(t *testing.T) {
+	m := new(Marshaler)
+	m.SetFieldOptions("jsonpb.KnownTypes.lv", FieldOptions{NestedDelimiter: '|'})
+	in := &pb.KnownTypes{Lv: &stpb.ListValue{Values: []*stpb.Value{list(list(str("a")))}}}
+	if _, err := m.MarshalRecord(in); err == nil {
+		t.Error("MarshalRecord() succeeded, want error")
+	}
+}
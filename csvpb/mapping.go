@@ -0,0 +1,553 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	stpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+// columnMapping feeds several columns into a single field, instead of a
+// column of its own, and splits the field back into them.
+type columnMapping struct {
+	columns []string
+	// prefix, if set with columns nil, maps every column starting with it
+	// on input.
+	prefix string
+	// formats describe the cells of columns, for Report.
+	formats []string
+	// combine sets target, the field with prop, from the cells of columns,
+	// which are not all null.
+	combine func(u *Unmarshaler, target reflect.Value, prop *proto.Properties, columns, cells []string) error
+	// split returns the cells of columns for v, the set field with prop.
+	split func(m *Marshaler, v reflect.Value, prop *proto.Properties) ([]string, error)
+}
+
+// lookupMapping returns the mapping of the field with prop in byName, if
+// any.
+func lookupMapping(byName map[string]*columnMapping, prop *proto.Properties) *columnMapping {
+	if len(byName) == 0 || prop == nil {
+		return nil
+	}
+	if name, ok := propNames.Load(prop); ok {
+		return byName[name.(string)]
+	}
+	return nil
+}
+
+func (u *Unmarshaler) mapping(prop *proto.Properties) *columnMapping {
+	return lookupMapping(u.mappingsByName, prop)
+}
+
+func (m *Marshaler) mapping(prop *proto.Properties) *columnMapping {
+	return lookupMapping(m.mappingsByName, prop)
+}
+
+func (u *Unmarshaler) addMapping(field string, mapping *columnMapping) {
+	if u.mappingsByName == nil {
+		u.mappingsByName = make(map[string]*columnMapping)
+	}
+	u.mappingsByName[field] = mapping
+}
+
+func (m *Marshaler) addMapping(field string, mapping *columnMapping) {
+	if m.mappingsByName == nil {
+		m.mappingsByName = make(map[string]*columnMapping)
+	}
+	m.mappingsByName[field] = mapping
+}
+
+// unmarshalMapped sets target, the field with prop, from the columns of
+// mapping in fields, removing them. Fields without any of the columns or
+// with all of them null are left unset.
+func (u *Unmarshaler) unmarshalMapped(target reflect.Value, prop *proto.Properties, mapping *columnMapping, fields map[string]string) error {
+	columns := mapping.columns
+	if mapping.dynamic() {
+		for column := range fields {
+			if strings.HasPrefix(column, mapping.prefix) {
+				columns = append(columns, column)
+			}
+		}
+		// Errors name the same column for the same record
+		sort.Strings(columns)
+	}
+	cells := make([]string, len(columns))
+	var missing string
+	found, null := 0, 0
+	o := u.fieldOptions(prop)
+	for i, column := range columns {
+		cell, ok := fields[column]
+		if !ok {
+			missing = column
+			continue
+		}
+		delete(fields, column)
+		cells[i] = cell
+		found++
+		if isNull(o, cell) {
+			null++
+		}
+	}
+	if found == 0 || null == len(cells) {
+		return nil
+	}
+	if missing != "" {
+		return fmt.Errorf("field %q: missing column %q", prop.OrigName, missing)
+	}
+	if err := mapping.combine(u, target, prop, columns, cells); err != nil {
+		return fmt.Errorf("field %q: %v", prop.OrigName, err)
+	}
+	return nil
+}
+
+// dynamic tells whether the columns of mapping depend on the input.
+func (mapping *columnMapping) dynamic() bool {
+	return mapping.columns == nil && mapping.prefix != ""
+}
+
+// covers tells whether mapping maps column on input.
+func (mapping *columnMapping) covers(column string) bool {
+	if mapping.dynamic() {
+		return strings.HasPrefix(column, mapping.prefix)
+	}
+	for _, c := range mapping.columns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// coveredBy tells whether any of mappings maps column on input.
+func coveredBy(mappings []*columnMapping, column string) bool {
+	for _, mapping := range mappings {
+		if mapping.covers(column) {
+			return true
+		}
+	}
+	return false
+}
+
+// marshalMapped returns the cells of the columns of mapping for v, the
+// field with prop. v is the zero Value for a member of a oneof that is not
+// set.
+func (m *Marshaler) marshalMapped(v reflect.Value, prop *proto.Properties, mapping *columnMapping) ([]string, error) {
+	if !v.IsValid() || v.Kind() == reflect.Ptr && v.IsNil() {
+		cells := make([]string, len(mapping.columns))
+		for i := range cells {
+			cells[i] = m.nullCell(prop)
+		}
+		return cells, nil
+	}
+	cells, err := mapping.split(m, v, prop)
+	if err == nil && len(cells) != len(mapping.columns) {
+		err = fmt.Errorf("%d cells for %d columns", len(cells), len(mapping.columns))
+	}
+	return cells, err
+}
+
+// ColumnMapping feeds several columns into a single field, instead of a
+// column of its own, like a full name made up of a first and last name.
+type ColumnMapping struct {
+	// Columns name the columns, in the order of the cells of Combine and
+	// Split.
+	Columns []string
+	// Combine returns the value of the field for the cells of Columns, nil
+	// leaving it unset. A string is converted like the cell of the field,
+	// any other value has to be assignable to the field, or to what it
+	// points to.
+	Combine func(cells []string) (interface{}, error)
+	// Split returns the cells of Columns for v, the value of the set field,
+	// like *string for an optional string field. Required by Marshaler.
+	Split func(v interface{}) ([]string, error)
+}
+
+// MapColumns feeds the field with the full name field, like
+// "pkg.Msg.field", from the columns of c. Null cells are passed to
+// Combine, unless all are null and the field is left unset.
+func (u *Unmarshaler) MapColumns(field string, c ColumnMapping) {
+	u.addMapping(field, c.mapping())
+}
+
+// MapColumns writes the field with the full name field, like
+// "pkg.Msg.field", into the columns of c, instead of a column of its own.
+func (m *Marshaler) MapColumns(field string, c ColumnMapping) {
+	m.addMapping(field, c.mapping())
+}
+
+func (c ColumnMapping) mapping() *columnMapping {
+	return &columnMapping{
+		columns: append([]string(nil), c.Columns...),
+		formats: make([]string, len(c.Columns)),
+		combine: func(u *Unmarshaler, target reflect.Value, prop *proto.Properties, columns, cells []string) error {
+			if c.Combine == nil {
+				return errors.New("mapping without Combine")
+			}
+			value, err := c.Combine(cells)
+			if err != nil {
+				return err
+			}
+			return u.setValue(target, prop, value)
+		},
+		split: func(m *Marshaler, v reflect.Value, prop *proto.Properties) ([]string, error) {
+			if c.Split == nil {
+				return nil, errors.New("mapping without Split")
+			}
+			return c.Split(v.Interface())
+		},
+	}
+}
+
+// setValue sets target, the field with prop, to value, leaving it unset
+// if nil. A string is converted like the cell of the field, any other value
+// has to be assignable to the field, or to what it points to.
+func (u *Unmarshaler) setValue(target reflect.Value, prop *proto.Properties, value interface{}) error {
+	if value == nil {
+		return nil
+	}
+	if s, ok := value.(string); ok {
+		return u.unmarshalValue(target, s, prop, noneHint)
+	}
+	v := reflect.ValueOf(value)
+	switch t := target.Type(); {
+	case v.Type().AssignableTo(t):
+		target.Set(v)
+	case t.Kind() == reflect.Ptr && v.Type().AssignableTo(t.Elem()):
+		p := reflect.New(t.Elem())
+		p.Elem().Set(v)
+		target.Set(p)
+	default:
+		return fmt.Errorf("%T not assignable to %v", value, t)
+	}
+	return nil
+}
+
+// MapEpochColumns feeds the Timestamp or Duration field with the full name
+// field, like "pkg.Msg.field", from a column of seconds and one of nanos,
+// instead of a column of its own. A null nanos cell counts as 0.
+func (u *Unmarshaler) MapEpochColumns(field, secondsColumn, nanosColumn string) {
+	u.addMapping(field, epochMapping(secondsColumn, nanosColumn))
+}
+
+// MapEpochColumns writes the Timestamp or Duration field with the full
+// name field, like "pkg.Msg.field", into a column of seconds and one of
+// nanos, instead of a column of its own.
+func (m *Marshaler) MapEpochColumns(field, secondsColumn, nanosColumn string) {
+	m.addMapping(field, epochMapping(secondsColumn, nanosColumn))
+}
+
+func epochMapping(secondsColumn, nanosColumn string) *columnMapping {
+	return &columnMapping{
+		columns: []string{secondsColumn, nanosColumn},
+		formats: []string{"seconds since the Unix epoch", "nanoseconds"},
+		combine: combineEpoch,
+		split:   splitEpoch,
+	}
+}
+
+// epochType returns the well-known type of field type t, should it be a
+// Timestamp or Duration.
+func epochType(t reflect.Type) (string, error) {
+	if t.Kind() == reflect.Ptr {
+		if w, ok := reflect.Zero(t).Interface().(wkt); ok {
+			switch name := w.XXX_WellKnownType(); name {
+			case "Timestamp", "Duration":
+				return name, nil
+			}
+		}
+	}
+	return "", errors.New("epoch columns need a Timestamp or Duration field")
+}
+
+func combineEpoch(u *Unmarshaler, target reflect.Value, prop *proto.Properties, columns, cells []string) error {
+	name, err := epochType(target.Type())
+	if err != nil {
+		return err
+	}
+	s, err := strconv.ParseInt(unquote(cells[0]), 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad seconds: %v", err)
+	}
+	var ns int64
+	if !isNull(u.fieldOptions(prop), cells[1]) {
+		if ns, err = strconv.ParseInt(unquote(cells[1]), 10, 32); err != nil {
+			return fmt.Errorf("bad nanos: %v", err)
+		}
+	}
+	if name == "Timestamp" {
+		if ns < 0 || ns >= secondInNanos {
+			return fmt.Errorf("invalid timestamp nanos %d", ns)
+		}
+		err = checkTimestamp(prop, s)
+	} else {
+		err = checkDuration(s, ns)
+	}
+	if err != nil {
+		return err
+	}
+	v := reflect.New(target.Type().Elem())
+	v.Elem().Field(0).SetInt(s)
+	v.Elem().Field(1).SetInt(ns)
+	target.Set(v)
+	return nil
+}
+
+func splitEpoch(m *Marshaler, v reflect.Value, prop *proto.Properties) ([]string, error) {
+	if _, err := epochType(v.Type()); err != nil {
+		return nil, err
+	}
+	s, ns := v.Elem().Field(0).Int(), v.Elem().Field(1).Int()
+	return []string{strconv.FormatInt(s, 10), strconv.FormatInt(ns, 10)}, nil
+}
+
+// DateTimeColumns describes a date and a time column making up a
+// Timestamp.
+type DateTimeColumns struct {
+	// Date and Time name the columns.
+	Date, Time string
+	// DateLayout and TimeLayout format the cells, as time.Time.Format
+	// does. 2006-01-02 and 15:04:05 if empty.
+	DateLayout, TimeLayout string
+	// Location is the time zone of the cells. UTC if nil.
+	Location *time.Location
+}
+
+func (c DateTimeColumns) layouts() (string, string) {
+	date, clock := c.DateLayout, c.TimeLayout
+	if date == "" {
+		date = "2006-01-02"
+	}
+	if clock == "" {
+		clock = "15:04:05"
+	}
+	return date, clock
+}
+
+func (c DateTimeColumns) location() *time.Location {
+	if c.Location == nil {
+		return time.UTC
+	}
+	return c.Location
+}
+
+// MapDateTimeColumns feeds the Timestamp field with the full name field,
+// like "pkg.Msg.field", from a date and a time column, instead of a column
+// of its own. A null time cell stands for midnight.
+func (u *Unmarshaler) MapDateTimeColumns(field string, c DateTimeColumns) {
+	u.addMapping(field, dateTimeMapping(c))
+}
+
+// MapDateTimeColumns splits the Timestamp field with the full name field,
+// like "pkg.Msg.field", into a date and a time column, instead of a column
+// of its own.
+func (m *Marshaler) MapDateTimeColumns(field string, c DateTimeColumns) {
+	m.addMapping(field, dateTimeMapping(c))
+}
+
+func dateTimeMapping(c DateTimeColumns) *columnMapping {
+	dateLayout, timeLayout := c.layouts()
+	loc := c.location()
+	return &columnMapping{
+		columns: []string{c.Date, c.Time},
+		formats: []string{
+			"date in the layout " + dateLayout + " in " + loc.String(),
+			"time in the layout " + timeLayout + " in " + loc.String(),
+		},
+		combine: func(u *Unmarshaler, target reflect.Value, prop *proto.Properties, columns, cells []string) error {
+			if name, err := epochType(target.Type()); err != nil || name != "Timestamp" {
+				return errors.New("date and time columns need a Timestamp field")
+			}
+			date := unquote(cells[0])
+			var t time.Time
+			var err error
+			if isNull(u.fieldOptions(prop), cells[1]) {
+				t, err = time.ParseInLocation(dateLayout, date, loc)
+			} else {
+				t, err = time.ParseInLocation(dateLayout+" "+timeLayout, date+" "+unquote(cells[1]), loc)
+			}
+			if err != nil {
+				return fmt.Errorf("bad Timestamp: %v", err)
+			}
+			s, ns, err := u.timestampParts(prop, date, t)
+			if err != nil {
+				return err
+			}
+			v := reflect.New(target.Type().Elem())
+			v.Elem().Field(0).SetInt(s)
+			v.Elem().Field(1).SetInt(ns)
+			target.Set(v)
+			return nil
+		},
+		split: func(m *Marshaler, v reflect.Value, prop *proto.Properties) ([]string, error) {
+			if name, err := epochType(v.Type()); err != nil || name != "Timestamp" {
+				return nil, errors.New("date and time columns need a Timestamp field")
+			}
+			s, ns := v.Elem().Field(0).Int(), v.Elem().Field(1).Int()
+			if err := checkTimestamp(prop, s); err != nil {
+				return nil, err
+			}
+			t := time.Unix(s, ns).In(loc)
+			return []string{t.Format(dateLayout), t.Format(timeLayout)}, nil
+		},
+	}
+}
+
+// StructColumns describes columns making up a Struct, one per key, named
+// by a common prefix and the key, like attrs.color.
+type StructColumns struct {
+	// Prefix starts the names of the columns.
+	Prefix string
+	// Keys are the keys of the columns, in order. Required by Marshaler.
+	// Unmarshaler reads every column starting with Prefix if nil.
+	Keys []string
+}
+
+// MapStructColumns feeds the Struct field with the full name field, like
+// "pkg.Msg.field", from the columns of c, instead of a column of its own.
+// Cells are read like Value cells, null cells leave their key out.
+func (u *Unmarshaler) MapStructColumns(field string, c StructColumns) {
+	u.addMapping(field, structMapping(c))
+}
+
+// MapStructColumns writes the Struct field with the full name field, like
+// "pkg.Msg.field", into the columns of c.Keys, instead of a column of its
+// own. Keys missing from the Struct are null, others are written like
+// Value cells.
+func (m *Marshaler) MapStructColumns(field string, c StructColumns) {
+	m.addMapping(field, structMapping(c))
+}
+
+func structMapping(c StructColumns) *columnMapping {
+	mapping := &columnMapping{
+		prefix: c.Prefix,
+		combine: func(u *Unmarshaler, target reflect.Value, prop *proto.Properties, columns, cells []string) error {
+			if target.Type() != reflect.TypeOf(&stpb.Struct{}) {
+				return errors.New("struct columns need a Struct field")
+			}
+			s := &stpb.Struct{Fields: make(map[string]*stpb.Value, len(cells))}
+			o := u.fieldOptions(prop)
+			for i, cell := range cells {
+				if !isNull(o, cell) {
+					s.Fields[strings.TrimPrefix(columns[i], c.Prefix)] = u.detectValue(cell)
+				}
+			}
+			target.Set(reflect.ValueOf(s))
+			return nil
+		},
+		split: func(m *Marshaler, v reflect.Value, prop *proto.Properties) ([]string, error) {
+			s, ok := v.Interface().(*stpb.Struct)
+			if !ok {
+				return nil, errors.New("struct columns need a Struct field")
+			}
+			cells := make([]string, len(c.Keys))
+			for i, key := range c.Keys {
+				value, ok := s.Fields[key]
+				if !ok {
+					cells[i] = m.nullCell(prop)
+					continue
+				}
+				var err error
+				if cells[i], err = m.marshalStructValue(value, prop); err != nil {
+					return nil, fmt.Errorf("key %q: %v", key, err)
+				}
+			}
+			return cells, nil
+		},
+	}
+	for _, key := range c.Keys {
+		mapping.columns = append(mapping.columns, c.Prefix+key)
+		mapping.formats = append(mapping.formats, "number, boolean or text")
+	}
+	return mapping
+}
+
+// SplitColumn feeds several fields from column, by the named capture
+// groups of re, like (?P<area>\d{3})-(?P<number>\d{7}). Every group names a
+// column of its own, a field by its orig or camel name, which is read
+// like any other column. Cells not matched by re fail, null cells leave
+// the fields unset, as do groups not taking part in a match.
+func (u *Unmarshaler) SplitColumn(column string, re *regexp.Regexp) {
+	if u.splits == nil {
+		u.splits = make(map[string]*regexp.Regexp)
+	}
+	u.splits[column] = re
+}
+
+// splitColumns replaces the columns of SplitColumn in fields by those of
+// their capture groups.
+func (u *Unmarshaler) splitColumns(fields map[string]string) error {
+	for column, re := range u.splits {
+		cell, ok := fields[column]
+		if !ok {
+			continue
+		}
+		delete(fields, column)
+		if cell == nullToken {
+			continue
+		}
+		match := re.FindStringSubmatchIndex(cell)
+		if match == nil {
+			return fmt.Errorf("column %q: %q does not match %v", column, cell, re)
+		}
+		for i, name := range re.SubexpNames() {
+			if name == "" || match[2*i] < 0 {
+				continue
+			}
+			if _, ok := fields[name]; ok {
+				return fmt.Errorf("column %q: group %q clashes with a column", column, name)
+			}
+			fields[name] = cell[match[2*i]:match[2*i+1]]
+		}
+	}
+	return nil
+}
+
+// splitsInto tells whether any group of the column split by re is one of
+// names.
+func splitsInto(re *regexp.Regexp, names map[string]bool) bool {
+	for _, name := range re.SubexpNames() {
+		if name != "" && names[name] {
+			return true
+		}
+	}
+	return false
+}
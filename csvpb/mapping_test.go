@@ -0,0 +1,370 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	durpb "github.com/golang/protobuf/ptypes/duration"
+	stpb "github.com/golang/protobuf/ptypes/struct"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+)
+
+func TestMapEpochColumns(t *testing.T) {
+	u := &Unmarshaler{Header: []string{"ts_seconds", "ts_nanos", "dur_s", "dur_ns"}}
+	u.MapEpochColumns("jsonpb.KnownTypes.ts", "ts_seconds", "ts_nanos")
+	u.MapEpochColumns("jsonpb.KnownTypes.dur", "dur_s", "dur_ns")
+	tests := []struct {
+		record []string
+		want   *pb.KnownTypes
+	}{
+		{[]string{"1400000000", "21000000", "-1", "-500"}, &pb.KnownTypes{
+			Ts:  &tspb.Timestamp{Seconds: 14e8, Nanos: 21e6},
+			Dur: &durpb.Duration{Seconds: -1, Nanos: -500},
+		}},
+		{[]string{"1400000000", "null", "null", "null"}, &pb.KnownTypes{Ts: &tspb.Timestamp{Seconds: 14e8}}},
+		{[]string{"1400000000", "-1", "0", "0"}, nil},
+		{[]string{"1", "0", "1", "-1"}, nil},
+		{[]string{"253402300800", "0", "0", "0"}, nil},
+		{[]string{"x", "0", "0", "0"}, nil},
+	}
+	for _, tt := range tests {
+		got := new(pb.KnownTypes)
+		err := u.UnmarshalRecord(tt.record, got)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q: got %v, expected an error", tt.record, got)
+			}
+			continue
+		}
+		if err != nil || !proto.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, %v, want %v", tt.record, got, err, tt.want)
+		}
+	}
+
+	u = &Unmarshaler{Header: []string{"ts_seconds"}}
+	u.MapEpochColumns("jsonpb.KnownTypes.ts", "ts_seconds", "ts_nanos")
+	if err := u.UnmarshalRecord([]string{"1"}, new(pb.KnownTypes)); err == nil || !strings.Contains(err.Error(), "ts_nanos") {
+		t.Errorf("missing nanos column: got %v", err)
+	}
+}
+
+func TestMarshalEpochColumns(t *testing.T) {
+	m := new(Marshaler)
+	m.MapEpochColumns("jsonpb.KnownTypes.ts", "ts_seconds", "ts_nanos")
+	in := &pb.KnownTypes{Ts: &tspb.Timestamp{Seconds: 14e8, Nanos: 21e6}}
+	header, err := m.Header(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := m.MarshalRecord(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := make(map[string]string)
+	for i, column := range header {
+		cells[column] = record[i]
+	}
+	if _, ok := cells["ts"]; ok {
+		t.Errorf("got column ts in %v", header)
+	}
+	if cells["ts_seconds"] != "1400000000" || cells["ts_nanos"] != "21000000" {
+		t.Errorf("got %v", cells)
+	}
+
+	u := &Unmarshaler{Header: header}
+	u.MapEpochColumns("jsonpb.KnownTypes.ts", "ts_seconds", "ts_nanos")
+	got := new(pb.KnownTypes)
+	if err := u.UnmarshalRecord(record, got); err != nil || !proto.Equal(got.Ts, in.Ts) {
+		t.Errorf("got %v, %v, want %v", got.Ts, err, in.Ts)
+	}
+}
+
+func TestMapDateTimeColumns(t *testing.T) {
+	c := DateTimeColumns{
+		Date:       "date",
+		Time:       "time",
+		DateLayout: "01/02/2006",
+		TimeLayout: "3:04 PM",
+		Location:   time.FixedZone("CEST", 2*60*60),
+	}
+	// 2024-05-01T13:04:00Z
+	in := &pb.KnownTypes{Ts: &tspb.Timestamp{Seconds: 1714568640}}
+
+	m := new(Marshaler)
+	m.MapDateTimeColumns("jsonpb.KnownTypes.ts", c)
+	report, err := m.Report(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var header, record []string
+	for _, r := range report {
+		if r.Field == "ts" {
+			header = append(header, r.Column)
+		}
+	}
+	if !reflect.DeepEqual(header, []string{"date", "time"}) {
+		t.Fatalf("got columns %v for ts", header)
+	}
+	if header, err = m.Header(in); err != nil {
+		t.Fatal(err)
+	}
+	if record, err = m.MarshalRecord(in); err != nil {
+		t.Fatal(err)
+	}
+	cells := make(map[string]string)
+	for i, column := range header {
+		cells[column] = record[i]
+	}
+	if cells["date"] != "05/01/2024" || cells["time"] != "3:04 PM" {
+		t.Errorf("got %v", cells)
+	}
+
+	u := &Unmarshaler{Header: []string{"date", "time"}}
+	u.MapDateTimeColumns("jsonpb.KnownTypes.ts", c)
+	for _, tt := range []struct {
+		record  []string
+		seconds int64
+	}{
+		{[]string{"05/01/2024", "3:04 PM"}, 1714568640},
+		{[]string{"05/01/2024", "null"}, 1714514400},
+		{[]string{"2024-05-01", "3:04 PM"}, 0},
+	} {
+		got := new(pb.KnownTypes)
+		err := u.UnmarshalRecord(tt.record, got)
+		if tt.seconds == 0 {
+			if err == nil {
+				t.Errorf("%q: got %v, expected an error", tt.record, got)
+			}
+			continue
+		}
+		if err != nil || got.GetTs().GetSeconds() != tt.seconds {
+			t.Errorf("%q: got %v, %v, want %d seconds", tt.record, got, err, tt.seconds)
+		}
+	}
+}
+
+func TestMapColumns(t *testing.T) {
+	name := ColumnMapping{
+		Columns: []string{"first", "last"},
+		Combine: func(cells []string) (interface{}, error) {
+			return strings.TrimSpace(cells[0] + " " + cells[1]), nil
+		},
+		Split: func(v interface{}) ([]string, error) {
+			parts := strings.SplitN(*v.(*string), " ", 2)
+			if len(parts) != 2 {
+				return nil, errors.New("not a full name")
+			}
+			return parts, nil
+		},
+	}
+	kilo := map[string]float64{"g": 1e-3, "kg": 1}
+	weight := ColumnMapping{
+		Columns: []string{"value", "unit"},
+		Combine: func(cells []string) (interface{}, error) {
+			f, err := strconv.ParseFloat(cells[0], 64)
+			if err != nil {
+				return nil, err
+			}
+			factor, ok := kilo[cells[1]]
+			if !ok {
+				return nil, errors.New("unknown unit " + cells[1])
+			}
+			return f * factor, nil
+		},
+	}
+
+	u := &Unmarshaler{Header: []string{"first", "last", "value", "unit"}}
+	u.MapColumns("jsonpb.Simple.o_string", name)
+	u.MapColumns("jsonpb.Simple.o_double", weight)
+	tests := []struct {
+		record []string
+		want   *pb.Simple
+	}{
+		{[]string{"Ada", "Lovelace", "1500", "g"}, &pb.Simple{OString: proto.String("Ada Lovelace"), ODouble: proto.Float64(1.5)}},
+		{[]string{"Ada", "null", "null", "null"}, &pb.Simple{OString: proto.String("Ada null")}},
+		{[]string{"null", "null", "1", "lb"}, nil},
+	}
+	for _, tt := range tests {
+		got := new(pb.Simple)
+		err := u.UnmarshalRecord(tt.record, got)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q: got %v, expected an error", tt.record, got)
+			}
+			continue
+		}
+		if err != nil || !proto.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, %v, want %v", tt.record, got, err, tt.want)
+		}
+	}
+
+	// Combine returning a value of the wrong type
+	u = &Unmarshaler{Header: []string{"value", "unit"}}
+	u.MapColumns("jsonpb.Simple.o_double", ColumnMapping{
+		Columns: weight.Columns,
+		Combine: func([]string) (interface{}, error) { return 1, nil },
+	})
+	if err := u.UnmarshalRecord([]string{"1", "kg"}, new(pb.Simple)); err == nil {
+		t.Error("int for double: expected an error")
+	}
+
+	m := new(Marshaler)
+	m.MapColumns("jsonpb.Simple.o_string", name)
+	in := &pb.Simple{OString: proto.String("Ada Lovelace")}
+	header, err := m.Header(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := m.MarshalRecord(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := make(map[string]string)
+	for i, column := range header {
+		cells[column] = record[i]
+	}
+	if cells["first"] != "Ada" || cells["last"] != "Lovelace" {
+		t.Errorf("got %v", cells)
+	}
+	m.MapColumns("jsonpb.Simple.o_double", weight)
+	if _, err := m.MarshalRecord(&pb.Simple{ODouble: proto.Float64(1)}); err == nil {
+		t.Error("mapping without Split: expected an error")
+	}
+}
+
+func TestSplitColumn(t *testing.T) {
+	u := &Unmarshaler{Header: []string{"phone", "oBool"}}
+	u.SplitColumn("phone", regexp.MustCompile(`^(?P<o_int32>\d{3})-(?P<oInt64>\d{7})(?: x(?P<oString>\d+))?$`))
+	tests := []struct {
+		record []string
+		want   *pb.Simple
+	}{
+		{[]string{"555-1234567", "true"}, &pb.Simple{OInt32: proto.Int32(555), OInt64: proto.Int64(1234567), OBool: proto.Bool(true)}},
+		{[]string{"555-1234567 x89", "null"}, &pb.Simple{OInt32: proto.Int32(555), OInt64: proto.Int64(1234567), OString: proto.String("89")}},
+		{[]string{"null", "false"}, &pb.Simple{OBool: proto.Bool(false)}},
+		{[]string{"5551234567", "true"}, nil},
+	}
+	for _, tt := range tests {
+		got := new(pb.Simple)
+		err := u.UnmarshalRecord(tt.record, got)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q: got %v, expected an error", tt.record, got)
+			}
+			continue
+		}
+		if err != nil || !proto.Equal(got, tt.want) {
+			t.Errorf("%q: got %v, %v, want %v", tt.record, got, err, tt.want)
+		}
+	}
+
+	// Groups are projected like the columns of their fields
+	u.Columns = []string{"oInt32"}
+	got := new(pb.Simple)
+	if err := u.UnmarshalRecord([]string{"555-1234567", "true"}, got); err != nil || got.GetOInt32() != 555 || got.OBool != nil {
+		t.Errorf("projected: got %v, %v", got, err)
+	}
+
+	// Groups not naming a field are unknown columns
+	u = &Unmarshaler{Header: []string{"phone"}}
+	u.SplitColumn("phone", regexp.MustCompile(`(?P<area>\d{3})`))
+	if err := u.UnmarshalRecord([]string{"555"}, new(pb.Simple)); err == nil {
+		t.Error("group area: expected an error")
+	}
+}
+
+func TestMapStructColumns(t *testing.T) {
+	u := &Unmarshaler{Header: []string{"attrs.color", "dur", "attrs.size"}}
+	u.MapStructColumns("jsonpb.KnownTypes.st", StructColumns{Prefix: "attrs."})
+	for _, tt := range []struct {
+		record []string
+		want   *stpb.Struct
+	}{
+		{[]string{"red", "1s", "42"}, &stpb.Struct{Fields: map[string]*stpb.Value{
+			"color": {Kind: &stpb.Value_StringValue{StringValue: "red"}},
+			"size":  {Kind: &stpb.Value_NumberValue{NumberValue: 42}},
+		}}},
+		{[]string{"red", "1s", "null"}, &stpb.Struct{Fields: map[string]*stpb.Value{
+			"color": {Kind: &stpb.Value_StringValue{StringValue: "red"}},
+		}}},
+		{[]string{"null", "1s", "null"}, nil},
+	} {
+		got := new(pb.KnownTypes)
+		if err := u.UnmarshalRecord(tt.record, got); err != nil {
+			t.Errorf("%q: %v", tt.record, err)
+			continue
+		}
+		if !proto.Equal(got.St, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.record, got.St, tt.want)
+		}
+	}
+
+	u.Columns = []string{"st"}
+	got := new(pb.KnownTypes)
+	if err := u.UnmarshalRecord([]string{"red", "bad", "null"}, got); err != nil {
+		t.Fatal(err)
+	}
+	if got.GetSt().GetFields()["color"].GetStringValue() != "red" || got.Dur != nil {
+		t.Errorf("projected: got %v", got)
+	}
+
+	m := new(Marshaler)
+	m.MapStructColumns("jsonpb.KnownTypes.st", StructColumns{Prefix: "attrs.", Keys: []string{"color", "size"}})
+	in := &pb.KnownTypes{St: &stpb.Struct{Fields: map[string]*stpb.Value{
+		"color": {Kind: &stpb.Value_StringValue{StringValue: "red"}},
+	}}}
+	header, err := m.Header(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err := m.MarshalRecord(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cells := make(map[string]string)
+	for i, column := range header {
+		cells[column] = record[i]
+	}
+	if cells["attrs.color"] != "red" || cells["attrs.size"] != "null" {
+		t.Errorf("got %v", cells)
+	}
+}
@@ -0,0 +1,88 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Maps of cells by column share the flat shape of CSV records, like form
+// data, query parameters or key-value stores.
+
+// UnmarshalMap populates the fields of pb from cells by column, matched
+// and converted like a record. Header is ignored.
+func (u *Unmarshaler) UnmarshalMap(m map[string]string, pb proto.Message) error {
+	uc := *u
+	uc.Header = make([]string, 0, len(m))
+	for column := range m {
+		uc.Header = append(uc.Header, column)
+	}
+	// Errors name the same column for the same map
+	sort.Strings(uc.Header)
+	record := make([]string, len(uc.Header))
+	for i, column := range uc.Header {
+		record[i] = m[column]
+	}
+	return uc.UnmarshalRecord(record, pb)
+}
+
+// MarshalMap converts pb into cells by column, as written by
+// MarshalRecord.
+func (m *Marshaler) MarshalMap(pb proto.Message) (map[string]string, error) {
+	header, err := m.Header(pb)
+	if err != nil {
+		return nil, err
+	}
+	record, err := m.MarshalRecord(pb)
+	if err != nil {
+		return nil, err
+	}
+	cells := make(map[string]string, len(header))
+	for i, column := range header {
+		cells[column] = record[i]
+	}
+	return cells, nil
+}
+
+// FromMap populates the fields of pb from cells by column, using an
+// Unmarshaler with default options.
+func FromMap(m map[string]string, pb proto.Message) error {
+	return new(Unmarshaler).UnmarshalMap(m, pb)
+}
+
+// ToMap converts pb into cells by column, using a Marshaler with default
+// options.
+func ToMap(pb proto.Message) (map[string]string, error) {
+	return new(Marshaler).MarshalMap(pb)
+}
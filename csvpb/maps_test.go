@@ -0,0 +1,95 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestFromMap(t *testing.T) {
+	tests := []struct {
+		in   map[string]string
+		want *pb.Simple
+	}{
+		{map[string]string{"oInt32": "5", "o_string": "foo"}, &pb.Simple{OInt32: proto.Int32(5), OString: proto.String("foo")}},
+		{map[string]string{"oBool": "null"}, &pb.Simple{}},
+		{map[string]string{}, &pb.Simple{}},
+	}
+	for _, tt := range tests {
+		got := new(pb.Simple)
+		if err := FromMap(tt.in, got); err != nil {
+			t.Errorf("FromMap(%v): %v", tt.in, err)
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("FromMap(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	if err := FromMap(map[string]string{"oInt32": "x"}, new(pb.Simple)); err == nil {
+		t.Error("FromMap() succeeded, want error")
+	}
+	if err := FromMap(map[string]string{"unknown": "x"}, new(pb.Simple)); err == nil {
+		t.Error("FromMap() with unknown column succeeded, want error")
+	}
+}
+
+func TestToMap(t *testing.T) {
+	in := &pb.Simple{OInt32: proto.Int32(5), OString: proto.String("foo"), OBytes: []byte("bar")}
+	got, err := ToMap(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got["oInt32"] != "5" || got["oString"] != "foo" || got["oBool"] != "null" {
+		t.Errorf("ToMap() = %v", got)
+	}
+
+	m := &Marshaler{OrigName: true}
+	if got, err = m.MarshalMap(in); err != nil {
+		t.Fatal(err)
+	}
+	if got["o_int32"] != "5" {
+		t.Errorf("MarshalMap() = %v", got)
+	}
+
+	back := new(pb.Simple)
+	if err := FromMap(got, back); err != nil {
+		t.Fatal(err)
+	}
+	if !proto.Equal(back, in) {
+		t.Errorf("round trip: got %v, want %v", back, in)
+	}
+}
@@ -0,0 +1,516 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+
+	stpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+// nullToken is the cell content of a field without a value.
+const nullToken = "null"
+
+// Marshaler is a configurable object for converting between
+// protocol buffer objects and a CSV representation for them.
+type Marshaler struct {
+	// Whether to use the original (.proto) name for columns, with
+	// HeaderAuto.
+	OrigName bool
+
+	// OmitHeader leaves out the header record, for sinks requiring
+	// headerless output like bulk loads into databases or shards appended
+	// to.
+	OmitHeader bool
+
+	// SecondaryHeader, if set, is written as a record after the header,
+	// holding cells by column, like units or descriptions. Columns not in
+	// it are empty.
+	SecondaryHeader map[string]string
+
+	// Newlines decides what becomes of line breaks within strings.
+	Newlines NewlinePolicy
+
+	// NewlineEscape is written for line breaks by NewlineReplace, \n if
+	// empty.
+	NewlineEscape string
+
+	// HeaderStyle decides whether columns are named by the orig or camel
+	// name of fields. HeaderAuto leaves it to OrigName.
+	HeaderStyle HeaderStyle
+
+	// Whether to render enum values as integers, as opposed to string values.
+	EnumsAsInts bool
+
+	// Dialect adapts cells to a particular loader, like BigQuery. Cells are
+	// what Unmarshaler reads if nil.
+	Dialect *Dialect
+
+	// NonFinite holds the cells written for non-finite floats. NaN,
+	// Infinity and -Infinity if nil.
+	NonFinite *NonFinite
+
+	// FloatFormat controls how floats are written, unless overridden by
+	// FloatFormats. Shortest if nil.
+	FloatFormat *FloatFormat
+
+	// FloatFormats holds the format of floats by column name.
+	FloatFormats map[string]*FloatFormat
+
+	// ColumnFormats holds the format of values by column name, overriding
+	// any other format. They apply to scalar values, including the elements
+	// of lists and the values of wrappers, but not to null cells.
+	ColumnFormats map[string]*ColumnFormat
+
+	// Deterministic makes equal messages yield identical bytes, regardless
+	// of the order fields are declared in. Columns are ordered by field
+	// number and floats are written in their shortest form, with negative
+	// zero as 0. FloatFormat and FloatFormats are ignored.
+	Deterministic bool
+
+	// NewRecordWriter, if set, creates the RecordWriter encoding the
+	// output, instead of a csv.Writer.
+	NewRecordWriter func(w io.Writer) RecordWriter
+
+	// Encrypt, if set, is called with the column and cell of every cell of
+	// a record before it is written, returning the ciphertext of encrypted
+	// columns and other cells unchanged. Headers are not encrypted.
+	Encrypt func(column, plaintext string) (string, error)
+
+	// fieldOptionsByName holds the options of SetFieldOptions.
+	fieldOptionsByName map[string]*FieldOptions
+
+	// mappingsByName holds the columns mapped onto fields, by the full name
+	// of the field.
+	mappingsByName map[string]*columnMapping
+}
+
+// Header returns the columns used for messages of the type of pb. Every
+// field is a column, with each member of a oneof being a column of its
+// own.
+func (m *Marshaler) Header(pb proto.Message) ([]string, error) {
+	if codec := fastCodec(pb); codec != nil {
+		if header, err := codec.Header(m); err != ErrNoFastPath {
+			return header, err
+		}
+	}
+	var header []string
+	err := m.walkColumns(pb, func(name string, prop *proto.Properties, _ reflect.Value) error {
+		if mapping := m.mapping(prop); mapping != nil {
+			header = append(header, mapping.columns...)
+			return nil
+		}
+		header = append(header, name)
+		return nil
+	})
+	return header, err
+}
+
+// MarshalRecord converts pb into a record matching Header.
+func (m *Marshaler) MarshalRecord(pb proto.Message) ([]string, error) {
+	if codec := fastCodec(pb); codec != nil {
+		if record, err := codec.MarshalRecord(m, pb); err != ErrNoFastPath {
+			return record, err
+		}
+	}
+	if err := checkRequiredFields(pb); err != nil {
+		return nil, err
+	}
+	var record []string
+	err := m.walkColumns(pb, func(name string, prop *proto.Properties, v reflect.Value) error {
+		if mapping := m.mapping(prop); mapping != nil {
+			cells, err := m.marshalMapped(v, prop, mapping)
+			if err != nil {
+				return fmt.Errorf("field %q: %v", prop.OrigName, err)
+			}
+			for i, cell := range cells {
+				if cells[i], err = m.encryptCell(mapping.columns[i], cell); err != nil {
+					return err
+				}
+			}
+			record = append(record, cells...)
+			return nil
+		}
+		cell, err := m.marshalValue(v, prop)
+		if err != nil {
+			return fmt.Errorf("column %q: %v", name, err)
+		}
+		if cell, err = m.encryptCell(name, cell); err != nil {
+			return err
+		}
+		record = append(record, cell)
+		return nil
+	})
+	return record, err
+}
+
+// MarshalNext writes pb as the next record of enc. Should enc have no
+// records yet, the header is written first, unless OmitHeader is set.
+// Should enc append to existing data, the header is checked against it
+// instead.
+func (m *Marshaler) MarshalNext(enc *Encoder, pb proto.Message) error {
+	record, err := m.MarshalRecord(pb)
+	if err != nil {
+		return err
+	}
+	if enc.records == 0 && !m.OmitHeader {
+		header, err := m.Header(pb)
+		if err != nil {
+			return err
+		}
+		if err := m.encodeHeader(enc, header); err != nil {
+			return err
+		}
+	} else if enc.existing != nil {
+		header, err := m.Header(pb)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(header, enc.existing) {
+			return &HeaderMismatchError{Existing: enc.existing, Header: header}
+		}
+		enc.existing = nil
+	}
+	return enc.Encode(record)
+}
+
+// encodeHeader writes header to enc, followed by SecondaryHeader.
+func (m *Marshaler) encodeHeader(enc *Encoder, header []string) error {
+	if err := enc.Encode(header); err != nil {
+		return err
+	}
+	if len(m.SecondaryHeader) == 0 {
+		return nil
+	}
+	record := make([]string, len(header))
+	for i, column := range header {
+		record[i] = m.SecondaryHeader[column]
+	}
+	return enc.Encode(record)
+}
+
+// newEncoder creates an Encoder writing to w through NewRecordWriter.
+func (m *Marshaler) newEncoder(w io.Writer) *Encoder {
+	if m.NewRecordWriter == nil {
+		return NewEncoder(w)
+	}
+	return NewRecordEncoder(m.NewRecordWriter(w))
+}
+
+// Marshal marshals a protocol buffer into CSV, consisting of the header
+// and a single record.
+func (m *Marshaler) Marshal(w io.Writer, pb proto.Message) error {
+	enc := m.newEncoder(w)
+	if err := m.MarshalNext(enc, pb); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
+
+// MarshalEach marshals the messages returned by next into CSV, consisting
+// of the header and a record per message, until next returns io.EOF. Any
+// other error of next is returned, after flushing the records before.
+func (m *Marshaler) MarshalEach(w io.Writer, next func() (proto.Message, error)) error {
+	enc := m.newEncoder(w)
+	for {
+		pb, err := next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if ferr := enc.Flush(); ferr != nil {
+				return ferr
+			}
+			return err
+		}
+		if err := m.MarshalNext(enc, pb); err != nil {
+			return err
+		}
+	}
+	return enc.Flush()
+}
+
+// MarshalToString converts a protocol buffer object to CSV string.
+func (m *Marshaler) MarshalToString(pb proto.Message) (string, error) {
+	var buf bytes.Buffer
+	if err := m.Marshal(&buf, pb); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// walkColumns calls fn for every column of pb. v is the zero Value for
+// members of a oneof that are not set.
+func (m *Marshaler) walkColumns(pb proto.Message, fn func(name string, prop *proto.Properties, v reflect.Value) error) error {
+	s := reflect.ValueOf(pb)
+	if s.Kind() != reflect.Ptr || s.IsNil() {
+		return errors.New("Marshal called with nil")
+	}
+	s = s.Elem()
+	if s.Kind() != reflect.Struct {
+		return fmt.Errorf("Marshal called with non-struct %v", s.Type())
+	}
+	if err := scanFieldOptions(s.Type()); err != nil {
+		return err
+	}
+
+	type column struct {
+		prop *proto.Properties
+		v    reflect.Value
+	}
+	var columns []column
+
+	sprops := proto.GetProperties(s.Type())
+	for i := 0; i < s.NumField(); i++ {
+		ft := s.Type().Field(i)
+		if strings.HasPrefix(ft.Name, "XXX_") {
+			continue
+		}
+
+		if ft.Tag.Get("protobuf_oneof") == "" {
+			columns = append(columns, column{sprops.Prop[i], s.Field(i)})
+			continue
+		}
+
+		// Every member of the oneof is a column, in field number order.
+		var oneofs []*proto.OneofProperties
+		for _, oop := range sprops.OneofTypes {
+			if oop.Field == i {
+				oneofs = append(oneofs, oop)
+			}
+		}
+		sort.Slice(oneofs, func(i, j int) bool {
+			return oneofs[i].Prop.Tag < oneofs[j].Prop.Tag
+		})
+		for _, oop := range oneofs {
+			columns = append(columns, column{oop.Prop, oneofValue(s.Field(i), oop.Type)})
+		}
+	}
+
+	if m.Deterministic {
+		sort.SliceStable(columns, func(i, j int) bool {
+			return columns[i].prop.Tag < columns[j].prop.Tag
+		})
+	}
+	for _, c := range columns {
+		if err := fn(m.columnName(c.prop), c.prop, c.v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Marshaler) columnName(prop *proto.Properties) string {
+	if o := m.fieldOptions(prop); o != nil && o.Column != "" {
+		return o.Column
+	}
+	if m.columnStyle() == HeaderOrigName {
+		return prop.OrigName
+	}
+	return acceptedJSONFieldNames(prop).camel
+}
+
+// columnStyle returns whether m names columns by the orig or camel name of
+// fields.
+func (m *Marshaler) columnStyle() HeaderStyle {
+	if m.HeaderStyle != HeaderAuto {
+		return m.HeaderStyle
+	}
+	if m.OrigName {
+		return HeaderOrigName
+	}
+	return HeaderCamelCase
+}
+
+// marshalValue converts a field value into a cell.
+// prop may be nil.
+func (m *Marshaler) marshalValue(v reflect.Value, prop *proto.Properties) (string, error) {
+	if !v.IsValid() {
+		return m.nullCell(prop), nil
+	}
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return m.nullCell(prop), nil
+		}
+
+		// Handle well-known types.
+		if w, ok := v.Interface().(wkt); ok {
+			s := v.Elem()
+			switch w.XXX_WellKnownType() {
+			case "DoubleValue", "FloatValue", "Int64Value", "UInt64Value",
+				"Int32Value", "UInt32Value", "BoolValue", "StringValue", "BytesValue":
+				return m.marshalValue(s.Field(0), prop)
+			case "Duration":
+				return formatDuration(s.Field(0).Int(), s.Field(1).Int())
+			case "Timestamp":
+				if err := checkTimestamp(prop, s.Field(0).Int()); err != nil {
+					return "", err
+				}
+				if layout := timestampLayout(m.fieldOptions(prop)); layout != "" {
+					return time.Unix(s.Field(0).Int(), s.Field(1).Int()).UTC().Format(layout), nil
+				}
+				return m.formatTimestamp(s.Field(0).Int(), s.Field(1).Int())
+			case "Empty":
+				return emptyCell, nil
+			case "Value":
+				return m.marshalStructValue(v.Interface().(*stpb.Value), prop)
+			case "ListValue":
+				return m.marshalListValue(v.Interface().(*stpb.ListValue), prop)
+			default:
+				return "", fmt.Errorf("%s not supported", w.XXX_WellKnownType())
+			}
+		}
+
+		if v.Elem().Kind() == reflect.Struct {
+			return "", errors.New("Nested messages not supported yet")
+		}
+		return m.marshalValue(v.Elem(), prop)
+	}
+
+	if cf := m.columnFormat(prop); cf != nil && isScalar(v) {
+		return cf.format(v.Interface())
+	}
+
+	switch v.Kind() {
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			if compressed(m.fieldOptions(prop)) {
+				return compressCell(v.Bytes())
+			}
+			return base64.StdEncoding.EncodeToString(v.Bytes()), nil
+		}
+		return m.marshalList(v, prop)
+	case reflect.Map:
+		if v.Len() == 0 {
+			return m.nullCell(prop), nil
+		}
+		return "", errors.New("Maps not supported yet")
+	case reflect.Bool:
+		return m.formatBool(v.Bool()), nil
+	case reflect.Int32, reflect.Int64:
+		if prop != nil && prop.Enum == nullValueEnum {
+			return m.nullCell(prop), nil
+		}
+		if prop != nil && prop.Enum != "" && !m.EnumsAsInts {
+			if s, ok := v.Interface().(fmt.Stringer); ok {
+				return s.String(), nil
+			}
+		}
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32:
+		return m.formatFloat(v.Float(), 32, prop), nil
+	case reflect.Float64:
+		return m.formatFloat(v.Float(), 64, prop), nil
+	case reflect.String:
+		if compressed(m.fieldOptions(prop)) {
+			return compressCell([]byte(v.String()))
+		}
+		return m.formatString(v.String())
+	}
+	return "", fmt.Errorf("%v not supported", v.Type())
+}
+
+// marshalList converts the elements of a slice into a nested CSV record.
+func (m *Marshaler) marshalList(v reflect.Value, prop *proto.Properties) (string, error) {
+	cells := make([]string, v.Len())
+	for i := range cells {
+		cell, err := m.marshalValue(v.Index(i), prop)
+		if err != nil {
+			return "", err
+		}
+		cells[i] = cell
+	}
+	return joinList(cells, listDelimiter(m.fieldOptions(prop)))
+}
+
+func (m *Marshaler) marshalStructValue(v *stpb.Value, prop *proto.Properties) (string, error) {
+	switch k := v.Kind.(type) {
+	case nil, *stpb.Value_NullValue:
+		return "", nil
+	case *stpb.Value_NumberValue:
+		return m.formatFloat(k.NumberValue, 64, prop), nil
+	case *stpb.Value_StringValue:
+		return m.formatString(k.StringValue)
+	case *stpb.Value_BoolValue:
+		return m.formatBool(k.BoolValue), nil
+	case *stpb.Value_ListValue:
+		return m.marshalValue(reflect.ValueOf(k.ListValue), nil)
+	}
+	return "", fmt.Errorf("%T not supported", v.Kind)
+}
+
+func formatDuration(s, ns int64) (string, error) {
+	if err := checkDuration(s, ns); err != nil {
+		return "", err
+	}
+	// Generated output always contains 0, 3, 6, or 9 fractional digits,
+	// depending on required precision.
+	f := "%d.%09d"
+	if ns < 0 {
+		ns = -ns
+		if s == 0 {
+			f = "-%d.%09d"
+		}
+	}
+	x := fmt.Sprintf(f, s, ns)
+	x = strings.TrimSuffix(x, "000")
+	x = strings.TrimSuffix(x, "000")
+	x = strings.TrimSuffix(x, ".000")
+	return x + "s", nil
+}
+
+func formatTimestamp(s, ns int64) (string, error) {
+	if ns < 0 || ns >= secondInNanos {
+		return "", fmt.Errorf("invalid timestamp nanos %d", ns)
+	}
+	t := time.Unix(s, ns).UTC()
+	// time.RFC3339Nano isn't exactly right (we need to get 3/6/9 fractional digits).
+	x := t.Format("2006-01-02T15:04:05.000000000")
+	x = strings.TrimSuffix(x, "000")
+	x = strings.TrimSuffix(x, "000")
+	x = strings.TrimSuffix(x, ".000")
+	return x + "Z", nil
+}
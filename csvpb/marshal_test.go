@@ -0,0 +1,318 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	proto3pb "github.com/golang/protobuf/proto/proto3_proto"
+	durpb "github.com/golang/protobuf/ptypes/duration"
+	stpb "github.com/golang/protobuf/ptypes/struct"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+	wpb "github.com/golang/protobuf/ptypes/wrappers"
+)
+
+var marshalingTests = []struct {
+	desc      string
+	marshaler Marshaler
+	pb        proto.Message
+	csv       string
+}{
+	{"enum", Marshaler{}, enumObject, "color,rColor,simple,rSimple,repeats,rRepeats\nGREEN,\"RED,GREEN,BLUE\",null,,null,\n"},
+	{"enum as int", Marshaler{EnumsAsInts: true}, enumObject, "color,rColor,simple,rSimple,repeats,rRepeats\n1,\"0,1,2\",null,,null,\n"},
+	{"orig name", Marshaler{OrigName: true}, enumObject, "color,r_color,simple,r_simple,repeats,r_repeats\nGREEN,\"RED,GREEN,BLUE\",null,,null,\n"},
+	{"orig name style", Marshaler{HeaderStyle: HeaderOrigName}, enumObject, "color,r_color,simple,r_simple,repeats,r_repeats\nGREEN,\"RED,GREEN,BLUE\",null,,null,\n"},
+	{"camel case style", Marshaler{OrigName: true, HeaderStyle: HeaderCamelCase}, enumObject, "color,rColor,simple,rSimple,repeats,rRepeats\nGREEN,\"RED,GREEN,BLUE\",null,,null,\n"},
+	{"repeated strings", Marshaler{}, &pb.Repeats{RString: []string{"a,b", "c"}}, "rBool,rInt32,rInt64,rUint32,rUint64,rSint32,rSint64,rFloat,rDouble,rString,rBytes\n" +
+		",,,,,,,,,\"\"\"a,b\"\",c\",\n"},
+	{"column formats", Marshaler{ColumnFormats: map[string]*ColumnFormat{
+		"rInt64":  {Verb: "%04d"},
+		"rDouble": {Func: func(v interface{}) (string, error) { return fmt.Sprintf("$%.2f", v), nil }},
+	}}, &pb.Repeats{RInt64: []int64{7, 12345}, RDouble: []float64{3.14159}}, "rBool,rInt32,rInt64,rUint32,rUint64,rSint32,rSint64,rFloat,rDouble,rString,rBytes\n" +
+		",,\"0007,12345\",,,,,,$3.14,,\n"},
+	{"column format of enum", Marshaler{ColumnFormats: map[string]*ColumnFormat{"color": {Verb: "%d"}}}, enumObject,
+		"color,rColor,simple,rSimple,repeats,rRepeats\n1,\"RED,GREEN,BLUE\",null,,null,\n"},
+	{"oneof", Marshaler{}, &pb.MsgWithOneof{Union: &pb.MsgWithOneof_Country{Country: "Australia"}},
+		"title,salary,Country,homeAddress,msgWithRequired\nnull,null,Australia,null,null\n"},
+}
+
+func TestMarshaling(t *testing.T) {
+	for _, tt := range marshalingTests {
+		str, err := tt.marshaler.MarshalToString(tt.pb)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if str != tt.csv {
+			t.Errorf("%s: got [%v] want [%v]", tt.desc, str, tt.csv)
+		}
+	}
+}
+
+var marshalingColumnTests = []struct {
+	desc   string
+	pb     proto.Message
+	column string
+	cell   string
+}{
+	{"Duration", &pb.KnownTypes{Dur: &durpb.Duration{Seconds: 3}}, "dur", "3s"},
+	{"negative Duration", &pb.KnownTypes{Dur: &durpb.Duration{Nanos: -5e8}}, "dur", "-0.500s"},
+	{"Timestamp", &pb.KnownTypes{Ts: &tspb.Timestamp{Seconds: 14e8, Nanos: 21e6}}, "ts", "2014-05-13T16:53:20.021Z"},
+	{"null Timestamp", &pb.KnownTypes{}, "ts", "null"},
+	{"number Value", &pb.KnownTypes{Val: &stpb.Value{Kind: &stpb.Value_NumberValue{NumberValue: 1}}}, "val", "1"},
+	{"null Value", &pb.KnownTypes{Val: &stpb.Value{Kind: &stpb.Value_NullValue{}}}, "val", ""},
+	{"ListValue", &pb.KnownTypes{Lv: &stpb.ListValue{Values: []*stpb.Value{
+		{Kind: &stpb.Value_StringValue{StringValue: "x"}},
+		{Kind: &stpb.Value_BoolValue{BoolValue: true}},
+	}}}, "lv", "x,true"},
+	{"Int64Value", &pb.KnownTypes{I64: &wpb.Int64Value{Value: -3}}, "i64", "-3"},
+	{"BytesValue", &pb.KnownTypes{Bytes: &wpb.BytesValue{Value: []byte("wow")}}, "bytes", "d293"},
+	{"NaN", &pb.Simple{ODouble: proto.Float64(math.NaN())}, "oDouble", "NaN"},
+	{"-Inf", &pb.Simple{OFloat: proto.Float32(float32(math.Inf(-1)))}, "oFloat", "-Infinity"},
+	{"proto3 enum zero value", &proto3pb.Message{}, "hilarity", "UNKNOWN"},
+	{"proto3 empty map", &proto3pb.Message{}, "terrain", "null"},
+}
+
+func TestMarshalingColumns(t *testing.T) {
+	var m Marshaler
+	for _, tt := range marshalingColumnTests {
+		header, err := m.Header(tt.pb)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		record, err := m.MarshalRecord(tt.pb)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if len(header) != len(record) {
+			t.Errorf("%s: header %v does not match record %v", tt.desc, header, record)
+			continue
+		}
+		for i, name := range header {
+			if name == tt.column && record[i] != tt.cell {
+				t.Errorf("%s: got %q want %q", tt.desc, record[i], tt.cell)
+			}
+		}
+	}
+}
+
+var roundTripTests = []struct {
+	desc string
+	pb   proto.Message
+}{
+	{"simple flat object", simpleObject},
+	{"repeated fields flat object", repeatsObject},
+	{"nested enum flat object", enumObject},
+	{"oneof", &pb.MsgWithOneof{Union: &pb.MsgWithOneof_Salary{Salary: 31000}}},
+	{"known types", &pb.KnownTypes{
+		Dur: &durpb.Duration{Seconds: -3, Nanos: -5e8},
+		Ts:  &tspb.Timestamp{Seconds: 14e8, Nanos: 21e6},
+		Lv:  &stpb.ListValue{Values: []*stpb.Value{{Kind: &stpb.Value_StringValue{StringValue: "x"}}}},
+		Val: &stpb.Value{Kind: &stpb.Value_BoolValue{BoolValue: true}},
+		Dbl: &wpb.DoubleValue{Value: 1.2},
+		U32: &wpb.UInt32Value{Value: 4},
+		Str: &wpb.StringValue{Value: "plush"},
+	}},
+	{"proto3", &proto3pb.Message{Name: "x", Hilarity: proto3pb.Message_PUNS, RFunny: []proto3pb.Message_Humour{proto3pb.Message_SLAPSTICK}}},
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	for _, tt := range roundTripTests {
+		var m Marshaler
+		header, err := m.Header(tt.pb)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		record, err := m.MarshalRecord(tt.pb)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+
+		var buf strings.Builder
+		enc := NewEncoder(&buf)
+		if err := enc.Encode(record); err != nil {
+			t.Fatal(err)
+		}
+		if err := enc.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		u := Unmarshaler{Header: header}
+		p := reflect.New(reflect.TypeOf(tt.pb).Elem()).Interface().(proto.Message)
+		if err := u.UnmarshalString(buf.String(), p); err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+
+		exp := proto.MarshalTextString(tt.pb)
+		act := proto.MarshalTextString(p)
+		if exp != act {
+			t.Errorf("%s: got [%s] want [%s]", tt.desc, act, exp)
+		}
+	}
+}
+
+func TestMarshalingBadInput(t *testing.T) {
+	var m Marshaler
+	if _, err := m.MarshalToString(&pb.MsgWithRequired{}); err == nil {
+		t.Error("an error was expected for an unset required field")
+	}
+	if _, err := m.MarshalToString(&pb.Widget{RSimple: []*pb.Simple{innerSimple}}); err == nil {
+		t.Error("an error was expected for nested messages")
+	}
+}
+
+func TestMarshalDeterministic(t *testing.T) {
+	m := Marshaler{Deterministic: true}
+	header, err := m.Header(&pb.KnownTypes{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"dur", "ts", "dbl", "flt", "i64", "u64", "i32", "u32", "bool", "str", "bytes", "st", "an", "lv", "val"}
+	if !reflect.DeepEqual(header, want) {
+		t.Errorf("got header %q, expected %q", header, want)
+	}
+
+	m.FloatFormat = Fixed
+	var outputs []string
+	for _, f := range []float64{0, math.Copysign(0, -1)} {
+		str, err := m.MarshalToString(&pb.Simple{ODouble: proto.Float64(f), OFloat: proto.Float32(6e23)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		outputs = append(outputs, str)
+	}
+	if !strings.Contains(outputs[0], ",6e+23,null,0,") {
+		t.Errorf("got [%s], expected shortest floats", outputs[0])
+	}
+	if outputs[0] != outputs[1] {
+		t.Errorf("got [%s] for negative zero, expected [%s]", outputs[1], outputs[0])
+	}
+}
+
+func TestMarshalEach(t *testing.T) {
+	pbs := []proto.Message{&pb.Simple3{Dub: 1}, &pb.Simple3{Dub: 2.5}}
+	next := func(fail error) func() (proto.Message, error) {
+		i := 0
+		return func() (proto.Message, error) {
+			if i == len(pbs) {
+				return nil, fail
+			}
+			i++
+			return pbs[i-1], nil
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := new(Marshaler).MarshalEach(&buf, next(io.EOF)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "dub\n1\n2.5\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	errNext := errors.New("cursor closed")
+	if err := new(Marshaler).MarshalEach(&buf, next(errNext)); err != errNext {
+		t.Errorf("got %v, want %v", err, errNext)
+	}
+	if want := "dub\n1\n2.5\n"; buf.String() != want {
+		t.Errorf("records before the error: got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestMarshalSecondaryHeader(t *testing.T) {
+	m := &Marshaler{SecondaryHeader: map[string]string{"oString": "text"}}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, p := range []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("foo")},
+		&pb.Simple{OInt32: proto.Int32(2)},
+	} {
+		if err := m.MarshalNext(enc, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(buf.String(), "\n")
+	if len(lines) != 5 || !strings.HasPrefix(lines[1], ",,") || !strings.Contains(lines[1], ",text,") {
+		t.Errorf("got %q", buf.String())
+	}
+
+	u := &Unmarshaler{SkipRows: 1}
+	pbs, _, err := u.UnmarshalAll(&buf, newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pbs) != 2 || pbs[0].(*pb.Simple).GetOString() != "foo" {
+		t.Errorf("got %v", pbs)
+	}
+}
+
+func TestMarshalOmitHeader(t *testing.T) {
+	m := &Marshaler{OmitHeader: true}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, p := range []proto.Message{&pb.Simple3{Dub: 1}, &pb.Simple3{Dub: 2.5}} {
+		if err := m.MarshalNext(enc, p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "1\n2.5\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	if err := m.MarshalMulti(&buf, "type", []proto.Message{&pb.Simple3{Dub: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "jsonpb.Simple3,1\n"; buf.String() != want {
+		t.Errorf("multi: got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,248 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// CSV holding messages of several types names the type of every record in
+// a discriminator column, by the full name of its message, like
+// "jsonpb.Simple". The header is the union of the columns of all types,
+// with the columns of other types left null in every record.
+
+// MultiHeader returns the header of records of the types of pbs, starting
+// with typeColumn. Columns shared by several types occur once.
+func (m *Marshaler) MultiHeader(typeColumn string, pbs ...proto.Message) ([]string, error) {
+	header := []string{typeColumn}
+	seen := map[string]bool{typeColumn: true}
+	types := make(map[reflect.Type]bool)
+	for _, pb := range pbs {
+		if t := reflect.TypeOf(pb); !types[t] {
+			types[t] = true
+		} else {
+			continue
+		}
+		columns, err := m.Header(pb)
+		if err != nil {
+			return nil, err
+		}
+		for _, column := range columns {
+			if !seen[column] {
+				seen[column] = true
+				header = append(header, column)
+			}
+		}
+	}
+	return header, nil
+}
+
+// MarshalMultiRecord converts pb into a record matching header, as
+// returned by MultiHeader, with the name of its type in typeColumn.
+func (m *Marshaler) MarshalMultiRecord(header []string, typeColumn string, pb proto.Message) ([]string, error) {
+	name := proto.MessageName(pb)
+	if name == "" {
+		return nil, fmt.Errorf("%T not registered", pb)
+	}
+	columns, err := m.Header(pb)
+	if err != nil {
+		return nil, err
+	}
+	cells, err := m.MarshalRecord(pb)
+	if err != nil {
+		return nil, err
+	}
+	byColumn := make(map[string]string, len(columns))
+	for i, column := range columns {
+		byColumn[column] = cells[i]
+	}
+
+	record := make([]string, len(header))
+	for i, column := range header {
+		if column == typeColumn {
+			record[i] = name
+		} else if cell, ok := byColumn[column]; ok {
+			record[i] = cell
+			delete(byColumn, column)
+		} else {
+			record[i] = m.null()
+		}
+	}
+	if len(byColumn) > 0 {
+		// Pick any column to be the scapegoat.
+		var column string
+		for column = range byColumn {
+			break
+		}
+		return nil, fmt.Errorf("column %q of %s not in header", column, name)
+	}
+	return record, nil
+}
+
+// MarshalMulti marshals pbs, which may be of different types, into CSV
+// with the name of their type in typeColumn.
+func (m *Marshaler) MarshalMulti(w io.Writer, typeColumn string, pbs []proto.Message) error {
+	header, err := m.MultiHeader(typeColumn, pbs...)
+	if err != nil {
+		return err
+	}
+	enc := m.newEncoder(w)
+	if !m.OmitHeader {
+		if err := m.encodeHeader(enc, header); err != nil {
+			return err
+		}
+	}
+	for _, pb := range pbs {
+		record, err := m.MarshalMultiRecord(header, typeColumn, pb)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+	return enc.Flush()
+}
+
+// UnmarshalEachMulti unmarshals every record of a CSV into a message of
+// the type named in typeColumn and passes it to fn, like UnmarshalEach.
+// Types are looked up in the protobuf registry. Columns not naming a field
+// of the type of a record are ignored for it, as they belong to other
+// types. Records of unknown types fail with CategoryConversion.
+// The returned Summary is never nil, even if an error occurs.
+func (u *Unmarshaler) UnmarshalEachMulti(r io.Reader, typeColumn string, fn func(proto.Message) error) (*Summary, error) {
+	dec := u.newDecoder(r)
+	defer dec.Release()
+	s := &Summary{
+		Errors: make(map[ErrorCategory]int),
+	}
+	err := u.eachDecoded(dec, s, multiDecoder(typeColumn), fn)
+	s.BytesConsumed = dec.InputOffset()
+	return s, err
+}
+
+// multiType unmarshals the records of a single type.
+type multiType struct {
+	t reflect.Type
+	u Unmarshaler
+	// columns are the indices of the columns of the type.
+	columns []int
+}
+
+// multiDecoder dispatches records on the type named in typeColumn.
+func multiDecoder(typeColumn string) recordDecoder {
+	typeIndex := -1
+	types := make(map[string]*multiType)
+	return func(uc *Unmarshaler, record []string) (proto.Message, ErrorCategory, error) {
+		if typeIndex < 0 {
+			for i, column := range uc.Header {
+				if column == typeColumn {
+					typeIndex = i
+				}
+			}
+			if typeIndex < 0 {
+				return nil, CategoryParse, fmt.Errorf("missing type column %q", typeColumn)
+			}
+		}
+		if len(record) != len(uc.Header) {
+			return nil, CategoryParse, csv.ErrFieldCount
+		}
+
+		name := record[typeIndex]
+		mt, ok := types[name]
+		if !ok {
+			t := proto.MessageType(name)
+			if t == nil || t.Kind() != reflect.Ptr {
+				return nil, CategoryConversion, fmt.Errorf("unknown message type %q", name)
+			}
+			mt = uc.multiType(t.Elem(), typeIndex)
+			types[name] = mt
+		}
+
+		cells := make([]string, len(mt.columns))
+		for i, c := range mt.columns {
+			cells[i] = record[c]
+		}
+		pb := reflect.New(mt.t).Interface().(proto.Message)
+		category, err := mt.u.unmarshalDecoded(cells, pb)
+		return pb, category, err
+	}
+}
+
+// multiType returns how records of the message type t are unmarshaled.
+func (u *Unmarshaler) multiType(t reflect.Type, typeIndex int) *multiType {
+	names := make(map[string]bool)
+	var prefixed []*columnMapping
+	// Errors are reported by unmarshalRecord
+	scanFieldOptions(t)
+	sprops := proto.GetProperties(t)
+	for _, prop := range sprops.Prop {
+		n := u.columnNames(prop)
+		names[n.orig], names[n.camel] = true, true
+		if mapping := u.mapping(prop); mapping != nil {
+			for _, c := range mapping.columns {
+				names[c] = true
+			}
+			if mapping.dynamic() {
+				prefixed = append(prefixed, mapping)
+			}
+		}
+	}
+	for _, oop := range sprops.OneofTypes {
+		n := u.columnNames(oop.Prop)
+		names[n.orig], names[n.camel] = true, true
+	}
+	for column, re := range u.splits {
+		if splitsInto(re, names) {
+			names[column] = true
+		}
+	}
+
+	mt := &multiType{t: t, u: *u}
+	mt.u.Header = nil
+	for i, column := range u.Header {
+		if i != typeIndex && (names[column] || coveredBy(prefixed, column)) {
+			mt.columns = append(mt.columns, i)
+			mt.u.Header = append(mt.u.Header, column)
+		}
+	}
+	mt.u.projection = nil
+	if mt.u.Columns != nil {
+		mt.u.projection = mt.u.projectColumns(t)
+	}
+	return mt
+}
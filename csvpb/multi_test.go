@@ -0,0 +1,107 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestMarshalMulti(t *testing.T) {
+	pbs := []proto.Message{
+		&pb.Simple3{Dub: 1.5},
+		&pb.MsgWithOneof{Union: &pb.MsgWithOneof_Title{Title: "x"}},
+		&pb.Simple3{Dub: 2},
+	}
+	var buf strings.Builder
+	if err := new(Marshaler).MarshalMulti(&buf, "type", pbs); err != nil {
+		t.Fatal(err)
+	}
+	exp := "type,dub,title,salary,Country,homeAddress,msgWithRequired\n" +
+		"jsonpb.Simple3,1.5,null,null,null,null,null\n" +
+		"jsonpb.MsgWithOneof,null,x,null,null,null,null\n" +
+		"jsonpb.Simple3,2,null,null,null,null,null\n"
+	if buf.String() != exp {
+		t.Fatalf("got %q, expected %q", buf.String(), exp)
+	}
+
+	var got []proto.Message
+	s, err := new(Unmarshaler).UnmarshalEachMulti(strings.NewReader(buf.String()), "type", func(m proto.Message) error {
+		got = append(got, m)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(pbs) || s.RowsDecoded != len(pbs) {
+		t.Fatalf("got %v, %+v", got, s)
+	}
+	for i := range pbs {
+		if !proto.Equal(got[i], pbs[i]) {
+			t.Errorf("%d: got %v, expected %v", i, got[i], pbs[i])
+		}
+	}
+
+	header := []string{"type", "dub"}
+	if _, err := new(Marshaler).MarshalMultiRecord(header, "type", &pb.MsgWithOneof{}); err == nil {
+		t.Error("expected an error for columns not in header")
+	}
+}
+
+func TestUnmarshalEachMultiErrors(t *testing.T) {
+	tests := []struct {
+		desc     string
+		input    string
+		category ErrorCategory
+	}{
+		{"unknown type", "type,dub\nfoo.Bar,1\n", CategoryConversion},
+		{"missing type column", "kind,dub\njsonpb.Simple3,1\n", CategoryParse},
+		{"bad cell", "type,dub\njsonpb.Simple3,x\n", CategoryConversion},
+	}
+	for _, tt := range tests {
+		_, err := new(Unmarshaler).UnmarshalEachMulti(strings.NewReader(tt.input), "type", func(proto.Message) error { return nil })
+		re, ok := err.(*RowError)
+		if !ok || re.Category != tt.category || re.Row != 2 {
+			t.Errorf("%s: got %v", tt.desc, err)
+		}
+	}
+
+	u := &Unmarshaler{SkipInvalidRows: true}
+	s, err := u.UnmarshalEachMulti(strings.NewReader("type,dub\nfoo.Bar,1\njsonpb.Simple3,2\n"), "type", func(proto.Message) error { return nil })
+	if err != nil || s.RowsSkipped != 1 || s.RowsDecoded != 1 {
+		t.Errorf("got %v, %+v", err, s)
+	}
+}
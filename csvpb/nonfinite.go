@@ -0,0 +1,118 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"math"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// NonFinite holds the cells standing for non-finite floats, like #DIV/0!
+// of spreadsheets. A Marshaler writes the first cell of each kind, an
+// Unmarshaler accepts any of them besides what ParseFloat accepts. Cells
+// are matched exactly.
+type NonFinite struct {
+	NaN    []string
+	Inf    []string
+	NegInf []string
+}
+
+func (t *NonFinite) parse(cell string) (float64, bool) {
+	for _, tokens := range []struct {
+		cells []string
+		f     float64
+	}{
+		{t.NaN, math.NaN()},
+		{t.Inf, math.Inf(1)},
+		{t.NegInf, math.Inf(-1)},
+	} {
+		for _, c := range tokens.cells {
+			if c == cell {
+				return tokens.f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+func (t *NonFinite) format(f float64) (string, bool) {
+	var cells []string
+	switch {
+	case math.IsNaN(f):
+		cells = t.NaN
+	case math.IsInf(f, 1):
+		cells = t.Inf
+	case math.IsInf(f, -1):
+		cells = t.NegInf
+	}
+	if len(cells) == 0 {
+		return "", false
+	}
+	return cells[0], true
+}
+
+func (u *Unmarshaler) parseFloat(cell string, bitSize int) (float64, error) {
+	if u.NonFinite != nil {
+		if f, ok := u.NonFinite.parse(cell); ok {
+			return f, nil
+		}
+	}
+	if number, ok := u.percentNumber(cell); ok {
+		f, err := u.coercer().ParseFloat(u.numberCell(number), bitSize)
+		if err != nil || u.Percent == PercentNumber {
+			return f, err
+		}
+		return f / 100, nil
+	}
+	return u.coercer().ParseFloat(u.numberCell(cell), bitSize)
+}
+
+// formatFloat formats f of the field with prop, which may be nil.
+func (m *Marshaler) formatFloat(f float64, bitSize int, prop *proto.Properties) string {
+	if m.NonFinite != nil {
+		if cell, ok := m.NonFinite.format(f); ok {
+			return cell
+		}
+	}
+	if m.Deterministic {
+		if f == 0 {
+			// Turns negative zero into positive zero
+			f = 0
+		}
+		return FormatFloat(f, bitSize)
+	}
+	if ff := m.floatFormat(prop); ff != nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+		return ff.format(f, bitSize)
+	}
+	return FormatFloat(f, bitSize)
+}
@@ -0,0 +1,104 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+var spreadsheet = &NonFinite{
+	NaN:    []string{"#NUM!", "#DIV/0!", "nan"},
+	Inf:    []string{"inf"},
+	NegInf: []string{"-inf"},
+}
+
+func TestParseNonFinite(t *testing.T) {
+	tests := []struct {
+		nonFinite *NonFinite
+		cell      string
+		want      float64
+		ok        bool
+	}{
+		{nil, "NaN", math.NaN(), true},
+		{nil, `"NaN"`, math.NaN(), true},
+		{nil, "Infinity", math.Inf(1), true},
+		{nil, `"-Infinity"`, math.Inf(-1), true},
+		{nil, "#DIV/0!", 0, false},
+		{spreadsheet, "#NUM!", math.NaN(), true},
+		{spreadsheet, "#DIV/0!", math.NaN(), true},
+		{spreadsheet, "inf", math.Inf(1), true},
+		{spreadsheet, "-inf", math.Inf(-1), true},
+		{spreadsheet, "Infinity", math.Inf(1), true},
+		{spreadsheet, "#num!", 0, false},
+		{spreadsheet, "1.5", 1.5, true},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{Header: []string{"oDouble"}, NonFinite: tt.nonFinite}
+		got := new(pb.Simple)
+		err := u.UnmarshalRecord([]string{tt.cell}, got)
+		if (err == nil) != tt.ok {
+			t.Errorf("%q: got error %v", tt.cell, err)
+			continue
+		}
+		if !tt.ok {
+			continue
+		}
+		if f := got.GetODouble(); f != tt.want && !(math.IsNaN(f) && math.IsNaN(tt.want)) {
+			t.Errorf("%q: got %v, expected %v", tt.cell, f, tt.want)
+		}
+	}
+}
+
+func TestFormatNonFinite(t *testing.T) {
+	tests := []struct {
+		nonFinite *NonFinite
+		want      []string
+	}{
+		{nil, []string{"NaN", "Infinity", "-Infinity", "1.5"}},
+		{spreadsheet, []string{"#NUM!", "inf", "-inf", "1.5"}},
+		{&NonFinite{NaN: []string{""}}, []string{"", "Infinity", "-Infinity", "1.5"}},
+	}
+	for _, tt := range tests {
+		m := &Marshaler{NonFinite: tt.nonFinite}
+		var got []string
+		for _, f := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1.5} {
+			got = append(got, m.formatFloat(f, 64, nil))
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %q, expected %q", tt.nonFinite, got, tt.want)
+		}
+	}
+}
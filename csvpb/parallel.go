@@ -0,0 +1,183 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/abergmeier/golang-protobuf/splitio"
+	"github.com/golang/protobuf/proto"
+)
+
+// shardResult is the outcome of unmarshaling a shard of a file.
+type shardResult struct {
+	pbs []proto.Message
+	s   *Summary
+	err error
+}
+
+// UnmarshalFileParallel unmarshals every record of the CSV file at path
+// into messages created by factory, like UnmarshalAll. The file is cut
+// into shards at record boundaries, which workers goroutines unmarshal
+// concurrently. Should Header be nil, the first record of the file is used
+// as header of every shard. workers defaults to GOMAXPROCS if less than 1.
+//
+// The messages are returned in the order of the file. Errors are those of
+// the first failing record in the file, with RowError.Row counted from the
+// start of the file. The returned Summary is never nil, even if an error
+// occurs.
+func (u *Unmarshaler) UnmarshalFileParallel(path string, workers int, factory func() proto.Message) ([]proto.Message, *Summary, error) {
+	s := &Summary{
+		Errors: make(map[ErrorCategory]int),
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, s, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, s, err
+	}
+	size := fi.Size()
+	if u.MaxInputSize > 0 && size > u.MaxInputSize {
+		return nil, s, &LimitError{What: "input size", Limit: u.MaxInputSize}
+	}
+
+	if workers < 1 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	// Several shards per worker even out shards of differing cost.
+	target := int(size / int64(4*workers))
+
+	var shards []io.Reader
+	var header int64
+	if u.Header == nil {
+		if header, err = headerLength(f, size); err != nil {
+			return nil, s, err
+		}
+		shards, err = splitio.ShardsAt(f, size, target)
+	} else {
+		var sections []*io.SectionReader
+		sections, err = splitio.SectionsAt(f, size, target, true)
+		for _, section := range sections {
+			shards = append(shards, section)
+		}
+	}
+	if err != nil {
+		return nil, s, err
+	}
+
+	budget := &memoryBudget{limit: u.MaxMemory}
+	// Progress of shards is meaningless for the file
+	shardU := *u
+	shardU.OnProgress = nil
+	results := make([]shardResult, len(shards))
+	var mu sync.Mutex
+	// failed is the index of the first shard known to fail. Shards after it
+	// are not unmarshaled.
+	failed := len(shards)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				mu.Lock()
+				skip := i > failed
+				mu.Unlock()
+				if skip {
+					continue
+				}
+				su := shardU
+				if i > 0 {
+					// Only the first shard holds the skipped records
+					su.SkipRows = 0
+				}
+				pbs, s, err := su.unmarshalAll(shards[i], factory, budget)
+				results[i] = shardResult{pbs, s, err}
+				if err != nil {
+					mu.Lock()
+					if i < failed {
+						failed = i
+					}
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range shards {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	// Merge the shards in the order of the file.
+	var pbs []proto.Message
+	s.BytesConsumed = header
+	for i, res := range results {
+		rowsBefore := s.RowsRead
+		if i > 0 {
+			rowsBefore += u.SkipRows
+		}
+		s.RowsRead += res.s.RowsRead
+		s.RowsDecoded += res.s.RowsDecoded
+		s.RowsSkipped += res.s.RowsSkipped
+		s.RowsFiltered += res.s.RowsFiltered
+		// Every shard repeats the header.
+		s.BytesConsumed += res.s.BytesConsumed - header
+		for c, n := range res.s.Errors {
+			s.Errors[c] += n
+		}
+		pbs = append(pbs, res.pbs...)
+		if res.err != nil {
+			if re, ok := res.err.(*RowError); ok {
+				re.Row += rowsBefore
+			}
+			return pbs, s, res.err
+		}
+	}
+	return pbs, s, nil
+}
+
+// headerLength returns the number of bytes of the first record of the
+// size bytes of r.
+func headerLength(r io.ReaderAt, size int64) (int64, error) {
+	header, _ := splitio.NewReadersFunc(io.NewSectionReader(r, 0, size), splitio.ScanCSVRecords)
+	b, err := ioutil.ReadAll(header)
+	return int64(len(b)), err
+}
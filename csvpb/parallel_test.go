@@ -0,0 +1,208 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// writeTempCSV writes content to a temporary file, returning its path.
+func writeTempCSV(t *testing.T, content []byte) string {
+	f, err := ioutil.TempFile("", "csvpb_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(content); err != nil {
+		t.Fatal(err)
+	}
+	return f.Name()
+}
+
+// parallelInput returns a CSV of n records, some with cells spanning
+// lines. The record at bad, if any, fails to unmarshal.
+func parallelInput(n, bad int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("oInt32,oString\n")
+	for i := 1; i <= n; i++ {
+		switch {
+		case i == bad:
+			buf.WriteString("nan,bad\n")
+		case i%3 == 0:
+			fmt.Fprintf(&buf, "%d,\"line\n%d\"\n", i, i)
+		default:
+			fmt.Fprintf(&buf, "%d,row %d\n", i, i)
+		}
+	}
+	return buf.Bytes()
+}
+
+func TestUnmarshalFileParallel(t *testing.T) {
+	input := parallelInput(500, 0)
+	path := writeTempCSV(t, input)
+	defer os.Remove(path)
+
+	exp, expSummary, err := new(Unmarshaler).UnmarshalAll(bytes.NewReader(input), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, workers := range []int{0, 1, 3, 16} {
+		pbs, s, err := new(Unmarshaler).UnmarshalFileParallel(path, workers, newSimple)
+		if err != nil {
+			t.Fatalf("%d workers: %v", workers, err)
+		}
+		if len(pbs) != len(exp) {
+			t.Fatalf("%d workers: got %d messages, expected %d", workers, len(pbs), len(exp))
+		}
+		for i := range exp {
+			if !proto.Equal(pbs[i], exp[i]) {
+				t.Errorf("%d workers: message %d: got %v, expected %v", workers, i, pbs[i], exp[i])
+			}
+		}
+		if !reflect.DeepEqual(s, expSummary) {
+			t.Errorf("%d workers: got summary %+v, expected %+v", workers, s, expSummary)
+		}
+	}
+}
+
+func TestUnmarshalFileParallelErrors(t *testing.T) {
+	input := parallelInput(500, 345)
+	path := writeTempCSV(t, input)
+	defer os.Remove(path)
+
+	for _, skip := range []bool{false, true} {
+		u := &Unmarshaler{SkipInvalidRows: skip}
+		exp, expSummary, expErr := u.UnmarshalAll(bytes.NewReader(input), newSimple)
+		pbs, s, err := u.UnmarshalFileParallel(path, 4, newSimple)
+		if !reflect.DeepEqual(err, expErr) {
+			t.Errorf("skip %v: got error %v, expected %v", skip, err, expErr)
+		}
+		if len(pbs) != len(exp) {
+			t.Errorf("skip %v: got %d messages, expected %d", skip, len(pbs), len(exp))
+		}
+		if skip && !reflect.DeepEqual(s, expSummary) {
+			t.Errorf("skip %v: got summary %+v, expected %+v", skip, s, expSummary)
+		}
+	}
+}
+
+func TestUnmarshalFileParallelSkipRows(t *testing.T) {
+	input := parallelInput(500, 345)
+	input = append([]byte("oInt32,oString\ncount,text\n"), input[len("oInt32,oString\n"):]...)
+	path := writeTempCSV(t, input)
+	defer os.Remove(path)
+
+	u := &Unmarshaler{SkipRows: 1}
+	exp, _, expErr := u.UnmarshalAll(bytes.NewReader(input), newSimple)
+	if re, ok := expErr.(*RowError); !ok || re.Row != 347 {
+		t.Fatalf("got error %v, expected an error in row 347", expErr)
+	}
+	pbs, _, err := u.UnmarshalFileParallel(path, 4, newSimple)
+	if !reflect.DeepEqual(err, expErr) {
+		t.Errorf("got error %v, expected %v", err, expErr)
+	}
+	if len(pbs) != len(exp) {
+		t.Errorf("got %d messages, expected %d", len(pbs), len(exp))
+	}
+}
+
+func TestUnmarshalFileParallelHeader(t *testing.T) {
+	path := writeTempCSV(t, []byte("1,foo\n2,\"b\nar\"\n3,baz\nx,bad\n"))
+	defer os.Remove(path)
+
+	u := &Unmarshaler{Header: []string{"oInt32", "oString"}}
+	pbs, s, err := u.UnmarshalFileParallel(path, 2, newSimple)
+	exp := []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("foo")},
+		&pb.Simple{OInt32: proto.Int32(2), OString: proto.String("b\nar")},
+		&pb.Simple{OInt32: proto.Int32(3), OString: proto.String("baz")},
+	}
+	if re, ok := err.(*RowError); !ok || re.Row != 4 || re.Category != CategoryConversion {
+		t.Errorf("got error %v, expected a conversion error in row 4", err)
+	}
+	if !reflect.DeepEqual(pbs, exp) {
+		t.Errorf("got %v, expected %v", pbs, exp)
+	}
+	if s.RowsRead != 4 || s.BytesConsumed != 27 {
+		t.Errorf("got summary %+v", s)
+	}
+}
+
+func TestUnmarshalFileParallelEmpty(t *testing.T) {
+	for _, content := range []string{"", "oInt32,oString\n"} {
+		path := writeTempCSV(t, []byte(content))
+		defer os.Remove(path)
+		pbs, s, err := new(Unmarshaler).UnmarshalFileParallel(path, 2, newSimple)
+		if err != nil || len(pbs) != 0 || s.RowsRead != 0 {
+			t.Errorf("%q: got %v, %+v, %v", content, pbs, s, err)
+		}
+	}
+
+	if _, _, err := new(Unmarshaler).UnmarshalFileParallel("/nonexistent.csv", 2, newSimple); !os.IsNotExist(err) {
+		t.Errorf("got %v, expected a missing file", err)
+	}
+}
+
+func TestUnmarshalFileParallelMaxMemory(t *testing.T) {
+	input := parallelInput(500, 0)
+	path := writeTempCSV(t, input)
+	defer os.Remove(path)
+
+	all, _, err := new(Unmarshaler).UnmarshalAll(bytes.NewReader(input), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var size int64
+	for _, m := range all {
+		size += int64(proto.Size(m))
+	}
+
+	u := &Unmarshaler{MaxMemory: size}
+	if _, _, err := u.UnmarshalFileParallel(path, 4, newSimple); err != nil {
+		t.Errorf("at the limit: %v", err)
+	}
+	u.MaxMemory = size - 1
+	if _, _, err := u.UnmarshalFileParallel(path, 4, newSimple); err == nil {
+		t.Error("expected a MemoryLimitError")
+	} else if _, ok := err.(*MemoryLimitError); !ok {
+		t.Errorf("got %v, expected a MemoryLimitError", err)
+	}
+}
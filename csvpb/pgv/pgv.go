@@ -0,0 +1,89 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package pgv runs the rules of protoc-gen-validate on unmarshaled messages.
+
+protoc-gen-validate generates a Validate method, and in recent versions a
+ValidateAll method, for every message of a file with rules. The Validator of
+this package calls them by their signature, so that neither the generator
+nor its runtime are dependencies:
+
+	u := &csvpb.Unmarshaler{Validator: pgv.Validator{}}
+
+Violations fail their record with csvpb.CategoryValidation, attributed to
+its row by csvpb.RowError.
+*/
+package pgv
+
+import (
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// validator is the method generated for every message.
+type validator interface {
+	Validate() error
+}
+
+// allValidator is the method generated by recent versions, reporting every
+// violation instead of the first.
+type allValidator interface {
+	ValidateAll() error
+}
+
+// Validator is a csvpb.Validator running the generated rules of messages.
+type Validator struct {
+	// All reports every violation of a message, if it has ValidateAll,
+	// instead of the first.
+	All bool
+
+	// Required rejects messages without generated rules instead of
+	// accepting them.
+	Required bool
+}
+
+// Validate runs the generated rules of pb.
+func (v Validator) Validate(pb proto.Message) error {
+	if v.All {
+		if av, ok := pb.(allValidator); ok {
+			return av.ValidateAll()
+		}
+	}
+	if vv, ok := pb.(validator); ok {
+		return vv.Validate()
+	}
+	if v.Required {
+		return fmt.Errorf("pgv: %s has no generated rules", proto.MessageName(pb))
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package pgv
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+var _ csvpb.Validator = Validator{}
+
+var (
+	errFirst = errors.New("first violation")
+	errAll   = errors.New("all violations")
+)
+
+// rules mimics a message with rules generated by protoc-gen-validate.
+type rules struct {
+	pb.Simple
+}
+
+func (*rules) Validate() error { return errFirst }
+
+// allRules mimics a message generated by a version with ValidateAll.
+type allRules struct {
+	rules
+}
+
+func (*allRules) ValidateAll() error { return errAll }
+
+func TestValidator(t *testing.T) {
+	tests := []struct {
+		v    Validator
+		pb   proto.Message
+		want error
+	}{
+		{Validator{}, new(rules), errFirst},
+		{Validator{}, new(allRules), errFirst},
+		{Validator{All: true}, new(rules), errFirst},
+		{Validator{All: true}, new(allRules), errAll},
+		{Validator{}, new(pb.Simple), nil},
+	}
+	for i, tt := range tests {
+		if got := tt.v.Validate(tt.pb); got != tt.want {
+			t.Errorf("%d: got %v, expected %v", i, got, tt.want)
+		}
+	}
+	if err := (Validator{Required: true}).Validate(new(pb.Simple)); err == nil {
+		t.Error("Required accepted a message without rules")
+	}
+}
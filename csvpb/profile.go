@@ -0,0 +1,299 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"container/heap"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ColumnType is the type inferred for the values of a column.
+type ColumnType int
+
+const (
+	// TypeUnknown is a column holding only null cells.
+	TypeUnknown ColumnType = iota
+	TypeBool
+	TypeInt64
+	TypeDouble
+	TypeTimestamp
+	TypeString
+)
+
+func (t ColumnType) String() string {
+	switch t {
+	case TypeUnknown:
+		return "unknown"
+	case TypeBool:
+		return "bool"
+	case TypeInt64:
+		return "int64"
+	case TypeDouble:
+		return "double"
+	case TypeTimestamp:
+		return "google.protobuf.Timestamp"
+	case TypeString:
+		return "string"
+	}
+	return fmt.Sprintf("ColumnType(%d)", int(t))
+}
+
+// Bit masks of the types a cell is compatible with.
+const (
+	boolMask      = 1 << TypeBool
+	int64Mask     = 1 << TypeInt64
+	doubleMask    = 1 << TypeDouble
+	timestampMask = 1 << TypeTimestamp
+	stringMask    = 1 << TypeString
+	allMask       = boolMask | int64Mask | doubleMask | timestampMask | stringMask
+)
+
+// ColumnProfile holds statistics about the values of a single column.
+type ColumnProfile struct {
+	Name string
+	// Type is the narrowest type all non-null cells can be parsed as.
+	Type ColumnType
+	// Count is the number of cells in the column, excluding the header.
+	Count int
+	// Nulls is the number of empty or null cells.
+	Nulls int
+	// Distinct estimates the number of distinct non-null cells. It is
+	// exact for up to 1024 distinct cells.
+	Distinct int
+	// Min and Max are the smallest and largest non-null cells, compared
+	// according to Type.
+	Min, Max string
+
+	mask     int
+	sketch   kmvSketch
+	minCells [TypeString + 1]string
+	maxCells [TypeString + 1]string
+	minNum   float64
+	maxNum   float64
+	minTime  time.Time
+	maxTime  time.Time
+}
+
+// NullRate returns the fraction of null cells in the column.
+func (p *ColumnProfile) NullRate() float64 {
+	if p.Count == 0 {
+		return 0
+	}
+	return float64(p.Nulls) / float64(p.Count)
+}
+
+// Profile reads a CSV, whose first record is the header, and reports
+// statistics for each of its columns.
+func Profile(r io.Reader) ([]*ColumnProfile, error) {
+	dec := NewDecoder(r)
+	defer dec.Release()
+	if !dec.More() {
+		return nil, dec.Err()
+	}
+	header, err := dec.Decode()
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := make([]*ColumnProfile, len(header))
+	for i, name := range header {
+		profiles[i] = &ColumnProfile{
+			Name: name,
+			mask: allMask,
+		}
+	}
+
+	for dec.More() {
+		record, err := dec.Decode()
+		if err != nil {
+			return nil, err
+		}
+		for i, cell := range record {
+			profiles[i].add(cell)
+		}
+	}
+	if err := dec.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, p := range profiles {
+		p.finish()
+	}
+	return profiles, nil
+}
+
+func (p *ColumnProfile) add(cell string) {
+	p.Count++
+	if cell == "" || cell == nullToken {
+		p.Nulls++
+		return
+	}
+	p.sketch.add(cell)
+
+	mask := stringMask
+	if b := strings.ToLower(cell); b == "true" || b == "false" {
+		mask |= boolMask
+	}
+	if _, err := strconv.ParseInt(cell, 10, 64); err == nil {
+		mask |= int64Mask
+	}
+	if f, err := strconv.ParseFloat(cell, 64); err == nil {
+		mask |= doubleMask
+		if p.mask&doubleMask != 0 {
+			if p.minCells[TypeDouble] == "" || f < p.minNum {
+				p.minNum, p.minCells[TypeDouble] = f, cell
+			}
+			if p.maxCells[TypeDouble] == "" || f > p.maxNum {
+				p.maxNum, p.maxCells[TypeDouble] = f, cell
+			}
+		}
+	}
+	if t, err := time.Parse(time.RFC3339Nano, cell); err == nil {
+		mask |= timestampMask
+		if p.mask&timestampMask != 0 {
+			if p.minCells[TypeTimestamp] == "" || t.Before(p.minTime) {
+				p.minTime, p.minCells[TypeTimestamp] = t, cell
+			}
+			if p.maxCells[TypeTimestamp] == "" || t.After(p.maxTime) {
+				p.maxTime, p.maxCells[TypeTimestamp] = t, cell
+			}
+		}
+	}
+	if p.minCells[TypeString] == "" || cell < p.minCells[TypeString] {
+		p.minCells[TypeString] = cell
+	}
+	if cell > p.maxCells[TypeString] {
+		p.maxCells[TypeString] = cell
+	}
+	p.mask &= mask
+}
+
+func (p *ColumnProfile) finish() {
+	p.Distinct = p.sketch.estimate()
+	if p.Count == p.Nulls {
+		p.Type = TypeUnknown
+		return
+	}
+	for _, t := range []ColumnType{TypeBool, TypeInt64, TypeDouble, TypeTimestamp, TypeString} {
+		if p.mask&(1<<uint(t)) != 0 {
+			p.Type = t
+			break
+		}
+	}
+
+	switch p.Type {
+	case TypeBool:
+		// Only two values, so lexical order serves as well as any.
+		p.Min, p.Max = p.minCells[TypeString], p.maxCells[TypeString]
+	case TypeInt64, TypeDouble:
+		p.Min, p.Max = p.minCells[TypeDouble], p.maxCells[TypeDouble]
+	default:
+		p.Min, p.Max = p.minCells[p.Type], p.maxCells[p.Type]
+	}
+}
+
+// kmvSize is the number of hashes kept by kmvSketch.
+const kmvSize = 1024
+
+// kmvSketch estimates the number of distinct values by keeping the
+// smallest kmvSize hashes seen (K Minimum Values).
+type kmvSketch struct {
+	hashes uint64Heap
+	seen   map[uint64]bool
+}
+
+func (s *kmvSketch) add(v string) {
+	h := fnv.New64a()
+	h.Write([]byte(v))
+	sum := mix64(h.Sum64())
+
+	if s.seen == nil {
+		s.seen = make(map[uint64]bool)
+	}
+	if s.seen[sum] {
+		return
+	}
+	if len(s.hashes) < kmvSize {
+		s.seen[sum] = true
+		heap.Push(&s.hashes, sum)
+		return
+	}
+	if sum >= s.hashes[0] {
+		return
+	}
+	delete(s.seen, s.hashes[0])
+	s.seen[sum] = true
+	s.hashes[0] = sum
+	heap.Fix(&s.hashes, 0)
+}
+
+func (s *kmvSketch) estimate() int {
+	if len(s.hashes) < kmvSize {
+		return len(s.hashes)
+	}
+	// The largest kept hash, normalized to [0, 1], approximates
+	// kmvSize / distinct.
+	kth := float64(s.hashes[0]) / math.MaxUint64
+	return int((kmvSize - 1) / kth)
+}
+
+// mix64 spreads FNV's poorly distributed high bits for short inputs
+// (the splitmix64 finalizer).
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// uint64Heap is a max-heap of uint64.
+type uint64Heap []uint64
+
+func (h uint64Heap) Len() int            { return len(h) }
+func (h uint64Heap) Less(i, j int) bool  { return h[i] > h[j] }
+func (h uint64Heap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *uint64Heap) Push(x interface{}) { *h = append(*h, x.(uint64)) }
+func (h *uint64Heap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,98 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var profileTests = []struct {
+	desc     string
+	column   string
+	typ      ColumnType
+	nulls    int
+	distinct int
+	min, max string
+}{
+	{"bool", "b,true\nb,FALSE\nb,\nb,true", TypeBool, 1, 2, "FALSE", "true"},
+	{"int", "i,10\ni,9\ni,-3\ni,null", TypeInt64, 1, 3, "-3", "10"},
+	{"double", "d,10\nd,9.5\nd,1e3", TypeDouble, 0, 3, "9.5", "1e3"},
+	{"timestamp", "t,2019-01-02T00:00:00Z\nt,2018-12-31T23:00:00-02:00\nt,2019-01-01T12:00:00Z", TypeTimestamp, 0, 3, "2018-12-31T23:00:00-02:00", "2019-01-02T00:00:00Z"},
+	{"string", "s,10\ns,foo\ns,bar", TypeString, 0, 3, "10", "foo"},
+	{"unknown", "u,\nu,null", TypeUnknown, 2, 0, "", ""},
+}
+
+func TestProfile(t *testing.T) {
+	for _, tt := range profileTests {
+		// Every test row is prefixed with a constant label column.
+		input := "label,value\n" + tt.column
+		profiles, err := Profile(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		if len(profiles) != 2 {
+			t.Fatalf("%s: unexpected profiles %v", tt.desc, profiles)
+		}
+
+		p := profiles[1]
+		if p.Name != "value" || p.Type != tt.typ || p.Nulls != tt.nulls || p.Distinct != tt.distinct || p.Min != tt.min || p.Max != tt.max {
+			t.Errorf("%s: got %v %v nulls=%d distinct=%d min=%q max=%q", tt.desc, p.Name, p.Type, p.Nulls, p.Distinct, p.Min, p.Max)
+		}
+		if p.Count != strings.Count(tt.column, "\n")+1 {
+			t.Errorf("%s: unexpected count %d", tt.desc, p.Count)
+		}
+	}
+}
+
+func TestProfileDistinctEstimate(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString("id\n")
+	const n = 20000
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "%d\n", i%(n/2))
+	}
+
+	profiles, err := Profile(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// KMV with 1024 hashes has a standard error of roughly 3%.
+	if d := profiles[0].Distinct; d < n/2*9/10 || d > n/2*11/10 {
+		t.Fatalf("Unexpected distinct estimate %d, expected about %d", d, n/2)
+	}
+	if r := profiles[0].NullRate(); r != 0 {
+		t.Fatalf("Unexpected null rate %v", r)
+	}
+}
@@ -0,0 +1,76 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"encoding/csv"
+)
+
+// RawMessage is a raw CSV cell. It can be used to capture cells and delay
+// their parsing until their type is known.
+type RawMessage string
+
+// IsNull reports whether the cell stands for a field without a value.
+func (m RawMessage) IsNull() bool {
+	return m == nullToken
+}
+
+// List splits a cell holding a list, itself encoded as a single CSV record,
+// into the raw cells of its elements.
+func (m RawMessage) List() ([]RawMessage, error) {
+	cells, err := SplitList(string(m))
+	if err != nil {
+		return nil, err
+	}
+	raws := make([]RawMessage, len(cells))
+	for i, cell := range cells {
+		raws[i] = RawMessage(cell)
+	}
+	return raws, nil
+}
+
+// RawRecord maps the columns of Header to the cells of record, without
+// parsing them.
+// Will panic, should Header be nil.
+func (u *Unmarshaler) RawRecord(record []string) (map[string]RawMessage, error) {
+	if u.Header == nil {
+		panic("Unmarshal needs header")
+	}
+	if len(record) != len(u.Header) {
+		return nil, csv.ErrFieldCount
+	}
+	raws := make(map[string]RawMessage, len(record))
+	for i, name := range u.Header {
+		raws[name] = RawMessage(record[i])
+	}
+	return raws, nil
+}
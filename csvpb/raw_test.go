@@ -0,0 +1,83 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"encoding/csv"
+	"reflect"
+	"testing"
+)
+
+func TestRawMessageList(t *testing.T) {
+	tests := []struct {
+		in      RawMessage
+		want    []RawMessage
+		wantErr bool
+	}{
+		{"", []RawMessage{}, false},
+		{"1,2,3", []RawMessage{"1", "2", "3"}, false},
+		{`"a,b",null`, []RawMessage{"a,b", "null"}, false},
+		{`"a`, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := tt.in.List()
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("RawMessage(%q).List() = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestRawMessageIsNull(t *testing.T) {
+	if !RawMessage("null").IsNull() || RawMessage("").IsNull() || RawMessage(`"null"`).IsNull() {
+		t.Error("IsNull() does not match the null token only")
+	}
+}
+
+func TestRawRecord(t *testing.T) {
+	u := &Unmarshaler{Header: []string{"oInt32", "rString"}}
+	got, err := u.RawRecord([]string{"1", "a,b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]RawMessage{"oInt32": "1", "rString": "a,b"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("RawRecord() = %q, want %q", got, want)
+	}
+	list, _ := got["rString"].List()
+	if !reflect.DeepEqual(list, []RawMessage{"a", "b"}) {
+		t.Errorf("List() = %q", list)
+	}
+
+	if _, err := u.RawRecord([]string{"1"}); err != csv.ErrFieldCount {
+		t.Errorf("RawRecord() error = %v, want %v", err, csv.ErrFieldCount)
+	}
+}
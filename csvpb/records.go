@@ -0,0 +1,101 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// recordSource yields the records of bulk operations. It is implemented by
+// Decoder.
+type recordSource interface {
+	More() bool
+	Decode() ([]string, error)
+	Err() error
+	InputOffset() int64
+	// inputSize returns the size of the input, -1 if unknown.
+	inputSize() int64
+}
+
+// sliceSource yields records held in memory.
+type sliceSource struct {
+	records [][]string
+}
+
+func (s *sliceSource) More() bool {
+	return len(s.records) > 0
+}
+
+func (s *sliceSource) Decode() ([]string, error) {
+	// Middleware may modify the record, which belongs to the caller
+	record := append([]string(nil), s.records[0]...)
+	s.records = s.records[1:]
+	return record, nil
+}
+
+func (s *sliceSource) Err() error {
+	return nil
+}
+
+func (s *sliceSource) InputOffset() int64 {
+	return 0
+}
+
+func (s *sliceSource) inputSize() int64 {
+	return -1
+}
+
+// UnmarshalRecords unmarshals records already parsed, like by another CSV
+// library, into messages created by factory. Should header be nil, Header
+// is used or, should it be nil too, the first record. records are not
+// modified.
+// The returned Summary is never nil, even if an error occurs. Its
+// BytesConsumed is 0.
+func (u *Unmarshaler) UnmarshalRecords(header []string, records [][]string, factory func() proto.Message) ([]proto.Message, *Summary, error) {
+	uc := *u
+	if header != nil {
+		uc.Header = header
+	}
+	s := &Summary{
+		Errors: make(map[ErrorCategory]int),
+	}
+	budget := &memoryBudget{limit: u.MaxMemory}
+	var pbs []proto.Message
+	err := uc.eachDecoded(&sliceSource{records}, s, factoryDecoder(factory), func(pb proto.Message) error {
+		if err := budget.charge(pb); err != nil {
+			return err
+		}
+		pbs = append(pbs, pb)
+		return nil
+	})
+	return pbs, s, err
+}
@@ -0,0 +1,90 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestUnmarshalRecords(t *testing.T) {
+	exp := []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("foo")},
+		&pb.Simple{OInt32: proto.Int32(2), OString: proto.String("bar")},
+	}
+	tests := []struct {
+		desc    string
+		u       *Unmarshaler
+		header  []string
+		records [][]string
+	}{
+		{"header", &Unmarshaler{}, []string{"oInt32", "oString"}, [][]string{{"1", "foo"}, {"2", "bar"}}},
+		{"unmarshaler header", &Unmarshaler{Header: []string{"oInt32", "oString"}}, nil, [][]string{{"1", "foo"}, {"2", "bar"}}},
+		{"first record", &Unmarshaler{}, nil, [][]string{{"oInt32", "oString"}, {"1", "foo"}, {"2", "bar"}}},
+		{"middleware", &Unmarshaler{Middleware: []RecordMiddleware{TrimCells}}, []string{"oInt32", "oString"}, [][]string{{"1", " foo"}, {" 2", "bar "}}},
+	}
+	for _, tt := range tests {
+		before := make([][]string, len(tt.records))
+		for i, record := range tt.records {
+			before[i] = append([]string(nil), record...)
+		}
+		pbs, s, err := tt.u.UnmarshalRecords(tt.header, tt.records, newSimple)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(pbs, exp) {
+			t.Errorf("%s: got %v, expected %v", tt.desc, pbs, exp)
+		}
+		if s.RowsRead != 2 || s.RowsDecoded != 2 {
+			t.Errorf("%s: got summary %+v", tt.desc, s)
+		}
+		if !reflect.DeepEqual(tt.records, before) {
+			t.Errorf("%s: records modified to %q", tt.desc, tt.records)
+		}
+	}
+}
+
+func TestUnmarshalRecordsError(t *testing.T) {
+	u := &Unmarshaler{}
+	_, s, err := u.UnmarshalRecords([]string{"oInt32"}, [][]string{{"1"}, {"bad"}}, newSimple)
+	if re, ok := err.(*RowError); !ok || re.Row != 2 || re.Category != CategoryConversion {
+		t.Errorf("got error %v, expected a conversion error in row 2", err)
+	}
+	if s == nil || s.Errors[CategoryConversion] != 1 {
+		t.Errorf("got summary %+v", s)
+	}
+}
@@ -0,0 +1,273 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// ColumnReport describes a column of the CSV layout of a message type, as
+// written by a Marshaler.
+type ColumnReport struct {
+	// Column is the name in the header.
+	Column string
+	// Field is the name of the field in the .proto file.
+	Field string
+	// Number is the field number.
+	Number int32
+	// Type is the type of the field, like int32, repeated string or
+	// google.protobuf.Timestamp.
+	Type string
+	// Format describes the cells of the column.
+	Format string
+	// Required reports whether the field is required.
+	Required bool
+	// Nullable reports whether the field may have no value, written as
+	// Null.
+	Nullable bool
+	Null     string
+}
+
+// Report describes the columns m writes for messages of the type of pb,
+// in the order of Header, like for the producers of files to be read
+// back.
+func (m *Marshaler) Report(pb proto.Message) ([]ColumnReport, error) {
+	var columns []ColumnReport
+	var oneofs map[*proto.Properties]reflect.Type
+	err := m.walkColumns(pb, func(name string, prop *proto.Properties, v reflect.Value) error {
+		if mapping := m.mapping(prop); mapping != nil {
+			for i, column := range mapping.columns {
+				c := ColumnReport{
+					Column:   column,
+					Field:    prop.OrigName,
+					Number:   int32(prop.Tag),
+					Format:   mapping.formats[i],
+					Nullable: true,
+					Null:     m.nullCell(prop),
+				}
+				if v.IsValid() {
+					c.Type = reportType(v.Type(), prop)
+				}
+				columns = append(columns, c)
+			}
+			return nil
+		}
+		c := ColumnReport{
+			Column:   name,
+			Field:    prop.OrigName,
+			Number:   int32(prop.Tag),
+			Required: prop.Required,
+		}
+		var t reflect.Type
+		if v.IsValid() {
+			t = v.Type()
+		} else {
+			if oneofs == nil {
+				oneofs = oneofFieldTypes(reflect.TypeOf(pb).Elem())
+			}
+			t = oneofs[prop]
+			// Unset members of the oneof are null
+			c.Nullable = true
+		}
+		if t.Kind() == reflect.Ptr {
+			c.Nullable = true
+		}
+		if c.Nullable {
+			c.Null = m.nullCell(prop)
+		}
+		c.Type = reportType(t, prop)
+		c.Format = m.reportFormat(t, prop)
+		columns = append(columns, c)
+		return nil
+	})
+	return columns, err
+}
+
+// oneofFieldTypes returns the types of the members of the oneofs of the
+// messages of struct type t, by their properties.
+func oneofFieldTypes(t reflect.Type) map[*proto.Properties]reflect.Type {
+	types := make(map[*proto.Properties]reflect.Type)
+	for _, oop := range proto.GetProperties(t).OneofTypes {
+		types[oop.Prop] = oop.Type.Elem().Field(0).Type
+	}
+	return types
+}
+
+// reportType returns the protobuf type of a field of Go type t.
+func reportType(t reflect.Type, prop *proto.Properties) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		}
+		return "repeated " + reportType(t.Elem(), prop)
+	case reflect.Map:
+		return fmt.Sprintf("map<%s, %s>", reportType(t.Key(), prop.MapKeyProp), reportType(t.Elem(), prop.MapValProp))
+	case reflect.Struct:
+		return proto.MessageName(reflect.New(t).Interface().(proto.Message))
+	case reflect.Int32:
+		if prop != nil && prop.Enum != "" {
+			return prop.Enum
+		}
+	case reflect.Float32:
+		return "float"
+	case reflect.Float64:
+		return "double"
+	}
+	return t.Kind().String()
+}
+
+// reportFormat describes the cells m writes for a field of Go type t.
+func (m *Marshaler) reportFormat(t reflect.Type, prop *proto.Properties) string {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		w, ok := reflect.New(t).Interface().(wkt)
+		if !ok {
+			return "not supported"
+		}
+		switch w.XXX_WellKnownType() {
+		case "DoubleValue", "FloatValue", "Int64Value", "UInt64Value",
+			"Int32Value", "UInt32Value", "BoolValue", "StringValue", "BytesValue":
+			return m.reportFormat(t.Field(0).Type, prop)
+		case "Duration":
+			return "duration, like 1.5s"
+		case "Timestamp":
+			if layout := timestampLayout(m.fieldOptions(prop)); layout != "" {
+				return "UTC time in the layout " + layout
+			}
+			if m.Dialect != nil && m.Dialect.TimestampLayout != "" {
+				return "UTC time in the layout " + m.Dialect.TimestampLayout
+			}
+			return "RFC 3339 time"
+		case "Empty":
+			return "any cell but null if set, written as " + emptyCell
+		case "Value":
+			return fmt.Sprintf("number, %s, %s or text, empty for null", m.formatBool(true), m.formatBool(false))
+		case "ListValue":
+			nested := "JSON arrays"
+			if o := m.fieldOptions(prop); o != nil && o.NestedDelimiter != 0 {
+				nested = fmt.Sprintf("records separated by %q", o.NestedDelimiter)
+			}
+			return "list of numbers, booleans, text or lists as " + nested + ", as a CSV record within the cell"
+		}
+		return "not supported"
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			if compressed(m.fieldOptions(prop)) {
+				return "gzip compressed, base64"
+			}
+			return "base64"
+		}
+		elem := m.reportFormat(t.Elem(), prop)
+		if elem == "not supported" {
+			return elem
+		}
+		return "list of " + elem + ", as a CSV record within the cell"
+	case reflect.Map:
+		return "not supported, null if empty"
+	case reflect.Bool:
+		return fmt.Sprintf("%s or %s", m.formatBool(true), m.formatBool(false))
+	case reflect.Int32:
+		if prop != nil && prop.Enum == nullValueEnum {
+			return "always " + m.nullCell(prop)
+		}
+		if prop != nil && prop.Enum != "" {
+			if m.EnumsAsInts {
+				return "enum number"
+			}
+			return "enum name (" + strings.Join(enumNames(prop.Enum), ", ") + ")"
+		}
+		return "decimal integer"
+	case reflect.Int64, reflect.Uint32, reflect.Uint64:
+		return "decimal integer"
+	case reflect.Float32:
+		return m.reportFloatFormat(32, prop)
+	case reflect.Float64:
+		return m.reportFloatFormat(64, prop)
+	case reflect.String:
+		if compressed(m.fieldOptions(prop)) {
+			return "gzip compressed text, base64"
+		}
+		return "text"
+	}
+	return "not supported"
+}
+
+// reportFloatFormat describes the floats m writes for prop.
+func (m *Marshaler) reportFloatFormat(bitSize int, prop *proto.Properties) string {
+	desc := "decimal number"
+	if ff := m.floatFormat(prop); ff != nil && !m.Deterministic {
+		switch {
+		case ff.Notation == 'f' && ff.Precision >= 0:
+			desc = fmt.Sprintf("decimal number with %d digits after the point", ff.Precision)
+		case ff.Notation == 'f':
+			desc = "decimal number without exponent"
+		case ff.Notation == 'e':
+			desc = "decimal number with exponent"
+		}
+	}
+	return fmt.Sprintf("%s, or %s, %s and %s", desc,
+		m.formatFloat(math.NaN(), bitSize, prop),
+		m.formatFloat(math.Inf(1), bitSize, prop),
+		m.formatFloat(math.Inf(-1), bitSize, prop))
+}
+
+// enumNames returns the names of the values of the registered enum name,
+// ordered by number.
+func enumNames(name string) []string {
+	values := proto.EnumValueMap(name)
+	names := make([]string, 0, len(values))
+	for n := range values {
+		names = append(names, n)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if values[names[i]] != values[names[j]] {
+			return values[names[i]] < values[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	return names
+}
@@ -0,0 +1,102 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestReport(t *testing.T) {
+	got, err := new(Marshaler).Report(new(pb.Widget))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ColumnReport{
+		{"color", "color", 1, "jsonpb.Widget_Color", "enum name (RED, GREEN, BLUE)", false, true, "null"},
+		{"rColor", "r_color", 2, "repeated jsonpb.Widget_Color", "list of enum name (RED, GREEN, BLUE), as a CSV record within the cell", false, false, ""},
+		{"simple", "simple", 10, "jsonpb.Simple", "not supported", false, true, "null"},
+		{"rSimple", "r_simple", 11, "repeated jsonpb.Simple", "not supported", false, false, ""},
+		{"repeats", "repeats", 20, "jsonpb.Repeats", "not supported", false, true, "null"},
+		{"rRepeats", "r_repeats", 21, "repeated jsonpb.Repeats", "not supported", false, false, ""},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReportOptions(t *testing.T) {
+	tests := []struct {
+		m      Marshaler
+		pb     proto.Message
+		column string
+		want   ColumnReport
+	}{
+		{Marshaler{}, new(pb.Simple), "oDouble",
+			ColumnReport{"oDouble", "o_double", 16, "double", "decimal number, or NaN, Infinity and -Infinity", false, true, "null"}},
+		{Marshaler{FloatFormat: Decimals(2), NonFinite: &NonFinite{NaN: []string{"nan"}}}, new(pb.Simple), "oDouble",
+			ColumnReport{"oDouble", "o_double", 16, "double", "decimal number with 2 digits after the point, or nan, Infinity and -Infinity", false, true, "null"}},
+		{Marshaler{OrigName: true, Dialect: BigQuery}, new(pb.Simple), "o_bool",
+			ColumnReport{"o_bool", "o_bool", 1, "bool", "true or false", false, true, ""}},
+		{Marshaler{EnumsAsInts: true}, new(pb.Widget), "color",
+			ColumnReport{"color", "color", 1, "jsonpb.Widget_Color", "enum number", false, true, "null"}},
+		{Marshaler{}, new(pb.MsgWithOneof), "salary",
+			ColumnReport{"salary", "salary", 2, "int64", "decimal integer", false, true, "null"}},
+		{Marshaler{}, new(pb.KnownTypes), "ts",
+			ColumnReport{"ts", "ts", 2, "google.protobuf.Timestamp", "RFC 3339 time", false, true, "null"}},
+		{Marshaler{}, new(pb.KnownTypes), "bytes",
+			ColumnReport{"bytes", "bytes", 11, "google.protobuf.BytesValue", "base64", false, true, "null"}},
+	}
+	for _, tt := range tests {
+		columns, err := tt.m.Report(tt.pb)
+		if err != nil {
+			t.Errorf("%s: %v", tt.column, err)
+			continue
+		}
+		found := false
+		for _, c := range columns {
+			if c.Column == tt.column {
+				found = true
+				if c != tt.want {
+					t.Errorf("%s: got %+v, want %+v", tt.column, c, tt.want)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: missing", tt.column)
+		}
+	}
+}
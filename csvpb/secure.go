@@ -0,0 +1,137 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// NewSecureUnmarshaler returns an Unmarshaler for untrusted input, like
+// user uploads. It rejects unknown columns, cells that are not UTF-8, and
+// bounds the size of the input, records, cells and lists as well as the
+// memory held by UnmarshalAll. The limits may be adjusted before use.
+func NewSecureUnmarshaler() *Unmarshaler {
+	return &Unmarshaler{
+		AllowUnknownFields: false,
+		MaxInputSize:       64 << 20,
+		MaxMemory:          256 << 20,
+		MaxColumns:         1024,
+		MaxCellSize:        64 << 10,
+		MaxListLength:      1024,
+		ValidUTF8:          true,
+	}
+}
+
+// LimitError is returned for input exceeding a limit of the Unmarshaler.
+type LimitError struct {
+	// What names the limited quantity, like "cell size".
+	What  string
+	Limit int64
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("csvpb: %s exceeds limit of %d", e.What, e.Limit)
+}
+
+// limitedReader fails reading beyond limit bytes, unlike io.LimitedReader
+// which reports EOF.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	n     int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.n >= l.limit {
+		// Only fail if there is more to read
+		var b [1]byte
+		if n, err := l.r.Read(b[:]); n == 0 {
+			return 0, err
+		}
+		return 0, &LimitError{What: "input size", Limit: l.limit}
+	}
+	if rest := l.limit - l.n; int64(len(p)) > rest {
+		p = p[:rest]
+	}
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+	return n, err
+}
+
+// newDecoder creates a Decoder reading r, bounded by MaxInputSize.
+func (u *Unmarshaler) newDecoder(r io.Reader) *Decoder {
+	size := u.InputSize
+	if size == 0 && u.OnProgress != nil {
+		size = inputSize(r)
+	}
+	if u.MaxInputSize > 0 {
+		r = &limitedReader{r: r, limit: u.MaxInputSize}
+	}
+	dec := newDecoder(r, u.NewRecordReader)
+	dec.size = size
+	return dec
+}
+
+// inputSize returns the size of r, -1 if unknown.
+func inputSize(r io.Reader) int64 {
+	switch r := r.(type) {
+	case interface{ Size() int64 }:
+		return r.Size()
+	case *os.File:
+		if fi, err := r.Stat(); err == nil && fi.Mode().IsRegular() {
+			return fi.Size()
+		}
+	}
+	return -1
+}
+
+// checkRecord checks record against MaxColumns, MaxCellSize and ValidUTF8.
+func (u *Unmarshaler) checkRecord(record []string) error {
+	if u.MaxColumns > 0 && len(record) > u.MaxColumns {
+		return &LimitError{What: "column count", Limit: int64(u.MaxColumns)}
+	}
+	if u.MaxCellSize <= 0 && !u.ValidUTF8 {
+		return nil
+	}
+	for i, cell := range record {
+		if u.MaxCellSize > 0 && len(cell) > u.MaxCellSize {
+			return &LimitError{What: "cell size", Limit: int64(u.MaxCellSize)}
+		}
+		if u.ValidUTF8 && !utf8.ValidString(cell) {
+			return fmt.Errorf("csvpb: cell %d is not valid UTF-8", i+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestSecureUnmarshaler(t *testing.T) {
+	tests := []struct {
+		desc  string
+		u     func(u *Unmarshaler)
+		input string
+		limit string
+	}{
+		{"accepted", nil, "oString\nfoo\n", ""},
+		{"input size", func(u *Unmarshaler) { u.MaxInputSize = 10 }, "oString\nfoo\nbar\n", "input size"},
+		{"input size exact", func(u *Unmarshaler) { u.MaxInputSize = 12 }, "oString\nfoo\n", ""},
+		{"column count", func(u *Unmarshaler) { u.MaxColumns = 1 }, "oString,oInt32\nfoo,1\n", "column count"},
+		{"cell size", func(u *Unmarshaler) { u.MaxCellSize = 2 }, "oString\nfoo\n", "cell size"},
+		{"list length", func(u *Unmarshaler) { u.MaxListLength = 2 }, "rBool\n\"true,false,true\"\n", "list length"},
+		{"utf8", nil, "oString\n\xff\n", "-"},
+		{"unknown field", nil, "unknown\nfoo\n", "-"},
+	}
+	for _, tt := range tests {
+		u := NewSecureUnmarshaler()
+		if tt.u != nil {
+			tt.u(u)
+		}
+		factory := newSimple
+		if strings.HasPrefix(tt.input, "rBool") {
+			factory = func() proto.Message { return new(pb.Repeats) }
+		}
+		_, _, err := u.UnmarshalAll(strings.NewReader(tt.input), factory)
+		switch tt.limit {
+		case "":
+			if err != nil {
+				t.Errorf("%s: %v", tt.desc, err)
+			}
+		case "-":
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.desc)
+			}
+		default:
+			re, ok := err.(*RowError)
+			if !ok {
+				t.Errorf("%s: got %v, expected a RowError", tt.desc, err)
+				continue
+			}
+			if le, ok := re.Err.(*LimitError); !ok || le.What != tt.limit {
+				t.Errorf("%s: got %v, expected %s limit", tt.desc, re.Err, tt.limit)
+			}
+		}
+	}
+}
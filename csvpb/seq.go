@@ -0,0 +1,84 @@
+//go:build go1.23
+// +build go1.23
+
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"io"
+	"iter"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// errStopped stops a bulk operation whose iteration was stopped.
+var errStopped = errors.New("csvpb: iteration stopped")
+
+// All returns an iterator over the messages of a CSV, unmarshaled like
+// UnmarshalEach. An error ends the iteration, with a nil message.
+func (u *Unmarshaler) All(r io.Reader, factory func() proto.Message) iter.Seq2[proto.Message, error] {
+	return func(yield func(proto.Message, error) bool) {
+		_, err := u.UnmarshalEach(r, factory, func(pb proto.Message) error {
+			if !yield(pb, nil) {
+				return errStopped
+			}
+			return nil
+		})
+		if err != nil && err != errStopped {
+			yield(nil, err)
+		}
+	}
+}
+
+// EncodeSeq marshals the messages of seq as records, like MarshalNext, and
+// flushes them. An error of seq stops it and is returned, after flushing
+// the records before.
+func (e *Encoder) EncodeSeq(seq iter.Seq2[proto.Message, error]) error {
+	m := e.Marshaler
+	if m == nil {
+		m = new(Marshaler)
+	}
+	for pb, err := range seq {
+		if err != nil {
+			if ferr := e.Flush(); ferr != nil {
+				return ferr
+			}
+			return err
+		}
+		if err := m.MarshalNext(e, pb); err != nil {
+			return err
+		}
+	}
+	return e.Flush()
+}
@@ -0,0 +1,75 @@
+//go:build go1.23
+// +build go1.23
+
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestSeq(t *testing.T) {
+	input := "dub\n1\n2.5\n"
+	u := new(Unmarshaler)
+	factory := func() proto.Message { return new(pb.Simple3) }
+
+	var buf strings.Builder
+	if err := NewEncoder(&buf).EncodeSeq(u.All(strings.NewReader(input), factory)); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != input {
+		t.Errorf("got %q, want %q", buf.String(), input)
+	}
+
+	// Stopping early
+	for m, err := range u.All(strings.NewReader(input), factory) {
+		if err != nil || m.(*pb.Simple3).Dub != 1 {
+			t.Errorf("got %v, %v", m, err)
+		}
+		break
+	}
+
+	buf.Reset()
+	err := NewEncoder(&buf).EncodeSeq(u.All(strings.NewReader("dub\n1\nbad\n"), factory))
+	if _, ok := err.(*RowError); !ok {
+		t.Errorf("got %v, expected a RowError", err)
+	}
+	if want := "dub\n1\n"; buf.String() != want {
+		t.Errorf("records before the error: got %q, want %q", buf.String(), want)
+	}
+}
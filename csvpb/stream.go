@@ -0,0 +1,92 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"context"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// StreamContext is Stream, returning ctx.Err() once ctx is done. Canceling
+// ctx is how a consumer no longer receiving from ch releases StreamContext,
+// which would block on sending otherwise. ctx is checked between records,
+// so a Read of r blocking indefinitely holds it up, unless r is closed.
+// The returned Summary is never nil, even if an error occurs.
+func (u *Unmarshaler) StreamContext(ctx context.Context, r io.Reader, factory func() proto.Message, ch chan<- proto.Message) (*Summary, error) {
+	defer close(ch)
+	return u.UnmarshalEach(r, factory, func(pb proto.Message) error {
+		select {
+		case ch <- pb:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// MessageStream is a stream of messages unmarshaled by a goroutine, as
+// returned by Unmarshaler.Messages.
+type MessageStream struct {
+	// C delivers the messages. It is closed once the goroutine is done.
+	C <-chan proto.Message
+
+	done    chan struct{}
+	summary *Summary
+	err     error
+}
+
+// Messages unmarshals every record of a CSV into messages created by
+// factory within a goroutine, like StreamContext. Up to buffer messages are
+// unmarshaled ahead of the consumer, which blocks the goroutine once
+// reached. The goroutine ends with the input, the first error or ctx being
+// done, so a consumer stopping early has to cancel ctx.
+func (u *Unmarshaler) Messages(ctx context.Context, r io.Reader, factory func() proto.Message, buffer int) *MessageStream {
+	ch := make(chan proto.Message, buffer)
+	s := &MessageStream{
+		C:    ch,
+		done: make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		s.summary, s.err = u.StreamContext(ctx, r, factory, ch)
+	}()
+	return s
+}
+
+// Wait waits for the goroutine to end and returns what it processed. The
+// returned Summary is never nil, even if an error occurs.
+func (s *MessageStream) Wait() (*Summary, error) {
+	<-s.done
+	return s.summary, s.err
+}
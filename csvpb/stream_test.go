@@ -0,0 +1,90 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestMessages(t *testing.T) {
+	u := &Unmarshaler{Header: []string{"oInt32"}}
+	s := u.Messages(context.Background(), strings.NewReader("1\n2\n3\n"), newSimple, 2)
+	var got []int32
+	for m := range s.C {
+		got = append(got, m.(*pb.Simple).GetOInt32())
+	}
+	summary, err := s.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 || summary.RowsDecoded != 3 {
+		t.Errorf("got %v, %+v", got, summary)
+	}
+}
+
+func TestMessagesCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	u := &Unmarshaler{Header: []string{"oInt32"}}
+	s := u.Messages(ctx, strings.NewReader(strings.Repeat("1\n", 100)), newSimple, 1)
+
+	// The consumer stops after the first message.
+	<-s.C
+	cancel()
+	summary, err := s.Wait()
+	if err != context.Canceled {
+		t.Errorf("got %v, expected %v", err, context.Canceled)
+	}
+	if summary.RowsDecoded >= 100 {
+		t.Errorf("got %+v, expected to stop early", summary)
+	}
+	for range s.C {
+	}
+}
+
+func TestStreamContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	u := &Unmarshaler{Header: []string{"oInt32"}}
+	ch := make(chan proto.Message)
+	if _, err := u.StreamContext(ctx, strings.NewReader("1\n"), newSimple, ch); err != context.Canceled {
+		t.Errorf("got %v, expected %v", err, context.Canceled)
+	}
+	if _, ok := <-ch; ok {
+		t.Error("channel not closed")
+	}
+}
@@ -0,0 +1,331 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Strictness is the level of coercion of cells into numbers, bools and
+// enums.
+type Strictness int
+
+const (
+	// Lenient accepts numbers and bools quoted like in JSON.
+	Lenient Strictness = iota
+	// Strict rejects quoted cells and cells with surrounding whitespace.
+	Strict
+	// Permissive ignores surrounding whitespace, truncates numbers with a
+	// fraction into integer fields and accepts yes/no, y/n and on/off as
+	// bools.
+	Permissive
+)
+
+func (s Strictness) String() string {
+	switch s {
+	case Lenient:
+		return "lenient"
+	case Strict:
+		return "strict"
+	case Permissive:
+		return "permissive"
+	}
+	return fmt.Sprintf("Strictness(%d)", int(s))
+}
+
+// RangePolicy decides what becomes of integers too large or too small for
+// their field.
+type RangePolicy int
+
+const (
+	// RangeFail fails with a RangeError.
+	RangeFail RangePolicy = iota
+	// RangeSaturate uses the largest or smallest integer of the field.
+	RangeSaturate
+	// RangeWrap keeps the lower bits of the integer, like a conversion
+	// between integer types in Go.
+	RangeWrap
+)
+
+// PercentPolicy decides whether cells of float fields may be percentages,
+// like 45%.
+type PercentPolicy int
+
+const (
+	// PercentFail fails percentages like any other malformed number.
+	PercentFail PercentPolicy = iota
+	// PercentFraction reads percentages as fractions, 45% as 0.45.
+	PercentFraction
+	// PercentNumber drops the percent sign, reading 45% as 45.
+	PercentNumber
+)
+
+// percentNumber returns the number of a percentage cell, should
+// PercentPolicy accept it.
+func (u *Unmarshaler) percentNumber(cell string) (string, bool) {
+	cell = unquote(cell)
+	if u.Percent == PercentFail || !strings.HasSuffix(cell, "%") {
+		return "", false
+	}
+	return strings.TrimRight(cell[:len(cell)-1], " "), true
+}
+
+// RangeError describes a cell holding an integer out of the range of its
+// field.
+type RangeError struct {
+	// Field is the original name of the field.
+	Field string
+	// Value is the cell.
+	Value string
+	// Type is the Go type of the field, like int32.
+	Type string
+}
+
+func (e *RangeError) Error() string {
+	return fmt.Sprintf("field %q: %s out of range for %s", e.Field, e.Value, e.Type)
+}
+
+// scalarCell prepares the cell of a number, bool or enum for parsing.
+func (u *Unmarshaler) scalarCell(cell string) (string, error) {
+	switch u.Strictness {
+	case Strict:
+		if strings.TrimSpace(cell) != cell {
+			return "", fmt.Errorf("stray whitespace in %q", cell)
+		}
+		if unquote(cell) != cell {
+			return "", fmt.Errorf("quoted value %q", cell)
+		}
+	case Permissive:
+		return strings.TrimSpace(cell), nil
+	}
+	return cell, nil
+}
+
+func (u *Unmarshaler) warn(prop *proto.Properties, cell, reason string) {
+	if u.Warn != nil {
+		u.Warn(prop, cell, reason)
+	}
+}
+
+// truncated returns the integer part of cell, should it be a finite
+// number, for Permissive.
+func (u *Unmarshaler) truncated(cell string) (float64, bool) {
+	if u.Strictness != Permissive {
+		return 0, false
+	}
+	f, err := ParseFloat(cell, 64)
+	if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return math.Trunc(f), true
+}
+
+// integerDigits returns the digits of an integer cell and their base,
+// which is 10 unless IntegerPrefixes accepts the prefix of cell.
+func (u *Unmarshaler) integerDigits(cell string) (string, int) {
+	cell = unquote(cell)
+	if !u.IntegerPrefixes {
+		return cell, 10
+	}
+	sign := ""
+	if strings.HasPrefix(cell, "-") || strings.HasPrefix(cell, "+") {
+		sign, cell = cell[:1], cell[1:]
+	}
+	if len(cell) < 3 || cell[0] != '0' || cell[2] == '-' || cell[2] == '+' {
+		return sign + cell, 10
+	}
+	switch cell[1] {
+	case 'x', 'X':
+		return sign + cell[2:], 16
+	case 'o', 'O':
+		return sign + cell[2:], 8
+	case 'b', 'B':
+		return sign + cell[2:], 2
+	}
+	return sign + cell, 10
+}
+
+// numberCell drops the underscores grouping the digits of a number cell,
+// should DigitSeparators accept them. Underscores are accepted between two
+// digits only, hexadecimal ones for integers with a prefix.
+func (u *Unmarshaler) numberCell(cell string) string {
+	if !u.DigitSeparators || !strings.Contains(cell, "_") {
+		return cell
+	}
+	isDigit := func(c byte) bool { return '0' <= c && c <= '9' }
+	if _, base := u.integerDigits(strings.Replace(cell, "_", "", -1)); base == 16 {
+		isDigit = func(c byte) bool {
+			return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+		}
+	}
+	digits := make([]byte, 0, len(cell))
+	for i := 0; i < len(cell); i++ {
+		if cell[i] != '_' {
+			digits = append(digits, cell[i])
+			continue
+		}
+		if i == 0 || i == len(cell)-1 || !isDigit(cell[i-1]) || !isDigit(cell[i+1]) {
+			// Leave the cell to fail parsing
+			return cell
+		}
+	}
+	return string(digits)
+}
+
+func (u *Unmarshaler) parseInt(cell string, bitSize int, prop *proto.Properties) (int64, error) {
+	cell = u.numberCell(cell)
+	n, err := u.coercer().ParseInt(cell, bitSize)
+	if err == nil {
+		return n, nil
+	}
+	if digits, base := u.integerDigits(cell); base != 10 {
+		if n, perr := strconv.ParseInt(digits, base, bitSize); perr == nil {
+			return n, nil
+		}
+	}
+	x, err := u.coerceInteger(cell, bitSize, true, prop, err)
+	if err != nil {
+		return 0, err
+	}
+	return x.Int64(), nil
+}
+
+func (u *Unmarshaler) parseUint(cell string, bitSize int, prop *proto.Properties) (uint64, error) {
+	cell = u.numberCell(cell)
+	n, err := u.coercer().ParseUint(cell, bitSize)
+	if err == nil {
+		return n, nil
+	}
+	if digits, base := u.integerDigits(cell); base != 10 {
+		if n, perr := strconv.ParseUint(digits, base, bitSize); perr == nil {
+			return n, nil
+		}
+	}
+	x, err := u.coerceInteger(cell, bitSize, false, prop, err)
+	if err != nil {
+		return 0, err
+	}
+	return x.Uint64(), nil
+}
+
+// coerceInteger converts a cell that failed to parse with parseErr into an
+// integer fitting bitSize, as far as Strictness and IntegerRange allow.
+func (u *Unmarshaler) coerceInteger(cell string, bitSize int, signed bool, prop *proto.Properties, parseErr error) (*big.Int, error) {
+	var reasons []string
+	x, ok := new(big.Int).SetString(u.integerDigits(cell))
+	if !ok {
+		t, ok := u.truncated(cell)
+		if !ok {
+			return nil, parseErr
+		}
+		x, _ = big.NewFloat(t).Int(nil)
+		reasons = append(reasons, "truncated to "+x.String())
+	}
+
+	min, max := integerBounds(bitSize, signed)
+	if x.Cmp(min) < 0 || x.Cmp(max) > 0 {
+		switch u.IntegerRange {
+		case RangeSaturate:
+			if x.Sign() < 0 {
+				x = min
+			} else {
+				x = max
+			}
+			reasons = append(reasons, "saturated to "+x.String())
+		case RangeWrap:
+			mod := new(big.Int).Lsh(big.NewInt(1), uint(bitSize))
+			x = new(big.Int).Mod(x, mod)
+			if x.Cmp(max) > 0 {
+				x.Sub(x, mod)
+			}
+			reasons = append(reasons, "wrapped to "+x.String())
+		default:
+			re := &RangeError{Value: cell, Type: integerType(bitSize, signed)}
+			if prop != nil {
+				re.Field = prop.OrigName
+			}
+			return nil, re
+		}
+	}
+	for _, reason := range reasons {
+		u.warn(prop, cell, reason)
+	}
+	return x, nil
+}
+
+// integerBounds returns the smallest and largest integer of bitSize.
+func integerBounds(bitSize int, signed bool) (*big.Int, *big.Int) {
+	one := big.NewInt(1)
+	if !signed {
+		max := new(big.Int).Lsh(one, uint(bitSize))
+		return new(big.Int), max.Sub(max, one)
+	}
+	max := new(big.Int).Lsh(one, uint(bitSize-1))
+	min := new(big.Int).Neg(max)
+	return min, max.Sub(max, one)
+}
+
+func integerType(bitSize int, signed bool) string {
+	if signed {
+		return fmt.Sprintf("int%d", bitSize)
+	}
+	return fmt.Sprintf("uint%d", bitSize)
+}
+
+// permissiveBools are the words Permissive accepts as bools besides those
+// of ParseBool.
+var permissiveBools = map[string]bool{
+	"yes": true,
+	"y":   true,
+	"on":  true,
+	"no":  false,
+	"n":   false,
+	"off": false,
+}
+
+func (u *Unmarshaler) parseBool(cell string, prop *proto.Properties) (bool, error) {
+	b, err := u.coercer().ParseBool(cell)
+	if err == nil || u.Strictness != Permissive {
+		return b, err
+	}
+	if b, ok := permissiveBools[strings.ToLower(cell)]; ok {
+		u.warn(prop, cell, fmt.Sprintf("read as %t", b))
+		return b, nil
+	}
+	return b, err
+}
@@ -0,0 +1,326 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestStrictness(t *testing.T) {
+	tests := []struct {
+		strictness Strictness
+		column     string
+		cell       string
+		want       *pb.Simple
+		warnings   []string
+	}{
+		{Lenient, "oInt32", `"7"`, &pb.Simple{OInt32: proto.Int32(7)}, nil},
+		{Lenient, "oInt32", " 7", nil, nil},
+		{Lenient, "oInt32", "7.9", nil, nil},
+		{Lenient, "oBool", "TRUE", &pb.Simple{OBool: proto.Bool(true)}, nil},
+		{Strict, "oInt32", "7", &pb.Simple{OInt32: proto.Int32(7)}, nil},
+		{Strict, "oInt32", `"7"`, nil, nil},
+		{Strict, "oInt32", "7 ", nil, nil},
+		{Strict, "oDouble", `"NaN"`, nil, nil},
+		{Strict, "oBool", " true", nil, nil},
+		{Strict, "oString", " x ", &pb.Simple{OString: proto.String(" x ")}, nil},
+		{Permissive, "oInt32", " 7 ", &pb.Simple{OInt32: proto.Int32(7)}, nil},
+		{Permissive, "oInt32", `"7"`, &pb.Simple{OInt32: proto.Int32(7)}, nil},
+		{Permissive, "oInt32", "7.9", &pb.Simple{OInt32: proto.Int32(7)}, []string{"o_int32 7.9: truncated to 7"}},
+		{Permissive, "oInt32", "-1.0", &pb.Simple{OInt32: proto.Int32(-1)}, []string{"o_int32 -1.0: truncated to -1"}},
+		{Permissive, "oInt32", "3e9", nil, nil},
+		{Permissive, "oInt32", "NaN", nil, nil},
+		{Permissive, "oInt64", "1e18", &pb.Simple{OInt64: proto.Int64(1e18)}, []string{"o_int64 1e18: truncated to 1000000000000000000"}},
+		{Permissive, "oUint32", "4.5", &pb.Simple{OUint32: proto.Uint32(4)}, []string{"o_uint32 4.5: truncated to 4"}},
+		{Permissive, "oUint32", "-4.5", nil, nil},
+		{Permissive, "oBool", "Yes", &pb.Simple{OBool: proto.Bool(true)}, []string{"o_bool Yes: read as true"}},
+		{Permissive, "oBool", "off", &pb.Simple{OBool: proto.Bool(false)}, []string{"o_bool off: read as false"}},
+		{Permissive, "oBool", "maybe", nil, nil},
+	}
+	for _, tt := range tests {
+		var warnings []string
+		u := &Unmarshaler{
+			Header:     []string{tt.column},
+			Strictness: tt.strictness,
+			Warn: func(prop *proto.Properties, cell, reason string) {
+				warnings = append(warnings, prop.OrigName+" "+cell+": "+reason)
+			},
+		}
+		got := new(pb.Simple)
+		err := u.UnmarshalRecord([]string{tt.cell}, got)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%v %s %q: got %v, expected an error", tt.strictness, tt.column, tt.cell, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%v %s %q: %v", tt.strictness, tt.column, tt.cell, err)
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("%v %s %q: got %v, expected %v", tt.strictness, tt.column, tt.cell, got, tt.want)
+		}
+		if !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("%v %s %q: got warnings %q, expected %q", tt.strictness, tt.column, tt.cell, warnings, tt.warnings)
+		}
+	}
+}
+
+func TestStrictnessEnum(t *testing.T) {
+	for _, tt := range []struct {
+		strictness Strictness
+		ok         bool
+	}{{Lenient, true}, {Strict, false}, {Permissive, true}} {
+		u := &Unmarshaler{Header: []string{"color"}, Strictness: tt.strictness}
+		err := u.UnmarshalRecord([]string{" BLUE"}, new(pb.Widget))
+		if (err == nil) != tt.ok {
+			t.Errorf("%v: got %v", tt.strictness, err)
+		}
+	}
+}
+
+func TestIntegerRange(t *testing.T) {
+	tests := []struct {
+		policy   RangePolicy
+		column   string
+		cell     string
+		want     *pb.Simple
+		warnings []string
+	}{
+		{RangeSaturate, "oInt32", "3000000000", &pb.Simple{OInt32: proto.Int32(2147483647)}, []string{"saturated to 2147483647"}},
+		{RangeSaturate, "oInt32", "-3000000000", &pb.Simple{OInt32: proto.Int32(-2147483648)}, []string{"saturated to -2147483648"}},
+		{RangeSaturate, "oInt64", "1" + "00000000000000000000", &pb.Simple{OInt64: proto.Int64(9223372036854775807)}, []string{"saturated to 9223372036854775807"}},
+		{RangeSaturate, "oUint32", "-1", &pb.Simple{OUint32: proto.Uint32(0)}, []string{"saturated to 0"}},
+		{RangeSaturate, "oUint64", "18446744073709551616", &pb.Simple{OUint64: proto.Uint64(18446744073709551615)}, []string{"saturated to 18446744073709551615"}},
+		{RangeWrap, "oInt32", "3000000000", &pb.Simple{OInt32: proto.Int32(-1294967296)}, []string{"wrapped to -1294967296"}},
+		{RangeWrap, "oInt32", "-2147483649", &pb.Simple{OInt32: proto.Int32(2147483647)}, []string{"wrapped to 2147483647"}},
+		{RangeWrap, "oUint32", "-1", &pb.Simple{OUint32: proto.Uint32(4294967295)}, []string{"wrapped to 4294967295"}},
+		{RangeWrap, "oUint64", "18446744073709551617", &pb.Simple{OUint64: proto.Uint64(1)}, []string{"wrapped to 1"}},
+		{RangeWrap, "oInt32", `"5"`, &pb.Simple{OInt32: proto.Int32(5)}, nil},
+		{RangeWrap, "oInt32", "x", nil, nil},
+	}
+	for _, tt := range tests {
+		var warnings []string
+		u := &Unmarshaler{
+			Header:       []string{tt.column},
+			IntegerRange: tt.policy,
+			Warn: func(prop *proto.Properties, cell, reason string) {
+				warnings = append(warnings, reason)
+			},
+		}
+		got := new(pb.Simple)
+		err := u.UnmarshalRecord([]string{tt.cell}, got)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s %q: got %v, expected an error", tt.column, tt.cell, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: %v", tt.column, tt.cell, err)
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("%s %q: got %v, expected %v", tt.column, tt.cell, got, tt.want)
+		}
+		if !reflect.DeepEqual(warnings, tt.warnings) {
+			t.Errorf("%s %q: got warnings %q, expected %q", tt.column, tt.cell, warnings, tt.warnings)
+		}
+	}
+}
+
+func TestRangeError(t *testing.T) {
+	tests := []struct {
+		strictness Strictness
+		column     string
+		cell       string
+		want       RangeError
+	}{
+		{Lenient, "oInt32", "3000000000", RangeError{"o_int32", "3000000000", "int32"}},
+		{Lenient, "oUint64", "-1", RangeError{"o_uint64", "-1", "uint64"}},
+		{Permissive, "oInt32", "3e9", RangeError{"o_int32", "3e9", "int32"}},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{Header: []string{tt.column}, Strictness: tt.strictness}
+		err := u.UnmarshalRecord([]string{tt.cell}, new(pb.Simple))
+		re, ok := err.(*RangeError)
+		if !ok || *re != tt.want {
+			t.Errorf("%s %q: got %v, expected %v", tt.column, tt.cell, err, &tt.want)
+		}
+	}
+	want := `field "o_int32": 3000000000 out of range for int32`
+	if got := tests[0].want.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestIntegerPrefixes(t *testing.T) {
+	tests := []struct {
+		column string
+		cell   string
+		want   *pb.Simple
+	}{
+		{"oInt32", "0x1F", &pb.Simple{OInt32: proto.Int32(31)}},
+		{"oInt32", "-0X1f", &pb.Simple{OInt32: proto.Int32(-31)}},
+		{"oInt32", "0o17", &pb.Simple{OInt32: proto.Int32(15)}},
+		{"oInt32", "017", &pb.Simple{OInt32: proto.Int32(17)}},
+		{"oInt32", `"0b101"`, &pb.Simple{OInt32: proto.Int32(5)}},
+		{"oUint64", "0xFFFFFFFFFFFFFFFF", &pb.Simple{OUint64: proto.Uint64(18446744073709551615)}},
+		{"oInt32", "0x", nil},
+		{"oInt32", "0x-5", nil},
+		{"oInt32", "0o8", nil},
+		{"oInt32", "0x80000000", nil},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{Header: []string{tt.column}, IntegerPrefixes: true}
+		got := new(pb.Simple)
+		err := u.UnmarshalRecord([]string{tt.cell}, got)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s %q: got %v, expected an error", tt.column, tt.cell, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: %v", tt.column, tt.cell, err)
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("%s %q: got %v, expected %v", tt.column, tt.cell, got, tt.want)
+		}
+	}
+
+	// Opt-in only
+	u := &Unmarshaler{Header: []string{"oInt32"}}
+	if err := u.UnmarshalRecord([]string{"0x1F"}, new(pb.Simple)); err == nil {
+		t.Error("accepted 0x1F without IntegerPrefixes")
+	}
+	// Saturating applies to prefixed integers too
+	u = &Unmarshaler{Header: []string{"oInt32"}, IntegerPrefixes: true, IntegerRange: RangeSaturate}
+	got := new(pb.Simple)
+	if err := u.UnmarshalRecord([]string{"0x80000000"}, got); err != nil || got.GetOInt32() != 2147483647 {
+		t.Errorf("saturated 0x80000000: got %v, %v", got, err)
+	}
+}
+
+func TestDigitSeparators(t *testing.T) {
+	tests := []struct {
+		column string
+		cell   string
+		want   *pb.Simple
+	}{
+		{"oInt32", "1_000_000", &pb.Simple{OInt32: proto.Int32(1000000)}},
+		{"oInt64", "-9_000", &pb.Simple{OInt64: proto.Int64(-9000)}},
+		{"oUint32", "4_2", &pb.Simple{OUint32: proto.Uint32(42)}},
+		{"oDouble", "1_234.567_8", &pb.Simple{ODouble: proto.Float64(1234.5678)}},
+		{"oDouble", "1e1_0", &pb.Simple{ODouble: proto.Float64(1e10)}},
+		{"oInt32", "_1", nil},
+		{"oInt32", "1_", nil},
+		{"oInt32", "1__0", nil},
+		{"oDouble", "1_.5", nil},
+		{"oDouble", "1e_5", nil},
+		{"oInt32", "0x1F_FF", nil},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{Header: []string{tt.column}, DigitSeparators: true}
+		got := new(pb.Simple)
+		err := u.UnmarshalRecord([]string{tt.cell}, got)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s %q: got %v, expected an error", tt.column, tt.cell, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: %v", tt.column, tt.cell, err)
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("%s %q: got %v, expected %v", tt.column, tt.cell, got, tt.want)
+		}
+	}
+
+	// Hexadecimal digits with IntegerPrefixes
+	u := &Unmarshaler{Header: []string{"oInt32"}, DigitSeparators: true, IntegerPrefixes: true}
+	got := new(pb.Simple)
+	if err := u.UnmarshalRecord([]string{"0x1F_FF"}, got); err != nil || got.GetOInt32() != 0x1FFF {
+		t.Errorf("0x1F_FF: got %v, %v", got, err)
+	}
+	// Opt-in only
+	u = &Unmarshaler{Header: []string{"oInt32"}}
+	if err := u.UnmarshalRecord([]string{"1_000"}, new(pb.Simple)); err == nil {
+		t.Error("accepted 1_000 without DigitSeparators")
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		policy PercentPolicy
+		column string
+		cell   string
+		want   *pb.Simple
+	}{
+		{PercentFraction, "oDouble", "45%", &pb.Simple{ODouble: proto.Float64(0.45)}},
+		{PercentFraction, "oDouble", "-2.5 %", &pb.Simple{ODouble: proto.Float64(-0.025)}},
+		{PercentFraction, "oFloat", `"150%"`, &pb.Simple{OFloat: proto.Float32(1.5)}},
+		{PercentFraction, "oDouble", "0.5", &pb.Simple{ODouble: proto.Float64(0.5)}},
+		{PercentNumber, "oDouble", "45%", &pb.Simple{ODouble: proto.Float64(45)}},
+		{PercentFraction, "oDouble", "%", nil},
+		{PercentFraction, "oDouble", "45%%", nil},
+		{PercentFraction, "oInt32", "45%", nil},
+		{PercentFail, "oDouble", "45%", nil},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{Header: []string{tt.column}, Percent: tt.policy}
+		got := new(pb.Simple)
+		err := u.UnmarshalRecord([]string{tt.cell}, got)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s %q: got %v, expected an error", tt.column, tt.cell, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: %v", tt.column, tt.cell, err)
+			continue
+		}
+		if !proto.Equal(got, tt.want) {
+			t.Errorf("%s %q: got %v, expected %v", tt.column, tt.cell, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Timestamps range from 0001-01-01T00:00:00Z to 9999-12-31T23:59:59.999999999Z,
+// as the protobuf spec requires.
+const (
+	minTimestampSeconds = -62135596800
+	maxTimestampSeconds = 253402300799
+)
+
+// TimestampRangeError describes a Timestamp outside the range of
+// 0001-01-01 to 9999-12-31.
+type TimestampRangeError struct {
+	// Field is the original name of the field, if known.
+	Field string
+	// Seconds are the seconds of the Timestamp since the Unix epoch.
+	Seconds int64
+}
+
+func (e *TimestampRangeError) Error() string {
+	t := time.Unix(e.Seconds, 0).UTC().Format(time.RFC3339)
+	if e.Field == "" {
+		return fmt.Sprintf("Timestamp %s out of range", t)
+	}
+	return fmt.Sprintf("field %q: Timestamp %s out of range", e.Field, t)
+}
+
+// checkTimestamp fails with a *TimestampRangeError, should s seconds be
+// out of the range of Timestamps. prop may be nil.
+func checkTimestamp(prop *proto.Properties, s int64) error {
+	if s >= minTimestampSeconds && s <= maxTimestampSeconds {
+		return nil
+	}
+	e := &TimestampRangeError{Seconds: s}
+	if prop != nil {
+		e.Field = prop.OrigName
+	}
+	return e
+}
+
+// timestampParts returns the seconds and nanos of t, which was parsed from
+// cell for the field with prop. Times out of the range of Timestamps fail
+// or are clamped, depending on ClampTimestamps.
+func (u *Unmarshaler) timestampParts(prop *proto.Properties, cell string, t time.Time) (int64, int64, error) {
+	s, ns := t.Unix(), int64(t.Nanosecond())
+	err := checkTimestamp(prop, s)
+	if err == nil {
+		return s, ns, nil
+	}
+	if !u.ClampTimestamps {
+		return 0, 0, err
+	}
+	if s < minTimestampSeconds {
+		s, ns = minTimestampSeconds, 0
+	} else {
+		s, ns = maxTimestampSeconds, secondInNanos-1
+	}
+	u.warn(prop, cell, "clamped to "+time.Unix(s, ns).UTC().Format(time.RFC3339Nano))
+	return s, ns, nil
+}
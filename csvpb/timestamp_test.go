@@ -0,0 +1,90 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
+)
+
+func TestTimestampRange(t *testing.T) {
+	tests := []struct {
+		cell  string
+		clamp bool
+		want  *tspb.Timestamp
+	}{
+		{"0001-01-01T00:00:00Z", false, &tspb.Timestamp{Seconds: minTimestampSeconds}},
+		{"9999-12-31T23:59:59.999999999Z", false, &tspb.Timestamp{Seconds: maxTimestampSeconds, Nanos: 999999999}},
+		{"0001-01-01T00:00:00+01:00", false, nil},
+		{"9999-12-31T23:59:59-01:00", false, nil},
+		{"0001-01-01T00:00:00+01:00", true, &tspb.Timestamp{Seconds: minTimestampSeconds}},
+		{"9999-12-31T23:59:59-01:00", true, &tspb.Timestamp{Seconds: maxTimestampSeconds, Nanos: 999999999}},
+	}
+	for _, tt := range tests {
+		var warnings []string
+		u := &Unmarshaler{
+			Header:          []string{"ts"},
+			ClampTimestamps: tt.clamp,
+			Warn: func(prop *proto.Properties, cell, reason string) {
+				warnings = append(warnings, reason)
+			},
+		}
+		got := new(pb.KnownTypes)
+		err := u.UnmarshalRecord([]string{tt.cell}, got)
+		if tt.want == nil {
+			if re, ok := err.(*TimestampRangeError); !ok || re.Field != "ts" {
+				t.Errorf("%q: got %v, expected a TimestampRangeError", tt.cell, err)
+			}
+			continue
+		}
+		if err != nil || !proto.Equal(got.Ts, tt.want) {
+			t.Errorf("%q: got %v, %v, want %v", tt.cell, got.Ts, err, tt.want)
+		}
+		if tt.clamp && len(warnings) != 1 {
+			t.Errorf("%q: got warnings %q", tt.cell, warnings)
+		}
+	}
+}
+
+func TestMarshalTimestampRange(t *testing.T) {
+	var buf strings.Builder
+	err := new(Marshaler).Marshal(&buf, &pb.KnownTypes{Ts: &tspb.Timestamp{Seconds: maxTimestampSeconds + 1}})
+	want := `column "ts": field "ts": Timestamp 10000-01-01T00:00:00Z out of range`
+	if err == nil || err.Error() != want {
+		t.Errorf("got %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,230 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// OutputColumn is a column written by Transform.
+type OutputColumn struct {
+	// Column is the name of the column in the header of the message, or a
+	// Passthrough column.
+	Column string
+	// Name is the name written in the header, Column if empty.
+	Name string
+}
+
+// TransformOptions configures Transform.
+type TransformOptions struct {
+	// Unmarshaler reads the input. Should it be nil or its Header be nil,
+	// the first record is used as header.
+	Unmarshaler *Unmarshaler
+
+	// Marshaler writes the output.
+	Marshaler *Marshaler
+
+	// Passthrough names columns copied verbatim from every input record to
+	// the output, after the columns of the message. They are not
+	// unmarshaled, so they may be unknown to the message.
+	Passthrough []string
+
+	// Output, if set, lists the columns written, in order, by the columns
+	// of the message and Passthrough. Other columns are dropped.
+	Output []OutputColumn
+
+	// Func rewrites every decoded message before it is written. Should it
+	// return nil, the row is dropped. Messages are written unchanged if
+	// Func is nil.
+	Func func(proto.Message) (proto.Message, error)
+}
+
+// Transform converts the CSV read from r into messages created by factory,
+// passes them through opts.Func and writes them as CSV to w. The header is
+// taken from the first message written, so Func may return messages of
+// another type than factory. Nothing is written for input without
+// records. opts may be nil. Input missing a Passthrough column fails with
+// CategoryParse. An Output column neither of the message nor
+// of Passthrough fails once the first message is written.
+// The returned Summary is never nil, even if an error occurs.
+func Transform(r io.Reader, w io.Writer, factory func() proto.Message, opts *TransformOptions) (*Summary, error) {
+	if opts == nil {
+		opts = &TransformOptions{}
+	}
+	u := opts.Unmarshaler
+	if u == nil {
+		u = &Unmarshaler{}
+	}
+	m := opts.Marshaler
+	if m == nil {
+		m = &Marshaler{}
+	}
+
+	decode := factoryDecoder(factory)
+	var passthrough []string
+	if len(opts.Passthrough) > 0 {
+		decode = passthroughDecoder(opts.Passthrough, decode, &passthrough)
+	}
+
+	dec := u.newDecoder(r)
+	defer dec.Release()
+	s := &Summary{
+		Errors: make(map[ErrorCategory]int),
+	}
+	enc := m.newEncoder(w)
+	var layout []int
+	first := true
+	err := u.eachDecoded(dec, s, decode, func(pb proto.Message) error {
+		if opts.Func != nil {
+			var err error
+			if pb, err = opts.Func(pb); err != nil || pb == nil {
+				return err
+			}
+		}
+		record, err := m.MarshalRecord(pb)
+		if err != nil {
+			return err
+		}
+		record = append(record, passthrough...)
+		if first {
+			first = false
+			header, err := m.Header(pb)
+			if err != nil {
+				return err
+			}
+			header = append(header, opts.Passthrough...)
+			if layout, header, err = outputLayout(header, opts.Output); err != nil {
+				return err
+			}
+			if !m.OmitHeader {
+				if err := m.encodeHeader(enc, header); err != nil {
+					return err
+				}
+			}
+		}
+		if opts.Output == nil {
+			return enc.Encode(record)
+		}
+		out := make([]string, len(layout))
+		for i, c := range layout {
+			if c >= len(record) {
+				return fmt.Errorf("%s has no column %q", proto.MessageName(pb), opts.Output[i].Column)
+			}
+			out[i] = record[c]
+		}
+		return enc.Encode(out)
+	})
+	s.BytesConsumed = dec.InputOffset()
+	if err != nil {
+		return s, err
+	}
+	return s, enc.Flush()
+}
+
+// passthroughDecoder unmarshals records by decode without the columns
+// named by passthrough, whose cells it stores in cells.
+func passthroughDecoder(passthrough []string, decode recordDecoder, cells *[]string) recordDecoder {
+	var indices []int
+	var skip map[int]bool
+	var inner Unmarshaler
+	return func(uc *Unmarshaler, record []string) (proto.Message, ErrorCategory, error) {
+		if skip == nil {
+			skip = make(map[int]bool, len(passthrough))
+			for _, column := range passthrough {
+				i := indexOf(uc.Header, column)
+				if i < 0 {
+					skip = nil
+					return nil, CategoryParse, fmt.Errorf("missing passthrough column %q", column)
+				}
+				indices = append(indices, i)
+				skip[i] = true
+			}
+			inner = *uc
+			inner.Header = nil
+			for i, column := range uc.Header {
+				if !skip[i] {
+					inner.Header = append(inner.Header, column)
+				}
+			}
+		}
+		if len(record) != len(uc.Header) {
+			return nil, CategoryParse, csv.ErrFieldCount
+		}
+
+		*cells = make([]string, len(indices))
+		for i, c := range indices {
+			(*cells)[i] = record[c]
+		}
+		rest := make([]string, 0, len(inner.Header))
+		for i, cell := range record {
+			if !skip[i] {
+				rest = append(rest, cell)
+			}
+		}
+		return decode(&inner, rest)
+	}
+}
+
+// outputLayout returns the indices into records of header of the columns
+// of output and the header they are written with, header itself if output
+// is nil.
+func outputLayout(header []string, output []OutputColumn) ([]int, []string, error) {
+	if output == nil {
+		return nil, header, nil
+	}
+	layout := make([]int, len(output))
+	names := make([]string, len(output))
+	for i, c := range output {
+		if layout[i] = indexOf(header, c.Column); layout[i] < 0 {
+			return nil, nil, fmt.Errorf("unknown output column %q", c.Column)
+		}
+		names[i] = c.Name
+		if names[i] == "" {
+			names[i] = c.Column
+		}
+	}
+	return layout, names, nil
+}
+
+// indexOf returns the index of the first s in ss, or -1.
+func indexOf(ss []string, s string) int {
+	for i := range ss {
+		if ss[i] == s {
+			return i
+		}
+	}
+	return -1
+}
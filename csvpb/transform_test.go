@@ -0,0 +1,120 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestTransform(t *testing.T) {
+	double := func(m proto.Message) (proto.Message, error) {
+		s := m.(*pb.Simple3)
+		if s.Dub < 0 {
+			return nil, nil
+		}
+		return &pb.Simple3{Dub: s.Dub * 2}, nil
+	}
+	tests := []struct {
+		desc string
+		in   string
+		opts *TransformOptions
+		out  string
+	}{
+		{"copy", "dub\n1.5\n2\n", nil, "dub\n1.5\n2\n"},
+		{"func", "dub\n1.5\n-1\n2\n", &TransformOptions{Func: double}, "dub\n3\n4\n"},
+		{"header", "1.5\n", &TransformOptions{Unmarshaler: &Unmarshaler{Header: []string{"dub"}}}, "dub\n1.5\n"},
+		{"empty", "dub\n", nil, ""},
+		{"passthrough", "note,dub,id\nx,1.5,1\n\"a,b\",-1,2\n", &TransformOptions{Func: double, Passthrough: []string{"id", "note"}},
+			"dub,id,note\n3,1,x\n"},
+		{"passthrough header", "1,x\n", &TransformOptions{
+			Unmarshaler: &Unmarshaler{Header: []string{"dub", "note"}},
+			Passthrough: []string{"note"},
+		}, "dub,note\n1,x\n"},
+		{"output", "note,dub\nx,1.5\n", &TransformOptions{
+			Passthrough: []string{"note"},
+			Output:      []OutputColumn{{Column: "note", Name: "Note"}, {Column: "dub"}},
+		}, "Note,dub\nx,1.5\n"},
+	}
+	for _, tt := range tests {
+		var buf strings.Builder
+		_, err := Transform(strings.NewReader(tt.in), &buf, func() proto.Message { return new(pb.Simple3) }, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if got := buf.String(); got != tt.out {
+			t.Errorf("%s: got %q, expected %q", tt.desc, got, tt.out)
+		}
+	}
+}
+
+func TestTransformUnknownOutputColumn(t *testing.T) {
+	opts := &TransformOptions{Output: []OutputColumn{{Column: "note"}}}
+	_, err := Transform(strings.NewReader("dub\n1\n"), ioutil.Discard, func() proto.Message { return new(pb.Simple3) }, opts)
+	if err == nil || err.Error() != `unknown output column "note"` {
+		t.Errorf("got %v, expected an unknown output column", err)
+	}
+}
+
+func TestTransformMissingPassthrough(t *testing.T) {
+	opts := &TransformOptions{Passthrough: []string{"note"}}
+	_, err := Transform(strings.NewReader("dub\n1\n"), ioutil.Discard, func() proto.Message { return new(pb.Simple3) }, opts)
+	if re, ok := err.(*RowError); !ok || re.Category != CategoryParse {
+		t.Errorf("got %v, expected a parse error", err)
+	}
+}
+
+func TestTransformError(t *testing.T) {
+	errBroken := errors.New("broken")
+	opts := &TransformOptions{Func: func(proto.Message) (proto.Message, error) {
+		return nil, errBroken
+	}}
+	s, err := Transform(strings.NewReader("dub\n1\n2\n"), &strings.Builder{}, func() proto.Message { return new(pb.Simple3) }, opts)
+	if err != errBroken {
+		t.Errorf("got %v, expected %v", err, errBroken)
+	}
+	if s.RowsDecoded != 1 {
+		t.Errorf("got %d rows decoded, expected 1", s.RowsDecoded)
+	}
+
+	_, err = Transform(strings.NewReader("dub\nx\n"), &strings.Builder{}, func() proto.Message { return new(pb.Simple3) }, nil)
+	if _, ok := err.(*RowError); !ok {
+		t.Errorf("got %v, expected a RowError", err)
+	}
+}
@@ -0,0 +1,219 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"io"
+	"unsafe"
+)
+
+// transientReader is the RecordReader of UnmarshalEachTransient, parsing
+// CSV like a default csv.Reader. The cells of a record reference a buffer
+// overwritten by the record after next, instead of being copied into a
+// string of their own. As Decoder reads a record ahead, that is once the
+// record following them is decoded.
+type transientReader struct {
+	r *bufio.Reader
+	// n is the number of records read, selecting the buffers of the next.
+	n       int
+	buffers [2][]byte
+	records [2][]string
+	ends    []int
+	// raw holds a line exceeding the buffer of r.
+	raw     []byte
+	line    int
+	nFields int
+}
+
+func newTransientReader(r io.Reader) RecordReader {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &transientReader{r: br}
+}
+
+// readLine reads the next line, like csv.Reader, with \r\n normalized to
+// \n. It is only valid until the next call.
+func (t *transientReader) readLine() ([]byte, error) {
+	line, err := t.r.ReadSlice('\n')
+	if err == bufio.ErrBufferFull {
+		t.raw = append(t.raw[:0], line...)
+		for err == bufio.ErrBufferFull {
+			line, err = t.r.ReadSlice('\n')
+			t.raw = append(t.raw, line...)
+		}
+		line = t.raw
+	}
+	if len(line) > 0 && err == io.EOF {
+		err = nil
+		if line[len(line)-1] == '\r' {
+			line = line[:len(line)-1]
+		}
+	}
+	t.line++
+	if n := len(line); n >= 2 && line[n-2] == '\r' && line[n-1] == '\n' {
+		line[n-2] = '\n'
+		line = line[:n-1]
+	}
+	return line, err
+}
+
+func lengthNL(b []byte) int {
+	if len(b) > 0 && b[len(b)-1] == '\n' {
+		return 1
+	}
+	return 0
+}
+
+// Read returns the next record, failing like csv.Reader.
+func (t *transientReader) Read() ([]string, error) {
+	var line []byte
+	var errRead error
+	for errRead == nil {
+		line, errRead = t.readLine()
+		if errRead == nil && len(line) == lengthNL(line) {
+			// Skip empty lines
+			continue
+		}
+		break
+	}
+	if errRead == io.EOF {
+		return nil, errRead
+	}
+
+	var err error
+	recLine, col := t.line, 1
+	buf := t.buffers[t.n%2][:0]
+	t.ends = t.ends[:0]
+parseField:
+	for {
+		if len(line) == 0 || line[0] != '"' {
+			i := bytes.IndexByte(line, ',')
+			field := line
+			if i >= 0 {
+				field = field[:i]
+			} else {
+				field = field[:len(field)-lengthNL(field)]
+			}
+			if j := bytes.IndexByte(field, '"'); j >= 0 {
+				err = &csv.ParseError{StartLine: recLine, Line: t.line, Column: col + j, Err: csv.ErrBareQuote}
+				break parseField
+			}
+			buf = append(buf, field...)
+			t.ends = append(t.ends, len(buf))
+			if i < 0 {
+				break parseField
+			}
+			line = line[i+1:]
+			col += i + 1
+			continue parseField
+		}
+		line = line[1:]
+		col++
+		for {
+			i := bytes.IndexByte(line, '"')
+			switch {
+			case i >= 0:
+				buf = append(buf, line[:i]...)
+				line = line[i+1:]
+				col += i + 1
+				switch {
+				case len(line) > 0 && line[0] == '"':
+					buf = append(buf, '"')
+					line = line[1:]
+					col++
+				case len(line) > 0 && line[0] == ',':
+					line = line[1:]
+					col++
+					t.ends = append(t.ends, len(buf))
+					continue parseField
+				case lengthNL(line) == len(line):
+					t.ends = append(t.ends, len(buf))
+					break parseField
+				default:
+					err = &csv.ParseError{StartLine: recLine, Line: t.line, Column: col - 1, Err: csv.ErrQuote}
+					break parseField
+				}
+			case len(line) > 0:
+				// The field continues on the next line
+				buf = append(buf, line...)
+				if errRead != nil {
+					break parseField
+				}
+				col += len(line)
+				line, errRead = t.readLine()
+				if len(line) > 0 {
+					col = 1
+				}
+				if errRead == io.EOF {
+					errRead = nil
+				}
+			default:
+				if errRead == nil {
+					err = &csv.ParseError{StartLine: recLine, Line: t.line, Column: col, Err: csv.ErrQuote}
+					break parseField
+				}
+				t.ends = append(t.ends, len(buf))
+				break parseField
+			}
+		}
+	}
+	if err == nil {
+		err = errRead
+	}
+
+	// The cells are slices of a single string sharing the memory of buf
+	t.buffers[t.n%2] = buf
+	var s string
+	if len(buf) > 0 {
+		s = *(*string)(unsafe.Pointer(&buf))
+	}
+	record := t.records[t.n%2][:0]
+	start := 0
+	for _, end := range t.ends {
+		record = append(record, s[start:end])
+		start = end
+	}
+	t.records[t.n%2] = record
+	t.n++
+
+	if t.n == 1 {
+		t.nFields = len(record)
+	} else if len(record) != t.nFields && err == nil {
+		err = &csv.ParseError{StartLine: recLine, Line: recLine, Column: 1, Err: csv.ErrFieldCount}
+	}
+	return record, err
+}
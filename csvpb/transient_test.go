@@ -0,0 +1,83 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"encoding/csv"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestTransientReader compares transientReader to csv.Reader.
+func TestTransientReader(t *testing.T) {
+	for _, input := range []string{
+		"",
+		"a,b\n1,2\n",
+		"a,b\r\n1,2\r\n\r\n\n3,4",
+		"a,b\n1,2\r",
+		`a,"b ""c"", d",e` + "\n" + `"",""` + "\n",
+		"a,\"multi\nline\r\nfield\"\n",
+		"a,b\n1,2,3\n",
+		"a,b\"c\n",
+		"a,\"b\"c\n",
+		"a,\"b\n",
+		",\n,\n",
+		"a," + strings.Repeat("x", 10000) + "\n",
+	} {
+		want := csv.NewReader(strings.NewReader(input))
+		got := newTransientReader(strings.NewReader(input))
+		for i := 0; ; i++ {
+			wr, werr := want.Read()
+			gr, gerr := got.Read()
+			if !reflect.DeepEqual(gr, wr) && len(gr)+len(wr) > 0 {
+				t.Errorf("%q: record %d = %q, want %q", input, i, gr, wr)
+			}
+			if (gerr == nil) != (werr == nil) {
+				t.Fatalf("%q: record %d: got error %v, want %v", input, i, gerr, werr)
+			}
+			if werr != nil {
+				if pe, ok := werr.(*csv.ParseError); ok {
+					if ge, _ := gerr.(*csv.ParseError); ge == nil || ge.Err != pe.Err {
+						t.Errorf("%q: record %d: got error %v, want %v", input, i, gerr, werr)
+					}
+				} else if gerr != werr {
+					t.Errorf("%q: record %d: got error %v, want %v", input, i, gerr, werr)
+				}
+				if werr == io.EOF || i > 10 {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+var errNegative = errors.New("negative o_int32")
+
+func validatePositive(m proto.Message) error {
+	if m.(*pb.Simple).GetOInt32() < 0 {
+		return errNegative
+	}
+	return nil
+}
+
+func TestUnmarshalRecordValidator(t *testing.T) {
+	u := &Unmarshaler{Header: []string{"oInt32"}, Validator: ValidatorFunc(validatePositive)}
+	if err := u.UnmarshalRecord([]string{"1"}, new(pb.Simple)); err != nil {
+		t.Errorf("valid record: %v", err)
+	}
+	if err := u.UnmarshalRecord([]string{"-1"}, new(pb.Simple)); err != errNegative {
+		t.Errorf("invalid record: got %v, expected %v", err, errNegative)
+	}
+}
+
+func TestUnmarshalAllValidator(t *testing.T) {
+	input := "oInt32\n1\n-2\n3\n"
+	u := &Unmarshaler{Validator: ValidatorFunc(validatePositive)}
+	_, s, err := u.UnmarshalAll(strings.NewReader(input), newSimple)
+	re, ok := err.(*RowError)
+	if !ok || re.Row != 3 || re.Category != CategoryValidation || re.Err != errNegative {
+		t.Fatalf("got %v, expected a validation error in row 3", err)
+	}
+	if s.Errors[CategoryValidation] != 1 {
+		t.Errorf("got errors %v", s.Errors)
+	}
+
+	u.SkipInvalidRows = true
+	pbs, s, err := u.UnmarshalAll(strings.NewReader(input), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pbs) != 2 || s.RowsSkipped != 1 || s.Errors[CategoryValidation] != 1 {
+		t.Errorf("got %d messages, summary %+v", len(pbs), s)
+	}
+}
@@ -0,0 +1,148 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"strconv"
+	"strings"
+
+	stpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+// ValueDetector interprets a cell of a google.protobuf.Value field, which
+// carries no type. Returns nil if it does not apply to the cell.
+type ValueDetector func(cell string) *stpb.Value
+
+// DetectNull interprets empty cells as null.
+func DetectNull(cell string) *stpb.Value {
+	if cell != "" {
+		return nil
+	}
+	return &stpb.Value{Kind: &stpb.Value_NullValue{}}
+}
+
+// DetectDecimal interprets numbers with a decimal point as numbers.
+func DetectDecimal(cell string) *stpb.Value {
+	if !strings.Contains(cell, ".") {
+		return nil
+	}
+	return DetectNumber(cell)
+}
+
+// maxExactInteger is the largest magnitude of the integers a float64 holds
+// exactly.
+const maxExactInteger = 1 << 53
+
+// isInteger reports whether cell is a decimal integer with an optional sign.
+func isInteger(cell string) bool {
+	if cell != "" && (cell[0] == '-' || cell[0] == '+') {
+		cell = cell[1:]
+	}
+	if cell == "" {
+		return false
+	}
+	for i := 0; i < len(cell); i++ {
+		if cell[i] < '0' || cell[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// DetectInteger interprets decimal integers a number holds exactly, up to
+// 2^53 in magnitude, as numbers. Larger ones are left to other detectors.
+func DetectInteger(cell string) *stpb.Value {
+	if !isInteger(cell) {
+		return nil
+	}
+	v, err := strconv.ParseInt(cell, 10, 64)
+	if err != nil || v > maxExactInteger || v < -maxExactInteger {
+		return nil
+	}
+	return &stpb.Value{Kind: &stpb.Value_NumberValue{NumberValue: float64(v)}}
+}
+
+// DetectLongInteger interprets decimal integers a number does not hold
+// exactly, beyond 2^53 in magnitude, as strings, preserving every digit.
+func DetectLongInteger(cell string) *stpb.Value {
+	if !isInteger(cell) || DetectInteger(cell) != nil {
+		return nil
+	}
+	return &stpb.Value{Kind: &stpb.Value_StringValue{StringValue: cell}}
+}
+
+// DetectBool interprets the words of strconv.ParseBool as bools. The
+// digits and letters it accepts are not, being ambiguous.
+func DetectBool(cell string) *stpb.Value {
+	switch cell {
+	case "1", "t", "T", "0", "f", "F":
+		return nil
+	}
+	v, err := strconv.ParseBool(cell)
+	if err != nil {
+		return nil
+	}
+	return &stpb.Value{Kind: &stpb.Value_BoolValue{BoolValue: v}}
+}
+
+// DetectNumber interprets anything strconv.ParseFloat accepts as a number.
+func DetectNumber(cell string) *stpb.Value {
+	v, err := strconv.ParseFloat(cell, 64)
+	if err != nil {
+		return nil
+	}
+	return &stpb.Value{Kind: &stpb.Value_NumberValue{NumberValue: v}}
+}
+
+// DefaultValueDetectors are the detectors of an Unmarshaler without
+// ValueDetectors.
+var DefaultValueDetectors = []ValueDetector{DetectNull, DetectInteger, DetectDecimal, DetectBool, DetectNumber}
+
+// PreciseValueDetectors are DefaultValueDetectors keeping integers a number
+// does not hold exactly as strings, rather than rounding them.
+var PreciseValueDetectors = []ValueDetector{DetectNull, DetectInteger, DetectLongInteger, DetectDecimal, DetectBool, DetectNumber}
+
+// detectValue interprets cell with the first detector to apply.
+func (u *Unmarshaler) detectValue(cell string) *stpb.Value {
+	detectors := u.ValueDetectors
+	if detectors == nil {
+		detectors = DefaultValueDetectors
+	}
+	for _, detect := range detectors {
+		if v := detect(cell); v != nil {
+			return v
+		}
+	}
+	// There is no good way to detect signedness so default to plain
+	// string for anything else
+	return &stpb.Value{Kind: &stpb.Value_StringValue{StringValue: cell}}
+}
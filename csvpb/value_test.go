@@ -0,0 +1,131 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package csvpb
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	stpb "github.com/golang/protobuf/ptypes/struct"
+)
+
+func numberValue(f float64) *stpb.Value {
+	return &stpb.Value{Kind: &stpb.Value_NumberValue{NumberValue: f}}
+}
+
+func stringValue(s string) *stpb.Value {
+	return &stpb.Value{Kind: &stpb.Value_StringValue{StringValue: s}}
+}
+
+func boolValue(b bool) *stpb.Value {
+	return &stpb.Value{Kind: &stpb.Value_BoolValue{BoolValue: b}}
+}
+
+func TestValueDetectors(t *testing.T) {
+	nullValue := &stpb.Value{Kind: &stpb.Value_NullValue{}}
+	zipCodes := []ValueDetector{DetectNull, DetectBool}
+	custom := []ValueDetector{func(cell string) *stpb.Value {
+		if cell == "-" {
+			return &stpb.Value{}
+		}
+		return nil
+	}}
+
+	tests := []struct {
+		detectors []ValueDetector
+		cell      string
+		want      *stpb.Value
+	}{
+		{nil, "", nullValue},
+		{nil, "1.5", numberValue(1.5)},
+		{nil, "42", numberValue(42)},
+		{nil, "true", boolValue(true)},
+		{nil, "FALSE", boolValue(false)},
+		{nil, "t", stringValue("t")},
+		{nil, "1", numberValue(1)},
+		{nil, "v1.2", stringValue("v1.2")},
+		{zipCodes, "01234", stringValue("01234")},
+		{zipCodes, "true", boolValue(true)},
+		{zipCodes, "", nullValue},
+		{custom, "-", &stpb.Value{}},
+		{custom, "", stringValue("")},
+		{[]ValueDetector{}, "1", stringValue("1")},
+		{nil, "9007199254740993", numberValue(9007199254740992)},
+		{PreciseValueDetectors, "9007199254740993", stringValue("9007199254740993")},
+		{PreciseValueDetectors, "42", numberValue(42)},
+		{PreciseValueDetectors, "1e3", numberValue(1000)},
+	}
+	for _, tt := range tests {
+		u := &Unmarshaler{Header: []string{"val"}, ValueDetectors: tt.detectors}
+		got := new(pb.KnownTypes)
+		if err := u.UnmarshalRecord([]string{tt.cell}, got); err != nil {
+			t.Errorf("UnmarshalRecord(%q) error = %v", tt.cell, err)
+			continue
+		}
+		if !proto.Equal(got.Val, tt.want) {
+			t.Errorf("UnmarshalRecord(%q) with %d detectors = %v, want %v", tt.cell, len(tt.detectors), got.Val, tt.want)
+		}
+	}
+}
+
+func TestDetectors(t *testing.T) {
+	tests := []struct {
+		name   string
+		detect ValueDetector
+		cell   string
+		want   *stpb.Value
+	}{
+		{"DetectNull", DetectNull, "x", nil},
+		{"DetectDecimal", DetectDecimal, "12", nil},
+		{"DetectDecimal", DetectDecimal, "1.", numberValue(1)},
+		{"DetectInteger", DetectInteger, "42", numberValue(42)},
+		{"DetectInteger", DetectInteger, "-9007199254740992", numberValue(-9007199254740992)},
+		{"DetectInteger", DetectInteger, "9007199254740993", nil},
+		{"DetectInteger", DetectInteger, "1e3", nil},
+		{"DetectInteger", DetectInteger, "-", nil},
+		{"DetectLongInteger", DetectLongInteger, "42", nil},
+		{"DetectLongInteger", DetectLongInteger, "9007199254740993", stringValue("9007199254740993")},
+		{"DetectLongInteger", DetectLongInteger, "-123456789012345678901234", stringValue("-123456789012345678901234")},
+		{"DetectLongInteger", DetectLongInteger, "1.5", nil},
+		{"DetectBool", DetectBool, "0", nil},
+		{"DetectBool", DetectBool, "True", boolValue(true)},
+		{"DetectNumber", DetectNumber, "1e3", numberValue(1000)},
+		{"DetectNumber", DetectNumber, "x", nil},
+	}
+	for _, tt := range tests {
+		if got := tt.detect(tt.cell); !proto.Equal(got, tt.want) {
+			t.Errorf("%s(%q) = %v, want %v", tt.name, tt.cell, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package fixedpb reads protocol buffers from and writes protocol buffers to
+fixed-width flat files, as used by mainframe and banking feeds.
+
+A Layout maps byte ranges of every record to columns, which are converted
+the way csvpb converts the cells of a CSV record. Surrounding spaces are
+ignored, with a blank range being null. So is the Pad of right-aligned
+values.
+*/
+package fixedpb
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Field is a range of bytes of a record holding a column.
+type Field struct {
+	// Name of the column, matching a field like a CSV header does.
+	Name string
+
+	// Offset of the first byte of the range, starting with 0.
+	Offset int
+
+	// Width of the range in bytes.
+	Width int
+
+	// Whether the value is right-aligned, padded with Pad to the left.
+	// Values are left-aligned, padded with spaces to the right, otherwise.
+	RightAlign bool
+
+	// Pad is the byte padding right-aligned values, a space if zero.
+	// Should it be '0', padding follows any sign. Reading a value strips
+	// the padding, so the value should not start with Pad itself, except
+	// for '0' preceding numbers.
+	Pad byte
+}
+
+// Layout describes the records of a fixed-width file.
+type Layout struct {
+	Fields []Field
+
+	// Whether records are exactly Length bytes without any line
+	// terminator, as opposed to being lines.
+	Unterminated bool
+}
+
+// Length returns the number of bytes of a record, without any line
+// terminator.
+func (l *Layout) Length() int {
+	n := 0
+	for _, f := range l.Fields {
+		if end := f.Offset + f.Width; end > n {
+			n = end
+		}
+	}
+	return n
+}
+
+// header returns the names of the fields.
+func (l *Layout) header() []string {
+	header := make([]string, len(l.Fields))
+	for i, f := range l.Fields {
+		header[i] = f.Name
+	}
+	return header
+}
+
+// validate checks that the fields have distinct names and do not overlap.
+func (l *Layout) validate() error {
+	if len(l.Fields) == 0 {
+		return errors.New("fixedpb: layout without fields")
+	}
+	fields := make([]Field, len(l.Fields))
+	copy(fields, l.Fields)
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Offset < fields[j].Offset })
+	names := make(map[string]bool)
+	for i, f := range fields {
+		if f.Offset < 0 || f.Width <= 0 {
+			return fmt.Errorf("fixedpb: field %q has invalid range %d+%d", f.Name, f.Offset, f.Width)
+		}
+		if i > 0 && fields[i-1].Offset+fields[i-1].Width > f.Offset {
+			return fmt.Errorf("fixedpb: fields %q and %q overlap", fields[i-1].Name, f.Name)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("fixedpb: duplicate field %q", f.Name)
+		}
+		names[f.Name] = true
+	}
+	return nil
+}
+
+// Unmarshaler is a configurable object for converting fixed-width records
+// into protocol buffers.
+type Unmarshaler struct {
+	Layout Layout
+
+	// Unmarshaler converts records into messages. Its Header is ignored,
+	// the names of the fields of Layout being the header.
+	Unmarshaler csvpb.Unmarshaler
+}
+
+// UnmarshalRecord populates pb from a single record. Bytes missing at the
+// end of the record are treated as spaces.
+// pb is reset before being populated.
+func (u *Unmarshaler) UnmarshalRecord(record []byte, pb proto.Message) error {
+	if err := u.Layout.validate(); err != nil {
+		return err
+	}
+	cu := u.Unmarshaler
+	cu.Header = u.Layout.header()
+	return u.unmarshalRecord(&cu, record, pb)
+}
+
+func (u *Unmarshaler) unmarshalRecord(cu *csvpb.Unmarshaler, record []byte, pb proto.Message) error {
+	cells := make([]string, len(u.Layout.Fields))
+	for i, f := range u.Layout.Fields {
+		var b []byte
+		if f.Offset < len(record) {
+			b = record[f.Offset:]
+			if len(b) > f.Width {
+				b = b[:f.Width]
+			}
+		}
+		cells[i] = f.cell(b)
+		if cells[i] == "" && f.RightAlign && f.Pad == '0' && !textField(pb, f.Name) {
+			// The zero padded entirely
+			cells[i] = "0"
+		}
+	}
+	return cu.UnmarshalRecord(cells, pb)
+}
+
+// cell converts the bytes of the range of f into a csvpb cell, stripping
+// the padding of MarshalRecord.
+func (f *Field) cell(b []byte) string {
+	b = bytes.Trim(b, " ")
+	if len(b) == 0 {
+		return "null"
+	}
+	if !f.RightAlign || f.Pad == 0 || f.Pad == ' ' {
+		return string(b)
+	}
+	if f.Pad == '0' && (b[0] == '-' || b[0] == '+') {
+		// Zeros follow the sign
+		digits := bytes.TrimLeft(b[1:], "0")
+		if len(digits) == 0 {
+			return string(b[0]) + "0"
+		}
+		return string(b[0]) + string(digits)
+	}
+	return string(bytes.TrimLeft(b, string(f.Pad)))
+}
+
+// textField tells whether the column name maps to a string or bytes field
+// of pb, whose values may be empty.
+func textField(pb proto.Message, name string) bool {
+	t := reflect.TypeOf(pb).Elem()
+	for i, prop := range proto.GetProperties(t).Prop {
+		if prop.OrigName != name && prop.JSONName != name {
+			continue
+		}
+		ft := t.Field(i).Type
+		if ft.Kind() == reflect.Ptr || ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8 {
+			ft = ft.Elem()
+		}
+		return ft.Kind() == reflect.String || ft.Kind() == reflect.Slice
+	}
+	return false
+}
+
+// UnmarshalEach calls fn with every record of r, converted into a message
+// created by factory.
+func (u *Unmarshaler) UnmarshalEach(r io.Reader, factory func() proto.Message, fn func(proto.Message) error) error {
+	if err := u.Layout.validate(); err != nil {
+		return err
+	}
+	cu := u.Unmarshaler
+	cu.Header = u.Layout.header()
+
+	next := u.lines(bufio.NewReader(r))
+	for n := 1; ; n++ {
+		record, err := next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("fixedpb: record %d: %v", n, err)
+		}
+		pb := factory()
+		if err := u.unmarshalRecord(&cu, record, pb); err != nil {
+			if cu.SkipInvalidRows {
+				continue
+			}
+			return fmt.Errorf("fixedpb: record %d: %v", n, err)
+		}
+		if err := fn(pb); err != nil {
+			return err
+		}
+	}
+}
+
+// lines returns a function returning the next record of r, io.EOF once
+// there are none. The record is only valid until the next call.
+func (u *Unmarshaler) lines(r *bufio.Reader) func() ([]byte, error) {
+	if u.Layout.Unterminated {
+		record := make([]byte, u.Layout.Length())
+		return func() ([]byte, error) {
+			_, err := io.ReadFull(r, record)
+			return record, err
+		}
+	}
+	return func() ([]byte, error) {
+		for {
+			line, err := r.ReadBytes('\n')
+			if err == io.EOF && len(line) > 0 {
+				err = nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			line = bytes.TrimSuffix(line, []byte{'\n'})
+			line = bytes.TrimSuffix(line, []byte{'\r'})
+			// Empty lines are no records.
+			if len(line) > 0 {
+				return line, nil
+			}
+		}
+	}
+}
+
+// UnmarshalAll converts every record of r into a message created by
+// factory.
+func (u *Unmarshaler) UnmarshalAll(r io.Reader, factory func() proto.Message) ([]proto.Message, error) {
+	var pbs []proto.Message
+	err := u.UnmarshalEach(r, factory, func(pb proto.Message) error {
+		pbs = append(pbs, pb)
+		return nil
+	})
+	return pbs, err
+}
@@ -0,0 +1,138 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package fixedpb
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+var layout = Layout{Fields: []Field{
+	{Name: "oString", Offset: 0, Width: 8},
+	{Name: "oInt32", Offset: 8, Width: 5, RightAlign: true, Pad: '0'},
+	{Name: "oBool", Offset: 14, Width: 5},
+}}
+
+func newSimple() proto.Message { return new(pb.Simple) }
+
+func TestUnmarshalAll(t *testing.T) {
+	in := "ACME    00042 true \r\n" +
+		"\n" +
+		"        -0007\n" +
+		"short"
+	u := Unmarshaler{Layout: layout}
+	got, err := u.UnmarshalAll(strings.NewReader(in), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*pb.Simple{
+		{OString: proto.String("ACME"), OInt32: proto.Int32(42), OBool: proto.Bool(true)},
+		{OInt32: proto.Int32(-7)},
+		{OString: proto.String("short")},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("UnmarshalAll() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("message %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUnmarshalUnterminated(t *testing.T) {
+	l := layout
+	l.Unterminated = true
+	u := Unmarshaler{Layout: l}
+	got, err := u.UnmarshalAll(strings.NewReader("a       00001 falseb       00002 true "), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !proto.Equal(got[1], &pb.Simple{OString: proto.String("b"), OInt32: proto.Int32(2), OBool: proto.Bool(true)}) {
+		t.Errorf("UnmarshalAll() = %v", got)
+	}
+
+	_, err = u.UnmarshalAll(strings.NewReader("a       00001 falseb"), newSimple)
+	if err == nil || !strings.Contains(err.Error(), "record 2: unexpected EOF") {
+		t.Errorf("UnmarshalAll() of truncated record error = %v", err)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	tests := []struct {
+		desc   string
+		layout Layout
+		in     string
+		want   string
+	}{
+		{"no fields", Layout{}, "x", "layout without fields"},
+		{"overlap", Layout{Fields: []Field{{Name: "a", Width: 3}, {Name: "b", Offset: 2, Width: 1}}}, "x", `fields "a" and "b" overlap`},
+		{"duplicate", Layout{Fields: []Field{{Name: "a", Width: 1}, {Name: "a", Offset: 1, Width: 1}}}, "x", `duplicate field "a"`},
+		{"width", Layout{Fields: []Field{{Name: "a"}}}, "x", "invalid range 0+0"},
+		{"unknown field", Layout{Fields: []Field{{Name: "nope", Width: 2}}}, "ab\n", `record 1: unknown field "nope"`},
+		{"conversion", layout, "        00001\n        x\n", "record 2:"},
+	}
+	for _, tt := range tests {
+		u := Unmarshaler{Layout: tt.layout}
+		_, err := u.UnmarshalAll(strings.NewReader(tt.in), newSimple)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: UnmarshalAll() error = %v, want %q", tt.desc, err, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalSkipInvalidRows(t *testing.T) {
+	u := Unmarshaler{Layout: layout, Unmarshaler: csvpb.Unmarshaler{SkipInvalidRows: true}}
+	got, err := u.UnmarshalAll(strings.NewReader("        x\n        00003\n"), newSimple)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !proto.Equal(got[0], &pb.Simple{OInt32: proto.Int32(3)}) {
+		t.Errorf("UnmarshalAll() = %v", got)
+	}
+}
+
+func TestUnmarshalRecord(t *testing.T) {
+	u := Unmarshaler{Layout: layout}
+	p := &pb.Simple{OUint32: proto.Uint32(1)}
+	if err := u.UnmarshalRecord([]byte("x"), p); err != nil {
+		t.Fatal(err)
+	}
+	if want := (&pb.Simple{OString: proto.String("x")}); !proto.Equal(p, want) {
+		t.Errorf("UnmarshalRecord() = %v, want %v", p, want)
+	}
+}
@@ -0,0 +1,131 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package fixedpb
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Marshaler is a configurable object for converting protocol buffers into
+// fixed-width records.
+type Marshaler struct {
+	Layout Layout
+
+	// Marshaler converts messages into columns, which have to include the
+	// names of the fields of Layout. Other columns are dropped.
+	Marshaler csvpb.Marshaler
+}
+
+// MarshalRecord converts pb into a record of Layout, without any line
+// terminator. Null columns are blank.
+func (m *Marshaler) MarshalRecord(pb proto.Message) ([]byte, error) {
+	if err := m.Layout.validate(); err != nil {
+		return nil, err
+	}
+	header, err := m.Marshaler.Header(pb)
+	if err != nil {
+		return nil, err
+	}
+	cells, err := m.Marshaler.MarshalRecord(pb)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]string, len(header))
+	for i, name := range header {
+		byName[name] = cells[i]
+	}
+
+	record := bytes.Repeat([]byte{' '}, m.Layout.Length())
+	for _, f := range m.Layout.Fields {
+		cell, ok := byName[f.Name]
+		if !ok {
+			return nil, fmt.Errorf("fixedpb: no column %q in %T", f.Name, pb)
+		}
+		if cell == "null" {
+			continue
+		}
+		if !m.Layout.Unterminated && strings.ContainsAny(cell, "\r\n") {
+			return nil, fmt.Errorf("fixedpb: field %q: line break in %q", f.Name, cell)
+		}
+		if err := f.put(record[f.Offset:f.Offset+f.Width], cell); err != nil {
+			return nil, err
+		}
+	}
+	return record, nil
+}
+
+// put writes the aligned cell to b, which is Width bytes.
+func (f *Field) put(b []byte, cell string) error {
+	if len(cell) > f.Width {
+		return fmt.Errorf("fixedpb: field %q: %q exceeds %d bytes", f.Name, cell, f.Width)
+	}
+	if !f.RightAlign {
+		copy(b, cell)
+		return nil
+	}
+	pad := f.Pad
+	if pad == 0 {
+		pad = ' '
+	}
+	for i := range b {
+		b[i] = pad
+	}
+	start := len(b) - len(cell)
+	if pad == '0' && len(cell) > 0 && (cell[0] == '-' || cell[0] == '+') {
+		// Zeros go between sign and digits.
+		b[0] = cell[0]
+		cell = cell[1:]
+		start++
+	}
+	copy(b[start:], cell)
+	return nil
+}
+
+// MarshalNext writes pb as the next record to w, followed by a newline
+// unless the layout is unterminated.
+func (m *Marshaler) MarshalNext(w io.Writer, pb proto.Message) error {
+	record, err := m.MarshalRecord(pb)
+	if err != nil {
+		return err
+	}
+	if !m.Layout.Unterminated {
+		record = append(record, '\n')
+	}
+	_, err = w.Write(record)
+	return err
+}
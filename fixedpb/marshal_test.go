@@ -0,0 +1,170 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package fixedpb
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestMarshalRecord(t *testing.T) {
+	tests := []struct {
+		desc string
+		pb   *pb.Simple
+		want string
+	}{
+		{"all", &pb.Simple{OString: proto.String("ACME"), OInt32: proto.Int32(42), OBool: proto.Bool(true)}, "ACME    00042 true "},
+		{"negative", &pb.Simple{OInt32: proto.Int32(-7)}, "        -0007      "},
+		{"null", &pb.Simple{}, "                   "},
+		{"full width", &pb.Simple{OString: proto.String("12345678"), OInt32: proto.Int32(99999)}, "1234567899999      "},
+	}
+	m := Marshaler{Layout: layout}
+	for _, tt := range tests {
+		got, err := m.MarshalRecord(tt.pb)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: MarshalRecord() = %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalRecordPadding(t *testing.T) {
+	m := Marshaler{Layout: Layout{Fields: []Field{
+		{Name: "o_uint32", Offset: 2, Width: 4, RightAlign: true},
+		{Name: "o_string", Offset: 7, Width: 3, RightAlign: true, Pad: '*'},
+	}}}
+	m.Marshaler.OrigName = true
+	got, err := m.MarshalRecord(&pb.Simple{OUint32: proto.Uint32(12), OString: proto.String("x")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "    12 **x"; string(got) != want {
+		t.Errorf("MarshalRecord() = %q, want %q", got, want)
+	}
+}
+
+func TestMarshalRecordPaddingRoundTrip(t *testing.T) {
+	l := Layout{Fields: []Field{
+		{Name: "o_string", Offset: 0, Width: 4, RightAlign: true, Pad: '*'},
+		{Name: "o_bytes", Offset: 4, Width: 8, RightAlign: true, Pad: '0'},
+		{Name: "o_int32", Offset: 12, Width: 5, RightAlign: true, Pad: '0'},
+		{Name: "o_double", Offset: 17, Width: 6, RightAlign: true, Pad: '0'},
+		{Name: "o_uint32", Offset: 23, Width: 4, RightAlign: true},
+	}}
+	tests := []*pb.Simple{
+		{OString: proto.String("x"), OBytes: []byte("ab"), OInt32: proto.Int32(-12), ODouble: proto.Float64(0.5), OUint32: proto.Uint32(7)},
+		{OString: proto.String(""), OBytes: []byte{}, OInt32: proto.Int32(0), ODouble: proto.Float64(-0.25), OUint32: proto.Uint32(0)},
+		{OString: proto.String("full"), OBytes: []byte("c"), OInt32: proto.Int32(12345), ODouble: proto.Float64(-1)},
+		// Unset bytes are written as empty
+		{OBytes: []byte{}, OInt32: proto.Int32(-1)},
+	}
+	for _, in := range tests {
+		m := Marshaler{Layout: l}
+		m.Marshaler.OrigName = true
+		record, err := m.MarshalRecord(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		u := Unmarshaler{Layout: l}
+		out := new(pb.Simple)
+		if err := u.UnmarshalRecord(record, out); err != nil {
+			t.Fatalf("UnmarshalRecord(%q) error = %v", record, err)
+		}
+		if !proto.Equal(out, in) {
+			t.Errorf("UnmarshalRecord(%q) = %v, want %v", record, out, in)
+		}
+	}
+}
+
+func TestMarshalRecordErrors(t *testing.T) {
+	tests := []struct {
+		desc   string
+		layout Layout
+		pb     *pb.Simple
+		want   string
+	}{
+		{"too long", layout, &pb.Simple{OString: proto.String("123456789")}, `"123456789" exceeds 8 bytes`},
+		{"line break", layout, &pb.Simple{OString: proto.String("a\nb")}, "line break"},
+		{"no column", Layout{Fields: []Field{{Name: "o_string", Width: 1}}}, &pb.Simple{}, `no column "o_string"`},
+		{"invalid layout", Layout{}, &pb.Simple{}, "layout without fields"},
+	}
+	for _, tt := range tests {
+		m := Marshaler{Layout: tt.layout}
+		_, err := m.MarshalRecord(tt.pb)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: MarshalRecord() error = %v, want %q", tt.desc, err, tt.want)
+		}
+	}
+}
+
+func TestMarshalRoundTrip(t *testing.T) {
+	in := []*pb.Simple{
+		{OString: proto.String("a b"), OInt32: proto.Int32(-12), OBool: proto.Bool(false)},
+		{OInt32: proto.Int32(0)},
+	}
+	for _, unterminated := range []bool{false, true} {
+		l := layout
+		l.Unterminated = unterminated
+		m := Marshaler{Layout: l}
+		var buf bytes.Buffer
+		for _, p := range in {
+			if err := m.MarshalNext(&buf, p); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if want := len(in) * (l.Length() + 1); !unterminated && buf.Len() != want {
+			t.Errorf("%d bytes written, want %d", buf.Len(), want)
+		}
+
+		u := Unmarshaler{Layout: l}
+		out, err := u.UnmarshalAll(&buf, newSimple)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(out) != len(in) {
+			t.Fatalf("unterminated %v: UnmarshalAll() = %v, want %v", unterminated, out, in)
+		}
+		for i := range in {
+			if !proto.Equal(out[i], in[i]) {
+				t.Errorf("unterminated %v: message %d = %v, want %v", unterminated, i, out[i], in[i])
+			}
+		}
+	}
+}
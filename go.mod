@@ -2,4 +2,7 @@ module github.com/abergmeier/golang-protobuf
 
 go 1.12
 
-require github.com/golang/protobuf v1.3.1
+require (
+	github.com/golang/protobuf v1.3.1
+	github.com/grpc-ecosystem/grpc-gateway v1.9.0
+)
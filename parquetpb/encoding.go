@@ -0,0 +1,468 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package parquetpb
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errCorrupt = errors.New("parquetpb: corrupt page")
+
+// bitWidth returns the number of bits needed to store levels up to max.
+func bitWidth(max int) uint {
+	w := uint(0)
+	for max > 0 {
+		w++
+		max >>= 1
+	}
+	return w
+}
+
+// appendRLE appends values in the RLE/bit-packing hybrid encoding, using
+// RLE runs only.
+func appendRLE(buf []byte, values []int, width uint) []byte {
+	byteWidth := int(width+7) / 8
+	var tmp [binary.MaxVarintLen64]byte
+	for i := 0; i < len(values); {
+		j := i + 1
+		for j < len(values) && values[j] == values[i] {
+			j++
+		}
+		n := binary.PutUvarint(tmp[:], uint64(j-i)<<1)
+		buf = append(buf, tmp[:n]...)
+		v := values[i]
+		for k := 0; k < byteWidth; k++ {
+			buf = append(buf, byte(v>>(8*uint(k))))
+		}
+		i = j
+	}
+	return buf
+}
+
+// decodeRLE decodes n values of the RLE/bit-packing hybrid encoding.
+func decodeRLE(data []byte, width uint, n int) ([]int, error) {
+	if width > 32 || n < 0 {
+		return nil, errCorrupt
+	}
+	// Runs may hold many values in few bytes, so the capacity grows with
+	// the runs actually decoded.
+	capacity := n
+	if capacity > 8*len(data) {
+		capacity = 8 * len(data)
+	}
+	values := make([]int, 0, capacity)
+	byteWidth := int(width+7) / 8
+	pos := 0
+	for len(values) < n {
+		header, m := binary.Uvarint(data[pos:])
+		if m <= 0 {
+			return nil, errCorrupt
+		}
+		pos += m
+		if header&1 == 0 {
+			if pos+byteWidth > len(data) || header>>1 > uint64(n-len(values)) {
+				return nil, errCorrupt
+			}
+			count := int(header >> 1)
+			v := 0
+			for k := 0; k < byteWidth; k++ {
+				v |= int(data[pos+k]) << (8 * uint(k))
+			}
+			pos += byteWidth
+			for k := 0; k < count; k++ {
+				values = append(values, v)
+			}
+			continue
+		}
+		// Bit-packed groups of 8 values, least significant bit first. Each
+		// group takes width bytes.
+		groups := header >> 1
+		if width > 0 && groups > uint64(len(data)-pos)/uint64(width) {
+			return nil, errCorrupt
+		}
+		if max := uint64(n-len(values)+7) / 8; groups > max {
+			if width > 0 {
+				return nil, errCorrupt
+			}
+			groups = max
+		}
+		count := int(groups) * 8
+		size := count * int(width) / 8
+		for k := 0; k < count && len(values) < n; k++ {
+			v := 0
+			for b := uint(0); b < width; b++ {
+				bit := uint(k)*width + b
+				if data[pos+int(bit/8)]&(1<<(bit%8)) != 0 {
+					v |= 1 << b
+				}
+			}
+			values = append(values, v)
+		}
+		pos += size
+	}
+	return values, nil
+}
+
+// appendPlain appends v in PLAIN encoding. Booleans are handled by
+// appendPlainBools, as they are packed.
+func appendPlain(buf []byte, t physicalType, v interface{}) []byte {
+	switch t {
+	case typeInt32:
+		return appendUint32(buf, uint32(v.(int32)))
+	case typeInt64:
+		return appendUint64(buf, uint64(v.(int64)))
+	case typeFloat:
+		return appendUint32(buf, math.Float32bits(v.(float32)))
+	case typeDouble:
+		return appendUint64(buf, math.Float64bits(v.(float64)))
+	case typeByteArray:
+		b := v.([]byte)
+		buf = appendUint32(buf, uint32(len(b)))
+		return append(buf, b...)
+	}
+	panic("parquetpb: no plain encoding for type")
+}
+
+func appendPlainBools(buf []byte, values []interface{}) []byte {
+	start := len(buf)
+	buf = append(buf, make([]byte, (len(values)+7)/8)...)
+	for i, v := range values {
+		if v.(bool) {
+			buf[start+i/8] |= 1 << uint(i%8)
+		}
+	}
+	return buf
+}
+
+func appendUint32(buf []byte, v uint32) []byte {
+	var b [4]byte
+	binary.LittleEndian.PutUint32(b[:], v)
+	return append(buf, b[:]...)
+}
+
+func appendUint64(buf []byte, v uint64) []byte {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	return append(buf, b[:]...)
+}
+
+// decodePlain decodes n values of type t in PLAIN encoding.
+func decodePlain(data []byte, t physicalType, typeLength int, n int) ([]interface{}, error) {
+	if n < 0 || n > plainCount(data, t, typeLength) {
+		return nil, errCorrupt
+	}
+	values := make([]interface{}, 0, n)
+	pos := 0
+	for i := 0; i < n; i++ {
+		switch t {
+		case typeBoolean:
+			if i/8 >= len(data) {
+				return nil, errCorrupt
+			}
+			values = append(values, data[i/8]&(1<<uint(i%8)) != 0)
+			continue
+		case typeInt32, typeFloat:
+			if pos+4 > len(data) {
+				return nil, errCorrupt
+			}
+			u := binary.LittleEndian.Uint32(data[pos:])
+			if t == typeInt32 {
+				values = append(values, int32(u))
+			} else {
+				values = append(values, math.Float32frombits(u))
+			}
+			pos += 4
+		case typeInt64, typeDouble:
+			if pos+8 > len(data) {
+				return nil, errCorrupt
+			}
+			u := binary.LittleEndian.Uint64(data[pos:])
+			if t == typeInt64 {
+				values = append(values, int64(u))
+			} else {
+				values = append(values, math.Float64frombits(u))
+			}
+			pos += 8
+		case typeByteArray:
+			if pos+4 > len(data) {
+				return nil, errCorrupt
+			}
+			size := int(binary.LittleEndian.Uint32(data[pos:]))
+			pos += 4
+			if size < 0 || size > len(data)-pos {
+				return nil, errCorrupt
+			}
+			values = append(values, data[pos:pos+size])
+			pos += size
+		case typeFixedLenByteArray:
+			if typeLength < 0 || typeLength > len(data)-pos {
+				return nil, errCorrupt
+			}
+			values = append(values, data[pos:pos+typeLength])
+			pos += typeLength
+		default:
+			return nil, errUnsupportedType(t)
+		}
+	}
+	return values, nil
+}
+
+// plainCount returns the most values of type t data holds in PLAIN
+// encoding.
+func plainCount(data []byte, t physicalType, typeLength int) int {
+	switch t {
+	case typeBoolean:
+		return 8 * len(data)
+	case typeInt32, typeFloat, typeByteArray:
+		return len(data) / 4
+	case typeInt64, typeDouble:
+		return len(data) / 8
+	case typeFixedLenByteArray:
+		if typeLength <= 0 {
+			return 0
+		}
+		return len(data) / typeLength
+	}
+	// Failing with errUnsupportedType for any value
+	return len(data)
+}
+
+// maxSnappyRatio bounds how much larger a block gets by decoding, a copy
+// of 3 bytes yielding at most 64.
+const maxSnappyRatio = 22
+
+// decodeSnappy decodes a block in the Snappy format, failing should it
+// decode into more than limit bytes.
+func decodeSnappy(src []byte, limit int) ([]byte, error) {
+	size, n := binary.Uvarint(src)
+	if n <= 0 || size > uint64(limit) || size > uint64(len(src))*maxSnappyRatio {
+		return nil, errCorrupt
+	}
+	src = src[n:]
+	dst := make([]byte, 0, size)
+	for len(src) > 0 {
+		tag := src[0]
+		var length, offset int
+		switch tag & 3 {
+		case 0:
+			length = int(tag>>2) + 1
+			src = src[1:]
+			if length > 60 {
+				extra := length - 60
+				if extra > len(src) {
+					return nil, errCorrupt
+				}
+				length = 0
+				for k := 0; k < extra; k++ {
+					length |= int(src[k]) << (8 * uint(k))
+				}
+				length++
+				src = src[extra:]
+			}
+			if length <= 0 || length > len(src) || uint64(len(dst)+length) > size {
+				return nil, errCorrupt
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+			continue
+		case 1:
+			if len(src) < 2 {
+				return nil, errCorrupt
+			}
+			length = 4 + int(tag>>2)&7
+			offset = int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+		case 2:
+			if len(src) < 3 {
+				return nil, errCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+		case 3:
+			if len(src) < 5 {
+				return nil, errCorrupt
+			}
+			length = 1 + int(tag>>2)
+			offset = int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+		}
+		if offset <= 0 || offset > len(dst) || uint64(len(dst)+length) > size {
+			return nil, errCorrupt
+		}
+		// Copies may overlap their own output, so copy byte by byte.
+		start := len(dst) - offset
+		for k := 0; k < length; k++ {
+			dst = append(dst, dst[start+k])
+		}
+	}
+	if uint64(len(dst)) != size {
+		return nil, errCorrupt
+	}
+	return dst, nil
+}
+
+// decodeDeltaBinaryPacked decodes n integers of the DELTA_BINARY_PACKED
+// encoding and returns them along with the number of bytes consumed.
+func decodeDeltaBinaryPacked(data []byte, n int) ([]int64, int, error) {
+	pos := 0
+	uvarint := func() (uint64, error) {
+		v, m := binary.Uvarint(data[pos:])
+		if m <= 0 {
+			return 0, errCorrupt
+		}
+		pos += m
+		return v, nil
+	}
+	varint := func() (int64, error) {
+		v, m := binary.Varint(data[pos:])
+		if m <= 0 {
+			return 0, errCorrupt
+		}
+		pos += m
+		return v, nil
+	}
+
+	blockSize, err := uvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	miniblocks, err := uvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := uvarint()
+	if err != nil {
+		return nil, 0, err
+	}
+	first, err := varint()
+	if err != nil {
+		return nil, 0, err
+	}
+	if miniblocks == 0 || miniblocks > uint64(len(data)) || blockSize > uint64(len(data))*64 ||
+		blockSize%miniblocks != 0 || blockSize/miniblocks%8 != 0 || blockSize/miniblocks == 0 || total > uint64(len(data))*64 {
+		return nil, 0, errCorrupt
+	}
+	if n < 0 || total < uint64(n) {
+		return nil, 0, errCorrupt
+	}
+	perMiniblock := int(blockSize / miniblocks)
+
+	values := make([]int64, 0, total)
+	if total > 0 {
+		values = append(values, first)
+	}
+	prev := first
+	for uint64(len(values)) < total {
+		minDelta, err := varint()
+		if err != nil {
+			return nil, 0, err
+		}
+		if pos+int(miniblocks) > len(data) {
+			return nil, 0, errCorrupt
+		}
+		widths := data[pos : pos+int(miniblocks)]
+		pos += int(miniblocks)
+		for _, width := range widths {
+			if uint64(len(values)) >= total {
+				break
+			}
+			if width > 64 {
+				return nil, 0, errCorrupt
+			}
+			size := perMiniblock * int(width) / 8
+			if pos+size > len(data) {
+				return nil, 0, errCorrupt
+			}
+			mb := data[pos : pos+size]
+			for k := 0; k < perMiniblock && uint64(len(values)) < total; k++ {
+				var delta uint64
+				for b := uint(0); b < uint(width); b++ {
+					bit := uint(k)*uint(width) + b
+					if mb[bit/8]&(1<<(bit%8)) != 0 {
+						delta |= 1 << b
+					}
+				}
+				prev = int64(uint64(prev) + uint64(minDelta) + delta)
+				values = append(values, prev)
+			}
+			pos += size
+		}
+	}
+	return values[:n], pos, nil
+}
+
+// decodeDeltaLengthByteArray decodes n byte arrays of the
+// DELTA_LENGTH_BYTE_ARRAY encoding.
+func decodeDeltaLengthByteArray(data []byte, n int) ([][]byte, error) {
+	lengths, pos, err := decodeDeltaBinaryPacked(data, n)
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, n)
+	for i, l := range lengths {
+		if l < 0 || l > int64(len(data)-pos) {
+			return nil, errCorrupt
+		}
+		values[i] = data[pos : pos+int(l)]
+		pos += int(l)
+	}
+	return values, nil
+}
+
+// decodeDeltaByteArray decodes n byte arrays of the DELTA_BYTE_ARRAY
+// encoding, storing each value as the length of the prefix shared with the
+// previous value and the remaining suffix.
+func decodeDeltaByteArray(data []byte, n int) ([][]byte, error) {
+	prefixes, pos, err := decodeDeltaBinaryPacked(data, n)
+	if err != nil {
+		return nil, err
+	}
+	suffixes, err := decodeDeltaLengthByteArray(data[pos:], n)
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, n)
+	var prev []byte
+	for i, p := range prefixes {
+		if p < 0 || p > int64(len(prev)) {
+			return nil, errCorrupt
+		}
+		v := make([]byte, 0, int(p)+len(suffixes[i]))
+		v = append(append(v, prev[:p]...), suffixes[i]...)
+		values[i] = v
+		prev = v
+	}
+	return values, nil
+}
@@ -0,0 +1,165 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package parquetpb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeRLE(t *testing.T) {
+	tests := []struct {
+		desc  string
+		data  []byte
+		width uint
+		n     int
+		want  []int
+	}{
+		// The example of the Parquet specification, followed by a run.
+		{"bit-packed and run", []byte{0x03, 0x88, 0xc6, 0xfa, 0x08, 0x05}, 3, 12, []int{0, 1, 2, 3, 4, 5, 6, 7, 5, 5, 5, 5}},
+		{"padded bit-packed", []byte{0x03, 0x05}, 1, 3, []int{1, 0, 1}},
+		{"zero width", []byte{0x06}, 0, 3, []int{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		got, err := decodeRLE(tt.data, tt.width, tt.n)
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.desc, got, tt.want)
+		}
+	}
+
+	if _, err := decodeRLE([]byte{0x08}, 8, 4); err == nil {
+		t.Error("truncated run: expected error")
+	}
+}
+
+func TestRLERoundTrip(t *testing.T) {
+	levels := []int{0, 0, 1, 1, 1, 0, 1}
+	got, err := decodeRLE(appendRLE(nil, levels, 1), 1, len(levels))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, levels) {
+		t.Errorf("got %v, want %v", got, levels)
+	}
+}
+
+func TestDecodeSnappy(t *testing.T) {
+	tests := []struct {
+		desc string
+		data []byte
+		want string
+	}{
+		{"literal", []byte{3, 0x08, 'a', 'b', 'c'}, "abc"},
+		{"overlapping copy", []byte{12, 0x08, 'a', 'b', 'c', 0x15, 3}, "abcabcabcabc"},
+		{"two byte offset", []byte{6, 0x08, 'x', 'y', 'z', 0x0a, 3, 0}, "xyzxyz"},
+	}
+	for _, tt := range tests {
+		got, err := decodeSnappy(tt.data, len(tt.want))
+		if err != nil {
+			t.Errorf("%s: %v", tt.desc, err)
+			continue
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+
+	for _, data := range [][]byte{
+		{5, 0x08, 'a', 'b', 'c'},
+		{4, 0x15, 3},
+		// Claims 2 GiB
+		{0x80, 0x80, 0x80, 0x80, 0x08, 0x08, 'a', 'b', 'c'},
+		// Copies beyond the size claimed
+		{6, 0x08, 'a', 'b', 'c', 0x15, 3},
+	} {
+		if _, err := decodeSnappy(data, 1<<20); err == nil {
+			t.Errorf("%v: expected error", data)
+		}
+	}
+}
+
+func TestThriftRoundTrip(t *testing.T) {
+	var w thriftWriter
+	w.structBegin()
+	w.fieldI32(1, -3)
+	w.fieldBinary(4, []byte("name"))
+	w.fieldBool(20, true)
+	w.fieldList(21, thriftI32, 2)
+	w.zigzag(7)
+	w.zigzag(-8)
+	w.fieldStruct(22)
+	w.fieldI64(1, 1<<40)
+	w.structEnd()
+	w.structEnd()
+
+	r := &thriftReader{buf: w.buf}
+	s, err := r.readStruct()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.int(1) != -3 || string(s.bytes(4)) != "name" || !s.bool(20, false) || s.strct(22).int(1) != 1<<40 {
+		t.Errorf("got %v", s)
+	}
+	if !reflect.DeepEqual(s.list(21), []interface{}{int64(7), int64(-8)}) {
+		t.Errorf("got list %v", s.list(21))
+	}
+	if r.pos != len(w.buf) {
+		t.Errorf("consumed %d of %d bytes", r.pos, len(w.buf))
+	}
+}
+
+func TestDecodeCorruptCounts(t *testing.T) {
+	if _, err := decodeRLE([]byte{2, 1}, 1, -1); err == nil {
+		t.Error("decodeRLE of -1 values: expected error")
+	}
+	// A bit-packed run of 2^62 groups
+	if _, err := decodeRLE([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0}, 1, 8); err == nil {
+		t.Error("decodeRLE of an overflowing run: expected error")
+	}
+	// An RLE run longer than n
+	if _, err := decodeRLE([]byte{0xfe, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01, 1}, 1, 8); err == nil {
+		t.Error("decodeRLE of an overlong run: expected error")
+	}
+	if _, err := decodePlain([]byte{1, 2, 3, 4}, typeInt32, 0, -1); err == nil {
+		t.Error("decodePlain of -1 values: expected error")
+	}
+	if _, err := decodePlain([]byte{1, 2, 3, 4}, typeInt64, 0, 1<<40); err == nil {
+		t.Error("decodePlain of more values than bytes: expected error")
+	}
+	if _, _, err := decodeDeltaBinaryPacked([]byte{128, 1, 4, 1, 0}, -1); err == nil {
+		t.Error("decodeDeltaBinaryPacked of -1 values: expected error")
+	}
+}
@@ -0,0 +1,658 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package parquetpb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// Reader reads the row groups of a Parquet file into messages. Columns are
+// matched with fields by either their original or their lowerCamelCase
+// name. Repeated fields are read from LIST columns as well as from
+// repeated primitive columns.
+type Reader struct {
+	// Whether to ignore columns without a matching field, as opposed to
+	// failing to read.
+	AllowUnknownFields bool
+
+	r         io.ReaderAt
+	size      int64
+	leaves    []*leaf
+	rowGroups []tstruct
+	numRows   int64
+}
+
+// leaf is a primitive column of the schema of a file.
+type leaf struct {
+	path       []string
+	physical   physicalType
+	typeLength int
+	converted  int32
+	// maxDef and maxRep are the maximum definition and repetition levels.
+	maxDef, maxRep int
+	// repDef is the definition level at which the repeated ancestor is
+	// defined, i.e. at which a list has an element.
+	repDef int
+}
+
+// triplet is a single value of a column along with its levels.
+type triplet struct {
+	rep, def int
+	value    interface{}
+}
+
+// NewReader returns a Reader for the Parquet file r of size bytes.
+func NewReader(r io.ReaderAt, size int64) (*Reader, error) {
+	if size < 12 {
+		return nil, errors.New("parquetpb: file too small")
+	}
+	var tail [8]byte
+	if _, err := r.ReadAt(tail[:], size-8); err != nil {
+		return nil, err
+	}
+	if string(tail[4:]) != magic {
+		return nil, errors.New("parquetpb: not a Parquet file")
+	}
+	footerSize := int64(binary.LittleEndian.Uint32(tail[:4]))
+	if footerSize > size-12 {
+		return nil, errors.New("parquetpb: footer too large")
+	}
+	footer := make([]byte, footerSize)
+	if _, err := r.ReadAt(footer, size-8-footerSize); err != nil {
+		return nil, err
+	}
+	tr := &thriftReader{buf: footer}
+	meta, err := tr.readStruct()
+	if err != nil {
+		return nil, err
+	}
+
+	pr := &Reader{
+		r:       r,
+		size:    size,
+		numRows: meta.int(3),
+	}
+	if err := pr.parseSchema(meta.list(2)); err != nil {
+		return nil, err
+	}
+	for _, rg := range meta.list(4) {
+		s, ok := rg.(tstruct)
+		if !ok {
+			return nil, errThrift
+		}
+		pr.rowGroups = append(pr.rowGroups, s)
+	}
+	return pr, nil
+}
+
+// parseSchema flattens the schema elements, stored depth first, into
+// leaves.
+func (r *Reader) parseSchema(elements []interface{}) error {
+	if len(elements) == 0 {
+		return errors.New("parquetpb: empty schema")
+	}
+	pos := 1
+	var walk func(children int, path []string, def, rep, repDef int) error
+	walk = func(children int, path []string, def, rep, repDef int) error {
+		for i := 0; i < children; i++ {
+			if pos >= len(elements) {
+				return errors.New("parquetpb: truncated schema")
+			}
+			e, ok := elements[pos].(tstruct)
+			if !ok {
+				return errThrift
+			}
+			pos++
+
+			p := append(append([]string(nil), path...), string(e.bytes(4)))
+			d, rp, rd := def, rep, repDef
+			switch e.int(3) {
+			case repetitionOptional:
+				d++
+			case repetitionRepeated:
+				d++
+				rp++
+				rd = d
+			}
+			if n := int(e.int(5)); n > 0 {
+				if err := walk(n, p, d, rp, rd); err != nil {
+					return err
+				}
+				continue
+			}
+			l := &leaf{
+				path:       p,
+				physical:   physicalType(e.int(1)),
+				typeLength: int(e.int(2)),
+				converted:  convertedNone,
+				maxDef:     d,
+				maxRep:     rp,
+				repDef:     rd,
+			}
+			if e.has(6) {
+				l.converted = int32(e.int(6))
+			}
+			r.leaves = append(r.leaves, l)
+		}
+		return nil
+	}
+	root, ok := elements[0].(tstruct)
+	if !ok {
+		return errThrift
+	}
+	return walk(int(root.int(5)), nil, 0, 0, 0)
+}
+
+// NumRows returns the number of rows in the file.
+func (r *Reader) NumRows() int64 {
+	return r.numRows
+}
+
+// NumRowGroups returns the number of row groups in the file.
+func (r *Reader) NumRowGroups() int {
+	return len(r.rowGroups)
+}
+
+// ReadAll reads every row of the file into messages created by factory.
+func (r *Reader) ReadAll(factory func() proto.Message) ([]proto.Message, error) {
+	var pbs []proto.Message
+	for i := range r.rowGroups {
+		rg, err := r.ReadRowGroup(i, factory)
+		if err != nil {
+			return pbs, err
+		}
+		pbs = append(pbs, rg...)
+	}
+	return pbs, nil
+}
+
+// ReadRowGroup reads the rows of row group i into messages created by
+// factory.
+func (r *Reader) ReadRowGroup(i int, factory func() proto.Message) ([]proto.Message, error) {
+	if i < 0 || i >= len(r.rowGroups) {
+		return nil, fmt.Errorf("parquetpb: row group %d out of range", i)
+	}
+	rg := r.rowGroups[i]
+	numRows := int(rg.int(3))
+	chunks := rg.list(1)
+	if len(chunks) != len(r.leaves) {
+		return nil, errors.New("parquetpb: row group does not match schema")
+	}
+	if numRows < 0 || numRows > 1<<30 {
+		return nil, errors.New("parquetpb: invalid number of rows")
+	}
+
+	pbs := make([]proto.Message, numRows)
+	values := make([]reflect.Value, numRows)
+	for k := range pbs {
+		pbs[k] = factory()
+		v := reflect.ValueOf(pbs[k])
+		if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+			return nil, errors.New("parquetpb: factory must return a pointer to a struct")
+		}
+		values[k] = v.Elem()
+	}
+	if numRows == 0 {
+		return pbs, nil
+	}
+
+	t := values[0].Type()
+	sprops := proto.GetProperties(t)
+	for j, l := range r.leaves {
+		field, prop := fieldFor(t, sprops, l.path[0])
+		if field < 0 {
+			if r.AllowUnknownFields {
+				continue
+			}
+			return nil, fmt.Errorf("parquetpb: unknown field %q in %v", l.path[0], t)
+		}
+		chunk, ok := chunks[j].(tstruct)
+		if !ok {
+			return nil, errThrift
+		}
+		triplets, err := r.readChunk(l, chunk.strct(3), numRows)
+		if err != nil {
+			return nil, fmt.Errorf("parquetpb: column %s: %v", l.path[0], err)
+		}
+		if err := assign(values, field, prop, l, triplets); err != nil {
+			return nil, fmt.Errorf("parquetpb: column %s: %v", l.path[0], err)
+		}
+	}
+	return pbs, nil
+}
+
+// fieldFor returns the index and properties of the field named name, or -1.
+func fieldFor(t reflect.Type, sprops *proto.StructProperties, name string) (int, *proto.Properties) {
+	for i, prop := range sprops.Prop {
+		if prop.OrigName == "" || t.Field(i).Tag.Get("protobuf_oneof") != "" {
+			continue
+		}
+		if prop.OrigName == name || prop.JSONName == name {
+			return i, prop
+		}
+	}
+	return -1, nil
+}
+
+// maxValues bounds the values of a column chunk, like the rows of a row
+// group.
+const maxValues = 1 << 30
+
+// readChunk reads every value of a column chunk of a row group of numRows
+// rows. Counts and sizes read from the file are checked before anything
+// is allocated for them.
+func (r *Reader) readChunk(l *leaf, meta tstruct, numRows int) ([]triplet, error) {
+	if meta == nil {
+		return nil, errors.New("missing column metadata")
+	}
+	start := meta.int(9)
+	if meta.has(11) && meta.int(11) > 0 && meta.int(11) < start {
+		start = meta.int(11)
+	}
+	size := meta.int(7)
+	if start < 0 || size < 0 || start > r.size || size > r.size-start {
+		return nil, errCorrupt
+	}
+	data := make([]byte, size)
+	if _, err := r.r.ReadAt(data, start); err != nil {
+		return nil, err
+	}
+	codec := Compression(meta.int(4))
+	numValues := meta.int(5)
+	if numValues < 0 || numValues > maxValues || l.maxRep == 0 && numValues > int64(numRows) {
+		return nil, errCorrupt
+	}
+
+	var dict []interface{}
+	var triplets []triplet
+	for int64(len(triplets)) < numValues {
+		tr := &thriftReader{buf: data}
+		header, err := tr.readStruct()
+		if err != nil {
+			return nil, err
+		}
+		data = data[tr.pos:]
+		compressedSize := int(header.int(3))
+		if compressedSize < 0 || compressedSize > len(data) {
+			return nil, errCorrupt
+		}
+		page := data[:compressedSize]
+		data = data[compressedSize:]
+		uncompressedSize := int(header.int(2))
+		if uncompressedSize < 0 || uncompressedSize > maxValues {
+			return nil, errCorrupt
+		}
+
+		switch header.int(1) {
+		case pageDictionary:
+			dh := header.strct(7)
+			if page, err = decompress(codec, page, uncompressedSize); err != nil {
+				return nil, err
+			}
+			if dict, err = decodePlain(page, l.physical, l.typeLength, int(dh.int(1))); err != nil {
+				return nil, err
+			}
+		case pageData:
+			dh := header.strct(5)
+			if page, err = decompress(codec, page, uncompressedSize); err != nil {
+				return nil, err
+			}
+			n := int(dh.int(1))
+			if n < 0 || int64(n) > numValues-int64(len(triplets)) {
+				return nil, errCorrupt
+			}
+			var reps, defs []int
+			if l.maxRep > 0 {
+				if reps, page, err = readLevels(page, l.maxRep, n); err != nil {
+					return nil, err
+				}
+			}
+			if l.maxDef > 0 {
+				if defs, page, err = readLevels(page, l.maxDef, n); err != nil {
+					return nil, err
+				}
+			}
+			if triplets, err = appendTriplets(triplets, l, page, int32(dh.int(2)), dict, reps, defs, n); err != nil {
+				return nil, err
+			}
+		case pageDataV2:
+			dh := header.strct(8)
+			n := int(dh.int(1))
+			if n < 0 || int64(n) > numValues-int64(len(triplets)) {
+				return nil, errCorrupt
+			}
+			repLen, defLen := int(dh.int(6)), int(dh.int(5))
+			if repLen < 0 || defLen < 0 || repLen > len(page) || defLen > len(page)-repLen || repLen+defLen > uncompressedSize {
+				return nil, errCorrupt
+			}
+			var reps, defs []int
+			if l.maxRep > 0 {
+				if reps, err = decodeRLE(page[:repLen], bitWidth(l.maxRep), n); err != nil {
+					return nil, err
+				}
+			}
+			if l.maxDef > 0 {
+				if defs, err = decodeRLE(page[repLen:repLen+defLen], bitWidth(l.maxDef), n); err != nil {
+					return nil, err
+				}
+			}
+			values := page[repLen+defLen:]
+			if dh.bool(7, true) {
+				if values, err = decompress(codec, values, uncompressedSize-repLen-defLen); err != nil {
+					return nil, err
+				}
+			}
+			if triplets, err = appendTriplets(triplets, l, values, int32(dh.int(4)), dict, reps, defs, n); err != nil {
+				return nil, err
+			}
+		case pageIndex:
+		default:
+			return nil, fmt.Errorf("unknown page type %d", header.int(1))
+		}
+	}
+	return triplets, nil
+}
+
+// readLevels reads the levels of a data page of version 1, prefixed by
+// their length, and returns the rest of the page.
+func readLevels(page []byte, max, n int) ([]int, []byte, error) {
+	if len(page) < 4 {
+		return nil, nil, errCorrupt
+	}
+	size := int(binary.LittleEndian.Uint32(page))
+	if size < 0 || size > len(page)-4 {
+		return nil, nil, errCorrupt
+	}
+	levels, err := decodeRLE(page[4:4+size], bitWidth(max), n)
+	return levels, page[4+size:], err
+}
+
+// appendTriplets decodes the uncompressed values of a data page and
+// appends them along with their levels.
+func appendTriplets(triplets []triplet, l *leaf, data []byte, encoding int32, dict []interface{}, reps, defs []int, n int) ([]triplet, error) {
+	var err error
+	present := n
+	if defs != nil {
+		present = 0
+		for _, d := range defs {
+			if d == l.maxDef {
+				present++
+			}
+		}
+	}
+
+	var values []interface{}
+	switch encoding {
+	case encodingPlain:
+		values, err = decodePlain(data, l.physical, l.typeLength, present)
+	case encodingPlainDictionary, encodingRLEDictionary:
+		if dict == nil {
+			return nil, errors.New("dictionary page missing")
+		}
+		if len(data) == 0 {
+			if present > 0 {
+				return nil, errCorrupt
+			}
+			break
+		}
+		var indices []int
+		if indices, err = decodeRLE(data[1:], uint(data[0]), present); err != nil {
+			return nil, err
+		}
+		values = make([]interface{}, present)
+		for k, idx := range indices {
+			if idx >= len(dict) {
+				return nil, errCorrupt
+			}
+			values[k] = dict[idx]
+		}
+	case encodingRLE:
+		if l.physical != typeBoolean {
+			return nil, fmt.Errorf("encoding RLE not supported for type %d", l.physical)
+		}
+		var bits []int
+		if bits, _, err = readLevels(data, 1, present); err != nil {
+			return nil, err
+		}
+		values = make([]interface{}, present)
+		for k, b := range bits {
+			values[k] = b == 1
+		}
+	case encodingDeltaBinaryPacked:
+		var ints []int64
+		if ints, _, err = decodeDeltaBinaryPacked(data, present); err != nil {
+			return nil, err
+		}
+		values = make([]interface{}, present)
+		for k, v := range ints {
+			switch l.physical {
+			case typeInt32:
+				values[k] = int32(v)
+			case typeInt64:
+				values[k] = v
+			default:
+				return nil, fmt.Errorf("encoding DELTA_BINARY_PACKED not supported for type %d", l.physical)
+			}
+		}
+	case encodingDeltaLengthByteArray, encodingDeltaByteArray:
+		var arrays [][]byte
+		if encoding == encodingDeltaLengthByteArray {
+			arrays, err = decodeDeltaLengthByteArray(data, present)
+		} else {
+			arrays, err = decodeDeltaByteArray(data, present)
+		}
+		if err != nil {
+			return nil, err
+		}
+		values = make([]interface{}, present)
+		for k, v := range arrays {
+			values[k] = v
+		}
+	default:
+		return nil, fmt.Errorf("encoding %d not supported", encoding)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	k := 0
+	for i := 0; i < n; i++ {
+		t := triplet{def: l.maxDef}
+		if reps != nil {
+			t.rep = reps[i]
+		}
+		if defs != nil {
+			t.def = defs[i]
+		}
+		if t.def == l.maxDef {
+			t.value = values[k]
+			k++
+		}
+		triplets = append(triplets, t)
+	}
+	return triplets, nil
+}
+
+// decompress decompresses data, failing should it be larger than size.
+func decompress(codec Compression, data []byte, size int) ([]byte, error) {
+	switch codec {
+	case Uncompressed:
+		return data, nil
+	case Snappy:
+		return decodeSnappy(data, size)
+	case Gzip:
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		b, err := ioutil.ReadAll(io.LimitReader(zr, int64(size)+1))
+		if err == nil && len(b) > size {
+			return nil, errCorrupt
+		}
+		return b, err
+	}
+	return nil, fmt.Errorf("compression %d not supported", codec)
+}
+
+// assign sets the field of every row from the triplets of its column.
+func assign(rows []reflect.Value, field int, prop *proto.Properties, l *leaf, triplets []triplet) error {
+	ft := rows[0].Type().Field(field).Type
+	repeated := ft.Kind() == reflect.Slice && ft.Elem().Kind() != reflect.Uint8
+	if repeated != (l.maxRep > 0) {
+		return errors.New("repetition does not match field")
+	}
+	if l.maxRep > 1 {
+		return errors.New("nested lists not supported")
+	}
+
+	row := -1
+	for _, t := range triplets {
+		if t.rep == 0 {
+			row++
+			if row >= len(rows) {
+				return errors.New("more values than rows")
+			}
+		} else if row < 0 {
+			return errCorrupt
+		}
+		fv := rows[row].Field(field)
+		if t.def < l.maxDef {
+			if repeated && t.def >= l.repDef {
+				return errors.New("null element in list")
+			}
+			if prop.Required {
+				return fmt.Errorf("required field %q is null", prop.OrigName)
+			}
+			continue
+		}
+		if repeated {
+			elem := reflect.New(ft.Elem()).Elem()
+			if err := setValue(elem, prop, l, t.value); err != nil {
+				return err
+			}
+			fv.Set(reflect.Append(fv, elem))
+			continue
+		}
+		if fv.Kind() == reflect.Ptr {
+			fv.Set(reflect.New(ft.Elem()))
+			fv = fv.Elem()
+		}
+		if err := setValue(fv, prop, l, t.value); err != nil {
+			return err
+		}
+	}
+	if row != len(rows)-1 {
+		return errors.New("fewer values than rows")
+	}
+	return nil
+}
+
+// setValue converts the physical value v into the kind of target.
+func setValue(target reflect.Value, prop *proto.Properties, l *leaf, v interface{}) error {
+	unsigned := l.converted == convertedUint32 || l.converted == convertedUint64
+	switch x := v.(type) {
+	case bool:
+		if target.Kind() == reflect.Bool {
+			target.SetBool(x)
+			return nil
+		}
+	case int32:
+		if unsigned {
+			return setInt(target, int64(uint32(x)), true)
+		}
+		return setInt(target, int64(x), false)
+	case int64:
+		return setInt(target, x, unsigned)
+	case float32:
+		return setFloat(target, float64(x))
+	case float64:
+		return setFloat(target, x)
+	case []byte:
+		switch target.Kind() {
+		case reflect.String:
+			target.SetString(string(x))
+			return nil
+		case reflect.Slice:
+			target.SetBytes(append([]byte{}, x...))
+			return nil
+		case reflect.Int32:
+			if prop.Enum != "" {
+				n, ok := proto.EnumValueMap(prop.Enum)[string(x)]
+				if !ok {
+					return fmt.Errorf("unknown value %q for enum %s", x, prop.Enum)
+				}
+				target.SetInt(int64(n))
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("cannot convert %T to %v", v, target.Type())
+}
+
+func setInt(target reflect.Value, v int64, unsigned bool) error {
+	switch target.Kind() {
+	case reflect.Int32, reflect.Int64:
+		if unsigned && v < 0 {
+			return fmt.Errorf("value %d overflows %v", uint64(v), target.Type())
+		}
+		if target.OverflowInt(v) {
+			return fmt.Errorf("value %d overflows %v", v, target.Type())
+		}
+		target.SetInt(v)
+		return nil
+	case reflect.Uint32, reflect.Uint64:
+		if !unsigned && v < 0 || target.OverflowUint(uint64(v)) {
+			return fmt.Errorf("value %d overflows %v", v, target.Type())
+		}
+		target.SetUint(uint64(v))
+		return nil
+	}
+	return fmt.Errorf("cannot convert integer to %v", target.Type())
+}
+
+func setFloat(target reflect.Value, v float64) error {
+	switch target.Kind() {
+	case reflect.Float32, reflect.Float64:
+		target.SetFloat(v)
+		return nil
+	}
+	return fmt.Errorf("cannot convert floating point to %v", target.Type())
+}
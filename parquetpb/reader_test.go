@@ -0,0 +1,201 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package parquetpb
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// The files in testdata are written by another implementation, using
+// dictionary encoding, Snappy compression and both versions of data pages.
+
+func openFile(t *testing.T, name string) *Reader {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestReadForeignFiles(t *testing.T) {
+	simple := []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(1), OString: proto.String("a"), ODouble: proto.Float64(1.5), OBool: proto.Bool(true), OUint64: proto.Uint64(1 << 63)},
+		&pb.Simple{OString: proto.String("a")},
+		&pb.Simple{OInt32: proto.Int32(-7), OString: proto.String("b"), OBool: proto.Bool(false)},
+	}
+	tests := []struct {
+		file    string
+		factory func() proto.Message
+		want    []proto.Message
+	}{
+		{"testdata/simple_snappy_v1.parquet", func() proto.Message { return new(pb.Simple) }, simple},
+		{"testdata/simple_snappy_v2.parquet", func() proto.Message { return new(pb.Simple) }, simple},
+		{"testdata/repeats_v1.parquet", func() proto.Message { return new(pb.Repeats) }, []proto.Message{
+			&pb.Repeats{RString: []string{"x", "y"}, RInt64: []int64{1, 2, 3}, RBool: []bool{true}},
+			&pb.Repeats{},
+			&pb.Repeats{RString: []string{"z"}},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.file, func(t *testing.T) {
+			got, err := openFile(t, tt.file).ReadAll(tt.factory)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkEqual(t, got, tt.want)
+		})
+	}
+}
+
+func TestReadDeltaEncodings(t *testing.T) {
+	got, err := openFile(t, "testdata/delta_v2.parquet").ReadAll(func() proto.Message { return new(pb.Simple) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 300 {
+		t.Fatalf("got %d messages, want 300", len(got))
+	}
+	for i, msg := range got {
+		want := &pb.Simple{
+			OInt64:  proto.Int64(int64(i*i) - 5000),
+			OInt32:  proto.Int32(int32(1000 - 7*i)),
+			OString: proto.String(fmt.Sprintf("key%04d", i/3)),
+		}
+		if !proto.Equal(msg, want) {
+			t.Fatalf("message %d: got %v, want %v", i, msg, want)
+		}
+	}
+}
+
+func TestReadUnknownFields(t *testing.T) {
+	r := openFile(t, "testdata/simple_snappy_v1.parquet")
+	factory := func() proto.Message { return new(pb.Simple3) }
+	if _, err := r.ReadAll(factory); err == nil || !strings.Contains(err.Error(), "unknown field") {
+		t.Errorf("got error %v", err)
+	}
+	r.AllowUnknownFields = true
+	got, err := r.ReadAll(factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Errorf("got %d messages, want 3", len(got))
+	}
+}
+
+func TestReadMismatch(t *testing.T) {
+	r := openFile(t, "testdata/repeats_v1.parquet")
+	_, err := r.ReadAll(func() proto.Message { return new(pb.SimpleSlice3) })
+	if err == nil {
+		t.Fatal("expected error")
+	}
+
+	r = openFile(t, "testdata/simple_snappy_v1.parquet")
+	_, err = r.ReadAll(func() proto.Message { return new(pb.Repeats) })
+	if err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestReadCorrupt(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/simple_snappy_v2.parquet")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		desc string
+		data []byte
+	}{
+		{"empty", nil},
+		{"no magic", append(append([]byte{}, data[:len(data)-1]...), 'X')},
+		{"truncated", data[len(data)/2:]},
+	}
+	for _, tt := range tests {
+		r, err := NewReader(bytes.NewReader(tt.data), int64(len(tt.data)))
+		if err == nil {
+			_, err = r.ReadAll(func() proto.Message { return new(pb.Simple) })
+		}
+		if err == nil {
+			t.Errorf("%s: expected error", tt.desc)
+		}
+	}
+}
+
+// TestReadMutated reads the files in testdata with every byte replaced by
+// a few values, which has to fail or succeed, but never panic.
+func TestReadMutated(t *testing.T) {
+	files := []struct {
+		name    string
+		factory func() proto.Message
+	}{
+		{"testdata/simple_snappy_v1.parquet", func() proto.Message { return new(pb.Simple) }},
+		{"testdata/simple_snappy_v2.parquet", func() proto.Message { return new(pb.Simple) }},
+		{"testdata/repeats_v1.parquet", func() proto.Message { return new(pb.Repeats) }},
+		{"testdata/delta_v2.parquet", func() proto.Message { return new(pb.Simple) }},
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mutated := make([]byte, len(data))
+		for i := range data {
+			for _, b := range []byte{0x00, 0x01, 0x7f, 0x80, 0xff, data[i] ^ 0x40} {
+				copy(mutated, data)
+				mutated[i] = b
+				func() {
+					defer func() {
+						if p := recover(); p != nil {
+							t.Errorf("%s with byte %d set to %#x: panic: %v", f.name, i, b, p)
+						}
+					}()
+					r, err := NewReader(bytes.NewReader(mutated), int64(len(mutated)))
+					if err == nil {
+						r.ReadAll(f.factory)
+					}
+				}()
+			}
+		}
+	}
+}
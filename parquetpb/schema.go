@@ -0,0 +1,204 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package parquetpb writes protocol buffers to Parquet files and reads Parquet
+files back into protocol buffers.
+
+The schema of a file is derived from the message type, every field being a
+column. Only messages of scalar, enum and bytes fields, singular or
+repeated, are supported. Enums are written by name.
+*/
+package parquetpb
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// physicalType is the Type of parquet.thrift.
+type physicalType int32
+
+const (
+	typeBoolean physicalType = iota
+	typeInt32
+	typeInt64
+	typeInt96
+	typeFloat
+	typeDouble
+	typeByteArray
+	typeFixedLenByteArray
+)
+
+func errUnsupportedType(t physicalType) error {
+	return fmt.Errorf("parquetpb: physical type %d not supported", t)
+}
+
+// FieldRepetitionType of parquet.thrift.
+const (
+	repetitionRequired = 0
+	repetitionOptional = 1
+	repetitionRepeated = 2
+)
+
+// ConvertedType of parquet.thrift, as far as used.
+const (
+	convertedNone   = -1
+	convertedUTF8   = 0
+	convertedList   = 3
+	convertedEnum   = 4
+	convertedUint32 = 13
+	convertedUint64 = 14
+)
+
+// column is a field of a message, stored as a single leaf column.
+type column struct {
+	name string
+	// field is the index of the struct field.
+	field int
+	prop  *proto.Properties
+	// kind is the kind of the field, or of its elements for repeated fields.
+	kind       reflect.Kind
+	physical   physicalType
+	converted  int32
+	repetition int32
+	enum       bool
+}
+
+// columnsOf returns the columns of the messages of struct type t.
+func columnsOf(t reflect.Type) ([]*column, error) {
+	var columns []*column
+	sprops := proto.GetProperties(t)
+	for i := 0; i < t.NumField(); i++ {
+		ft := t.Field(i)
+		if strings.HasPrefix(ft.Name, "XXX_") {
+			continue
+		}
+		prop := sprops.Prop[i]
+		if ft.Tag.Get("protobuf_oneof") != "" {
+			return nil, fmt.Errorf("parquetpb: oneof %s not supported", prop.OrigName)
+		}
+
+		c := &column{
+			name:       prop.OrigName,
+			field:      i,
+			prop:       prop,
+			converted:  convertedNone,
+			repetition: repetitionRequired,
+			enum:       prop.Enum != "",
+		}
+		et := ft.Type
+		switch {
+		case et.Kind() == reflect.Slice && et.Elem().Kind() != reflect.Uint8:
+			c.repetition = repetitionRepeated
+			et = et.Elem()
+		case et.Kind() == reflect.Ptr:
+			c.repetition = repetitionOptional
+			et = et.Elem()
+		case et.Kind() == reflect.Slice && !strings.Contains(ft.Tag.Get("protobuf"), ",proto3") && !prop.Required:
+			// proto2 bytes are unset while nil.
+			c.repetition = repetitionOptional
+		}
+		c.kind = et.Kind()
+
+		switch c.kind {
+		case reflect.Bool:
+			c.physical = typeBoolean
+		case reflect.Int32:
+			c.physical = typeInt32
+			if c.enum {
+				c.physical = typeByteArray
+				c.converted = convertedEnum
+			}
+		case reflect.Uint32:
+			c.physical = typeInt32
+			c.converted = convertedUint32
+		case reflect.Int64:
+			c.physical = typeInt64
+		case reflect.Uint64:
+			c.physical = typeInt64
+			c.converted = convertedUint64
+		case reflect.Float32:
+			c.physical = typeFloat
+		case reflect.Float64:
+			c.physical = typeDouble
+		case reflect.String:
+			c.physical = typeByteArray
+			c.converted = convertedUTF8
+		case reflect.Slice:
+			c.physical = typeByteArray
+		default:
+			return nil, fmt.Errorf("parquetpb: field %s of type %v not supported", prop.OrigName, ft.Type)
+		}
+		columns = append(columns, c)
+	}
+	return columns, nil
+}
+
+// messageType returns the struct type of pb.
+func messageType(pb proto.Message) (reflect.Type, error) {
+	t := reflect.TypeOf(pb)
+	if t == nil || t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("parquetpb: message must be a pointer to a struct")
+	}
+	return t.Elem(), nil
+}
+
+// physicalValue converts the value v of c into the representation of its
+// physical type.
+func (c *column) physicalValue(v reflect.Value) interface{} {
+	switch c.kind {
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Int32:
+		if c.enum {
+			return []byte(v.Interface().(fmt.Stringer).String())
+		}
+		return int32(v.Int())
+	case reflect.Uint32:
+		return int32(uint32(v.Uint()))
+	case reflect.Int64:
+		return v.Int()
+	case reflect.Uint64:
+		return int64(v.Uint())
+	case reflect.Float32:
+		return float32(v.Float())
+	case reflect.Float64:
+		return v.Float()
+	case reflect.String:
+		return []byte(v.String())
+	}
+	return v.Bytes()
+}
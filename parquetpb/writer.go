@@ -0,0 +1,442 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package parquetpb
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+
+	"github.com/golang/protobuf/proto"
+)
+
+// magic starts and ends every Parquet file.
+const magic = "PAR1"
+
+// DefaultRowGroupSize is the number of messages per row group, should
+// Writer.RowGroupSize be 0.
+const DefaultRowGroupSize = 10000
+
+// Compression is the CompressionCodec of parquet.thrift.
+type Compression int32
+
+// Compressions supported for writing. Snappy is supported for reading only.
+const (
+	Uncompressed Compression = 0
+	Snappy       Compression = 1
+	Gzip         Compression = 2
+)
+
+// Page types of parquet.thrift.
+const (
+	pageData       = 0
+	pageIndex      = 1
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+// Encodings of parquet.thrift.
+const (
+	encodingPlain                = 0
+	encodingPlainDictionary      = 2
+	encodingRLE                  = 3
+	encodingDeltaBinaryPacked    = 5
+	encodingDeltaLengthByteArray = 6
+	encodingDeltaByteArray       = 7
+	encodingRLEDictionary        = 8
+)
+
+// Writer writes messages of a single type as a Parquet file. Every field is
+// a column named by its original name. Repeated fields are written as
+// LIST, optional fields of proto2 as optional columns. Messages with
+// nested messages, oneofs or maps are not supported.
+type Writer struct {
+	// RowGroupSize is the number of messages per row group.
+	RowGroupSize int
+
+	// Compression of the pages. Must be set before the first Write.
+	Compression Compression
+
+	w         *countingWriter
+	name      string
+	typ       reflect.Type
+	columns   []*column
+	buffers   []columnBuffer
+	rows      int
+	rowGroups []rowGroupMeta
+	err       error
+}
+
+// columnBuffer holds the values of a column not yet written.
+type columnBuffer struct {
+	defs   []int
+	reps   []int
+	values []interface{}
+}
+
+type rowGroupMeta struct {
+	rows   int
+	size   int64
+	chunks []chunkMeta
+}
+
+type chunkMeta struct {
+	offset           int64
+	values           int
+	uncompressedSize int64
+	compressedSize   int64
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// NewWriter returns a Writer writing messages of the type of pb to w. The
+// schema is derived from the type of pb, its content is ignored.
+func NewWriter(w io.Writer, pb proto.Message) (*Writer, error) {
+	t, err := messageType(pb)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := columnsOf(t)
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		w:       &countingWriter{w: w},
+		name:    t.Name(),
+		typ:     t,
+		columns: columns,
+		buffers: make([]columnBuffer, len(columns)),
+	}, nil
+}
+
+// Write adds pb as the next row. Rows are buffered until a row group is
+// complete, so Close has to be called once done.
+func (w *Writer) Write(pb proto.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	v := reflect.ValueOf(pb)
+	if v.Type() != reflect.PtrTo(w.typ) {
+		return fmt.Errorf("parquetpb: cannot write %T to file of %v", pb, w.typ)
+	}
+	if v.IsNil() {
+		return errors.New("parquetpb: cannot write nil message")
+	}
+	s := v.Elem()
+	for i, c := range w.columns {
+		if err := w.buffers[i].add(c, s.Field(c.field)); err != nil {
+			return err
+		}
+	}
+	w.rows++
+
+	size := w.RowGroupSize
+	if size <= 0 {
+		size = DefaultRowGroupSize
+	}
+	if w.rows >= size {
+		return w.Flush()
+	}
+	return nil
+}
+
+func (b *columnBuffer) add(c *column, v reflect.Value) error {
+	switch c.repetition {
+	case repetitionRequired:
+		b.values = append(b.values, c.physicalValue(v))
+	case repetitionOptional:
+		if v.IsNil() {
+			if c.prop.Required {
+				return fmt.Errorf("parquetpb: required field %q is not set", c.prop.OrigName)
+			}
+			b.defs = append(b.defs, 0)
+			return nil
+		}
+		if v.Kind() == reflect.Ptr {
+			v = v.Elem()
+		}
+		b.defs = append(b.defs, 1)
+		b.values = append(b.values, c.physicalValue(v))
+	case repetitionRepeated:
+		if v.Len() == 0 {
+			b.defs = append(b.defs, 0)
+			b.reps = append(b.reps, 0)
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			b.defs = append(b.defs, 1)
+			if i == 0 {
+				b.reps = append(b.reps, 0)
+			} else {
+				b.reps = append(b.reps, 1)
+			}
+			b.values = append(b.values, c.physicalValue(v.Index(i)))
+		}
+	}
+	return nil
+}
+
+// Flush writes the buffered rows as a row group.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.rows == 0 {
+		return nil
+	}
+	if w.w.n == 0 {
+		if _, err := io.WriteString(w.w, magic); err != nil {
+			w.err = err
+			return err
+		}
+	}
+
+	rg := rowGroupMeta{rows: w.rows}
+	for i, c := range w.columns {
+		chunk, err := w.writeChunk(c, &w.buffers[i])
+		if err != nil {
+			w.err = err
+			return err
+		}
+		rg.size += chunk.uncompressedSize
+		rg.chunks = append(rg.chunks, chunk)
+		w.buffers[i] = columnBuffer{}
+	}
+	w.rowGroups = append(w.rowGroups, rg)
+	w.rows = 0
+	return nil
+}
+
+// writeChunk writes the values of b as a column chunk of a single data
+// page.
+func (w *Writer) writeChunk(c *column, b *columnBuffer) (chunkMeta, error) {
+	var page []byte
+	if c.repetition == repetitionRepeated {
+		page = appendLevels(page, b.reps)
+	}
+	if c.repetition != repetitionRequired {
+		page = appendLevels(page, b.defs)
+	}
+	if c.physical == typeBoolean {
+		page = appendPlainBools(page, b.values)
+	} else {
+		for _, v := range b.values {
+			page = appendPlain(page, c.physical, v)
+		}
+	}
+
+	compressed := page
+	switch w.Compression {
+	case Uncompressed:
+	case Gzip:
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(page)
+		if err := zw.Close(); err != nil {
+			return chunkMeta{}, err
+		}
+		compressed = buf.Bytes()
+	default:
+		return chunkMeta{}, fmt.Errorf("parquetpb: compression %d not supported for writing", w.Compression)
+	}
+
+	values := len(b.values)
+	if c.repetition != repetitionRequired {
+		values = len(b.defs)
+	}
+	var tw thriftWriter
+	tw.structBegin()
+	tw.fieldI32(1, pageData)
+	tw.fieldI32(2, int32(len(page)))
+	tw.fieldI32(3, int32(len(compressed)))
+	tw.fieldStruct(5)
+	tw.fieldI32(1, int32(values))
+	tw.fieldI32(2, encodingPlain)
+	tw.fieldI32(3, encodingRLE)
+	tw.fieldI32(4, encodingRLE)
+	tw.structEnd()
+	tw.structEnd()
+
+	chunk := chunkMeta{
+		offset:           w.w.n,
+		values:           values,
+		uncompressedSize: int64(len(tw.buf) + len(page)),
+		compressedSize:   int64(len(tw.buf) + len(compressed)),
+	}
+	if _, err := w.w.Write(tw.buf); err != nil {
+		return chunkMeta{}, err
+	}
+	if _, err := w.w.Write(compressed); err != nil {
+		return chunkMeta{}, err
+	}
+	return chunk, nil
+}
+
+// appendLevels appends levels of a data page, prefixed by their length.
+func appendLevels(buf []byte, levels []int) []byte {
+	start := len(buf)
+	buf = append(buf, 0, 0, 0, 0)
+	buf = appendRLE(buf, levels, 1)
+	n := len(buf) - start - 4
+	buf[start] = byte(n)
+	buf[start+1] = byte(n >> 8)
+	buf[start+2] = byte(n >> 16)
+	buf[start+3] = byte(n >> 24)
+	return buf
+}
+
+// Close flushes the buffered rows and writes the footer. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if w.w.n == 0 {
+		if _, err := io.WriteString(w.w, magic); err != nil {
+			return err
+		}
+	}
+
+	footer := w.footer()
+	footer = appendUint32(footer, uint32(len(footer)))
+	footer = append(footer, magic...)
+	_, err := w.w.Write(footer)
+	w.err = errors.New("parquetpb: write to closed Writer")
+	return err
+}
+
+// footer returns the FileMetaData.
+func (w *Writer) footer() []byte {
+	var tw thriftWriter
+	tw.structBegin()
+	tw.fieldI32(1, 1)
+
+	tw.fieldList(2, thriftStruct, 1+len(w.columns)+2*w.countRepeated())
+	tw.structBegin()
+	tw.fieldBinary(4, []byte(w.name))
+	tw.fieldI32(5, int32(len(w.columns)))
+	tw.structEnd()
+	for _, c := range w.columns {
+		if c.repetition == repetitionRepeated {
+			tw.structBegin()
+			tw.fieldI32(3, repetitionRequired)
+			tw.fieldBinary(4, []byte(c.name))
+			tw.fieldI32(5, 1)
+			tw.fieldI32(6, convertedList)
+			tw.structEnd()
+			tw.structBegin()
+			tw.fieldI32(3, repetitionRepeated)
+			tw.fieldBinary(4, []byte("list"))
+			tw.fieldI32(5, 1)
+			tw.structEnd()
+		}
+		tw.structBegin()
+		tw.fieldI32(1, int32(c.physical))
+		if c.repetition == repetitionRepeated {
+			tw.fieldI32(3, repetitionRequired)
+			tw.fieldBinary(4, []byte("element"))
+		} else {
+			tw.fieldI32(3, int32(c.repetition))
+			tw.fieldBinary(4, []byte(c.name))
+		}
+		if c.converted != convertedNone {
+			tw.fieldI32(6, c.converted)
+		}
+		tw.structEnd()
+	}
+
+	var rows int64
+	for _, rg := range w.rowGroups {
+		rows += int64(rg.rows)
+	}
+	tw.fieldI64(3, rows)
+
+	tw.fieldList(4, thriftStruct, len(w.rowGroups))
+	for _, rg := range w.rowGroups {
+		tw.structBegin()
+		tw.fieldList(1, thriftStruct, len(rg.chunks))
+		for i, chunk := range rg.chunks {
+			c := w.columns[i]
+			tw.structBegin()
+			tw.fieldI64(2, chunk.offset)
+			tw.fieldStruct(3)
+			tw.fieldI32(1, int32(c.physical))
+			tw.fieldList(2, thriftI32, 2)
+			tw.zigzag(encodingPlain)
+			tw.zigzag(encodingRLE)
+			if c.repetition == repetitionRepeated {
+				tw.fieldList(3, thriftBinary, 3)
+				tw.binary([]byte(c.name))
+				tw.binary([]byte("list"))
+				tw.binary([]byte("element"))
+			} else {
+				tw.fieldList(3, thriftBinary, 1)
+				tw.binary([]byte(c.name))
+			}
+			tw.fieldI32(4, int32(w.Compression))
+			tw.fieldI64(5, int64(chunk.values))
+			tw.fieldI64(6, chunk.uncompressedSize)
+			tw.fieldI64(7, chunk.compressedSize)
+			tw.fieldI64(9, chunk.offset)
+			tw.structEnd()
+			tw.structEnd()
+		}
+		tw.fieldI64(2, rg.size)
+		tw.fieldI64(3, int64(rg.rows))
+		tw.structEnd()
+	}
+	tw.fieldBinary(6, []byte("parquetpb"))
+	tw.structEnd()
+	return tw.buf
+}
+
+func (w *Writer) countRepeated() int {
+	n := 0
+	for _, c := range w.columns {
+		if c.repetition == repetitionRepeated {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,243 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package parquetpb
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// color is an enum, declared the way protoc-gen-go does.
+type color int32
+
+const (
+	colorRed   color = 0
+	colorGreen color = 1
+)
+
+var colorName = map[int32]string{0: "RED", 1: "GREEN"}
+var colorValue = map[string]int32{"RED": 0, "GREEN": 1}
+
+func (c color) String() string {
+	return proto.EnumName(colorName, int32(c))
+}
+
+func init() {
+	proto.RegisterEnum("parquetpb.Color", colorName, colorValue)
+}
+
+// palette is a proto3 message, declared the way protoc-gen-go does.
+type palette struct {
+	Name   string  `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Main   color   `protobuf:"varint,2,opt,name=main,proto3,enum=parquetpb.Color" json:"main,omitempty"`
+	Others []color `protobuf:"varint,3,rep,packed,name=others,proto3,enum=parquetpb.Color" json:"others,omitempty"`
+	Raw    []byte  `protobuf:"bytes,4,opt,name=raw,proto3" json:"raw,omitempty"`
+}
+
+func (m *palette) Reset()         { *m = palette{} }
+func (m *palette) String() string { return proto.CompactTextString(m) }
+func (*palette) ProtoMessage()    {}
+
+func roundTrip(t *testing.T, w func(*Writer), in []proto.Message, factory func() proto.Message) []proto.Message {
+	var buf bytes.Buffer
+	pw, err := NewWriter(&buf, in[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w != nil {
+		w(pw)
+	}
+	for _, msg := range in {
+		if err := pw.Write(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := pw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.NumRows() != int64(len(in)) {
+		t.Errorf("got %d rows, want %d", r.NumRows(), len(in))
+	}
+	out, err := r.ReadAll(factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func checkEqual(t *testing.T, got, want []proto.Message) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("message %d: got %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+var simples = []proto.Message{
+	&pb.Simple{
+		OBool:   proto.Bool(true),
+		OInt32:  proto.Int32(math.MinInt32),
+		OInt64:  proto.Int64(math.MaxInt64),
+		OUint32: proto.Uint32(math.MaxUint32),
+		OUint64: proto.Uint64(math.MaxUint64),
+		OSint32: proto.Int32(-1),
+		OFloat:  proto.Float32(1.5),
+		ODouble: proto.Float64(-2.25),
+		OString: proto.String("héllo"),
+		OBytes:  []byte{0, 1, 2},
+	},
+	&pb.Simple{},
+	&pb.Simple{OBool: proto.Bool(false), OBytes: []byte{}},
+}
+
+func TestRoundTrip(t *testing.T) {
+	tests := []struct {
+		desc    string
+		options func(*Writer)
+	}{
+		{"defaults", nil},
+		{"gzip", func(w *Writer) { w.Compression = Gzip }},
+		{"small row groups", func(w *Writer) { w.RowGroupSize = 2 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			out := roundTrip(t, tt.options, simples, func() proto.Message { return new(pb.Simple) })
+			checkEqual(t, out, simples)
+		})
+	}
+}
+
+func TestRoundTripRepeated(t *testing.T) {
+	in := []proto.Message{
+		&pb.Repeats{RString: []string{"a", "b"}, RInt64: []int64{1}, RBool: []bool{true, false, true}},
+		&pb.Repeats{},
+		&pb.Repeats{RBytes: [][]byte{{1}, {}}, RDouble: []float64{math.Inf(1)}},
+	}
+	out := roundTrip(t, nil, in, func() proto.Message { return new(pb.Repeats) })
+	checkEqual(t, out, in)
+}
+
+func TestRoundTripProto3(t *testing.T) {
+	in := []proto.Message{
+		&palette{Name: "warm", Main: colorGreen, Others: []color{colorRed, colorGreen}, Raw: []byte("x")},
+		&palette{},
+	}
+	out := roundTrip(t, nil, in, func() proto.Message { return new(palette) })
+	checkEqual(t, out, in)
+}
+
+func TestRowGroups(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, new(pb.Simple))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.RowGroupSize = 2
+	for i := 0; i < 5; i++ {
+		if err := w.Write(&pb.Simple{OInt32: proto.Int32(int32(i))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.NumRowGroups() != 3 {
+		t.Fatalf("got %d row groups, want 3", r.NumRowGroups())
+	}
+	rg, err := r.ReadRowGroup(2, func() proto.Message { return new(pb.Simple) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkEqual(t, rg, []proto.Message{&pb.Simple{OInt32: proto.Int32(4)}})
+}
+
+func TestEmptyFile(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, new(pb.Simple))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.NumRows() != 0 || r.NumRowGroups() != 0 {
+		t.Errorf("got %d rows in %d row groups", r.NumRows(), r.NumRowGroups())
+	}
+}
+
+func TestWriterErrors(t *testing.T) {
+	if _, err := NewWriter(new(bytes.Buffer), new(pb.Widget)); err == nil || !strings.Contains(err.Error(), "not supported") {
+		t.Errorf("Widget: got error %v", err)
+	}
+	if _, err := NewWriter(new(bytes.Buffer), new(pb.MsgWithOneof)); err == nil || !strings.Contains(err.Error(), "oneof") {
+		t.Errorf("MsgWithOneof: got error %v", err)
+	}
+
+	w, err := NewWriter(new(bytes.Buffer), new(pb.Simple))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(new(pb.Repeats)); err == nil {
+		t.Error("expected error writing another type")
+	}
+	w, err = NewWriter(new(bytes.Buffer), new(pb.MsgWithRequired))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Write(new(pb.MsgWithRequired)); err == nil || !strings.Contains(err.Error(), "required") {
+		t.Errorf("got error %v", err)
+	}
+}
@@ -0,0 +1,618 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/golang/protobuf/protoc-gen-go/generator"
+
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+// generate produces a .csvpb.go file for every file to generate.
+func generate(req *plugin.CodeGeneratorRequest) *plugin.CodeGeneratorResponse {
+	resp := new(plugin.CodeGeneratorResponse)
+	g := &fileGenerator{}
+	for _, p := range strings.Split(req.GetParameter(), ",") {
+		switch p {
+		case "":
+		case "paths=source_relative":
+			g.sourceRelative = true
+		case "paths=import":
+			g.sourceRelative = false
+		case "skip_unsupported=true":
+			g.skipUnsupported = true
+		case "skip_unsupported=false":
+			g.skipUnsupported = false
+		default:
+			resp.Error = proto.String(fmt.Sprintf("unknown parameter %q", p))
+			return resp
+		}
+	}
+
+	files := make(map[string]*descriptor.FileDescriptorProto)
+	// Go names declared by protoc-gen-go, per Go package
+	declared := make(map[goPackageKey]map[string]bool)
+	for _, f := range req.ProtoFile {
+		files[f.GetName()] = f
+		key := newGoPackageKey(f)
+		if declared[key] == nil {
+			declared[key] = make(map[string]bool)
+		}
+		addGoNames(declared[key], f)
+	}
+
+	for _, name := range req.FileToGenerate {
+		f, ok := files[name]
+		if !ok {
+			resp.Error = proto.String(fmt.Sprintf("no descriptor for %s", name))
+			return resp
+		}
+		g.declared = declared[newGoPackageKey(f)]
+		content, err := g.generateFile(f)
+		if err != nil {
+			resp.Error = proto.String(fmt.Sprintf("%s: %v", name, err))
+			return resp
+		}
+		resp.File = append(resp.File, &plugin.CodeGeneratorResponse_File{
+			Name:    proto.String(g.outputName(f)),
+			Content: proto.String(content),
+		})
+	}
+	return resp
+}
+
+// fileGenerator generates the code for a single .proto file.
+type fileGenerator struct {
+	sourceRelative  bool
+	skipUnsupported bool
+
+	// declared holds the Go names protoc-gen-go declares in the package of
+	// file.
+	declared map[string]bool
+	file     *descriptor.FileDescriptorProto
+	buf      bytes.Buffer
+}
+
+// goPackageKey identifies the Go package of a file.
+type goPackageKey struct {
+	importPath, name string
+}
+
+func newGoPackageKey(f *descriptor.FileDescriptorProto) goPackageKey {
+	importPath, name := goPackage(f)
+	if importPath == "" {
+		// Files without import path share a package by directory
+		importPath = path.Dir(f.GetName())
+	}
+	return goPackageKey{importPath, name}
+}
+
+// addGoNames adds the package level Go names protoc-gen-go declares for f
+// to names.
+func addGoNames(names map[string]bool, f *descriptor.FileDescriptorProto) {
+	addEnums := func(parent []string, enums []*descriptor.EnumDescriptorProto) {
+		for _, e := range enums {
+			goName := generator.CamelCaseSlice(append(append([]string(nil), parent...), e.GetName()))
+			names[goName] = true
+			names[goName+"_name"] = true
+			names[goName+"_value"] = true
+			// Values are prefixed by the enclosing message, if any
+			prefix := goName
+			if len(parent) > 0 {
+				prefix = generator.CamelCaseSlice(parent)
+			}
+			for _, v := range e.Value {
+				names[prefix+"_"+v.GetName()] = true
+			}
+		}
+	}
+	addExtensions := func(parent []string, exts []*descriptor.FieldDescriptorProto) {
+		for _, x := range exts {
+			names["E_"+generator.CamelCaseSlice(append(append([]string(nil), parent...), x.GetName()))] = true
+		}
+	}
+	var addMessages func(parent []string, descs []*descriptor.DescriptorProto)
+	addMessages = func(parent []string, descs []*descriptor.DescriptorProto) {
+		for _, d := range descs {
+			if d.GetOptions().GetMapEntry() {
+				continue
+			}
+			typeName := append(append([]string(nil), parent...), d.GetName())
+			goName := generator.CamelCaseSlice(typeName)
+			names[goName] = true
+			for _, fd := range d.Field {
+				if fd.OneofIndex != nil {
+					// The wrapper type of a oneof field
+					names[goName+"_"+generator.CamelCase(fd.GetName())] = true
+				}
+				if fd.DefaultValue != nil {
+					names["Default_"+goName+"_"+generator.CamelCase(fd.GetName())] = true
+				}
+			}
+			addEnums(typeName, d.EnumType)
+			addExtensions(typeName, d.Extension)
+			addMessages(typeName, d.NestedType)
+		}
+	}
+	addEnums(nil, f.EnumType)
+	addExtensions(nil, f.Extension)
+	addMessages(nil, f.MessageType)
+}
+
+// goPackage returns the import path and name of the Go package of f, the
+// same way protoc-gen-go determines them.
+func goPackage(f *descriptor.FileDescriptorProto) (importPath, name string) {
+	opt := f.GetOptions().GetGoPackage()
+	if i := strings.Index(opt, ";"); i >= 0 {
+		return opt[:i], opt[i+1:]
+	}
+	if strings.Contains(opt, "/") {
+		return opt, path.Base(opt)
+	}
+	if opt != "" {
+		return "", opt
+	}
+	if pkg := f.GetPackage(); pkg != "" {
+		return "", strings.Replace(pkg, ".", "_", -1)
+	}
+	return "", strings.TrimSuffix(path.Base(f.GetName()), ".proto")
+}
+
+func (g *fileGenerator) outputName(f *descriptor.FileDescriptorProto) string {
+	name := strings.TrimSuffix(f.GetName(), ".proto") + ".csvpb.go"
+	if importPath, _ := goPackage(f); importPath != "" && !g.sourceRelative {
+		return path.Join(importPath, path.Base(name))
+	}
+	return name
+}
+
+func (g *fileGenerator) P(args ...interface{}) {
+	for _, arg := range args {
+		fmt.Fprint(&g.buf, arg)
+	}
+	g.buf.WriteByte('\n')
+}
+
+func (g *fileGenerator) generateFile(f *descriptor.FileDescriptorProto) (string, error) {
+	g.file = f
+	g.buf.Reset()
+
+	var messages []*message
+	var unsupported []string
+	var collect func(parent []string, descs []*descriptor.DescriptorProto)
+	collect = func(parent []string, descs []*descriptor.DescriptorProto) {
+		for _, d := range descs {
+			if d.GetOptions().GetMapEntry() {
+				continue
+			}
+			typeName := append(append([]string(nil), parent...), d.GetName())
+			m, err := g.newMessage(typeName, d)
+			if err != nil {
+				unsupported = append(unsupported, fmt.Sprintf("%s: %v", strings.Join(typeName, "."), err))
+			} else {
+				messages = append(messages, m)
+			}
+			collect(typeName, d.NestedType)
+		}
+	}
+	collect(nil, f.MessageType)
+
+	if len(unsupported) > 0 && !g.skipUnsupported {
+		return "", fmt.Errorf("unsupported messages:\n\t%s", strings.Join(unsupported, "\n\t"))
+	}
+
+	var collisions []string
+	for _, m := range messages {
+		for _, name := range []string{m.goName + "Columns", m.goName + "Header"} {
+			if g.declared[name] {
+				collisions = append(collisions, name)
+			}
+		}
+	}
+	if len(collisions) > 0 {
+		return "", fmt.Errorf("names already declared by protoc-gen-go: %s", strings.Join(collisions, ", "))
+	}
+
+	var body bytes.Buffer
+	for _, m := range messages {
+		g.buf.Reset()
+		g.generateColumns(m)
+		g.generateMarshal(m)
+		g.generateUnmarshal(m)
+		body.Write(g.buf.Bytes())
+	}
+	if len(messages) > 0 {
+		g.buf.Reset()
+		g.generateRegistration(messages)
+		body.Write(g.buf.Bytes())
+	}
+
+	_, pkg := goPackage(f)
+	g.buf.Reset()
+	g.P("// Code generated by protoc-gen-csvpb. DO NOT EDIT.")
+	g.P("// source: ", f.GetName())
+	g.P()
+	g.P("package ", pkg)
+	g.P()
+	g.P("import (")
+	for _, imp := range []struct{ name, path string }{
+		{"base64.", "encoding/base64"},
+		{"csv.", "encoding/csv"},
+		{"fmt.", "fmt"},
+		{"strconv.", "strconv"},
+		{"", ""},
+		{"csvpb.", "github.com/abergmeier/golang-protobuf/csvpb"},
+	} {
+		if imp.name == "" {
+			g.P()
+		} else if bytes.Contains(body.Bytes(), []byte(imp.name)) {
+			g.P(strconv.Quote(imp.path))
+		}
+	}
+	g.P(")")
+	g.P()
+	if len(unsupported) > 0 {
+		g.P("// Skipped unsupported messages:")
+		for _, u := range unsupported {
+			g.P("// \t", u)
+		}
+		g.P()
+	}
+	g.buf.Write(body.Bytes())
+
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("generated invalid Go: %v", err)
+	}
+	return string(src), nil
+}
+
+// message is a message supported by the generator.
+type message struct {
+	goName string
+	fields []*field
+}
+
+// field is a field of a supported message.
+type field struct {
+	desc *descriptor.FieldDescriptorProto
+	// goName is the name of the struct field.
+	goName string
+	// jsonName is the lowerCamelCase name of the field.
+	jsonName string
+	// enumName is the Go type of an enum field.
+	enumName string
+	// pointer tells whether a singular field is stored as pointer.
+	pointer bool
+}
+
+func (g *fileGenerator) newMessage(typeName []string, d *descriptor.DescriptorProto) (*message, error) {
+	m := &message{
+		goName: generator.CamelCaseSlice(typeName),
+	}
+	proto3 := g.file.GetSyntax() == "proto3"
+	for _, fd := range d.Field {
+		f := &field{
+			desc:     fd,
+			goName:   generator.CamelCase(fd.GetName()),
+			jsonName: fd.GetJsonName(),
+		}
+		if f.jsonName == "" {
+			f.jsonName = jsonCamelCase(fd.GetName())
+		}
+		if fd.OneofIndex != nil {
+			return nil, fmt.Errorf("field %s: oneofs not supported", fd.GetName())
+		}
+
+		switch fd.GetType() {
+		case descriptor.FieldDescriptorProto_TYPE_MESSAGE, descriptor.FieldDescriptorProto_TYPE_GROUP:
+			return nil, fmt.Errorf("field %s: nested messages not supported", fd.GetName())
+		case descriptor.FieldDescriptorProto_TYPE_ENUM:
+			prefix := "." + g.file.GetPackage() + "."
+			if g.file.GetPackage() == "" {
+				prefix = "."
+			}
+			if !strings.HasPrefix(fd.GetTypeName(), prefix) {
+				return nil, fmt.Errorf("field %s: enums of other packages not supported", fd.GetName())
+			}
+			f.enumName = generator.CamelCaseSlice(strings.Split(strings.TrimPrefix(fd.GetTypeName(), prefix), "."))
+		}
+
+		f.pointer = !proto3 &&
+			fd.GetLabel() != descriptor.FieldDescriptorProto_LABEL_REPEATED &&
+			fd.GetType() != descriptor.FieldDescriptorProto_TYPE_BYTES
+		m.fields = append(m.fields, f)
+	}
+	return m, nil
+}
+
+// jsonCamelCase converts a field name to lowerCamelCase the way protoc
+// derives json_name.
+func jsonCamelCase(s string) string {
+	var b []byte
+	upper := false
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c == '_' {
+			upper = true
+			continue
+		}
+		if upper && 'a' <= c && c <= 'z' {
+			c -= 'a' - 'A'
+		}
+		upper = false
+		b = append(b, c)
+	}
+	return string(b)
+}
+
+func (f *field) repeated() bool {
+	return f.desc.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REPEATED
+}
+
+func (f *field) required() bool {
+	return f.desc.GetLabel() == descriptor.FieldDescriptorProto_LABEL_REQUIRED
+}
+
+// format returns an expression formatting the value v of f as a cell.
+func (f *field) format(v string) string {
+	switch f.desc.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "strconv.FormatBool(" + v + ")"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "strconv.FormatInt(int64(" + v + "), 10)"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "strconv.FormatInt(" + v + ", 10)"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "strconv.FormatUint(uint64(" + v + "), 10)"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "strconv.FormatUint(" + v + ", 10)"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "csvpb.FormatFloat(float64(" + v + "), 32)"
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "csvpb.FormatFloat(" + v + ", 64)"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "base64.StdEncoding.EncodeToString(" + v + ")"
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return v + ".String()"
+	}
+	return v
+}
+
+// parse returns an expression parsing the cell c into a value and an
+// error, along with the conversion of that value into the Go type of f.
+// The expression has no error, should conv be empty.
+func (f *field) parse(c string) (expr, conv string) {
+	switch f.desc.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "csvpb.ParseBool(" + c + ")", "%s"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "csvpb.ParseInt(" + c + ", 32)", "int32(%s)"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "csvpb.ParseInt(" + c + ", 64)", "%s"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "csvpb.ParseUint(" + c + ", 32)", "uint32(%s)"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "csvpb.ParseUint(" + c + ", 64)", "%s"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "csvpb.ParseFloat(" + c + ", 32)", "float32(%s)"
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "csvpb.ParseFloat(" + c + ", 64)", "%s"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "csvpb.ParseBytes(" + c + ")", "%s"
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return "csvpb.ParseEnum(" + c + ", " + f.enumName + "_value)", f.enumName + "(%s)"
+	}
+	return c, ""
+}
+
+func (g *fileGenerator) generateColumns(m *message) {
+	g.P("// ", m.goName, "Columns holds the column name of every field of ", m.goName, ",")
+	g.P("// as written by csvpb.Marshaler.")
+	g.P("var ", m.goName, "Columns = struct {")
+	for _, f := range m.fields {
+		g.P(f.goName, " string")
+	}
+	g.P("}{")
+	for _, f := range m.fields {
+		g.P(f.goName, ": ", strconv.Quote(f.jsonName), ",")
+	}
+	g.P("}")
+	g.P()
+	g.P("// ", m.goName, "Header is the header matching the records of ", m.goName, ".MarshalCSV.")
+	g.P("var ", m.goName, "Header = []string{")
+	for _, f := range m.fields {
+		g.P(m.goName, "Columns.", f.goName, ",")
+	}
+	g.P("}")
+	g.P()
+}
+
+func (g *fileGenerator) generateMarshal(m *message) {
+	g.P("// MarshalCSV converts m into a record matching ", m.goName, "Header.")
+	g.P("func (m *", m.goName, ") MarshalCSV() ([]string, error) {")
+	g.P("record := make([]string, ", len(m.fields), ")")
+	for i, f := range m.fields {
+		cell := fmt.Sprintf("record[%d]", i)
+		v := "m." + f.goName
+		switch {
+		case f.repeated():
+			g.P("{")
+			g.P("cells := make([]string, len(", v, "))")
+			g.P("for j, v := range ", v, " {")
+			g.P("cells[j] = ", f.format("v"))
+			g.P("}")
+			g.P("cell, err := csvpb.JoinList(cells)")
+			g.P("if err != nil {")
+			g.P("return nil, err")
+			g.P("}")
+			g.P(cell, " = cell")
+			g.P("}")
+		case f.pointer:
+			g.P("if ", v, " != nil {")
+			g.P(cell, " = ", f.format("*"+v))
+			g.P("} else {")
+			if f.required() {
+				g.P("return nil, fmt.Errorf(\"required field %q is not set\", ", strconv.Quote(f.desc.GetName()), ")")
+			} else {
+				g.P(cell, " = \"null\"")
+			}
+			g.P("}")
+		default:
+			g.P(cell, " = ", f.format(v))
+		}
+	}
+	g.P("return record, nil")
+	g.P("}")
+	g.P()
+}
+
+func (g *fileGenerator) generateUnmarshal(m *message) {
+	g.P("// UnmarshalCSV populates m from a record whose columns are named by header.")
+	g.P("// Columns may be named by either the original or the lowerCamelCase")
+	g.P("// name of a field.")
+	g.P("func (m *", m.goName, ") UnmarshalCSV(header, record []string) error {")
+	g.P("if len(header) != len(record) {")
+	g.P("return csv.ErrFieldCount")
+	g.P("}")
+	g.P("m.Reset()")
+	g.P("for i, name := range header {")
+	g.P("cell := record[i]")
+	g.P("switch name {")
+	for _, f := range m.fields {
+		if f.jsonName == f.desc.GetName() {
+			g.P("case ", strconv.Quote(f.jsonName), ":")
+		} else {
+			g.P("case ", strconv.Quote(f.desc.GetName()), ", ", strconv.Quote(f.jsonName), ":")
+		}
+		v := "m." + f.goName
+		switch {
+		case f.repeated():
+			g.P("cells, err := csvpb.SplitList(cell)")
+			g.P("if err != nil {")
+			g.P("return fmt.Errorf(\"column %q: %v\", name, err)")
+			g.P("}")
+			expr, conv := f.parse("c")
+			if conv == "" {
+				g.P(v, " = cells")
+				continue
+			}
+			g.P(v, " = make(", g.goType(f), ", len(cells))")
+			g.P("for j, c := range cells {")
+			g.P("v, err := ", expr)
+			g.P("if err != nil {")
+			g.P("return fmt.Errorf(\"column %q: %v\", name, err)")
+			g.P("}")
+			g.P(v, "[j] = ", fmt.Sprintf(conv, "v"))
+			g.P("}")
+		default:
+			if f.pointer {
+				g.P("if cell == \"null\" {")
+				g.P("continue")
+				g.P("}")
+			}
+			expr, conv := f.parse("cell")
+			value := expr
+			if conv != "" {
+				g.P("v, err := ", expr)
+				g.P("if err != nil {")
+				g.P("return fmt.Errorf(\"column %q: %v\", name, err)")
+				g.P("}")
+				value = fmt.Sprintf(conv, "v")
+			}
+			if f.pointer {
+				g.P("x := ", value)
+				g.P(v, " = &x")
+			} else {
+				g.P(v, " = ", value)
+			}
+		}
+	}
+	g.P("default:")
+	g.P("return fmt.Errorf(\"unknown field %q in ", m.goName, "\", name)")
+	g.P("}")
+	g.P("}")
+	for _, f := range m.fields {
+		if f.required() {
+			g.P("if m.", f.goName, " == nil {")
+			g.P("return fmt.Errorf(\"required field %q is not set\", ", strconv.Quote(f.desc.GetName()), ")")
+			g.P("}")
+		}
+	}
+	g.P("return nil")
+	g.P("}")
+	g.P()
+}
+
+// generateRegistration registers the generated code of messages as
+// csvpb.FastCodec.
+func (g *fileGenerator) generateRegistration(messages []*message) {
+	g.P("func init() {")
+	for _, m := range messages {
+		g.P("csvpb.RegisterFastCodec((*", m.goName, ")(nil), csvpb.GeneratedCodec(", m.goName, "Header))")
+	}
+	g.P("}")
+}
+
+// goType returns the Go type of a repeated field.
+func (g *fileGenerator) goType(f *field) string {
+	switch f.desc.GetType() {
+	case descriptor.FieldDescriptorProto_TYPE_BOOL:
+		return "[]bool"
+	case descriptor.FieldDescriptorProto_TYPE_INT32, descriptor.FieldDescriptorProto_TYPE_SINT32, descriptor.FieldDescriptorProto_TYPE_SFIXED32:
+		return "[]int32"
+	case descriptor.FieldDescriptorProto_TYPE_INT64, descriptor.FieldDescriptorProto_TYPE_SINT64, descriptor.FieldDescriptorProto_TYPE_SFIXED64:
+		return "[]int64"
+	case descriptor.FieldDescriptorProto_TYPE_UINT32, descriptor.FieldDescriptorProto_TYPE_FIXED32:
+		return "[]uint32"
+	case descriptor.FieldDescriptorProto_TYPE_UINT64, descriptor.FieldDescriptorProto_TYPE_FIXED64:
+		return "[]uint64"
+	case descriptor.FieldDescriptorProto_TYPE_FLOAT:
+		return "[]float32"
+	case descriptor.FieldDescriptorProto_TYPE_DOUBLE:
+		return "[]float64"
+	case descriptor.FieldDescriptorProto_TYPE_BYTES:
+		return "[][]byte"
+	case descriptor.FieldDescriptorProto_TYPE_ENUM:
+		return "[]" + f.enumName
+	}
+	return "[]string"
+}
@@ -0,0 +1,324 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/protoc-gen-go/descriptor"
+
+	_ "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+func fileDescriptor(t *testing.T, name string) *descriptor.FileDescriptorProto {
+	gz := proto.FileDescriptor(name)
+	if gz == nil {
+		t.Fatalf("%s not registered", name)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(gz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := new(descriptor.FileDescriptorProto)
+	if err := proto.Unmarshal(data, fd); err != nil {
+		t.Fatal(err)
+	}
+	return fd
+}
+
+func request(t *testing.T, parameter string, names ...string) *plugin.CodeGeneratorRequest {
+	req := &plugin.CodeGeneratorRequest{
+		Parameter:      proto.String(parameter),
+		FileToGenerate: names,
+	}
+	for _, name := range names {
+		req.ProtoFile = append(req.ProtoFile, fileDescriptor(t, name))
+	}
+	return req
+}
+
+func TestGenerate(t *testing.T) {
+	resp := generate(request(t, "skip_unsupported=true", "test_objects.proto", "more_test_objects.proto"))
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	if len(resp.File) != 2 {
+		t.Fatalf("got %d files, want 2", len(resp.File))
+	}
+
+	for _, tt := range []struct {
+		file     string
+		contains []string
+		excludes []string
+	}{
+		{
+			file: "test_objects.csvpb.go",
+			contains: []string{
+				"package jsonpb\n",
+				"func (m *Simple) MarshalCSV() ([]string, error) {",
+				"var SimpleColumns = struct {",
+				"\tOInt32     string\n",
+				"\tOInt32:     \"oInt32\",\n",
+				"var SimpleHeader = []string{\n\tSimpleColumns.OBool,\n\tSimpleColumns.OInt32,\n",
+				"func (m *Simple) UnmarshalCSV(header, record []string) error {",
+				"func (m *Repeats) UnmarshalCSV(header, record []string) error {",
+				`case "o_bool", "oBool":`,
+				`return fmt.Errorf("required field %q is not set", "str")`,
+				"// \tWidget: field simple: nested messages not supported",
+				"csvpb.RegisterFastCodec((*Simple)(nil), csvpb.GeneratedCodec(SimpleHeader))\n",
+			},
+			excludes: []string{
+				"func (m *Widget) ",
+				"func (m *MsgWithOneof) ",
+			},
+		},
+		{
+			file: "more_test_objects.csvpb.go",
+			contains: []string{
+				"func (m *Simple3) MarshalCSV() ([]string, error) {",
+				"m.Slices = cells",
+			},
+			excludes: []string{
+				"func (m *Mappy) ",
+				`== "null"`,
+			},
+		},
+	} {
+		var content string
+		for _, f := range resp.File {
+			if f.GetName() == tt.file {
+				content = f.GetContent()
+			}
+		}
+		if content == "" {
+			t.Errorf("%s not generated", tt.file)
+			continue
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(content, s) {
+				t.Errorf("%s does not contain %q:\n%s", tt.file, s, content)
+			}
+		}
+		for _, s := range tt.excludes {
+			if strings.Contains(content, s) {
+				t.Errorf("%s contains %q", tt.file, s)
+			}
+		}
+	}
+}
+
+func TestGenerateUnsupported(t *testing.T) {
+	resp := generate(request(t, "", "test_objects.proto"))
+	if !strings.Contains(resp.GetError(), "Widget: field simple: nested messages not supported") {
+		t.Errorf("got error %q", resp.GetError())
+	}
+}
+
+func TestGenerateEnum(t *testing.T) {
+	req := &plugin.CodeGeneratorRequest{
+		FileToGenerate: []string{"e.proto"},
+		ProtoFile: []*descriptor.FileDescriptorProto{{
+			Name:    proto.String("e.proto"),
+			Package: proto.String("x.y"),
+			Syntax:  proto.String("proto3"),
+			MessageType: []*descriptor.DescriptorProto{{
+				Name: proto.String("M"),
+				Field: []*descriptor.FieldDescriptorProto{{
+					Name:     proto.String("kind"),
+					Number:   proto.Int32(1),
+					Label:    descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptor.FieldDescriptorProto_TYPE_ENUM.Enum(),
+					TypeName: proto.String(".x.y.M.Kind"),
+				}},
+				EnumType: []*descriptor.EnumDescriptorProto{{
+					Name: proto.String("Kind"),
+				}},
+			}},
+		}},
+	}
+	resp := generate(req)
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+	content := resp.File[0].GetContent()
+	for _, s := range []string{
+		"package x_y\n",
+		"record[0] = m.Kind.String()",
+		"v, err := csvpb.ParseEnum(cell, M_Kind_value)",
+		"m.Kind = M_Kind(v)",
+	} {
+		if !strings.Contains(content, s) {
+			t.Errorf("output does not contain %q:\n%s", s, content)
+		}
+	}
+}
+
+func TestGenerateCollision(t *testing.T) {
+	message := func(name string) *descriptor.DescriptorProto {
+		return &descriptor.DescriptorProto{
+			Name: proto.String(name),
+			Field: []*descriptor.FieldDescriptorProto{{
+				Name:   proto.String("id"),
+				Number: proto.Int32(1),
+				Label:  descriptor.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:   descriptor.FieldDescriptorProto_TYPE_INT64.Enum(),
+			}},
+		}
+	}
+	file := func(name string, messages ...*descriptor.DescriptorProto) *descriptor.FileDescriptorProto {
+		return &descriptor.FileDescriptorProto{
+			Name:        proto.String(name),
+			Package:     proto.String("x"),
+			Options:     &descriptor.FileOptions{GoPackage: proto.String("example.com/x")},
+			MessageType: messages,
+		}
+	}
+	outer := message("Outer")
+	outer.NestedType = []*descriptor.DescriptorProto{message("Inner")}
+	outer.EnumType = []*descriptor.EnumDescriptorProto{{
+		Name:  proto.String("Kind"),
+		Value: []*descriptor.EnumValueDescriptorProto{{Name: proto.String("InnerColumns"), Number: proto.Int32(0)}},
+	}}
+	tests := []struct {
+		desc  string
+		files []*descriptor.FileDescriptorProto
+		want  string
+	}{
+		{"message", []*descriptor.FileDescriptorProto{file("a.proto", message("Request"), message("RequestHeader"))}, "RequestHeader"},
+		{"enum value", []*descriptor.FileDescriptorProto{file("a.proto", outer)}, "Outer_InnerColumns"},
+		{"other file", []*descriptor.FileDescriptorProto{file("a.proto", message("Request")), file("b.proto", message("RequestColumns"))}, "RequestColumns"},
+		{"other package", []*descriptor.FileDescriptorProto{file("a.proto", message("Request")), {
+			Name:        proto.String("b.proto"),
+			Options:     &descriptor.FileOptions{GoPackage: proto.String("example.com/y")},
+			MessageType: []*descriptor.DescriptorProto{message("RequestColumns")},
+		}}, ""},
+	}
+	for _, tt := range tests {
+		resp := generate(&plugin.CodeGeneratorRequest{FileToGenerate: []string{"a.proto"}, ProtoFile: tt.files})
+		if tt.want == "" {
+			if resp.Error != nil {
+				t.Errorf("%s: got error %q", tt.desc, resp.GetError())
+			}
+			continue
+		}
+		if !strings.Contains(resp.GetError(), "names already declared by protoc-gen-go: "+tt.want) {
+			t.Errorf("%s: got error %q, want %s", tt.desc, resp.GetError(), tt.want)
+		}
+	}
+}
+
+func TestGenerateBadParameter(t *testing.T) {
+	resp := generate(request(t, "plugins=grpc", "test_objects.proto"))
+	if resp.GetError() != `unknown parameter "plugins=grpc"` {
+		t.Errorf("got error %q", resp.GetError())
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	tests := []struct {
+		desc           string
+		goPackage      string
+		sourceRelative bool
+		want           string
+	}{
+		{"no go_package", "", false, "dir/a.csvpb.go"},
+		{"import path", "example.com/x/pb", false, "example.com/x/pb/a.csvpb.go"},
+		{"source relative", "example.com/x/pb", true, "dir/a.csvpb.go"},
+		{"import path with name", "example.com/x/pb;xpb", false, "example.com/x/pb/a.csvpb.go"},
+	}
+	for _, tt := range tests {
+		f := &descriptor.FileDescriptorProto{
+			Name:    proto.String("dir/a.proto"),
+			Options: &descriptor.FileOptions{GoPackage: proto.String(tt.goPackage)},
+		}
+		g := &fileGenerator{sourceRelative: tt.sourceRelative}
+		if got := g.outputName(f); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.desc, got, tt.want)
+		}
+	}
+}
+
+// TestGeneratedCompiles type checks the generated code together with the
+// package generated by protoc-gen-go.
+func TestGeneratedCompiles(t *testing.T) {
+	if testing.Short() {
+		t.Skip("type checking from source is slow")
+	}
+	pkg, err := build.Import("github.com/golang/protobuf/jsonpb/jsonpb_test_proto", "", build.FindOnly)
+	if err != nil {
+		t.Skip(err)
+	}
+
+	resp := generate(request(t, "skip_unsupported=true", "test_objects.proto", "more_test_objects.proto"))
+	if resp.Error != nil {
+		t.Fatal(resp.GetError())
+	}
+
+	fset := token.NewFileSet()
+	var files []*ast.File
+	for _, name := range []string{"test_objects.pb.go", "more_test_objects.pb.go"} {
+		f, err := parser.ParseFile(fset, filepath.Join(pkg.Dir, name), nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+	for _, gen := range resp.File {
+		f, err := parser.ParseFile(fset, gen.GetName(), gen.GetContent(), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, f)
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("jsonpb", fset, files, nil); err != nil {
+		t.Error(err)
+	}
+}
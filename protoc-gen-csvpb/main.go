@@ -0,0 +1,102 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+protoc-gen-csvpb is a plugin for the Google protocol buffer compiler to
+generate CSV codecs. Run it by building this program and putting it in your
+path with the name
+
+	protoc-gen-csvpb
+
+so that, next to protoc-gen-go, you can run
+
+	protoc --go_out=output_directory --csvpb_out=output_directory input_directory/file.proto
+
+to generate output_directory/file.csvpb.go.
+
+For every message the generated file holds MarshalCSV and UnmarshalCSV
+methods, converting between the message and a CSV record by direct field
+access instead of reflection. They accept and produce the same cells as
+csvpb.Marshaler and csvpb.Unmarshaler do. Next to them, the variables
+<Message>Columns and <Message>Header name the columns of every field, so
+that code can refer to columns without repeating their names:
+
+	record[0] = pb.SimpleColumns.OInt32 // "oInt32"
+
+Generation fails, should these names collide with declarations of
+protoc-gen-go in the same Go package, like a message RequestHeader next to
+a message Request.
+
+The methods are registered as csvpb.FastCodec, so csvpb.Marshaler and
+csvpb.Unmarshaler use them instead of reflection whenever they are
+configured with default conversions.
+
+Only messages consisting of scalar, enum and bytes fields, singular or
+repeated, are supported. Generation fails for any other message, unless the
+parameter skip_unsupported=true is passed, which skips such messages. The
+parameter paths=source_relative places output files next to their input
+files, instead of deriving the directory from the go_package option.
+*/
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/golang/protobuf/proto"
+
+	plugin "github.com/golang/protobuf/protoc-gen-go/plugin"
+)
+
+func main() {
+	data, err := ioutil.ReadAll(os.Stdin)
+	if err != nil {
+		fail(err)
+	}
+
+	req := new(plugin.CodeGeneratorRequest)
+	if err := proto.Unmarshal(data, req); err != nil {
+		fail(err)
+	}
+
+	data, err = proto.Marshal(generate(req))
+	if err != nil {
+		fail(err)
+	}
+	if _, err := os.Stdout.Write(data); err != nil {
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	os.Stderr.WriteString("protoc-gen-csvpb: " + err.Error() + "\n")
+	os.Exit(1)
+}
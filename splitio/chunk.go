@@ -0,0 +1,225 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+)
+
+// Chunker cuts its input into chunks of whole records, each buffered in
+// memory so they can be processed independently.
+type Chunker struct {
+	// CSV makes chunks end at CSV record boundaries rather than at any
+	// newline, never cutting a quoted cell.
+	CSV bool
+
+	// Header makes every chunk start with the first record of the input,
+	// so each chunk can be decoded on its own. The header does not count
+	// towards the size of chunks.
+	Header bool
+
+	br     *bufio.Reader
+	target int
+	header []byte
+	err    error
+}
+
+// ChunkReader returns a Chunker yielding chunks of roughly targetBytes of
+// r each. Every chunk but the last is cut at the first newline after
+// targetBytes, so it is at least that long.
+func ChunkReader(r io.Reader, targetBytes int) *Chunker {
+	if targetBytes < 1 {
+		targetBytes = 1
+	}
+	return &Chunker{
+		br:     bufio.NewReader(r),
+		target: targetBytes,
+	}
+}
+
+// Next returns a Reader for the next chunk. Returns io.EOF once all of the
+// input is returned.
+func (c *Chunker) Next() (io.Reader, error) {
+	if c.err != nil {
+		return nil, c.err
+	}
+	if c.Header && c.header == nil {
+		header, err := c.finishRecord([]byte{}, new(recordState))
+		c.header = header
+		if err != nil {
+			// No records after the header
+			c.err = err
+			return nil, err
+		}
+	}
+
+	buf := make([]byte, len(c.header)+c.target)
+	copy(buf, c.header)
+	n, err := io.ReadFull(c.br, buf[len(c.header):])
+	buf = buf[:len(c.header)+n]
+	switch err {
+	case nil:
+	case io.ErrUnexpectedEOF:
+		c.err = io.EOF
+		return bytes.NewReader(buf), nil
+	default:
+		c.err = err
+		return nil, err
+	}
+
+	var s recordState
+	body := buf[len(c.header):]
+	if c.CSV {
+		// Find the quoting at the end of body.
+		for i := s.boundary(body); i >= 0; i = s.boundary(body) {
+			body = body[i:]
+		}
+	}
+	if !s.quoted && buf[len(buf)-1] == '\n' {
+		return bytes.NewReader(buf), nil
+	}
+	buf, err = c.finishRecord(buf, &s)
+	if err == io.EOF {
+		c.err = io.EOF
+	} else if err != nil {
+		c.err = err
+		return nil, err
+	}
+	return bytes.NewReader(buf), nil
+}
+
+// finishRecord appends lines of the input to buf until the end of the
+// current record, with s the quoting so far.
+func (c *Chunker) finishRecord(buf []byte, s *recordState) ([]byte, error) {
+	for {
+		line, err := c.br.ReadBytes('\n')
+		buf = append(buf, line...)
+		if err != nil {
+			return buf, err
+		}
+		if !c.CSV || s.boundary(line) >= 0 {
+			return buf, nil
+		}
+	}
+}
+
+// SectionsAt cuts the first size bytes of r into sections like
+// ChunkReader, with csv in place of Chunker.CSV. Rather than buffering the
+// chunks, it computes their offsets once. The returned readers share no
+// state, so they may be read concurrently, like the sections of an
+// *os.File.
+//
+// Finding CSV record boundaries requires reading all of r; otherwise only
+// the bytes around the cuts are read.
+func SectionsAt(r io.ReaderAt, size int64, targetBytes int, csv bool) ([]*io.SectionReader, error) {
+	target := int64(targetBytes)
+	if target < 1 {
+		target = 1
+	}
+	var sections []*io.SectionReader
+	var s recordState
+	buf := make([]byte, 32*1024)
+	// pos is the offset of the first byte not scanned yet.
+	start, pos := int64(0), int64(0)
+	for start < size {
+		// A section ends with the first newline from cut on.
+		cut := start + target - 1
+		if !csv && pos < cut {
+			pos = cut
+		}
+		end := size
+	scan:
+		for pos < size {
+			p := buf
+			if rest := size - pos; rest < int64(len(p)) {
+				p = p[:rest]
+			}
+			n, err := r.ReadAt(p, pos)
+			if n < len(p) {
+				if err == nil || err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+			for i, c := range p {
+				switch {
+				case c == '"' && csv:
+					s.quoted = !s.quoted
+				case c == '\n' && !s.quoted && pos+int64(i) >= cut:
+					end = pos + int64(i) + 1
+					pos = end
+					break scan
+				}
+			}
+			pos += int64(n)
+		}
+		sections = append(sections, io.NewSectionReader(r, start, end-start))
+		start = end
+	}
+	return sections, nil
+}
+
+// ShardsAt cuts the first size bytes of r, a CSV file, into shards like
+// SectionsAt. Every shard starts with the header of the file, so each can
+// be decoded on its own. The header does not count towards the size of
+// shards. Returns no shards if there are no records after the header.
+func ShardsAt(r io.ReaderAt, size int64, targetBytes int) ([]io.Reader, error) {
+	var s recordState
+	br := bufio.NewReader(io.NewSectionReader(r, 0, size))
+	var header []byte
+	for {
+		line, err := br.ReadBytes('\n')
+		header = append(header, line...)
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if s.boundary(line) >= 0 {
+			break
+		}
+	}
+
+	body := io.NewSectionReader(r, int64(len(header)), size-int64(len(header)))
+	sections, err := SectionsAt(body, body.Size(), targetBytes, true)
+	if err != nil {
+		return nil, err
+	}
+	shards := make([]io.Reader, len(sections))
+	for i, section := range sections {
+		shards[i] = io.MultiReader(bytes.NewReader(header), section)
+	}
+	return shards, nil
+}
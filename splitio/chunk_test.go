@@ -0,0 +1,210 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var chunkReaderTests = []struct {
+	name   string
+	input  string
+	target int
+	csv    bool
+	want   []string
+}{
+	{"Lines test", "a\nbb\nccc\nd\n", 3, false, []string{"a\nbb\n", "ccc\n", "d\n"}},
+	{"Exact test", "aa\nbb\n", 3, false, []string{"aa\n", "bb\n"}},
+	{"Unterminated test", "aaaa\nb", 2, false, []string{"aaaa\n", "b"}},
+	{"Single test", "a\nb\nc", 100, false, []string{"a\nb\nc"}},
+	{"Zero target test", "a\nb\n", 0, false, []string{"a\n", "b\n"}},
+	{"Empty test", "", 3, false, nil},
+	{"Quoted newline test", "a,\"b\nc\"\nd\n", 3, true, []string{"a,\"b\nc\"\n", "d\n"}},
+	{"Quote before target test", "\"a\nb\nc\"\nd\n", 4, true, []string{"\"a\nb\nc\"\n", "d\n"}},
+	{"Boundary at target test", "a\n\"b\nc\"\n", 2, true, []string{"a\n", "\"b\nc\"\n"}},
+	{"Quotes ignored test", "a,\"b\nc\"\nd\n", 3, false, []string{"a,\"b\n", "c\"\n", "d\n"}},
+}
+
+func TestChunkReader(t *testing.T) {
+	for _, st := range chunkReaderTests {
+		c := ChunkReader(iotest.OneByteReader(strings.NewReader(st.input)), st.target)
+		c.CSV = st.csv
+		var got []string
+		for {
+			r, err := c.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, readAll(t, r))
+		}
+		if !reflect.DeepEqual(got, st.want) {
+			t.Errorf("%s: got %q, expected %q", st.name, got, st.want)
+		}
+	}
+}
+
+func TestChunkReaderError(t *testing.T) {
+	c := ChunkReader(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("a\nb\n"))), 1)
+	if _, err := c.Next(); err != iotest.ErrTimeout {
+		t.Errorf("got %v, expected %v", err, iotest.ErrTimeout)
+	}
+	if _, err := c.Next(); err != iotest.ErrTimeout {
+		t.Errorf("got %v, expected the error to stick", err)
+	}
+}
+
+// TestChunkReaderIndependent reads chunks out of order.
+func TestChunkReaderIndependent(t *testing.T) {
+	c := ChunkReader(strings.NewReader("a\nb\n"), 1)
+	first, _ := c.Next()
+	second, _ := c.Next()
+	if b, _ := ioutil.ReadAll(second); string(b) != "b\n" {
+		t.Errorf("got %q, expected %q", b, "b\n")
+	}
+	if b, _ := ioutil.ReadAll(first); string(b) != "a\n" {
+		t.Errorf("got %q, expected %q", b, "a\n")
+	}
+}
+
+func TestSectionsAt(t *testing.T) {
+	for _, st := range chunkReaderTests {
+		sections, err := SectionsAt(strings.NewReader(st.input), int64(len(st.input)), st.target, st.csv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range sections {
+			got = append(got, readAll(t, r))
+		}
+		if !reflect.DeepEqual(got, st.want) {
+			t.Errorf("%s: got %q, expected %q", st.name, got, st.want)
+		}
+	}
+}
+
+func TestSectionsAtLarge(t *testing.T) {
+	input := strings.Repeat("1,\"x\ny\"\n", 10000)
+	sections, err := SectionsAt(strings.NewReader(input), int64(len(input)), 10000, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sections) != 8 {
+		t.Errorf("got %d sections, expected 8", len(sections))
+	}
+
+	got := make([]string, len(sections))
+	done := make(chan struct{})
+	for i := range sections {
+		go func(i int) {
+			b, _ := ioutil.ReadAll(sections[i])
+			got[i] = string(b)
+			done <- struct{}{}
+		}(i)
+	}
+	for range sections {
+		<-done
+	}
+	for i, s := range got {
+		if !strings.HasPrefix(s, "1,") || !strings.HasSuffix(s, "\"\n") {
+			t.Errorf("section %d not cut at a record boundary", i)
+		}
+	}
+	if strings.Join(got, "") != input {
+		t.Error("sections do not cover the input")
+	}
+}
+
+func TestSectionsAtShort(t *testing.T) {
+	if _, err := SectionsAt(strings.NewReader("a\nb\n"), 10, 1, true); err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, expected %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+var shardTests = []struct {
+	name   string
+	input  string
+	target int
+	want   []string
+}{
+	{"Shards test", "a,b\n1,2\n3,4\n5,6\n", 8, []string{"a,b\n1,2\n3,4\n", "a,b\n5,6\n"}},
+	{"Quoted header test", "\"a\nb\",c\n1,2\n3,4\n", 1, []string{"\"a\nb\",c\n1,2\n", "\"a\nb\",c\n3,4\n"}},
+	{"Quoted body test", "a\n\"1\n2\"\n3", 3, []string{"a\n\"1\n2\"\n", "a\n3"}},
+	{"Header only test", "a,b\n", 3, nil},
+	{"Unterminated header test", "a,b", 3, nil},
+	{"Empty test", "", 3, nil},
+}
+
+func TestChunkReaderHeader(t *testing.T) {
+	for _, st := range shardTests {
+		c := ChunkReader(iotest.OneByteReader(strings.NewReader(st.input)), st.target)
+		c.CSV = true
+		c.Header = true
+		var got []string
+		for {
+			r, err := c.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got = append(got, readAll(t, r))
+		}
+		if !reflect.DeepEqual(got, st.want) {
+			t.Errorf("%s: got %q, expected %q", st.name, got, st.want)
+		}
+	}
+}
+
+func TestShardsAt(t *testing.T) {
+	for _, st := range shardTests {
+		shards, err := ShardsAt(strings.NewReader(st.input), int64(len(st.input)), st.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, r := range shards {
+			got = append(got, readAll(t, r))
+		}
+		if !reflect.DeepEqual(got, st.want) {
+			t.Errorf("%s: got %q, expected %q", st.name, got, st.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// countingCloser counts how often it is closed.
+type countingCloser struct {
+	io.Reader
+	closes int
+}
+
+func (c *countingCloser) Close() error {
+	c.closes++
+	return nil
+}
+
+func TestClose(t *testing.T) {
+	splits := map[string]func(io.Reader) []io.Reader{
+		"Sequential": func(r io.Reader) []io.Reader {
+			lhs, rhs := NewReadersSequential(r, '\n')
+			return []io.Reader{lhs, rhs}
+		},
+		"Buffered": func(r io.Reader) []io.Reader {
+			lhs, rhs := NewReadersBuffered(r, '\n')
+			return []io.Reader{lhs, rhs}
+		},
+		"SplitN": func(r io.Reader) []io.Reader {
+			return SplitN(r, '\n', 3)
+		},
+		"Func": func(r io.Reader) []io.Reader {
+			lhs, rhs := NewReadersFunc(r, bufio.ScanLines)
+			return []io.Reader{lhs, rhs}
+		},
+		"HeaderBody": func(r io.Reader) []io.Reader {
+			_, body, err := HeaderBody(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return []io.Reader{body}
+		},
+	}
+	for name, split := range splits {
+		src := &countingCloser{Reader: strings.NewReader("a\nb\nc")}
+		rs := split(src)
+		for i, r := range rs {
+			if src.closes != 0 {
+				t.Errorf("%s: source closed before reader %d", name, i)
+			}
+			c := r.(io.Closer)
+			// Closing twice is fine.
+			for j := 0; j < 2; j++ {
+				if err := c.Close(); err != nil {
+					t.Errorf("%s: %v", name, err)
+				}
+			}
+			if _, err := r.Read(make([]byte, 1)); err != ErrClosed {
+				t.Errorf("%s: got %v, expected %v", name, err, ErrClosed)
+			}
+		}
+		if src.closes != 1 {
+			t.Errorf("%s: source closed %d times, expected once", name, src.closes)
+		}
+	}
+}
+
+// TestCloseAbandoned closes the first Reader before its end, letting the
+// second start.
+func TestCloseAbandoned(t *testing.T) {
+	for _, keep := range []Keep{KeepNone, KeepLeft, KeepRight} {
+		s := &Splitter{Sep: '\n', Keep: keep, WindowSize: 16}
+		lhs, rhs := s.Sequential(strings.NewReader(strings.Repeat("a", 100) + "\nb"))
+		if _, err := lhs.Read(make([]byte, 10)); err != nil {
+			t.Fatal(err)
+		}
+		lhs.(io.Closer).Close()
+
+		want := "b"
+		if keep == KeepRight {
+			want = "\nb"
+		}
+		got, err := ioutil.ReadAll(rhs)
+		if err != nil || string(got) != want {
+			t.Errorf("keep %d: got %q, %v, expected %q", keep, got, err, want)
+		}
+	}
+}
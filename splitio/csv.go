@@ -0,0 +1,70 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+// recordState tracks the RFC 4180 quoting of CSV data read so far.
+type recordState struct {
+	quoted bool
+}
+
+// boundary returns the index just after the first newline of data that
+// ends a record, or -1 if data holds none. Data up to the returned index,
+// or all of data, is consumed.
+func (s *recordState) boundary(data []byte) int {
+	for i, c := range data {
+		switch c {
+		case '"':
+			// An escaped quote toggles twice.
+			s.quoted = !s.quoted
+		case '\n':
+			if !s.quoted {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// ScanCSVRecords is a split function for a bufio.Scanner that returns each
+// CSV record of the input. Newlines inside quoted cells do not end a
+// record. Unlike bufio.ScanLines, the token keeps its line ending, so the
+// tokens concatenate to the input.
+func ScanCSVRecords(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	var s recordState
+	if i := s.boundary(data); i >= 0 {
+		return i, data[:i], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,73 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"bufio"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var scanCSVRecordsTests = []struct {
+	name  string
+	input string
+	want  []string
+}{
+	{"Lines test", "a,b\n1,2\n", []string{"a,b\n", "1,2\n"}},
+	{"Unterminated test", "a,b\n1,2", []string{"a,b\n", "1,2"}},
+	{"Quoted newline test", "a,\"b\nc\"\n1,2\n", []string{"a,\"b\nc\"\n", "1,2\n"}},
+	{"Escaped quote test", "\"a\"\"\n\"\"b\",c\n1\n", []string{"\"a\"\"\n\"\"b\",c\n", "1\n"}},
+	{"CRLF test", "a,\"b\r\nc\"\r\n1\r\n", []string{"a,\"b\r\nc\"\r\n", "1\r\n"}},
+	{"Empty line test", "a\n\nb", []string{"a\n", "\n", "b"}},
+	{"Unclosed quote test", "a\n\"b\nc", []string{"a\n", "\"b\nc"}},
+	{"Empty test", "", nil},
+}
+
+func TestScanCSVRecords(t *testing.T) {
+	for _, st := range scanCSVRecordsTests {
+		// Reading a byte at a time forces records across many scans.
+		s := bufio.NewScanner(iotest.OneByteReader(strings.NewReader(st.input)))
+		s.Split(ScanCSVRecords)
+		var got []string
+		for s.Scan() {
+			got = append(got, s.Text())
+		}
+		if err := s.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, st.want) {
+			t.Errorf("%s: got %q, expected %q", st.name, got, st.want)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+)
+
+// funcSplit holds the first token of its input, found on first use by
+// either of its readers.
+type funcSplit struct {
+	once  sync.Once
+	r     io.Reader
+	split bufio.SplitFunc
+	lhs   *bytes.Reader
+	rhs   io.Reader
+	err   error
+
+	closer *closer
+}
+
+func (s *funcSplit) init() error {
+	s.once.Do(func() {
+		var buf []byte
+		var readErr error
+		p := make([]byte, 4096)
+		for {
+			atEOF := readErr == io.EOF
+			advance, token, err := s.split(buf, atEOF)
+			if err == bufio.ErrFinalToken {
+				err = nil
+			}
+			if err != nil {
+				s.err = err
+				return
+			}
+			if advance > 0 || token != nil {
+				s.lhs = bytes.NewReader(token)
+				s.rhs = io.MultiReader(bytes.NewReader(buf[advance:]), s.r)
+				return
+			}
+			if atEOF {
+				// No token at all
+				s.lhs = bytes.NewReader(buf)
+				s.rhs = bytes.NewReader(nil)
+				return
+			}
+			if readErr != nil {
+				s.err = readErr
+				return
+			}
+			var n int
+			n, readErr = s.r.Read(p)
+			buf = append(buf, p[:n]...)
+		}
+	})
+	return s.err
+}
+
+type funcLhsReader struct {
+	s      *funcSplit
+	closed bool
+}
+
+func (r *funcLhsReader) Read(p []byte) (n int, err error) {
+	if r.closed {
+		return 0, ErrClosed
+	}
+	if err := r.s.init(); err != nil {
+		return 0, err
+	}
+	return r.s.lhs.Read(p)
+}
+
+func (r *funcLhsReader) Close() error {
+	return r.s.closer.release(&r.closed)
+}
+
+type funcRhsReader struct {
+	s      *funcSplit
+	closed bool
+}
+
+func (r *funcRhsReader) Read(p []byte) (n int, err error) {
+	if r.closed {
+		return 0, ErrClosed
+	}
+	if err := r.s.init(); err != nil {
+		return 0, err
+	}
+	return r.s.rhs.Read(p)
+}
+
+func (r *funcRhsReader) Close() error {
+	return r.s.closer.release(&r.closed)
+}
+
+// NewReadersFunc splits the input reader at a logical boundary, found by
+// split the way bufio.Scanner does. Returns a first Reader for the first
+// token of split. Also a second Reader for everything after the token,
+// from the advance split returned on. Should split find no token, the first
+// Reader holds everything.
+//
+// Everything until the end of the first token is buffered in memory, so
+// the Readers may be consumed in any order or concurrently.
+//
+// The Readers implement io.Closer. Closing both of them closes r, should
+// it be an io.Closer.
+func NewReadersFunc(r io.Reader, split bufio.SplitFunc) (io.Reader, io.Reader) {
+	s := &funcSplit{
+		r:      r,
+		split:  split,
+		closer: newCloser(r, 2),
+	}
+	return &funcLhsReader{s: s}, &funcRhsReader{s: s}
+}
@@ -0,0 +1,110 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// scanBlankLine splits at the first blank line.
+func scanBlankLine(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+		return i + 2, data[:i+1], nil
+	}
+	return 0, nil, nil
+}
+
+// scanEnd splits before the first line starting with #END.
+func scanEnd(data []byte, atEOF bool) (int, []byte, error) {
+	if bytes.HasPrefix(data, []byte("#END")) {
+		return 0, []byte{}, nil
+	}
+	if i := bytes.Index(data, []byte("\n#END")); i >= 0 {
+		return i + 1, data[:i+1], nil
+	}
+	return 0, nil, nil
+}
+
+var errScan = errors.New("scan failed")
+
+func scanFail(data []byte, atEOF bool) (int, []byte, error) {
+	return 0, nil, errScan
+}
+
+var newReadersFuncTests = []struct {
+	name  string
+	input string
+	split bufio.SplitFunc
+	lhs   string
+	rhs   string
+}{
+	{"Blank line test", "a: 1\nb: 2\n\nbody\n\nmore", scanBlankLine, "a: 1\nb: 2\n", "body\n\nmore"},
+	{"End marker test", "meta\n#END meta\nbody", scanEnd, "meta\n", "#END meta\nbody"},
+	{"Leading end marker test", "#END\nbody", scanEnd, "", "#END\nbody"},
+	{"No token test", "meta\nbody", scanBlankLine, "meta\nbody", ""},
+	{"Lines test", "header\nbody\nmore", bufio.ScanLines, "header", "body\nmore"},
+	{"CSV record test", "\"a\nb\",c\nbody", ScanCSVRecords, "\"a\nb\",c\n", "body"},
+}
+
+func TestNewReadersFunc(t *testing.T) {
+	for _, st := range newReadersFuncTests {
+		lhsR, rhsR := NewReadersFunc(iotest.OneByteReader(strings.NewReader(st.input)), st.split)
+		// Reading rhs first works like with NewReadersBuffered.
+		if got := readAll(t, rhsR); got != st.rhs {
+			t.Errorf("%s: got rhs %q, expected %q", st.name, got, st.rhs)
+		}
+		if got := readAll(t, lhsR); got != st.lhs {
+			t.Errorf("%s: got lhs %q, expected %q", st.name, got, st.lhs)
+		}
+	}
+}
+
+func TestNewReadersFuncError(t *testing.T) {
+	lhsR, rhsR := NewReadersFunc(strings.NewReader("meta"), scanFail)
+	if _, err := ioutil.ReadAll(lhsR); err != errScan {
+		t.Errorf("got %v, expected %v", err, errScan)
+	}
+	if _, err := ioutil.ReadAll(rhsR); err != errScan {
+		t.Errorf("got %v, expected %v", err, errScan)
+	}
+
+	lhsR, _ = NewReadersFunc(iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("meta"))), scanBlankLine)
+	if _, err := ioutil.ReadAll(lhsR); err != iotest.ErrTimeout {
+		t.Errorf("got %v, expected %v", err, iotest.ErrTimeout)
+	}
+}
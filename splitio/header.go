@@ -0,0 +1,72 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io"
+	"io/ioutil"
+)
+
+// HeaderBody parses the first record of a CSV stream as its header.
+// Returns the header and a Reader for the records after it, unparsed.
+// Cells of the header may span lines if quoted. Blank lines in front of
+// the header are skipped, like csv.Reader does. Returns io.EOF if there
+// is no header.
+//
+// body implements io.Closer, closing r should it be an io.Closer.
+func HeaderBody(r io.Reader) (header []string, body io.Reader, err error) {
+	body = r
+	for {
+		var lhs io.Reader
+		lhs, body = NewReadersFunc(body, ScanCSVRecords)
+		record, err := ioutil.ReadAll(lhs)
+		// Leave closing r to body.
+		lhs.(io.Closer).Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(record) == 0 {
+			return nil, nil, io.EOF
+		}
+		header, err = csv.NewReader(bytes.NewReader(record)).Read()
+		if err == io.EOF {
+			// Blank line
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return header, body, nil
+	}
+}
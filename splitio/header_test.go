@@ -0,0 +1,91 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"encoding/csv"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var headerBodyTests = []struct {
+	name   string
+	input  string
+	header []string
+	body   string
+}{
+	{"Simple test", "a,b\n1,2\n3,4\n", []string{"a", "b"}, "1,2\n3,4\n"},
+	{"Quoted test", "\"a\nb\",\"c,\"\"d\"\"\"\n1,2", []string{"a\nb", "c,\"d\""}, "1,2"},
+	{"CRLF test", "a,b\r\n1,2\r\n", []string{"a", "b"}, "1,2\r\n"},
+	{"Header only test", "a,b", []string{"a", "b"}, ""},
+	{"Blank lines test", "\n\r\na,b\n1,2", []string{"a", "b"}, "1,2"},
+}
+
+func TestHeaderBody(t *testing.T) {
+	for _, st := range headerBodyTests {
+		header, body, err := HeaderBody(iotest.OneByteReader(strings.NewReader(st.input)))
+		if err != nil {
+			t.Fatalf("%s: %v", st.name, err)
+		}
+		if !reflect.DeepEqual(header, st.header) {
+			t.Errorf("%s: got header %q, expected %q", st.name, header, st.header)
+		}
+		if got := readAll(t, body); got != st.body {
+			t.Errorf("%s: got body %q, expected %q", st.name, got, st.body)
+		}
+	}
+}
+
+func TestHeaderBodyErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input io.Reader
+		want  error
+	}{
+		{"Empty test", strings.NewReader(""), io.EOF},
+		{"Blank test", strings.NewReader("\n\n"), io.EOF},
+		{"Bare quote test", strings.NewReader("a\"b,c\n1,2"), csv.ErrBareQuote},
+		{"Read error test", iotest.TimeoutReader(iotest.OneByteReader(strings.NewReader("a,b"))), iotest.ErrTimeout},
+	}
+	for _, tt := range tests {
+		_, _, err := HeaderBody(tt.input)
+		if pe, ok := err.(*csv.ParseError); ok {
+			err = pe.Err
+		}
+		if err != tt.want {
+			t.Errorf("%s: got %v, expected %v", tt.name, err, tt.want)
+		}
+	}
+}
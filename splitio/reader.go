@@ -36,8 +36,8 @@ package splitio
 
 import (
 	"bufio"
+	"context"
 	"io"
-	"sync"
 )
 
 func min(lhs int, rhs int) int {
@@ -47,11 +47,59 @@ func min(lhs int, rhs int) int {
 	return rhs
 }
 
+// DefaultWindowSize is the number of bytes NewReadersSequential scans for
+// the separator at a time.
+const DefaultWindowSize = 1024
+
+// Keep decides what becomes of a separator.
+type Keep int
+
+const (
+	// KeepNone drops the separator.
+	KeepNone Keep = iota
+	// KeepLeft keeps the separator at the end of the Reader before it.
+	KeepLeft
+	// KeepRight keeps the separator at the start of the Reader after it.
+	KeepRight
+)
+
+// Splitter splits input readers by a separator.
+type Splitter struct {
+	// Sep is the separator.
+	Sep byte
+
+	// Keep decides whether the separator is part of any Reader.
+	Keep Keep
+
+	// WindowSize is the number of bytes Sequential scans for the separator
+	// at a time. DefaultWindowSize if 0.
+	WindowSize int
+
+	// MemoryLimit is the number of bytes of a section Buffered and SplitN
+	// buffer in memory. Larger sections are spilled to a temporary file,
+	// removed once the Reader of the section is closed. Unlimited if 0.
+	MemoryLimit int64
+
+	// TempDir is the directory for temporary files. os.TempDir() if empty.
+	TempDir string
+
+	// NoSpill makes reading sections larger than MemoryLimit fail with
+	// ErrMemoryLimit instead of spilling them to a temporary file.
+	NoSpill bool
+}
+
 type lhsReader struct {
-	br   *bufio.Reader
-	wg   *sync.WaitGroup
-	done bool
-	sep  byte
+	br     *bufio.Reader
+	ready  chan struct{}
+	done   bool
+	sep    byte
+	keep   Keep
+	window int
+	// abandoned reports whether the reader was closed before its end,
+	// leaving the rest of it for rhsReader to skip.
+	abandoned bool
+	closer    *closer
+	closed    bool
 }
 
 func findByte(s []byte, sep byte) int {
@@ -65,6 +113,9 @@ func findByte(s []byte, sep byte) int {
 }
 
 func (r *lhsReader) Read(p []byte) (n int, err error) {
+	if r.closed {
+		return 0, ErrClosed
+	}
 	if r.done {
 		return 0, io.EOF
 	}
@@ -73,62 +124,192 @@ func (r *lhsReader) Read(p []byte) (n int, err error) {
 		return 0, nil
 	}
 
-	bufLen := min(len(p), 1024)
-	array, peekErr := r.br.Peek(bufLen)
-	if peekErr != nil && peekErr != io.EOF {
-		return 0, peekErr
+	if r.br.Buffered() == 0 {
+		if _, err := r.br.Peek(1); err != nil {
+			if err == io.EOF {
+				// No separator at all
+				r.finish()
+			}
+			return 0, err
+		}
 	}
 
+	// Only ever read what was scanned, whatever the size of p.
+	array, _ := r.br.Peek(min(min(len(p), r.window), r.br.Buffered()))
+
 	i := findByte(array, r.sep)
 	if i == -1 {
-		return r.br.Read(p)
-	}
-
-	// Read until sep
-	p = p[:i]
-	n, err = r.br.Read(p)
-	if err != nil && err != io.EOF {
+		n = copy(p, array)
+		_, err = r.br.Discard(n)
 		return n, err
 	}
 
-	if err != io.EOF {
-		_, err := r.br.ReadByte()
-		if err != nil && err != io.EOF {
-			return n, err
-		}
+	// Read until sep, leaving it to rhs if kept there
+	end, skip := i, i+1
+	switch r.keep {
+	case KeepLeft:
+		end = i + 1
+	case KeepRight:
+		skip = i
 	}
+	n = copy(p, array[:end])
+	if _, err := r.br.Discard(skip); err != nil {
+		return n, err
+	}
+	r.finish()
+	return n, io.EOF
+}
 
+func (r *lhsReader) finish() {
 	r.done = true
 	// Signal other reader may start
-	r.wg.Done()
-	return n, io.EOF
+	close(r.ready)
+}
+
+func (r *lhsReader) Close() error {
+	if !r.done && !r.closed {
+		r.abandoned = true
+		r.finish()
+	}
+	return r.closer.release(&r.closed)
 }
 
 type rhsReader struct {
-	br *bufio.Reader
-	wg *sync.WaitGroup
+	br     *bufio.Reader
+	ctx    context.Context
+	ready  chan struct{}
+	lhs    *lhsReader
+	closer *closer
+	closed bool
 }
 
 func (r *rhsReader) Read(p []byte) (n int, err error) {
-	r.wg.Wait()
+	if r.closed {
+		return 0, ErrClosed
+	}
+	select {
+	case <-r.ready:
+	default:
+		// Only fail while actually waiting.
+		select {
+		case <-r.ready:
+		case <-r.ctx.Done():
+			return 0, r.ctx.Err()
+		}
+	}
+	if r.lhs.abandoned {
+		if err := r.skip(); err != nil {
+			return 0, err
+		}
+	}
 	return r.br.Read(p)
 }
 
+// skip drops what is left of the abandoned lhsReader.
+func (r *rhsReader) skip() error {
+	for {
+		_, err := r.br.ReadSlice(r.lhs.sep)
+		switch err {
+		case bufio.ErrBufferFull:
+			continue
+		case nil:
+			r.lhs.abandoned = false
+			if r.lhs.keep == KeepRight {
+				return r.br.UnreadByte()
+			}
+			return nil
+		case io.EOF:
+			r.lhs.abandoned = false
+			return nil
+		}
+		return err
+	}
+}
+
+func (r *rhsReader) Close() error {
+	return r.closer.release(&r.closed)
+}
+
 // NewReadersSequential splits the input reader by a separator.
 // Returns a first Reader for reading everything until first occurrence of
 // said separator. Also a second Reader for everything after first occurrence
 // of said separator.
-// Second Reader will only start once first Reader reached EOF.
+// Second Reader will only start once first Reader reached EOF or was
+// closed.
+//
+// The Readers implement io.Closer. Closing both of them closes r, should
+// it be an io.Closer.
 func NewReadersSequential(r io.Reader, sep byte) (io.Reader, io.Reader) {
-	br := bufio.NewReader(r)
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
-	return &lhsReader{
-			br:  br,
-			wg:  wg,
-			sep: sep,
-		}, &rhsReader{
-			br: br,
-			wg: wg,
-		}
+	s := &Splitter{Sep: sep}
+	return s.Sequential(r)
+}
+
+// NewReadersSequentialSize is like NewReadersSequential, scanning for the
+// separator in windows of size bytes. Larger windows mean fewer scans for
+// large Reads of the first Reader.
+func NewReadersSequentialSize(r io.Reader, sep byte, size int) (io.Reader, io.Reader) {
+	s := &Splitter{Sep: sep, WindowSize: size}
+	return s.Sequential(r)
+}
+
+// NewReadersSequentialContext is like NewReadersSequential. Reads of the
+// second Reader waiting for the first one return ctx.Err() once ctx is
+// done, rather than blocking forever should the first Reader be abandoned.
+func NewReadersSequentialContext(ctx context.Context, r io.Reader, sep byte) (io.Reader, io.Reader) {
+	s := &Splitter{Sep: sep}
+	return s.SequentialContext(ctx, r)
+}
+
+// Sequential splits the input reader like NewReadersSequential.
+func (s *Splitter) Sequential(r io.Reader) (io.Reader, io.Reader) {
+	return s.SequentialContext(context.Background(), r)
+}
+
+// SequentialContext splits the input reader like
+// NewReadersSequentialContext.
+func (s *Splitter) SequentialContext(ctx context.Context, r io.Reader) (io.Reader, io.Reader) {
+	size := s.WindowSize
+	if size == 0 {
+		size = DefaultWindowSize
+	}
+	if size < 16 {
+		size = 16
+	}
+	br := bufio.NewReaderSize(r, size)
+	ready := make(chan struct{})
+	c := newCloser(r, 2)
+	lhs := &lhsReader{
+		br:     br,
+		ready:  ready,
+		sep:    s.Sep,
+		keep:   s.Keep,
+		window: size,
+		closer: c,
+	}
+	return lhs, &rhsReader{
+		br:     br,
+		ctx:    ctx,
+		ready:  ready,
+		lhs:    lhs,
+		closer: c,
+	}
+}
+
+// NewReadersBuffered splits the input reader by a separator like
+// NewReadersSequential. Everything until the first occurrence of said
+// separator is buffered in memory, so the Readers may be consumed in any
+// order or concurrently.
+//
+// The Readers implement io.Closer. Closing both of them closes r, should
+// it be an io.Closer.
+func NewReadersBuffered(r io.Reader, sep byte) (io.Reader, io.Reader) {
+	s := &Splitter{Sep: sep}
+	return s.Buffered(r)
+}
+
+// Buffered splits the input reader like NewReadersBuffered, buffering the
+// first section up to MemoryLimit.
+func (s *Splitter) Buffered(r io.Reader) (io.Reader, io.Reader) {
+	rs := s.SplitN(r, 2)
+	return rs[0], rs[1]
 }
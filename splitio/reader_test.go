@@ -33,9 +33,13 @@ package splitio
 
 import (
 	"bytes"
+	"context"
+	"io"
 	"io/ioutil"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
 var splitReaderTest = []struct {
@@ -78,3 +82,192 @@ func TestNewReadersSequential(t *testing.T) {
 		}
 	}
 }
+
+// TestNewReadersBuffered reads the right hand side first, which would block
+// forever with NewReadersSequential.
+func TestNewReadersBuffered(t *testing.T) {
+	for _, st := range splitReaderTest {
+		lhsR, rhsR := NewReadersBuffered(bytes.NewReader(st.input), st.sep)
+
+		rhs, err := ioutil.ReadAll(rhsR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(rhs, st.rhs) {
+			t.Errorf("%s: got rhs %q, expected %q", st.name, rhs, st.rhs)
+		}
+
+		lhs, err := ioutil.ReadAll(lhsR)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(lhs, st.lhs) {
+			t.Errorf("%s: got lhs %q, expected %q", st.name, lhs, st.lhs)
+		}
+	}
+}
+
+func TestNewReadersBufferedConcurrent(t *testing.T) {
+	for _, st := range splitReaderTest {
+		lhsR, rhsR := NewReadersBuffered(bytes.NewReader(st.input), st.sep)
+
+		var lhs []byte
+		var lhsErr error
+		done := make(chan struct{})
+		go func() {
+			lhs, lhsErr = ioutil.ReadAll(lhsR)
+			close(done)
+		}()
+		rhs, err := ioutil.ReadAll(rhsR)
+		<-done
+		if err != nil || lhsErr != nil {
+			t.Fatal(err, lhsErr)
+		}
+		if !bytes.Equal(lhs, st.lhs) || !bytes.Equal(rhs, st.rhs) {
+			t.Errorf("%s: got %q and %q, expected %q and %q", st.name, lhs, rhs, st.lhs, st.rhs)
+		}
+	}
+}
+
+func TestNewReadersBufferedWithoutSeparator(t *testing.T) {
+	lhsR, rhsR := NewReadersBuffered(bytes.NewReader([]byte("foo,bar")), '\n')
+	rhs, err := ioutil.ReadAll(rhsR)
+	if err != nil || len(rhs) != 0 {
+		t.Errorf("got rhs %q, %v, expected empty", rhs, err)
+	}
+	lhs, err := ioutil.ReadAll(lhsR)
+	if err != nil || string(lhs) != "foo,bar" {
+		t.Errorf("got lhs %q, %v, expected %q", lhs, err, "foo,bar")
+	}
+}
+
+// readSize reads all of r with Reads of size bytes.
+func readSize(r io.Reader, size int) ([]byte, error) {
+	var b []byte
+	p := make([]byte, size)
+	for {
+		n, err := r.Read(p)
+		b = append(b, p[:n]...)
+		if err == io.EOF {
+			return b, nil
+		}
+		if err != nil {
+			return b, err
+		}
+	}
+}
+
+// TestNewReadersSequentialSize finds separators independent of the sizes of
+// the window and the Reads.
+func TestNewReadersSequentialSize(t *testing.T) {
+	long := bytes.Repeat([]byte("x"), 3000)
+	input := append(append(append([]byte{}, long...), '\n'), "1,2,3"...)
+	for _, window := range []int{0, 16, 1000, DefaultWindowSize, 8192} {
+		for _, readLen := range []int{1, 7, 1024, 5000} {
+			lhsR, rhsR := NewReadersSequentialSize(bytes.NewReader(input), '\n', window)
+			lhs, err := readSize(lhsR, readLen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(lhs, long) {
+				t.Errorf("window %d, reads of %d: got lhs of %d bytes, expected %d", window, readLen, len(lhs), len(long))
+			}
+			rhs, err := readSize(rhsR, readLen)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(rhs) != "1,2,3" {
+				t.Errorf("window %d, reads of %d: got rhs %q, expected %q", window, readLen, rhs, "1,2,3")
+			}
+		}
+	}
+}
+
+func TestNewReadersSequentialWithoutSeparator(t *testing.T) {
+	lhsR, rhsR := NewReadersSequential(bytes.NewReader([]byte("foo,bar")), '\n')
+	lhs, err := ioutil.ReadAll(lhsR)
+	if err != nil || string(lhs) != "foo,bar" {
+		t.Errorf("got lhs %q, %v, expected %q", lhs, err, "foo,bar")
+	}
+	// Would block forever if lhs did not signal its end.
+	rhs, err := ioutil.ReadAll(rhsR)
+	if err != nil || len(rhs) != 0 {
+		t.Errorf("got rhs %q, %v, expected empty", rhs, err)
+	}
+}
+
+var splitterKeepTests = []struct {
+	name  string
+	input string
+	keep  Keep
+	lhs   string
+	rhs   string
+}{
+	{"None test", "a,b\n1,2", KeepNone, "a,b", "1,2"},
+	{"Left test", "a,b\n1,2", KeepLeft, "a,b\n", "1,2"},
+	{"Right test", "a,b\n1,2", KeepRight, "a,b", "\n1,2"},
+	{"Right leading test", "\n1,2", KeepRight, "", "\n1,2"},
+	{"Left trailing test", "a,b\n", KeepLeft, "a,b\n", ""},
+	{"Missing test", "a,b", KeepRight, "a,b", ""},
+}
+
+func TestSplitterKeep(t *testing.T) {
+	for _, st := range splitterKeepTests {
+		s := &Splitter{Sep: '\n', Keep: st.keep}
+		splits := map[string]func(io.Reader) (io.Reader, io.Reader){
+			"Sequential": s.Sequential,
+			"Buffered":   s.Buffered,
+		}
+		for name, split := range splits {
+			lhsR, rhsR := split(strings.NewReader(st.input))
+			lhs, err := readSize(lhsR, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rhs, err := readSize(rhsR, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(lhs) != st.lhs || string(rhs) != st.rhs {
+				t.Errorf("%s %s: got %q and %q, expected %q and %q", st.name, name, lhs, rhs, st.lhs, st.rhs)
+			}
+		}
+	}
+}
+
+func TestNewReadersSequentialContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, rhsR := NewReadersSequentialContext(ctx, strings.NewReader("a,b\n1,2"), '\n')
+	errs := make(chan error)
+	go func() {
+		_, err := rhsR.Read(make([]byte, 10))
+		errs <- err
+	}()
+	cancel()
+	if err := <-errs; err != context.Canceled {
+		t.Errorf("got %v, expected %v", err, context.Canceled)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+	_, rhsR = NewReadersSequentialContext(ctx, strings.NewReader("a,b\n1,2"), '\n')
+	if _, err := rhsR.Read(make([]byte, 10)); err != context.DeadlineExceeded {
+		t.Errorf("got %v, expected %v", err, context.DeadlineExceeded)
+	}
+}
+
+// TestNewReadersSequentialContextDone reads the second Reader once the
+// first is done, even though ctx is done as well.
+func TestNewReadersSequentialContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	lhsR, rhsR := NewReadersSequentialContext(ctx, strings.NewReader("a,b\n1,2"), '\n')
+	if _, err := ioutil.ReadAll(lhsR); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	for i := 0; i < 3; i++ {
+		if _, err := rhsR.Read(make([]byte, 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// ErrMemoryLimit is returned when reading a section larger than
+// MemoryLimit, should the Splitter not spill.
+var ErrMemoryLimit = errors.New("splitio: section exceeds memory limit")
+
+// spillBuffer buffers a section in memory up to limit bytes, spilling it
+// to a temporary file in dir beyond that, unless noSpill is set. It is
+// written completely before being read.
+type spillBuffer struct {
+	limit   int64
+	dir     string
+	noSpill bool
+	mem     bytes.Buffer
+	file    *os.File
+	// reading reports whether file was rewound for reading.
+	reading bool
+}
+
+func (b *spillBuffer) Write(p []byte) (int, error) {
+	if b.file == nil {
+		if b.limit == 0 || int64(b.mem.Len()+len(p)) <= b.limit {
+			return b.mem.Write(p)
+		}
+		if b.noSpill {
+			return 0, ErrMemoryLimit
+		}
+		f, err := ioutil.TempFile(b.dir, "splitio")
+		if err != nil {
+			return 0, err
+		}
+		b.file = f
+		if _, err := b.mem.WriteTo(f); err != nil {
+			return 0, err
+		}
+	}
+	return b.file.Write(p)
+}
+
+func (b *spillBuffer) Read(p []byte) (int, error) {
+	if b.file == nil {
+		return b.mem.Read(p)
+	}
+	if !b.reading {
+		if _, err := b.file.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		b.reading = true
+	}
+	return b.file.Read(p)
+}
+
+// Close frees the buffer, removing any temporary file.
+func (b *spillBuffer) Close() error {
+	b.mem = bytes.Buffer{}
+	if b.file == nil {
+		return nil
+	}
+	f := b.file
+	b.file = nil
+	err := f.Close()
+	if rerr := os.Remove(f.Name()); err == nil {
+		err = rerr
+	}
+	return err
+}
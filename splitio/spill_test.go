@@ -0,0 +1,170 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func tempFiles(t *testing.T, dir string) int {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(infos)
+}
+
+func TestSplitterMemoryLimit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "splitio_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	first := strings.Repeat("x", 10000)
+	tests := []struct {
+		limit int64
+		files int
+	}{
+		{0, 0},
+		{20000, 0},
+		{10000, 0},
+		{9999, 1},
+		{16, 1},
+	}
+	for _, tt := range tests {
+		s := &Splitter{Sep: '\n', MemoryLimit: tt.limit, TempDir: dir}
+		lhs, rhs := s.Buffered(strings.NewReader(first + "\nbody"))
+		if got := readAll(t, rhs); got != "body" {
+			t.Errorf("limit %d: got rhs %q, expected %q", tt.limit, got, "body")
+		}
+		if got := tempFiles(t, dir); got != tt.files {
+			t.Errorf("limit %d: got %d temporary files, expected %d", tt.limit, got, tt.files)
+		}
+		if got := readAll(t, lhs); got != first {
+			t.Errorf("limit %d: got lhs of %d bytes, expected %d", tt.limit, len(got), len(first))
+		}
+		if err := lhs.(io.Closer).Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := tempFiles(t, dir); got != 0 {
+			t.Errorf("limit %d: %d temporary files left", tt.limit, got)
+		}
+	}
+}
+
+func TestSplitterMemoryLimitKeep(t *testing.T) {
+	dir, err := ioutil.TempDir("", "splitio_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, keep := range []Keep{KeepNone, KeepLeft, KeepRight} {
+		s := &Splitter{Sep: '\n', Keep: keep, MemoryLimit: 4, TempDir: dir}
+		rs := s.SplitN(strings.NewReader("meta data\nheader\nbody"), 3)
+		got := []string{"", "", readAll(t, rs[2])}
+		got[1] = readAll(t, rs[1])
+		got[0] = readAll(t, rs[0])
+		want := map[Keep]string{
+			KeepNone:  "meta data|header|body",
+			KeepLeft:  "meta data\n|header\n|body",
+			KeepRight: "meta data|\nheader|\nbody",
+		}[keep]
+		if strings.Join(got, "|") != want {
+			t.Errorf("keep %d: got %q, expected %q", keep, strings.Join(got, "|"), want)
+		}
+		for _, r := range rs {
+			r.(io.Closer).Close()
+		}
+	}
+	if got := tempFiles(t, dir); got != 0 {
+		t.Errorf("%d temporary files left", got)
+	}
+}
+
+func TestSplitterMemoryLimitError(t *testing.T) {
+	s := &Splitter{Sep: '\n', MemoryLimit: 1, TempDir: "/nonexistent/splitio"}
+	_, rhs := s.Buffered(strings.NewReader("meta\nbody"))
+	if _, err := ioutil.ReadAll(rhs); !os.IsNotExist(err) {
+		t.Errorf("got %v, expected missing directory", err)
+	}
+}
+
+func TestSplitterNoSpill(t *testing.T) {
+	s := &Splitter{Sep: '\n', MemoryLimit: 4, NoSpill: true, TempDir: "/nonexistent/splitio"}
+	lhs, rhs := s.Buffered(strings.NewReader("meta\nbody"))
+	if got := readAll(t, rhs); got != "body" {
+		t.Errorf("got rhs %q, expected %q", got, "body")
+	}
+	if got := readAll(t, lhs); got != "meta" {
+		t.Errorf("got lhs %q, expected %q", got, "meta")
+	}
+
+	_, rhs = s.Buffered(strings.NewReader("meta data\nbody"))
+	if _, err := ioutil.ReadAll(rhs); err != ErrMemoryLimit {
+		t.Errorf("got %v, expected ErrMemoryLimit", err)
+	}
+}
+
+func TestSplitterMemoryLimitClosed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "splitio_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &Splitter{Sep: '\n', MemoryLimit: 4, TempDir: dir}
+	rs := s.SplitN(strings.NewReader("meta data\nheader\nbody"), 3)
+	p := make([]byte, 2)
+	if _, err := io.ReadFull(rs[0], p); err != nil {
+		t.Fatal(err)
+	}
+	if err := rs[0].(io.Closer).Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readAll(t, rs[1]); got != "header" {
+		t.Errorf("got %q, expected %q", got, "header")
+	}
+	for _, r := range rs[1:] {
+		if err := r.(io.Closer).Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := tempFiles(t, dir); got != 0 {
+		t.Errorf("%d temporary files left", got)
+	}
+}
@@ -0,0 +1,224 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+)
+
+// splitter hands out the sections of its input to their readers. The
+// section at cur is streamed from br, sections before it are buffered.
+type splitter struct {
+	mu       sync.Mutex
+	br       *bufio.Reader
+	sep      byte
+	keep     Keep
+	cur      int
+	last     int
+	buffered []*spillBuffer
+	// closed reports the sections closed by their readers, whose rest is
+	// skipped instead of buffered.
+	closed []bool
+	// pending reports whether the section at cur starts with the
+	// separator ending the section before it.
+	pending bool
+	err     error
+	closer  *closer
+}
+
+// section is a Reader for the section at index of a splitter.
+type section struct {
+	s      *splitter
+	index  int
+	closed bool
+}
+
+func (r *section) Read(p []byte) (int, error) {
+	if r.closed {
+		return 0, ErrClosed
+	}
+	s := r.s
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.index < s.cur {
+		return s.buffered[r.index].Read(p)
+	}
+	// Buffer the sections in front, so their readers may continue later.
+	for s.cur < r.index {
+		if s.err != nil {
+			return 0, s.err
+		}
+		var w io.Writer = s.buffered[s.cur]
+		if s.closed[s.cur] {
+			w = ioutil.Discard
+		}
+		s.err = s.bufferSection(w)
+		s.cur++
+	}
+	if s.err != nil {
+		return 0, s.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if s.pending {
+		p[0] = s.sep
+		s.pending = false
+		return 1, nil
+	}
+	if r.index == s.last {
+		return s.br.Read(p)
+	}
+
+	n, end, err := s.stream(p)
+	if err != nil {
+		s.err = err
+		return n, err
+	}
+	if end {
+		s.cur++
+		if n == 0 {
+			return 0, io.EOF
+		}
+	}
+	return n, nil
+}
+
+func (r *section) Close() error {
+	r.s.mu.Lock()
+	r.s.closed[r.index] = true
+	// Free any buffered data.
+	err := r.s.buffered[r.index].Close()
+	r.s.mu.Unlock()
+	if cerr := r.s.closer.release(&r.closed); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// bufferSection reads the rest of the section at cur into b.
+func (s *splitter) bufferSection(b io.Writer) error {
+	if s.pending {
+		if _, err := b.Write([]byte{s.sep}); err != nil {
+			return err
+		}
+		s.pending = false
+	}
+	for {
+		line, err := s.br.ReadSlice(s.sep)
+		if err == nil && s.keep != KeepLeft {
+			line = line[:len(line)-1]
+		}
+		if _, err := b.Write(line); err != nil {
+			return err
+		}
+		switch err {
+		case bufio.ErrBufferFull:
+		case nil:
+			s.pending = s.keep == KeepRight
+			return nil
+		case io.EOF:
+			return nil
+		default:
+			return err
+		}
+	}
+}
+
+// stream reads from br into p, up to the next separator. end reports
+// whether the separator or EOF was reached.
+func (s *splitter) stream(p []byte) (n int, end bool, err error) {
+	if s.br.Buffered() == 0 {
+		if _, err := s.br.Peek(1); err == io.EOF {
+			return 0, true, nil
+		} else if err != nil {
+			return 0, false, err
+		}
+	}
+	buf, _ := s.br.Peek(min(len(p), s.br.Buffered()))
+	if i := bytes.IndexByte(buf, s.sep); i >= 0 {
+		end := i
+		if s.keep == KeepLeft {
+			end++
+		}
+		n = copy(p, buf[:end])
+		_, err = s.br.Discard(i + 1)
+		s.pending = s.keep == KeepRight
+		return n, true, err
+	}
+	n = copy(p, buf)
+	_, err = s.br.Discard(n)
+	return n, false, err
+}
+
+// SplitN splits the input reader into n Readers, delimited by the first
+// n-1 occurrences of a separator. The separators are dropped. Should the
+// input hold fewer separators, the trailing Readers are empty. Returns nil
+// if n is less than 1.
+//
+// The Readers may be consumed in any order or concurrently. Sections in
+// front of the one read are buffered in memory.
+//
+// The Readers implement io.Closer. Closing all of them closes r, should it
+// be an io.Closer.
+func SplitN(r io.Reader, sep byte, n int) []io.Reader {
+	s := &Splitter{Sep: sep}
+	return s.SplitN(r, n)
+}
+
+// SplitN splits the input reader like the function SplitN, keeping the
+// separators according to Keep and buffering sections up to MemoryLimit.
+func (sp *Splitter) SplitN(r io.Reader, n int) []io.Reader {
+	if n < 1 {
+		return nil
+	}
+	s := &splitter{
+		br:       bufio.NewReader(r),
+		sep:      sp.Sep,
+		keep:     sp.Keep,
+		last:     n - 1,
+		buffered: make([]*spillBuffer, n),
+		closed:   make([]bool, n),
+		closer:   newCloser(r, n),
+	}
+	readers := make([]io.Reader, n)
+	for i := range readers {
+		s.buffered[i] = &spillBuffer{limit: sp.MemoryLimit, dir: sp.TempDir, noSpill: sp.NoSpill}
+		readers[i] = &section{s: s, index: i}
+	}
+	return readers
+}
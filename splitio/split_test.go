@@ -0,0 +1,180 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package splitio
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+var splitNTests = []struct {
+	name  string
+	input string
+	n     int
+	want  []string
+}{
+	{"Sections test", "meta\nheader\nbody\nfooter", 4, []string{"meta", "header", "body", "footer"}},
+	{"Remainder test", "meta\nheader\nbody\nmore body", 3, []string{"meta", "header", "body\nmore body"}},
+	{"Short test", "meta\nheader", 4, []string{"meta", "header", "", ""}},
+	{"Empty sections test", "\n\nbody\n", 4, []string{"", "", "body", ""}},
+	{"Single test", "meta\nheader", 1, []string{"meta\nheader"}},
+	{"Empty test", "", 2, []string{"", ""}},
+}
+
+func readAll(t *testing.T, r io.Reader) string {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSplitN(t *testing.T) {
+	orders := map[string]func(n int) []int{
+		"forward": func(n int) []int {
+			order := make([]int, n)
+			for i := range order {
+				order[i] = i
+			}
+			return order
+		},
+		"backward": func(n int) []int {
+			order := make([]int, n)
+			for i := range order {
+				order[i] = n - 1 - i
+			}
+			return order
+		},
+	}
+	for name, order := range orders {
+		for _, st := range splitNTests {
+			// Reading a byte at a time streams sections across many Reads.
+			rs := SplitN(iotest.OneByteReader(strings.NewReader(st.input)), '\n', st.n)
+			got := make([]string, len(rs))
+			for _, i := range order(len(rs)) {
+				got[i] = readAll(t, rs[i])
+			}
+			if !reflect.DeepEqual(got, st.want) {
+				t.Errorf("%s %s: got %q, expected %q", st.name, name, got, st.want)
+			}
+		}
+	}
+}
+
+// TestSplitNInterleaved starts reading a section, then skips to a later one
+// before finishing it.
+func TestSplitNInterleaved(t *testing.T) {
+	rs := SplitN(strings.NewReader("meta data\nheader\nbody"), '\n', 3)
+	p := make([]byte, 4)
+	if n, err := rs[0].Read(p); err != nil || string(p[:n]) != "meta" {
+		t.Fatalf("got %q, %v, expected %q", p[:n], err, "meta")
+	}
+	if got := readAll(t, rs[2]); got != "body" {
+		t.Errorf("got %q, expected %q", got, "body")
+	}
+	if got := readAll(t, rs[0]); got != " data" {
+		t.Errorf("got %q, expected %q", got, " data")
+	}
+	if got := readAll(t, rs[1]); got != "header" {
+		t.Errorf("got %q, expected %q", got, "header")
+	}
+}
+
+func TestSplitNConcurrent(t *testing.T) {
+	input := strings.Repeat("x", 10000) + "\n" + strings.Repeat("y", 10000) + "\n" + strings.Repeat("z", 10000)
+	rs := SplitN(strings.NewReader(input), '\n', 3)
+	got := make([][]byte, len(rs))
+	errs := make(chan error, len(rs))
+	for i := range rs {
+		go func(i int) {
+			var err error
+			got[i], err = ioutil.ReadAll(rs[i])
+			errs <- err
+		}(i)
+	}
+	for range rs {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, c := range []byte("xyz") {
+		if !bytes.Equal(got[i], bytes.Repeat([]byte{c}, 10000)) {
+			t.Errorf("section %d: got %d bytes", i, len(got[i]))
+		}
+	}
+}
+
+func TestSplitNError(t *testing.T) {
+	rs := SplitN(iotest.TimeoutReader(strings.NewReader("meta\nheader")), '\n', 2)
+	if _, err := ioutil.ReadAll(rs[1]); err != iotest.ErrTimeout {
+		t.Errorf("got %v, expected %v", err, iotest.ErrTimeout)
+	}
+	if _, err := ioutil.ReadAll(rs[0]); err != nil {
+		t.Errorf("got %v, expected buffered section", err)
+	}
+}
+
+func TestSplitNInvalid(t *testing.T) {
+	if rs := SplitN(strings.NewReader("meta"), '\n', 0); rs != nil {
+		t.Errorf("got %v, expected nil", rs)
+	}
+}
+
+func TestSplitterSplitNKeep(t *testing.T) {
+	tests := []struct {
+		keep Keep
+		want []string
+	}{
+		{KeepNone, []string{"meta", "", "body", "foot\ner"}},
+		{KeepLeft, []string{"meta\n", "\n", "body\n", "foot\ner"}},
+		{KeepRight, []string{"meta", "\n", "\nbody", "\nfoot\ner"}},
+	}
+	for _, tt := range tests {
+		s := &Splitter{Sep: '\n', Keep: tt.keep}
+		// Read the middle sections first, buffering the first one.
+		for _, order := range [][]int{{0, 1, 2, 3}, {2, 0, 3, 1}} {
+			rs := s.SplitN(iotest.OneByteReader(strings.NewReader("meta\n\nbody\nfoot\ner")), 4)
+			got := make([]string, len(rs))
+			for _, i := range order {
+				got[i] = readAll(t, rs[i])
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("keep %d, order %v: got %q, expected %q", tt.keep, order, got, tt.want)
+			}
+		}
+	}
+}
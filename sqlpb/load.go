@@ -0,0 +1,250 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package sqlpb
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// maxParams is the number of parameters PostgreSQL allows per statement.
+const maxParams = 65535
+
+// Loader is a configurable object for converting protocol buffers into
+// data loading them into a database. Fields are converted the way csvpb
+// converts them, so that lists are CSV encoded, for example.
+type Loader struct {
+	// Marshaler converts messages into columns.
+	Marshaler csvpb.Marshaler
+
+	// BatchSize is the number of rows per INSERT statement, 100 if zero.
+	// Batches are smaller should they exceed the parameters PostgreSQL
+	// allows.
+	BatchSize int
+
+	// Placeholder returns the placeholder of parameter n, starting with 1.
+	// PostgreSQL placeholders like $1 are used if nil.
+	Placeholder func(n int) string
+}
+
+// Statement is a parameterized SQL statement.
+type Statement struct {
+	Query string
+	Args  []interface{}
+}
+
+// CopyStatement returns the COPY statement reading rows written by
+// WriteCopy for messages of the type of pb into table. table may be
+// qualified by a schema, like "public.events".
+func (l *Loader) CopyStatement(table string, pb proto.Message) (string, error) {
+	header, err := l.Marshaler.Header(pb)
+	if err != nil {
+		return "", err
+	}
+	return "COPY " + quoteTable(table) + " (" + quoteColumns(header) + ") FROM STDIN", nil
+}
+
+// Inserts returns INSERT statements adding pbs, which have to be of the
+// same type, to table. table may be qualified by a schema, like
+// "public.events".
+func (l *Loader) Inserts(table string, pbs []proto.Message) ([]Statement, error) {
+	if len(pbs) == 0 {
+		return nil, nil
+	}
+	header, err := l.Marshaler.Header(pbs[0])
+	if err != nil {
+		return nil, err
+	}
+	binary := bytesFields(pbs[0])
+	batch := l.BatchSize
+	if batch <= 0 {
+		batch = 100
+	}
+	if len(header) > 0 && batch*len(header) > maxParams {
+		batch = maxParams / len(header)
+	}
+	placeholder := l.Placeholder
+	if placeholder == nil {
+		placeholder = func(n int) string { return "$" + strconv.Itoa(n) }
+	}
+
+	prefix := "INSERT INTO " + quoteTable(table) + " (" + quoteColumns(header) + ") VALUES "
+	var stmts []Statement
+	for len(pbs) > 0 {
+		n := batch
+		if n > len(pbs) {
+			n = len(pbs)
+		}
+		var query strings.Builder
+		query.WriteString(prefix)
+		args := make([]interface{}, 0, n*len(header))
+		for i, pb := range pbs[:n] {
+			record, err := l.Marshaler.MarshalRecord(pb)
+			if err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			query.WriteByte('(')
+			for j, cell := range record {
+				if j > 0 {
+					query.WriteString(", ")
+				}
+				arg, err := param(cell, binary, header[j])
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+				query.WriteString(placeholder(len(args)))
+			}
+			query.WriteByte(')')
+		}
+		stmts = append(stmts, Statement{Query: query.String(), Args: args})
+		pbs = pbs[n:]
+	}
+	return stmts, nil
+}
+
+// param converts a cell of column into a parameter.
+func param(cell string, binary map[string]int, column string) (interface{}, error) {
+	if cell == "null" {
+		return nil, nil
+	}
+	if _, ok := binary[column]; ok {
+		b, err := base64.StdEncoding.DecodeString(cell)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %v", column, err)
+		}
+		return b, nil
+	}
+	return cell, nil
+}
+
+// CopyWriter writes protocol buffers as the rows of a PostgreSQL COPY in
+// text format.
+type CopyWriter struct {
+	// Marshaler converts messages into columns.
+	Marshaler csvpb.Marshaler
+
+	w      *bufio.Writer
+	binary map[string]int
+	header []string
+}
+
+// NewCopyWriter returns a CopyWriter writing to w.
+func NewCopyWriter(w io.Writer) *CopyWriter {
+	return &CopyWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes pb as the next row. Every message has to be of the same
+// type.
+func (c *CopyWriter) Write(pb proto.Message) error {
+	if c.header == nil {
+		header, err := c.Marshaler.Header(pb)
+		if err != nil {
+			return err
+		}
+		c.header = header
+		c.binary = bytesFields(pb)
+	}
+	record, err := c.Marshaler.MarshalRecord(pb)
+	if err != nil {
+		return err
+	}
+	if len(record) != len(c.header) {
+		return errors.New("sqlpb: messages of different types")
+	}
+	for i, cell := range record {
+		if i > 0 {
+			c.w.WriteByte('\t')
+		}
+		arg, err := param(cell, c.binary, c.header[i])
+		if err != nil {
+			return err
+		}
+		switch arg := arg.(type) {
+		case nil:
+			c.w.WriteString(`\N`)
+		case []byte:
+			// bytea in hex format, its backslash escaped.
+			c.w.WriteString(`\\x`)
+			c.w.WriteString(hex.EncodeToString(arg))
+		default:
+			c.w.WriteString(escapeCopy(cell))
+		}
+	}
+	return c.w.WriteByte('\n')
+}
+
+// Flush writes any buffered rows to the underlying writer.
+func (c *CopyWriter) Flush() error {
+	return c.w.Flush()
+}
+
+var copyEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+// escapeCopy escapes s for the text format of COPY.
+func escapeCopy(s string) string {
+	return copyEscaper.Replace(s)
+}
+
+// quoteTable quotes every dot separated part of table.
+func quoteTable(table string) string {
+	parts := strings.Split(table, ".")
+	for i, p := range parts {
+		parts[i] = quoteIdentifier(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+func quoteColumns(columns []string) string {
+	quoted := make([]string, len(columns))
+	for i, c := range columns {
+		quoted[i] = quoteIdentifier(c)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// quoteIdentifier quotes an SQL identifier, keeping its case.
+func quoteIdentifier(s string) string {
+	return `"` + strings.Replace(s, `"`, `""`, -1) + `"`
+}
@@ -0,0 +1,138 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package sqlpb
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+func TestCopyStatement(t *testing.T) {
+	l := new(Loader)
+	l.Marshaler.OrigName = true
+	got, err := l.CopyStatement(`public.my"table`, new(pb.SimpleSlice3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `COPY "public"."my""table" ("slices") FROM STDIN`; got != want {
+		t.Errorf("CopyStatement() = %q, want %q", got, want)
+	}
+}
+
+func TestCopyWriter(t *testing.T) {
+	var buf bytes.Buffer
+	c := NewCopyWriter(&buf)
+	c.Marshaler.OrigName = true
+	for _, p := range []*pb.Simple{
+		{OInt32: proto.Int32(1), OString: proto.String("tab\there\\ new\nline\r"), OBytes: []byte{0xde, 0xad}},
+		{OBool: proto.Bool(true)},
+	} {
+		if err := c.Write(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := `\N	1	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	tab\there\\ new\nline\r	\\xdead
+true	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\N	\\x
+`
+	if got := buf.String(); got != want {
+		t.Errorf("CopyWriter wrote\n%s\nwant\n%s", got, want)
+	}
+
+	if err := c.Write(new(pb.SimpleSlice3)); err == nil {
+		t.Error("Write() of other message type succeeded, want error")
+	}
+}
+
+func TestInserts(t *testing.T) {
+	l := Loader{BatchSize: 2}
+	l.Marshaler.OrigName = true
+	pbs := []proto.Message{
+		&pb.SimpleSlice3{Slices: []string{"a", "b,c"}},
+		&pb.SimpleSlice3{},
+		&pb.SimpleSlice3{Slices: []string{"d"}},
+	}
+	got, err := l.Inserts("events", pbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Statement{
+		{Query: `INSERT INTO "events" ("slices") VALUES ($1), ($2)`, Args: []interface{}{`a,"b,c"`, ""}},
+		{Query: `INSERT INTO "events" ("slices") VALUES ($1)`, Args: []interface{}{"d"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Inserts() = %q, want %q", got, want)
+	}
+}
+
+func TestInsertsArgs(t *testing.T) {
+	l := Loader{Placeholder: func(int) string { return "?" }}
+	got, err := l.Inserts("t", []proto.Message{
+		&pb.Simple{OInt64: proto.Int64(-2), OBytes: []byte{1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("Inserts() = %d statements, want 1", len(got))
+	}
+	if !strings.HasSuffix(got[0].Query, `"oBytes") VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`) {
+		t.Errorf("Inserts() query = %s", got[0].Query)
+	}
+	if got[0].Args[0] != nil || got[0].Args[3] != "-2" || !bytes.Equal(got[0].Args[18].([]byte), []byte{1}) {
+		t.Errorf("Inserts() args = %q", got[0].Args)
+	}
+}
+
+func TestInsertsMaxParams(t *testing.T) {
+	l := Loader{BatchSize: 5000}
+	pbs := make([]proto.Message, 5000)
+	for i := range pbs {
+		pbs[i] = new(pb.Simple)
+	}
+	got, err := l.Inserts("t", pbs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Simple has 19 columns.
+	if len(got) != 2 || len(got[0].Args) != 65535/19*19 {
+		t.Errorf("Inserts() = %d statements of %d args", len(got), len(got[0].Args))
+	}
+}
@@ -0,0 +1,208 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package sqlpb scans the results of database/sql queries into protocol
+buffers, and converts protocol buffers into PostgreSQL COPY data or INSERT
+statements for bulk loading.
+
+Columns are matched with fields by name, the way csvpb matches a header,
+so that query results and CSV files share one mapping. SQL NULL is null,
+like the null cell of csvpb.
+*/
+package sqlpb
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Scanner is a configurable object for converting rows into protocol
+// buffers.
+type Scanner struct {
+	// Unmarshaler converts rows into messages. Its Header is ignored, the
+	// names of the columns being the header.
+	Unmarshaler csvpb.Unmarshaler
+}
+
+// Scan populates pb from the current row of rows.
+// pb is reset before being populated.
+func (s *Scanner) Scan(rows *sql.Rows, pb proto.Message) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	cu := s.Unmarshaler
+	cu.Header = columns
+	return scan(&cu, rows, bytesFields(pb), pb)
+}
+
+// ScanAll converts every remaining row of rows into a message created by
+// factory. rows is not closed.
+func (s *Scanner) ScanAll(rows *sql.Rows, factory func() proto.Message) ([]proto.Message, error) {
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	cu := s.Unmarshaler
+	cu.Header = columns
+
+	var pbs []proto.Message
+	var binary map[string]int
+	for n := 1; rows.Next(); n++ {
+		pb := factory()
+		if binary == nil {
+			binary = bytesFields(pb)
+		}
+		if err := scan(&cu, rows, binary, pb); err != nil {
+			if cu.SkipInvalidRows {
+				continue
+			}
+			return pbs, fmt.Errorf("sqlpb: row %d: %v", n, err)
+		}
+		pbs = append(pbs, pb)
+	}
+	return pbs, rows.Err()
+}
+
+// ScanAll converts every remaining row of rows into a message created by
+// factory, using default options.
+func ScanAll(rows *sql.Rows, factory func() proto.Message) ([]proto.Message, error) {
+	return new(Scanner).ScanAll(rows, factory)
+}
+
+// scan populates pb from the current row of rows. binary holds the bytes
+// fields, as returned by bytesFields.
+func scan(cu *csvpb.Unmarshaler, rows *sql.Rows, binary map[string]int, pb proto.Message) error {
+	values := make([]interface{}, len(cu.Header))
+	dest := make([]interface{}, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return err
+	}
+	cells := make([]string, len(values))
+	var nulls []int
+	for i, v := range values {
+		field, ok := binary[cu.Header[i]]
+		if ok && v == nil && field >= 0 {
+			// csvpb reads null into bytes fields as base64, so the
+			// field is cleared afterwards instead.
+			nulls = append(nulls, field)
+			cells[i] = ""
+			continue
+		}
+		cell, err := format(v, ok)
+		if err != nil {
+			return fmt.Errorf("column %q: %v", cu.Header[i], err)
+		}
+		cells[i] = cell
+	}
+	if err := cu.UnmarshalRecord(cells, pb); err != nil {
+		return err
+	}
+	for _, field := range nulls {
+		reflect.ValueOf(pb).Elem().Field(field).SetBytes(nil)
+	}
+	return nil
+}
+
+// format converts a value scanned by database/sql into a csvpb cell.
+// Values of bytes fields are base64 encoded, like csvpb expects them.
+func format(v interface{}, binary bool) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "null", nil
+	case []byte:
+		if binary {
+			return base64.StdEncoding.EncodeToString(v), nil
+		}
+		return string(v), nil
+	case string:
+		if binary {
+			return base64.StdEncoding.EncodeToString([]byte(v)), nil
+		}
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return csvpb.FormatFloat(v, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano), nil
+	}
+	return "", fmt.Errorf("unsupported value of type %T", v)
+}
+
+// bytesFields maps the names, original as well as lowerCamelCase, of the
+// bytes fields of pb to the index of the struct field. Members of oneofs
+// map to -1.
+func bytesFields(pb proto.Message) map[string]int {
+	names := make(map[string]int)
+	add := func(prop *proto.Properties, t reflect.Type, field int) {
+		if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uint8 {
+			return
+		}
+		names[prop.OrigName] = field
+		if prop.JSONName != "" {
+			names[prop.JSONName] = field
+		}
+	}
+
+	st := reflect.TypeOf(pb).Elem()
+	sprops := proto.GetProperties(st)
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if strings.HasPrefix(f.Name, "XXX_") {
+			continue
+		}
+		if f.Tag.Get("protobuf_oneof") == "" {
+			add(sprops.Prop[i], f.Type, i)
+			continue
+		}
+		for _, oop := range sprops.OneofTypes {
+			if oop.Field == i {
+				add(oop.Prop, oop.Type.Elem().Field(0).Type, -1)
+			}
+		}
+	}
+	return names
+}
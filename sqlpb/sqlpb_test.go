@@ -0,0 +1,196 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package sqlpb
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// results are the results of the queries of the test driver.
+var results = map[string]struct {
+	columns []string
+	rows    [][]driver.Value
+}{
+	"simple": {
+		columns: []string{"o_int64", "oString", "o_bytes", "o_double", "o_bool"},
+		rows: [][]driver.Value{
+			{int64(-3), []byte("text"), []byte{0, 0xff}, 1.5, true},
+			{nil, "x", nil, nil, false},
+		},
+	},
+	"timestamp": {
+		columns: []string{"ts"},
+		rows:    [][]driver.Value{{time.Date(2019, 5, 1, 12, 0, 0, 5, time.FixedZone("CEST", 2*60*60))}},
+	},
+	"invalid": {
+		columns: []string{"o_int32"},
+		rows:    [][]driver.Value{{"x"}, {int64(2)}},
+	},
+}
+
+type testDriver struct{}
+
+func (testDriver) Open(string) (driver.Conn, error) { return testConn{}, nil }
+
+type testConn struct{}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) { return testStmt(query), nil }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return nil, errors.New("no transactions") }
+
+type testStmt string
+
+func (s testStmt) Close() error  { return nil }
+func (s testStmt) NumInput() int { return 0 }
+func (s testStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("no exec")
+}
+func (s testStmt) Query([]driver.Value) (driver.Rows, error) {
+	r, ok := results[string(s)]
+	if !ok {
+		return nil, errors.New("unknown query")
+	}
+	return &testRows{columns: r.columns, rows: r.rows}, nil
+}
+
+type testRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *testRows) Columns() []string { return r.columns }
+func (r *testRows) Close() error      { return nil }
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("sqlpbtest", testDriver{})
+}
+
+func query(t *testing.T, q string) *sql.Rows {
+	t.Helper()
+	db, err := sql.Open("sqlpbtest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestScanAll(t *testing.T) {
+	rows := query(t, "simple")
+	defer rows.Close()
+	got, err := ScanAll(rows, func() proto.Message { return new(pb.Simple) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*pb.Simple{
+		{OInt64: proto.Int64(-3), OString: proto.String("text"), OBytes: []byte{0, 0xff}, ODouble: proto.Float64(1.5), OBool: proto.Bool(true)},
+		{OString: proto.String("x"), OBool: proto.Bool(false)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ScanAll() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("message %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	rows := query(t, "timestamp")
+	defer rows.Close()
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	got := new(pb.KnownTypes)
+	if err := new(Scanner).Scan(rows, got); err != nil {
+		t.Fatal(err)
+	}
+	if s, ns := got.GetTs().GetSeconds(), got.GetTs().GetNanos(); s != 1556704800 || ns != 5 {
+		t.Errorf("Scan() = %v, want 1556704800s 5ns", got.GetTs())
+	}
+}
+
+func TestScanAllErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		query string
+		s     Scanner
+		want  string
+	}{
+		{"conversion", "invalid", Scanner{}, "sqlpb: row 1:"},
+		{"unknown column", "timestamp", Scanner{}, `unknown field "ts"`},
+	}
+	for _, tt := range tests {
+		rows := query(t, tt.query)
+		_, err := tt.s.ScanAll(rows, func() proto.Message { return new(pb.Simple) })
+		rows.Close()
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: ScanAll() error = %v, want %q", tt.desc, err, tt.want)
+		}
+	}
+}
+
+func TestScanAllOptions(t *testing.T) {
+	rows := query(t, "invalid")
+	defer rows.Close()
+	s := Scanner{Unmarshaler: csvpb.Unmarshaler{SkipInvalidRows: true}}
+	got, err := s.ScanAll(rows, func() proto.Message { return new(pb.Simple) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !proto.Equal(got[0], &pb.Simple{OInt32: proto.Int32(2)}) {
+		t.Errorf("ScanAll() = %v", got)
+	}
+}
@@ -0,0 +1,399 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package xlsxpb
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// Workbook is an Excel workbook opened for reading.
+type Workbook struct {
+	files  map[string]*zip.File
+	sheets []string
+	paths  map[string]string
+	shared []string
+}
+
+// Open opens the workbook read from r, which is size bytes long.
+func Open(r io.ReaderAt, size int64) (*Workbook, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("xlsxpb: %v", err)
+	}
+	wb := &Workbook{
+		files: make(map[string]*zip.File),
+		paths: make(map[string]string),
+	}
+	for _, f := range zr.File {
+		wb.files[f.Name] = f
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+			ID   string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := wb.decode("xl/workbook.xml", &workbook); err != nil {
+		return nil, err
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Type   string `xml:"Type,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := wb.decode("xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, err
+	}
+
+	targets := make(map[string]string)
+	sharedPath := ""
+	for _, rel := range rels.Relationships {
+		target := rel.Target
+		if strings.HasPrefix(target, "/") {
+			target = target[1:]
+		} else {
+			target = path.Join("xl", target)
+		}
+		targets[rel.ID] = target
+		if strings.HasSuffix(rel.Type, "/sharedStrings") {
+			sharedPath = target
+		}
+	}
+	for _, s := range workbook.Sheets {
+		target, ok := targets[s.ID]
+		if !ok {
+			return nil, fmt.Errorf("xlsxpb: sheet %q without worksheet", s.Name)
+		}
+		wb.sheets = append(wb.sheets, s.Name)
+		wb.paths[s.Name] = target
+	}
+
+	if sharedPath != "" {
+		if wb.shared, err = wb.sharedStrings(sharedPath); err != nil {
+			return nil, err
+		}
+	}
+	return wb, nil
+}
+
+// Sheets returns the names of the worksheets, in workbook order.
+func (wb *Workbook) Sheets() []string {
+	return wb.sheets
+}
+
+// open opens the part name of the workbook.
+func (wb *Workbook) open(name string) (io.ReadCloser, error) {
+	f, ok := wb.files[name]
+	if !ok {
+		return nil, fmt.Errorf("xlsxpb: missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("xlsxpb: %s: %v", name, err)
+	}
+	return rc, nil
+}
+
+// decode decodes the XML of part name into v.
+func (wb *Workbook) decode(name string, v interface{}) error {
+	rc, err := wb.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("xlsxpb: %s: %v", name, err)
+	}
+	return nil
+}
+
+// sharedStrings reads the shared string table of part name. The text of
+// rich strings is concatenated, ignoring phonetic runs.
+func (wb *Workbook) sharedStrings(name string) ([]string, error) {
+	var sst struct {
+		Items []struct {
+			T    *string `xml:"t"`
+			Runs []struct {
+				T string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := wb.decode(name, &sst); err != nil {
+		return nil, err
+	}
+	shared := make([]string, len(sst.Items))
+	for i, si := range sst.Items {
+		if si.T != nil {
+			shared[i] = *si.T
+			continue
+		}
+		var b strings.Builder
+		for _, r := range si.Runs {
+			b.WriteString(r.T)
+		}
+		shared[i] = b.String()
+	}
+	return shared, nil
+}
+
+// Rows returns the cells of every row of sheet, with empty cells being
+// empty strings. Rows without any cell are omitted.
+func (wb *Workbook) Rows(sheet string) ([][]string, error) {
+	var rows [][]string
+	err := wb.eachRow(sheet, func(_ int, cells []*string) error {
+		row := make([]string, len(cells))
+		for i, c := range cells {
+			if c != nil {
+				row[i] = *c
+			}
+		}
+		rows = append(rows, row)
+		return nil
+	})
+	return rows, err
+}
+
+// eachRow calls fn for every row of sheet having cells. Cells missing from
+// the row are nil. n is the number of the row, starting with 1.
+func (wb *Workbook) eachRow(sheet string, fn func(n int, cells []*string) error) error {
+	name, ok := wb.paths[sheet]
+	if !ok {
+		return fmt.Errorf("xlsxpb: no sheet %q", sheet)
+	}
+	rc, err := wb.open(name)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	d := xml.NewDecoder(rc)
+	n := 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("xlsxpb: %s: %v", name, err)
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != "row" {
+			continue
+		}
+		var row struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				R      string  `xml:"r,attr"`
+				T      string  `xml:"t,attr"`
+				V      *string `xml:"v"`
+				Inline *struct {
+					T    *string `xml:"t"`
+					Runs []struct {
+						T string `xml:"t"`
+					} `xml:"r"`
+				} `xml:"is"`
+			} `xml:"c"`
+		}
+		if err := d.DecodeElement(&row, &se); err != nil {
+			return fmt.Errorf("xlsxpb: %s: %v", name, err)
+		}
+		if row.R > 0 {
+			n = row.R
+		} else {
+			n++
+		}
+
+		var cells []*string
+		for i, c := range row.Cells {
+			col := i
+			if c.R != "" {
+				if col, err = columnIndex(c.R); err != nil {
+					return fmt.Errorf("xlsxpb: %s: row %d: %v", name, n, err)
+				}
+			}
+			var value string
+			switch {
+			case c.T == "inlineStr" && c.Inline != nil:
+				if c.Inline.T != nil {
+					value = *c.Inline.T
+				}
+				for _, r := range c.Inline.Runs {
+					value += r.T
+				}
+			case c.V == nil:
+				continue
+			case c.T == "s":
+				idx, err := strconv.Atoi(*c.V)
+				if err != nil || idx < 0 || idx >= len(wb.shared) {
+					return fmt.Errorf("xlsxpb: %s: cell %s: invalid shared string %q", name, c.R, *c.V)
+				}
+				value = wb.shared[idx]
+			case c.T == "b":
+				value = strconv.FormatBool(*c.V == "1")
+			case c.T == "" || c.T == "n":
+				value = normalizeNumber(*c.V)
+			default:
+				value = *c.V
+			}
+			for len(cells) <= col {
+				cells = append(cells, nil)
+			}
+			v := value
+			cells[col] = &v
+		}
+		if len(cells) == 0 {
+			continue
+		}
+		if err := fn(n, cells); err != nil {
+			return err
+		}
+	}
+}
+
+// normalizeNumber formats the number v of a cell the way csvpb parses it.
+// Integral numbers lose any fraction and exponent.
+func normalizeNumber(v string) string {
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return v
+	}
+	if f == math.Trunc(f) && math.Abs(f) <= maxExactInt {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	if strings.ContainsAny(v, "eE") {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	return v
+}
+
+// columnIndex returns the index of the column of cell reference ref, like
+// 0 for "A1" and 26 for "AA7".
+func columnIndex(ref string) (int, error) {
+	i := 0
+	n := 0
+	for ; n < len(ref) && ref[n] >= 'A' && ref[n] <= 'Z'; n++ {
+		// Checked within the loop, as long references overflow
+		if i = i*26 + int(ref[n]-'A'+1); i > 1<<14 {
+			return 0, fmt.Errorf("invalid cell reference %q", ref)
+		}
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("invalid cell reference %q", ref)
+	}
+	return i - 1, nil
+}
+
+// Unmarshaler is a configurable object for converting a worksheet into
+// protocol buffers.
+type Unmarshaler struct {
+	// Unmarshaler converts rows into messages. Should its Header be nil,
+	// the first row of the worksheet is used as header.
+	Unmarshaler csvpb.Unmarshaler
+
+	// Sheet is the name of the worksheet, the first one if empty.
+	Sheet string
+}
+
+// UnmarshalAll converts every row of the worksheet into a message created
+// by factory.
+func (u *Unmarshaler) UnmarshalAll(wb *Workbook, factory func() proto.Message) ([]proto.Message, error) {
+	sheet := u.Sheet
+	if sheet == "" {
+		if len(wb.sheets) == 0 {
+			return nil, fmt.Errorf("xlsxpb: workbook without sheets")
+		}
+		sheet = wb.sheets[0]
+	}
+
+	cu := u.Unmarshaler
+	var pbs []proto.Message
+	err := wb.eachRow(sheet, func(n int, cells []*string) error {
+		if cu.Header == nil {
+			cu.Header = make([]string, 0, len(cells))
+			for _, c := range cells {
+				name := ""
+				if c != nil {
+					name = *c
+				}
+				cu.Header = append(cu.Header, name)
+			}
+			for len(cu.Header) > 0 && cu.Header[len(cu.Header)-1] == "" {
+				cu.Header = cu.Header[:len(cu.Header)-1]
+			}
+			return nil
+		}
+
+		for len(cells) > len(cu.Header) && cells[len(cells)-1] == nil {
+			cells = cells[:len(cells)-1]
+		}
+		record := make([]string, len(cu.Header))
+		for i := range record {
+			record[i] = nullToken
+			if i < len(cells) && cells[i] != nil {
+				record[i] = *cells[i]
+			}
+		}
+		if len(cells) > len(record) {
+			record = nil
+		}
+
+		pb := factory()
+		var err error
+		if record == nil {
+			err = fmt.Errorf("%d cells, header has %d", len(cells), len(cu.Header))
+		} else {
+			err = cu.UnmarshalRecord(record, pb)
+		}
+		if err != nil {
+			if cu.SkipInvalidRows {
+				return nil
+			}
+			return fmt.Errorf("xlsxpb: sheet %q row %d: %v", sheet, n, err)
+		}
+		pbs = append(pbs, pb)
+		return nil
+	})
+	return pbs, err
+}
@@ -0,0 +1,244 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package xlsxpb
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// workbook returns a workbook of parts, like Excel writes them.
+func workbook(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range parts {
+		f, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// part returns the content of part name of a workbook.
+func part(t *testing.T, data []byte, name string) string {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range zr.File {
+		if f.Name != name {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		b, err := ioutil.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+	t.Fatalf("missing part %s", name)
+	return ""
+}
+
+func excelParts(sheet2 string) map[string]string {
+	return map[string]string{
+		"xl/workbook.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Notes" sheetId="1" r:id="rId1"/><sheet name="Data" sheetId="2" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/sharedStrings" Target="sharedStrings.xml"/>
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="/xl/worksheets/sheet2.xml"/>
+</Relationships>`,
+		"xl/sharedStrings.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" count="5" uniqueCount="5">
+<si><t>oInt32</t></si><si><t>o_string</t></si><si><t>oDouble</t></si>
+<si><r><t>rich</t></r><r><rPr><b/></rPr><t xml:space="preserve"> text</t></r></si>
+<si><t>oBool</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>
+<row r="1"><c r="A1" t="s"><v>3</v></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + sheet2 + `</sheetData></worksheet>`,
+	}
+}
+
+const excelData = `<row r="1" spans="1:4"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1" t="s"><v>2</v></c><c r="D1" t="s"><v>4</v></c></row>
+<row r="2"><c r="A2"><v>1.2E+1</v></c><c r="B2" t="s"><v>3</v></c><c r="C2" s="1"><v>0.10000000000000001</v></c><c r="D2" t="b"><v>1</v></c></row>
+<row r="3"><c r="B3" t="inlineStr"><is><t>inline</t></is></c><c r="C3" t="str"><f>1/2</f><v>0.5</v></c></row>
+<row r="5"><c r="A5"><v>-3</v></c><c r="F5" s="2"/></row>`
+
+func TestReadExcel(t *testing.T) {
+	data := workbook(t, excelParts(excelData))
+	wb, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := wb.Sheets(), []string{"Notes", "Data"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Sheets() = %q, want %q", got, want)
+	}
+
+	rows, err := wb.Rows("Data")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRows := [][]string{
+		{"oInt32", "o_string", "oDouble", "oBool"},
+		{"12", "rich text", "0.10000000000000001", "true"},
+		{"", "inline", "0.5"},
+		{"-3"},
+	}
+	if !reflect.DeepEqual(rows, wantRows) {
+		t.Errorf("Rows() = %q, want %q", rows, wantRows)
+	}
+
+	factory := func() proto.Message { return new(pb.Simple) }
+	if got, err := new(Unmarshaler).UnmarshalAll(wb, factory); err != nil || len(got) != 0 {
+		t.Errorf("UnmarshalAll() of first sheet = %v, %v, want no messages", got, err)
+	}
+
+	u := Unmarshaler{Sheet: "Data"}
+	got, err := u.UnmarshalAll(wb, factory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []proto.Message{
+		&pb.Simple{OInt32: proto.Int32(12), OString: proto.String("rich text"), ODouble: proto.Float64(0.1), OBool: proto.Bool(true)},
+		&pb.Simple{OString: proto.String("inline"), ODouble: proto.Float64(0.5)},
+		&pb.Simple{OInt32: proto.Int32(-3)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("UnmarshalAll() = %v, want %v", got, want)
+	}
+	for i := range want {
+		if !proto.Equal(got[i], want[i]) {
+			t.Errorf("message %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUnmarshalAllErrors(t *testing.T) {
+	tests := []struct {
+		desc  string
+		u     Unmarshaler
+		sheet string
+		want  string
+	}{{
+		desc:  "unknown sheet",
+		u:     Unmarshaler{Sheet: "Nope"},
+		sheet: excelData,
+		want:  `no sheet "Nope"`,
+	}, {
+		desc:  "conversion",
+		u:     Unmarshaler{Sheet: "Data"},
+		sheet: `<row r="1"><c r="A1" t="s"><v>0</v></c></row><row r="2"><c r="A2" t="inlineStr"><is><t>x</t></is></c></row>`,
+		want:  `sheet "Data" row 2:`,
+	}, {
+		desc:  "too many cells",
+		u:     Unmarshaler{Sheet: "Data"},
+		sheet: `<row r="1"><c r="A1" t="s"><v>0</v></c></row><row r="4"><c r="A4"><v>1</v></c><c r="C4"><v>1</v></c></row>`,
+		want:  `row 4: 3 cells, header has 1`,
+	}, {
+		desc:  "shared string",
+		u:     Unmarshaler{Sheet: "Data"},
+		sheet: `<row r="1"><c r="A1" t="s"><v>9</v></c></row>`,
+		want:  `invalid shared string "9"`,
+	}}
+	for _, tt := range tests {
+		data := workbook(t, excelParts(tt.sheet))
+		wb, err := Open(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = tt.u.UnmarshalAll(wb, func() proto.Message { return new(pb.Simple) })
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: UnmarshalAll() error = %v, want %q", tt.desc, err, tt.want)
+		}
+	}
+}
+
+func TestUnmarshalAllOptions(t *testing.T) {
+	data := workbook(t, excelParts(`<row r="1"><c r="A1"><v>4</v></c><c r="B1" t="inlineStr"><is><t>x</t></is></c></row>
+<row r="2"><c r="A2"><v>5</v></c><c r="B2" t="inlineStr"><is><t>y</t></is></c></row>`))
+	wb, err := Open(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := Unmarshaler{
+		Unmarshaler: csvpb.Unmarshaler{Header: []string{"oInt32", "oString"}, SkipInvalidRows: true},
+		Sheet:       "Data",
+	}
+	got, err := u.UnmarshalAll(wb, func() proto.Message { return new(pb.Simple) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*pb.Simple{
+		{OInt32: proto.Int32(4), OString: proto.String("x")},
+		{OInt32: proto.Int32(5), OString: proto.String("y")},
+	}
+	if len(got) != len(want) || !proto.Equal(got[0], want[0]) || !proto.Equal(got[1], want[1]) {
+		t.Errorf("UnmarshalAll() = %v, want %v", got, want)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	if _, err := Open(strings.NewReader("not a zip"), 9); err == nil {
+		t.Error("Open() of non-zip succeeded, want error")
+	}
+	data := workbook(t, map[string]string{"xl/workbook.xml": "<workbook/>"})
+	if _, err := Open(bytes.NewReader(data), int64(len(data))); err == nil || !strings.Contains(err.Error(), "workbook.xml.rels") {
+		t.Errorf("Open() without relationships error = %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package xlsxpb
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// maxExactInt is the largest integer a cell stores exactly. Larger
+// integers are written as strings.
+const maxExactInt = 1 << 53
+
+// Writer writes protocol buffers as rows of a workbook with a single
+// worksheet.
+type Writer struct {
+	// Marshaler converts messages into cells.
+	Marshaler csvpb.Marshaler
+
+	// Sheet is the name of the worksheet, "Sheet1" if empty.
+	Sheet string
+
+	zw     *zip.Writer
+	sheet  io.Writer
+	header []string
+	kinds  []cellKind
+	rows   int
+	err    error
+}
+
+// NewWriter returns a Writer writing a workbook to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w)}
+}
+
+// Write writes pb as the next row. Before the first message, the header
+// is written.
+func (w *Writer) Write(pb proto.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.sheet == nil {
+		if w.err = w.begin(); w.err != nil {
+			return w.err
+		}
+	}
+	record, err := w.Marshaler.MarshalRecord(pb)
+	if err != nil {
+		return err
+	}
+	if w.header == nil {
+		if w.header, w.err = w.Marshaler.Header(pb); w.err != nil {
+			return w.err
+		}
+		if w.kinds, w.err = columnKinds(&w.Marshaler, pb, w.header); w.err != nil {
+			return w.err
+		}
+		if w.err = w.writeRow(w.header, nil); w.err != nil {
+			return w.err
+		}
+	}
+	w.err = w.writeRow(record, w.kinds)
+	return w.err
+}
+
+// Close finishes the workbook. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.sheet == nil {
+		if w.err = w.begin(); w.err != nil {
+			return w.err
+		}
+	}
+	w.err = errors.New("xlsxpb: Writer closed")
+	if _, err := io.WriteString(w.sheet, "</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// begin writes every part but the worksheet and starts the worksheet.
+func (w *Writer) begin() error {
+	name := w.Sheet
+	if name == "" {
+		name = "Sheet1"
+	}
+	if len([]rune(name)) > 31 || strings.ContainsAny(name, `[]:*?/\`) {
+		return fmt.Errorf("xlsxpb: invalid sheet name %q", name)
+	}
+	var escaped bytes.Buffer
+	xml.EscapeText(&escaped, []byte(name))
+
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="` + nsPackageRels + `">` +
+			`<Relationship Id="rId1" Type="` + nsRelationships + `/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{"xl/workbook.xml", xml.Header + `<workbook xmlns="` + nsMain + `" xmlns:r="` + nsRelationships + `">` +
+			`<sheets><sheet name="` + escaped.String() + `" sheetId="1" r:id="rId1"/></sheets>` +
+			`</workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="` + nsPackageRels + `">` +
+			`<Relationship Id="rId1" Type="` + nsRelationships + `/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`</Relationships>`},
+	}
+	for _, p := range parts {
+		f, err := w.zw.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, p.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := w.zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return err
+	}
+	w.sheet = f
+	_, err = io.WriteString(f, xml.Header+`<worksheet xmlns="`+nsMain+`"><sheetData>`)
+	return err
+}
+
+// writeRow writes cells as the next row. Should kinds be nil, every cell
+// is a string.
+func (w *Writer) writeRow(cells []string, kinds []cellKind) error {
+	w.rows++
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<row r="%d">`, w.rows)
+	for i, cell := range cells {
+		if cell == nullToken {
+			continue
+		}
+		ref := columnName(i) + strconv.Itoa(w.rows)
+		kind := kindString
+		if kinds != nil {
+			kind = kinds[i]
+		}
+		switch {
+		case kind == kindBool && (cell == "true" || cell == "false"):
+			v := "0"
+			if cell == "true" {
+				v = "1"
+			}
+			fmt.Fprintf(&buf, `<c r="%s" t="b"><v>%s</v></c>`, ref, v)
+		case kind == kindNumber && isExactNumber(cell):
+			fmt.Fprintf(&buf, `<c r="%s"><v>%s</v></c>`, ref, cell)
+		default:
+			fmt.Fprintf(&buf, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">`, ref)
+			xml.EscapeText(&buf, []byte(cell))
+			buf.WriteString(`</t></is></c>`)
+		}
+	}
+	buf.WriteString(`</row>`)
+	_, err := w.sheet.Write(buf.Bytes())
+	return err
+}
+
+// isExactNumber reports whether cell is a finite number a worksheet stores
+// without losing precision.
+func isExactNumber(cell string) bool {
+	if strings.ContainsAny(cell, ".eE") {
+		f, err := strconv.ParseFloat(cell, 64)
+		return err == nil && !math.IsInf(f, 0) && !math.IsNaN(f)
+	}
+	i, err := strconv.ParseInt(cell, 10, 64)
+	return err == nil && -maxExactInt <= i && i <= maxExactInt
+}
+
+// columnName returns the letters naming the column with index i, like
+// "A" for 0 and "AA" for 26.
+func columnName(i int) string {
+	var name []byte
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = append([]byte{byte('A' + (i-1)%26)}, name...)
+	}
+	return string(name)
+}
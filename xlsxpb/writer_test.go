@@ -0,0 +1,214 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+package xlsxpb
+
+import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+
+	pb "github.com/golang/protobuf/jsonpb/jsonpb_test_proto"
+)
+
+// write writes pbs to a workbook.
+func write(t *testing.T, w *Writer, buf *bytes.Buffer, pbs ...proto.Message) *Workbook {
+	t.Helper()
+	for _, pb := range pbs {
+		if err := w.Write(pb); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	wb, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return wb
+}
+
+func TestWriterRoundTrip(t *testing.T) {
+	in := []proto.Message{
+		&pb.Simple{
+			OBool:   proto.Bool(true),
+			OInt32:  proto.Int32(-7),
+			OInt64:  proto.Int64(math.MaxInt64),
+			OUint64: proto.Uint64(1 << 40),
+			OFloat:  proto.Float32(0.1),
+			ODouble: proto.Float64(math.Inf(-1)),
+			OString: proto.String("a<b & \"c\"\n"),
+			OBytes:  []byte{0, 0xff},
+		},
+		&pb.Simple{OString: proto.String(""), OBytes: []byte{}},
+		&pb.Simple{OBytes: []byte{}},
+	}
+	var buf bytes.Buffer
+	wb := write(t, NewWriter(&buf), &buf, in...)
+	if got := wb.Sheets(); len(got) != 1 || got[0] != "Sheet1" {
+		t.Errorf("Sheets() = %q, want [Sheet1]", got)
+	}
+
+	u := new(Unmarshaler)
+	out, err := u.UnmarshalAll(wb, func() proto.Message { return new(pb.Simple) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(in) {
+		t.Fatalf("UnmarshalAll() = %d messages, want %d", len(out), len(in))
+	}
+	for i := range in {
+		if !proto.Equal(out[i], in[i]) {
+			t.Errorf("message %d = %v, want %v", i, out[i], in[i])
+		}
+	}
+}
+
+func TestWriterCells(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Marshaler.OrigName = true
+	w.Sheet = "Report & Co"
+	write(t, w, &buf, &pb.Simple{
+		OBool:   proto.Bool(false),
+		OInt32:  proto.Int32(3),
+		OInt64:  proto.Int64(1<<53 + 1),
+		OString: proto.String("12"),
+	})
+
+	sheet := part(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr"><is><t xml:space="preserve">o_bool</t></is></c>`,
+		`<c r="A2" t="b"><v>0</v></c>`,
+		`<c r="B2"><v>3</v></c>`,
+		`<c r="D2" t="inlineStr"><is><t xml:space="preserve">9007199254740993</t></is></c>`,
+		`<c r="R2" t="inlineStr"><is><t xml:space="preserve">12</t></is></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("worksheet lacks %s:\n%s", want, sheet)
+		}
+	}
+	if strings.Contains(sheet, `r="C2"`) {
+		t.Errorf("worksheet has cell for null field:\n%s", sheet)
+	}
+	if workbook := part(t, buf.Bytes(), "xl/workbook.xml"); !strings.Contains(workbook, `name="Report &amp; Co"`) {
+		t.Errorf("workbook lacks sheet name:\n%s", workbook)
+	}
+}
+
+func TestWriterRenamedColumns(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.Marshaler.SetFieldOptions("jsonpb.Simple.o_int32", csvpb.FieldOptions{Column: "count"})
+	w.Marshaler.MapColumns("jsonpb.Simple.o_bool", csvpb.ColumnMapping{
+		Columns: []string{"flag", "flag_text"},
+		Split: func(v interface{}) ([]string, error) {
+			b := strconv.FormatBool(*v.(*bool))
+			return []string{b, b}, nil
+		},
+	})
+	write(t, w, &buf, &pb.Simple{
+		OBool:  proto.Bool(true),
+		OInt32: proto.Int32(3),
+	})
+
+	sheet := part(t, buf.Bytes(), "xl/worksheets/sheet1.xml")
+	for _, want := range []string{
+		`<c r="A1" t="inlineStr"><is><t xml:space="preserve">flag</t></is></c>`,
+		`<c r="C1" t="inlineStr"><is><t xml:space="preserve">count</t></is></c>`,
+		`<c r="A2" t="b"><v>1</v></c>`,
+		`<c r="B2" t="b"><v>1</v></c>`,
+		`<c r="C2"><v>3</v></c>`,
+	} {
+		if !strings.Contains(sheet, want) {
+			t.Errorf("worksheet lacks %s:\n%s", want, sheet)
+		}
+	}
+}
+
+func TestWriterEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	wb := write(t, NewWriter(&buf), &buf)
+	rows, err := wb.Rows("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 0 {
+		t.Errorf("Rows() = %q, want none", rows)
+	}
+}
+
+func TestWriterInvalidSheet(t *testing.T) {
+	for _, name := range []string{"a/b", "[x]", strings.Repeat("x", 32)} {
+		w := NewWriter(ioutil.Discard)
+		w.Sheet = name
+		if err := w.Write(new(pb.Simple)); err == nil {
+			t.Errorf("Write() with sheet %q succeeded, want error", name)
+		}
+		if err := w.Close(); err == nil {
+			t.Errorf("Close() with sheet %q succeeded, want error", name)
+		}
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	tests := []struct {
+		i    int
+		want string
+	}{
+		{0, "A"},
+		{25, "Z"},
+		{26, "AA"},
+		{701, "ZZ"},
+		{702, "AAA"},
+		{16383, "XFD"},
+	}
+	for _, tt := range tests {
+		if got := columnName(tt.i); got != tt.want {
+			t.Errorf("columnName(%d) = %q, want %q", tt.i, got, tt.want)
+		}
+		if got, err := columnIndex(tt.want + "1"); err != nil || got != tt.i {
+			t.Errorf("columnIndex(%q) = %d, %v, want %d", tt.want+"1", got, err, tt.i)
+		}
+	}
+	for _, ref := range []string{"1", "XFE1", "AAAAAAAAAAAAAA1", "ZZZZZZZZZZZZZZZZZZZZ1"} {
+		if got, err := columnIndex(ref); err == nil {
+			t.Errorf("columnIndex(%q) = %d, want error", ref, got)
+		}
+	}
+}
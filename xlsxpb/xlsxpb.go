@@ -0,0 +1,135 @@
+// Go support for Protocol Buffers - Google's data interchange format
+//
+// Copyright 2019 Andreas Bergmeier.  All rights reserved.
+// https://github.com/abergmeier/golang-protobuf
+//
+// Redistribution and use in source and binary forms, with or without
+// modification, are permitted provided that the following conditions are
+// met:
+//
+//     * Redistributions of source code must retain the above copyright
+// notice, this list of conditions and the following disclaimer.
+//     * Redistributions in binary form must reproduce the above
+// copyright notice, this list of conditions and the following disclaimer
+// in the documentation and/or other materials provided with the
+// distribution.
+//     * Neither the name of Google Inc. nor the names of its
+// contributors may be used to endorse or promote products derived from
+// this software without specific prior written permission.
+//
+// THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+// "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+// LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS FOR
+// A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+// OWNER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+// SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT
+// LIMITED TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE,
+// DATA, OR PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY
+// THEORY OF LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT
+// (INCLUDING NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE
+// OF THIS SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+
+/*
+Package xlsxpb reads protocol buffers from Excel workbooks and writes
+protocol buffers to Excel workbooks.
+
+A worksheet holds messages like a CSV does: the first row is the header,
+naming the fields, with every further row being a message. Cells are
+converted the way csvpb converts them. Empty cells are null, numeric and
+boolean fields are written as numbers and booleans respectively.
+*/
+package xlsxpb
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/abergmeier/golang-protobuf/csvpb"
+	"github.com/golang/protobuf/proto"
+)
+
+// nullToken is the csvpb cell content of a field without a value.
+const nullToken = "null"
+
+// Namespaces of SpreadsheetML.
+const (
+	nsMain          = "http://schemas.openxmlformats.org/spreadsheetml/2006/main"
+	nsRelationships = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+	nsPackageRels   = "http://schemas.openxmlformats.org/package/2006/relationships"
+)
+
+// cellKind is how a cell is stored in a worksheet.
+type cellKind int
+
+const (
+	kindString cellKind = iota
+	kindNumber
+	kindBool
+)
+
+// columnKinds returns the kind of every column of header for messages of
+// the type of pb. Columns are resolved by the Report of m, so they are
+// named like m names them, with mapped columns being of the kind of their
+// field.
+func columnKinds(m *csvpb.Marshaler, pb proto.Message, header []string) ([]cellKind, error) {
+	columns, err := m.Report(pb)
+	if err != nil {
+		return nil, err
+	}
+	fields := fieldTypes(pb)
+	byName := make(map[string]cellKind)
+	for _, c := range columns {
+		if f, ok := fields[c.Number]; ok {
+			byName[c.Column] = kindOf(m, f.prop, f.typ)
+		}
+	}
+
+	kinds := make([]cellKind, len(header))
+	for i, name := range header {
+		kinds[i] = byName[name]
+	}
+	return kinds, nil
+}
+
+// fieldType is the Go type of a field along with its properties.
+type fieldType struct {
+	prop *proto.Properties
+	typ  reflect.Type
+}
+
+// fieldTypes returns the fields of messages of the type of pb, members of
+// oneofs included, by number.
+func fieldTypes(pb proto.Message) map[int32]fieldType {
+	st := reflect.TypeOf(pb).Elem()
+	sprops := proto.GetProperties(st)
+	fields := make(map[int32]fieldType)
+	for i := 0; i < st.NumField(); i++ {
+		f := st.Field(i)
+		if strings.HasPrefix(f.Name, "XXX_") || f.Tag.Get("protobuf_oneof") != "" {
+			continue
+		}
+		fields[int32(sprops.Prop[i].Tag)] = fieldType{sprops.Prop[i], f.Type}
+	}
+	for _, oop := range sprops.OneofTypes {
+		fields[int32(oop.Prop.Tag)] = fieldType{oop.Prop, oop.Type.Elem().Field(0).Type}
+	}
+	return fields
+}
+
+func kindOf(m *csvpb.Marshaler, prop *proto.Properties, t reflect.Type) cellKind {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return kindBool
+	case reflect.Int32, reflect.Int64:
+		if prop.Enum != "" && !m.EnumsAsInts {
+			return kindString
+		}
+		return kindNumber
+	case reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return kindNumber
+	}
+	return kindString
+}